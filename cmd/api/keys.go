@@ -0,0 +1,30 @@
+package main
+
+import (
+	"task_scheduler/internal/auth"
+	"task_scheduler/internal/config"
+)
+
+// loadKeySet builds the JWT key set from config. The HS256 secret, if set,
+// stays as a verification key so tokens issued before the switch to
+// asymmetric keys remain valid until they expire.
+func loadKeySet(cfg config.Config) (*auth.KeySet, error) {
+	if len(cfg.JWT.Keys) == 0 {
+		return auth.NewKeySet("", auth.HMACKey(cfg.JWT.Secret))
+	}
+
+	keys := make([]*auth.Key, 0, len(cfg.JWT.Keys)+1)
+	for _, k := range cfg.JWT.Keys {
+		key, err := auth.LoadKey(k.ID, k.Alg, k.PrivateKeyFile, k.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if cfg.JWT.Secret != "" {
+		legacy := auth.HMACKey(cfg.JWT.Secret)
+		legacy.SignKey = nil
+		keys = append(keys, legacy)
+	}
+	return auth.NewKeySet(cfg.JWT.ActiveKID, keys...)
+}
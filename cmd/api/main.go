@@ -7,17 +7,41 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"task_scheduler/internal/apikey"
+	"task_scheduler/internal/attachment"
 	"task_scheduler/internal/auth"
+	"task_scheduler/internal/automation"
 	"task_scheduler/internal/config"
+	"task_scheduler/internal/export"
 	"task_scheduler/internal/httpserver"
+	"task_scheduler/internal/lockout"
+	"task_scheduler/internal/mail"
+	"task_scheduler/internal/mfa"
+	"task_scheduler/internal/notify"
+	"task_scheduler/internal/overdue"
+	"task_scheduler/internal/storage"
 	"task_scheduler/internal/task"
+	"task_scheduler/internal/template"
+	"task_scheduler/internal/timetrack"
 	"task_scheduler/internal/user"
+	"task_scheduler/internal/workspace"
 	"time"
 
 	_ "modernc.org/sqlite"
 
+	apikeysqlite "task_scheduler/internal/apikey/sqlite"
+	attachmentsqlite "task_scheduler/internal/attachment/sqlite"
+	authsqlite "task_scheduler/internal/auth/sqlite"
+	automationsqlite "task_scheduler/internal/automation/sqlite"
+	exportsqlite "task_scheduler/internal/export/sqlite"
+	lockoutsqlite "task_scheduler/internal/lockout/sqlite"
+	mfasqlite "task_scheduler/internal/mfa/sqlite"
+	overduesqlite "task_scheduler/internal/overdue/sqlite"
 	tasksqlite "task_scheduler/internal/task/sqlite"
+	templatesqlite "task_scheduler/internal/template/sqlite"
+	timetracksqlite "task_scheduler/internal/timetrack/sqlite"
 	usersqlite "task_scheduler/internal/user/sqlite"
+	workspacesqlite "task_scheduler/internal/workspace/sqlite"
 )
 
 func main() {
@@ -47,22 +71,161 @@ func main() {
 		_ = db.Close()
 		log.Fatal("[MAIN] migrate users:", err)
 	}
+	if err := authsqlite.Migrate(db); err != nil {
+		_ = db.Close()
+		log.Fatal("[MAIN] migrate auth:", err)
+	}
+	if err := apikeysqlite.Migrate(db); err != nil {
+		_ = db.Close()
+		log.Fatal("[MAIN] migrate api keys:", err)
+	}
+	if err := mfasqlite.Migrate(db); err != nil {
+		_ = db.Close()
+		log.Fatal("[MAIN] migrate mfa:", err)
+	}
+	if err := lockoutsqlite.Migrate(db); err != nil {
+		_ = db.Close()
+		log.Fatal("[MAIN] migrate lockouts:", err)
+	}
+	if err := exportsqlite.Migrate(db); err != nil {
+		_ = db.Close()
+		log.Fatal("[MAIN] migrate exports:", err)
+	}
+	if err := workspacesqlite.Migrate(db); err != nil {
+		_ = db.Close()
+		log.Fatal("[MAIN] migrate workspaces:", err)
+	}
+	if err := attachmentsqlite.Migrate(db); err != nil {
+		_ = db.Close()
+		log.Fatal("[MAIN] migrate attachments:", err)
+	}
+	if err := timetracksqlite.Migrate(db); err != nil {
+		_ = db.Close()
+		log.Fatal("[MAIN] migrate time entries:", err)
+	}
+	if err := automationsqlite.Migrate(db); err != nil {
+		_ = db.Close()
+		log.Fatal("[MAIN] migrate automation:", err)
+	}
+	if err := templatesqlite.Migrate(db); err != nil {
+		_ = db.Close()
+		log.Fatal("[MAIN] migrate templates:", err)
+	}
+	if err := overduesqlite.Migrate(db); err != nil {
+		_ = db.Close()
+		log.Fatal("[MAIN] migrate escalations:", err)
+	}
 
 	//jwt токен
-	jwtManager := auth.NewJWTManager(
-		cfg.JWT.Secret,
-		cfg.JWT.TTL,
-	)
+	keySet, err := loadKeySet(cfg)
+	if err != nil {
+		_ = db.Close()
+		log.Fatal("[MAIN] load jwt keys:", err)
+	}
+	jwtManager := auth.NewJWTManagerWithKeys(keySet, cfg.JWT.TTL)
 	//repos
 	taskRepo := tasksqlite.New(db)
 	userRepo := usersqlite.New(db)
+	authRepo := authsqlite.New(db)
+	apiKeyRepo := apikeysqlite.New(db)
+	mfaRepo := mfasqlite.New(db)
+	exportRepo := exportsqlite.New(db)
+	workspaceRepo := workspacesqlite.New(db)
+
+	revocations := auth.NewRevocations(authRepo)
+	if err := revocations.Load(context.Background()); err != nil {
+		_ = db.Close()
+		log.Fatal("[MAIN] load revocations:", err)
+	}
+
+	//mailer
+	var mailer mail.Mailer = mail.NewStdoutMailer(cfg.Mail.From)
+	if cfg.Mail.Driver == "file" {
+		fileMailer, mailFile, err := mail.NewFileMailer(cfg.Mail.FilePath, cfg.Mail.From)
+		if err != nil {
+			_ = db.Close()
+			log.Fatal("[MAIN] open mail file:", err)
+		}
+		defer mailFile.Close()
+		mailer = fileMailer
+	}
 
 	//services
-	taskSvc := task.NewService(taskRepo)
-	userSvc := user.NewService(userRepo)
+	userSvc := user.NewService(userRepo, userRepo, userRepo, mailer, user.Options{
+		ResetTTL:        cfg.Auth.PasswordResetTTL,
+		VerificationTTL: cfg.Auth.VerificationTTL,
+		ResendInterval:  cfg.Auth.ResendInterval,
+	})
+	workspaceSvc := workspace.NewService(workspaceRepo, userSvc, mailer, cfg.Workspace.InviteTTL)
+	notifier := notify.NewMailNotifier(userSvc, mailer)
+	automationRepo := automationsqlite.New(db)
+	// движок правил и слушает задачи, и меняет их через тот же сервис
+	automationEngine := automation.NewEngine(automationRepo, nil)
+	taskSvc := task.NewService(taskRepo, taskRepo, taskRepo, userSvc, workspaceSvc, notifier, automationEngine, task.Options{
+		RequireVerifiedEmail: cfg.Auth.RequireVerifiedEmail,
+	})
+	automationEngine.SetTasks(taskSvc)
+	automationSvc := automation.NewService(automationRepo, taskSvc, workspaceSvc)
+	templateRepo := templatesqlite.New(db)
+	templateSvc := template.NewService(templateRepo, taskSvc, workspaceSvc)
+	tokenSvc := auth.NewTokenService(jwtManager, authRepo, revocations, cfg.JWT.RefreshTTL)
+	apiKeySvc := apikey.NewService(apiKeyRepo)
+	mfaSvc := mfa.NewService(mfaRepo)
+	// выгрузки, прерванные рестартом, уже не доедут
+	if err := exportRepo.FailUnfinished(context.Background(), "interrupted by restart", time.Now().UTC()); err != nil {
+		log.Println("[MAIN] fail unfinished exports:", err)
+	}
+	timeRepo := timetracksqlite.New(db)
+	exportSvc := export.NewService(exportRepo, export.Sources{
+		Users:       userRepo,
+		Tasks:       taskRepo,
+		Comments:    taskRepo,
+		TimeEntries: timeRepo,
+		Templates:   templateRepo,
+		Automations: automationRepo,
+	}, export.Options{
+		Dir: cfg.Export.Dir,
+		TTL: cfg.Export.TTL,
+	})
+	attachmentSvc := attachment.NewService(attachmentsqlite.New(db), newBlobStore(cfg.Attachments), taskSvc, attachment.Options{
+		MaxSize: cfg.Attachments.MaxSizeMB << 20,
+		Quota:   cfg.Attachments.QuotaMB << 20,
+	})
+	timeSvc := timetrack.NewService(timeRepo, taskSvc, workspaceSvc)
+	// при удалении аккаунта каждый пакет удаляет своё; сессии закрываются первыми
+	userSvc.SetAccountData(tokenSvc, workspaceSvc, taskRepo, timeRepo, automationRepo, templateRepo, apiKeyRepo, mfaRepo, exportSvc)
+	overdueSweeper := overdue.NewSweeper(overduesqlite.New(db), taskRepo, notifier, nil, escalationRules(cfg.Overdue))
+	lockoutCfg := cfg.Auth.Lockout
+	loginGuard := lockout.NewGuard(lockout.Limits{
+		MaxEmailFailures: lockoutCfg.MaxEmailFailures,
+		MaxIPFailures:    lockoutCfg.MaxIPFailures,
+		FreeAttempts:     lockoutCfg.FreeAttempts,
+		Window:           lockoutCfg.Window,
+		DelayBase:        lockoutCfg.Delay,
+		MaxDelay:         lockoutCfg.MaxDelay,
+		Lockout:          lockoutCfg.Duration,
+		MaxLockout:       lockoutCfg.MaxDuration,
+	}, lockoutsqlite.New(db))
 
 	//servers
-	srv := httpserver.New(addr, taskSvc, userSvc, jwtManager)
+	srv := httpserver.New(addr, httpserver.Deps{
+		Tasks:        taskSvc,
+		Users:        userSvc,
+		APIKeys:      apiKeySvc,
+		MFA:          mfaSvc,
+		Exports:      exportSvc,
+		Workspaces:   workspaceSvc,
+		Attachments:  attachmentSvc,
+		TimeTracking: timeSvc,
+		Automations:  automationSvc,
+		Templates:    templateSvc,
+		JWT:          jwtManager,
+		Revocations:  revocations,
+		Tokens:       tokenSvc,
+		LoginGuard:   loginGuard,
+
+		TrustForwardedFor: lockoutCfg.TrustForwardedFor,
+	})
 
 	go func() {
 		log.Println("[MAIN] starting server on", addr)
@@ -73,6 +236,70 @@ func main() {
 		}
 	}()
 
+	// файлы удалённых задач (и задач удалённых аккаунтов) чистим в фоне
+	go func() {
+		ticker := time.NewTicker(cfg.Attachments.PurgeInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := attachmentSvc.PurgeOrphaned(context.Background()); err != nil {
+				log.Println("[MAIN] purge attachments:", err)
+			}
+		}
+	}()
+
+	// просроченные задачи эскалируем по правилам из конфига
+	go func() {
+		ticker := time.NewTicker(cfg.Overdue.SweepInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := overdueSweeper.Sweep(context.Background()); err != nil {
+				log.Println("[MAIN] sweep overdue tasks:", err)
+			}
+		}
+	}()
+
+	// правила "task.due": срок задачи наступил
+	go func() {
+		ticker := time.NewTicker(cfg.Automation.DueSweepInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := automationEngine.SweepDue(context.Background()); err != nil {
+				log.Println("[MAIN] sweep due automations:", err)
+			}
+		}
+	}()
+
+	// ранги ручной сортировки удлиняются при перестановках — раскладываем заново
+	go func() {
+		ticker := time.NewTicker(cfg.Ranks.RebalanceInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := taskSvc.RebalanceRanks(context.Background()); err != nil {
+				log.Println("[MAIN] rebalance task ranks:", err)
+			}
+		}
+	}()
+
+	// SIGHUP — перечитываем jwt ключи (ротация без рестарта)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			newCfg, err := config.Load()
+			if err != nil {
+				log.Println("[MAIN] reload config:", err)
+				continue
+			}
+			ks, err := loadKeySet(newCfg)
+			if err != nil {
+				log.Println("[MAIN] reload jwt keys:", err)
+				continue
+			}
+			jwtManager.SetKeys(ks)
+			log.Println("[MAIN] jwt keys reloaded, active kid:", newCfg.JWT.ActiveKID)
+		}
+	}()
+
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
@@ -85,8 +312,37 @@ func main() {
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		log.Println("[MAIN] shutdown error:", err)
 	}
+	// дожидаемся вебхуков, уже поставленных в очередь
+	automationEngine.Close()
 	if err := db.Close(); err != nil {
 		log.Println("[MAIN] db close error:", err)
 	}
 	log.Println("[MAIN] exited")
 }
+
+func newBlobStore(cfg config.Attachments) storage.BlobStore {
+	if cfg.Driver == "s3" {
+		return storage.NewS3Store(storage.S3Config{
+			Endpoint:        cfg.S3.Endpoint,
+			Region:          cfg.S3.Region,
+			Bucket:          cfg.S3.Bucket,
+			AccessKeyID:     cfg.S3.AccessKeyID,
+			SecretAccessKey: cfg.S3.SecretAccessKey,
+		})
+	}
+	return storage.NewLocalStore(cfg.Dir)
+}
+
+func escalationRules(cfg config.Overdue) []overdue.Rule {
+	rules := make([]overdue.Rule, 0, len(cfg.Rules))
+	for _, r := range cfg.Rules {
+		rules = append(rules, overdue.Rule{
+			Name:   r.Name,
+			After:  r.After,
+			Action: overdue.Action(r.Action),
+			URL:    r.URL,
+			Secret: r.Secret,
+		})
+	}
+	return rules
+}
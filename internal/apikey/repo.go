@@ -0,0 +1,17 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrNotFound = errors.New("api key not found")
+
+type Repo interface {
+	Create(ctx context.Context, k *APIKey) error
+	GetByPrefix(ctx context.Context, prefix string) (*APIKey, error)
+	List(ctx context.Context, userID int) ([]APIKey, error)
+	Revoke(ctx context.Context, userID, id int, at time.Time) error
+	TouchLastUsed(ctx context.Context, id int, at time.Time) error
+}
@@ -0,0 +1,158 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"task_scheduler/internal/auth"
+	"time"
+)
+
+var (
+	ErrInvalidInput = errors.New("invalid input")
+	ErrInvalidKey   = errors.New("invalid api key")
+)
+
+// KeyPrefix marks our API keys so they can be told apart from JWTs.
+// Full key format: tsk_<8 hex prefix>_<secret>.
+const KeyPrefix = "tsk_"
+
+const (
+	maxNameLen = 100
+	// last_used_at пишем не чаще раза в минуту, чтобы не писать в БД на каждый запрос
+	touchInterval = time.Minute
+)
+
+type Service interface {
+	// Create returns the stored key and the raw key, which is shown only once.
+	Create(ctx context.Context, userID int, name string, scopes []string, expiresAt *time.Time) (*APIKey, string, error)
+	List(ctx context.Context, userID int) ([]APIKey, error)
+	Revoke(ctx context.Context, userID, id int) error
+	// AuthenticateKey resolves a raw key to its owner's user id and scopes.
+	AuthenticateKey(ctx context.Context, raw string) (int, []string, error)
+}
+
+type apiKeyService struct {
+	repo Repo
+}
+
+func NewService(repo Repo) Service {
+	return &apiKeyService{repo: repo}
+}
+
+func (s *apiKeyService) Create(ctx context.Context, userID int, name string, scopes []string, expiresAt *time.Time) (*APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if userID <= 0 || name == "" || len(name) > maxNameLen {
+		return nil, "", ErrInvalidInput
+	}
+	// у ключа должен быть хотя бы один известный scope
+	scopes = normalizeScopes(scopes)
+	if len(scopes) == 0 {
+		return nil, "", ErrInvalidInput
+	}
+	for _, sc := range scopes {
+		if !auth.IsValidScope(sc) {
+			return nil, "", ErrInvalidInput
+		}
+	}
+	now := time.Now().UTC()
+	if expiresAt != nil && !expiresAt.After(now) {
+		return nil, "", ErrInvalidInput
+	}
+
+	// 1) генерируем prefix + секрет
+	prefixBytes := make([]byte, 4)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return nil, "", err
+	}
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return nil, "", err
+	}
+	prefix := KeyPrefix + hex.EncodeToString(prefixBytes)
+	raw := prefix + "_" + base64.RawURLEncoding.EncodeToString(secretBytes)
+
+	// 2) сохраняем только хеш
+	k := &APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   hashKey(raw),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+		CreatedAt: now,
+	}
+	if err := s.repo.Create(ctx, k); err != nil {
+		return nil, "", err
+	}
+	return k, raw, nil
+}
+
+func (s *apiKeyService) List(ctx context.Context, userID int) ([]APIKey, error) {
+	if userID <= 0 {
+		return nil, ErrInvalidInput
+	}
+	return s.repo.List(ctx, userID)
+}
+
+func (s *apiKeyService) Revoke(ctx context.Context, userID, id int) error {
+	if userID <= 0 || id <= 0 {
+		return ErrInvalidInput
+	}
+	return s.repo.Revoke(ctx, userID, id, time.Now().UTC())
+}
+
+func (s *apiKeyService) AuthenticateKey(ctx context.Context, raw string) (int, []string, error) {
+	// tsk_<prefix>_<secret>
+	parts := strings.SplitN(raw, "_", 3)
+	if len(parts) != 3 || parts[0]+"_" != KeyPrefix {
+		return 0, nil, ErrInvalidKey
+	}
+
+	k, err := s.repo.GetByPrefix(ctx, KeyPrefix+parts[1])
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return 0, nil, ErrInvalidKey
+		}
+		return 0, nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(k.KeyHash), []byte(hashKey(raw))) != 1 {
+		return 0, nil, ErrInvalidKey
+	}
+
+	now := time.Now().UTC()
+	if k.RevokedAt != nil || (k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)) {
+		return 0, nil, ErrInvalidKey
+	}
+
+	if k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) >= touchInterval {
+		if err := s.repo.TouchLastUsed(ctx, k.ID, now); err != nil {
+			return 0, nil, err
+		}
+	}
+	return k.UserID, k.Scopes, nil
+}
+
+func normalizeScopes(scopes []string) []string {
+	out := make([]string, 0, len(scopes))
+	seen := make(map[string]bool, len(scopes))
+	for _, sc := range scopes {
+		sc = strings.TrimSpace(sc)
+		if sc == "" || seen[sc] {
+			continue
+		}
+		seen[sc] = true
+		out = append(out, sc)
+	}
+	return out
+}
+
+func hashKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
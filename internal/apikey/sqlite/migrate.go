@@ -0,0 +1,22 @@
+package sqlite
+
+import "database/sql"
+
+func Migrate(db *sql.DB) error {
+	const q = `
+	CREATE TABLE IF NOT EXISTS api_keys(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	prefix TEXT NOT NULL UNIQUE,
+	key_hash TEXT NOT NULL,
+	scopes TEXT NOT NULL,
+	expires_at TEXT NULL,
+	last_used_at TEXT NULL,
+	created_at TEXT NOT NULL,
+	revoked_at TEXT NULL);
+	CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys(user_id);
+	`
+	_, err := db.Exec(q)
+	return err
+}
@@ -0,0 +1,182 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"task_scheduler/internal/apikey"
+	"time"
+)
+
+type Repo struct {
+	db *sql.DB
+}
+
+func New(db *sql.DB) *Repo {
+	return &Repo{db: db}
+}
+
+const selectColumns = `id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, created_at, revoked_at`
+
+func (r *Repo) Create(ctx context.Context, k *apikey.APIKey) error {
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		k.UserID,
+		k.Name,
+		k.Prefix,
+		k.KeyHash,
+		strings.Join(k.Scopes, " "),
+		formatNullTime(k.ExpiresAt),
+		k.CreatedAt.UTC().Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	k.ID = int(id)
+	return nil
+}
+
+func (r *Repo) GetByPrefix(ctx context.Context, prefix string) (*apikey.APIKey, error) {
+	row := r.db.QueryRowContext(ctx,
+		`SELECT `+selectColumns+` FROM api_keys WHERE prefix = ?`,
+		prefix,
+	)
+	k, err := scanKey(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, apikey.ErrNotFound
+		}
+		return nil, err
+	}
+	return k, nil
+}
+
+func (r *Repo) List(ctx context.Context, userID int) ([]apikey.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+selectColumns+` FROM api_keys WHERE user_id = ? ORDER BY created_at DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]apikey.APIKey, 0)
+	for rows.Next() {
+		k, err := scanKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *Repo) Revoke(ctx context.Context, userID, id int, at time.Time) error {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE api_keys SET revoked_at = ?
+		 WHERE user_id = ? AND id = ? AND revoked_at IS NULL`,
+		at.UTC().Format(time.RFC3339Nano),
+		userID,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if aff == 0 {
+		return apikey.ErrNotFound
+	}
+	return nil
+}
+
+func (r *Repo) TouchLastUsed(ctx context.Context, id int, at time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE api_keys SET last_used_at = ? WHERE id = ?`,
+		at.UTC().Format(time.RFC3339Nano),
+		id,
+	)
+	return err
+}
+
+// DeleteAccountData deletes the user's keys when the account goes.
+func (r *Repo) DeleteAccountData(ctx context.Context, userID int) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM api_keys WHERE user_id = ?`, userID)
+	return err
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanKey(s scanner) (*apikey.APIKey, error) {
+	var (
+		k            apikey.APIKey
+		scopes       string
+		expiresAt    sql.NullString
+		lastUsedAt   sql.NullString
+		createdAtStr string
+		revokedAt    sql.NullString
+	)
+	if err := s.Scan(
+		&k.ID,
+		&k.UserID,
+		&k.Name,
+		&k.Prefix,
+		&k.KeyHash,
+		&scopes,
+		&expiresAt,
+		&lastUsedAt,
+		&createdAtStr,
+		&revokedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	k.Scopes = strings.Fields(scopes)
+
+	var err error
+	if k.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAtStr); err != nil {
+		return nil, err
+	}
+	if k.ExpiresAt, err = parseNullTime(expiresAt); err != nil {
+		return nil, err
+	}
+	if k.LastUsedAt, err = parseNullTime(lastUsedAt); err != nil {
+		return nil, err
+	}
+	if k.RevokedAt, err = parseNullTime(revokedAt); err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+func formatNullTime(t *time.Time) sql.NullString {
+	if t == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: t.UTC().Format(time.RFC3339Nano), Valid: true}
+}
+
+func parseNullTime(s sql.NullString) (*time.Time, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,24 @@
+package attachment
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrNotFound = errors.New("attachment not found")
+
+type Repo interface {
+	// Create inserts the attachment unless UsedBytes would then exceed quota
+	// (ErrQuotaExceeded); check and insert are one statement.
+	Create(ctx context.Context, a *Attachment, quota int64) error
+	// Get returns ErrNotFound unless the attachment belongs to the task.
+	Get(ctx context.Context, taskID, id int) (*Attachment, error)
+	List(ctx context.Context, taskID int) ([]Attachment, error)
+	Delete(ctx context.Context, id int) error
+	// UsedBytes sums the sizes counted against the quota an upload by the
+	// user to the task falls under: everything on the tasks of the task's
+	// workspace, or the user's uploads on existing personal tasks.
+	UsedBytes(ctx context.Context, uploaderID, taskID int) (int64, error)
+	// ListOrphaned returns attachments whose task no longer exists.
+	ListOrphaned(ctx context.Context, limit int) ([]Attachment, error)
+}
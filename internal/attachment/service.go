@@ -0,0 +1,251 @@
+package attachment
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"task_scheduler/internal/storage"
+	"task_scheduler/internal/task"
+	"time"
+	"unicode"
+)
+
+var (
+	ErrInvalidInput  = errors.New("invalid input")
+	ErrTooLarge      = errors.New("file is too large")
+	ErrQuotaExceeded = errors.New("attachment storage quota exceeded")
+)
+
+// sniffLen is how much of the upload http.DetectContentType looks at.
+const sniffLen = 512
+
+// purgeBatch bounds one pass of the orphan cleanup.
+const purgeBatch = 100
+
+type Service interface {
+	// Upload stores a file of the given size on the task. The content type
+	// is sniffed from the data; the client's claim is ignored.
+	Upload(ctx context.Context, userID, taskID int, filename string, r io.Reader, size int64) (*Attachment, error)
+	List(ctx context.Context, userID, taskID int) ([]Attachment, error)
+	// Open returns the attachment with its content. The caller closes it.
+	Open(ctx context.Context, userID, taskID, id int) (*Attachment, io.ReadSeekCloser, error)
+	// Delete is allowed to the uploader and to task owners.
+	Delete(ctx context.Context, userID, taskID, id int) error
+	// PurgeOrphaned removes attachments (and blobs) of deleted tasks.
+	PurgeOrphaned(ctx context.Context) error
+	// MaxSize is the largest accepted file, in bytes.
+	MaxSize() int64
+}
+
+// Tasks reports the caller's role on a task; task.ErrNotFound if none.
+type Tasks interface {
+	Role(ctx context.Context, userID, taskID int) (task.Role, error)
+}
+
+// Options limit uploads. Quota is per workspace for its tasks, and per
+// uploader for personal tasks.
+type Options struct {
+	MaxSize int64
+	Quota   int64
+}
+
+type attachmentService struct {
+	repo  Repo
+	blobs storage.BlobStore
+	tasks Tasks
+	opts  Options
+}
+
+func NewService(repo Repo, blobs storage.BlobStore, tasks Tasks, opts Options) Service {
+	return &attachmentService{
+		repo:  repo,
+		blobs: blobs,
+		tasks: tasks,
+		opts:  opts,
+	}
+}
+
+func (s *attachmentService) MaxSize() int64 {
+	return s.opts.MaxSize
+}
+
+func (s *attachmentService) Upload(ctx context.Context, userID, taskID int, filename string, r io.Reader, size int64) (*Attachment, error) {
+	filename = cleanFilename(filename)
+	if userID <= 0 || taskID <= 0 || filename == "" || size <= 0 {
+		return nil, ErrInvalidInput
+	}
+	if size > s.opts.MaxSize {
+		return nil, ErrTooLarge
+	}
+	// 1) загружать может editor
+	if err := s.require(ctx, userID, taskID, task.RoleEditor); err != nil {
+		return nil, err
+	}
+
+	// 2) квота: здесь — чтобы не грузить заведомо лишнее, окончательно — при записи
+	used, err := s.repo.UsedBytes(ctx, userID, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if used+size > s.opts.Quota {
+		return nil, ErrQuotaExceeded
+	}
+
+	// 3) тип определяем по содержимому, а не по имени или заголовку клиента
+	head := make([]byte, min(size, sniffLen))
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, ErrInvalidInput
+	}
+	contentType := http.DetectContentType(head)
+
+	key, err := newStorageKey(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.blobs.Put(ctx, key, io.MultiReader(bytes.NewReader(head), r), size, contentType); err != nil {
+		return nil, err
+	}
+
+	a := &Attachment{
+		TaskID:      taskID,
+		UploaderID:  userID,
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        size,
+		StorageKey:  key,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if err := s.repo.Create(ctx, a, s.opts.Quota); err != nil {
+		// без записи в БД объект никому не нужен
+		if delErr := s.blobs.Delete(context.WithoutCancel(ctx), key); delErr != nil {
+			log.Println("[ATTACHMENTS] delete blob error:", delErr)
+		}
+		return nil, err
+	}
+	return a, nil
+}
+
+func (s *attachmentService) List(ctx context.Context, userID, taskID int) ([]Attachment, error) {
+	if userID <= 0 || taskID <= 0 {
+		return nil, ErrInvalidInput
+	}
+	if err := s.require(ctx, userID, taskID, task.RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.repo.List(ctx, taskID)
+}
+
+func (s *attachmentService) Open(ctx context.Context, userID, taskID, id int) (*Attachment, io.ReadSeekCloser, error) {
+	if userID <= 0 || taskID <= 0 || id <= 0 {
+		return nil, nil, ErrInvalidInput
+	}
+	if err := s.require(ctx, userID, taskID, task.RoleViewer); err != nil {
+		return nil, nil, err
+	}
+	a, err := s.repo.Get(ctx, taskID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := s.blobs.Open(ctx, a.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, err
+	}
+	return a, content, nil
+}
+
+func (s *attachmentService) Delete(ctx context.Context, userID, taskID, id int) error {
+	if userID <= 0 || taskID <= 0 || id <= 0 {
+		return ErrInvalidInput
+	}
+	role, err := s.tasks.Role(ctx, userID, taskID)
+	if err != nil {
+		return err
+	}
+	a, err := s.repo.Get(ctx, taskID, id)
+	if err != nil {
+		return err
+	}
+	// свой файл удаляет editor, чужой — только owner
+	allowed := role.Allows(task.RoleOwner) || (a.UploaderID == userID && role.Allows(task.RoleEditor))
+	if !allowed {
+		return task.ErrForbidden
+	}
+	return s.remove(ctx, a)
+}
+
+func (s *attachmentService) PurgeOrphaned(ctx context.Context) error {
+	for {
+		orphans, err := s.repo.ListOrphaned(ctx, purgeBatch)
+		if err != nil {
+			return err
+		}
+		for i := range orphans {
+			if err := s.remove(ctx, &orphans[i]); err != nil {
+				return err
+			}
+		}
+		if len(orphans) < purgeBatch {
+			return nil
+		}
+	}
+}
+
+// remove deletes the blob first: a row without a blob is visible and can be
+// deleted again, a blob without a row would leak.
+func (s *attachmentService) remove(ctx context.Context, a *Attachment) error {
+	if err := s.blobs.Delete(ctx, a.StorageKey); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, a.ID)
+}
+
+func (s *attachmentService) require(ctx context.Context, userID, taskID int, min task.Role) error {
+	role, err := s.tasks.Role(ctx, userID, taskID)
+	if err != nil {
+		return err
+	}
+	if !role.Allows(min) {
+		return task.ErrForbidden
+	}
+	return nil
+}
+
+// newStorageKey is unguessable and never reused, so a blob can't be
+// confused with a deleted one.
+func newStorageKey(taskID int) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "tasks/" + strconv.Itoa(taskID) + "/" + hex.EncodeToString(b), nil
+}
+
+// cleanFilename keeps only the base name without control characters.
+func cleanFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "." || name == "/" || name == ".." {
+		return ""
+	}
+	if len(name) > 255 {
+		name = name[:255]
+	}
+	return strings.ToValidUTF8(name, "")
+}
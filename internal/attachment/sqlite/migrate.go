@@ -0,0 +1,21 @@
+package sqlite
+
+import "database/sql"
+
+func Migrate(db *sql.DB) error {
+	const q = `
+	CREATE TABLE IF NOT EXISTS task_attachments(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	task_id INTEGER NOT NULL,
+	uploader_id INTEGER NOT NULL,
+	filename TEXT NOT NULL,
+	content_type TEXT NOT NULL,
+	size INTEGER NOT NULL,
+	storage_key TEXT NOT NULL UNIQUE,
+	created_at TEXT NOT NULL);
+	CREATE INDEX IF NOT EXISTS idx_task_attachments_task_id ON task_attachments(task_id);
+	CREATE INDEX IF NOT EXISTS idx_task_attachments_uploader_id ON task_attachments(uploader_id);
+	`
+	_, err := db.Exec(q)
+	return err
+}
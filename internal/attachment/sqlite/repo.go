@@ -0,0 +1,156 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"task_scheduler/internal/attachment"
+	"time"
+)
+
+type Repo struct {
+	db *sql.DB
+}
+
+func New(db *sql.DB) *Repo {
+	return &Repo{db: db}
+}
+
+const selectColumns = `id, task_id, uploader_id, filename, content_type, size, storage_key, created_at`
+
+// usedBytes sums the uploads that share a quota with an upload by the user
+// (first argument) to the task (second): all uploads on the workspace's
+// tasks, or the user's own uploads on personal tasks.
+const usedBytes = `SELECT COALESCE(SUM(a.size), 0) FROM task_attachments a JOIN tasks t ON t.id = a.task_id
+	WHERE (t.workspace_id > 0 OR a.uploader_id = ?)
+	  AND t.workspace_id = (SELECT workspace_id FROM tasks WHERE id = ?)`
+
+func (r *Repo) Create(ctx context.Context, a *attachment.Attachment, quota int64) error {
+	// квоту проверяем в том же запросе: параллельные загрузки её не превысят
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO task_attachments (task_id, uploader_id, filename, content_type, size, storage_key, created_at)
+		 SELECT ?, ?, ?, ?, ?, ?, ?
+		 WHERE (`+usedBytes+`) + ? <= ?`,
+		a.TaskID,
+		a.UploaderID,
+		a.Filename,
+		a.ContentType,
+		a.Size,
+		a.StorageKey,
+		a.CreatedAt.UTC().Format(time.RFC3339Nano),
+		a.UploaderID,
+		a.TaskID,
+		a.Size,
+		quota,
+	)
+	if err != nil {
+		return err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if aff == 0 {
+		return attachment.ErrQuotaExceeded
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	a.ID = int(id)
+	return nil
+}
+
+func (r *Repo) Get(ctx context.Context, taskID, id int) (*attachment.Attachment, error) {
+	row := r.db.QueryRowContext(ctx,
+		`SELECT `+selectColumns+` FROM task_attachments WHERE task_id = ? AND id = ?`,
+		taskID,
+		id,
+	)
+	a, err := scanAttachment(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, attachment.ErrNotFound
+		}
+		return nil, err
+	}
+	return a, nil
+}
+
+func (r *Repo) List(ctx context.Context, taskID int) ([]attachment.Attachment, error) {
+	return r.list(ctx,
+		`SELECT `+selectColumns+` FROM task_attachments WHERE task_id = ? ORDER BY created_at, id`,
+		taskID,
+	)
+}
+
+func (r *Repo) Delete(ctx context.Context, id int) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM task_attachments WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if aff == 0 {
+		return attachment.ErrNotFound
+	}
+	return nil
+}
+
+func (r *Repo) UsedBytes(ctx context.Context, uploaderID, taskID int) (int64, error) {
+	var used int64
+	err := r.db.QueryRowContext(ctx, usedBytes, uploaderID, taskID).Scan(&used)
+	return used, err
+}
+
+func (r *Repo) ListOrphaned(ctx context.Context, limit int) ([]attachment.Attachment, error) {
+	return r.list(ctx,
+		`SELECT `+selectColumns+` FROM task_attachments
+		 WHERE task_id NOT IN (SELECT id FROM tasks)
+		 ORDER BY id LIMIT ?`,
+		limit,
+	)
+}
+
+func (r *Repo) list(ctx context.Context, query string, args ...any) ([]attachment.Attachment, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]attachment.Attachment, 0)
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAttachment(s scanner) (*attachment.Attachment, error) {
+	var (
+		a            attachment.Attachment
+		createdAtStr string
+	)
+	if err := s.Scan(&a.ID, &a.TaskID, &a.UploaderID, &a.Filename, &a.ContentType, &a.Size, &a.StorageKey, &createdAtStr); err != nil {
+		return nil, err
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, createdAtStr)
+	if err != nil {
+		return nil, err
+	}
+	a.CreatedAt = createdAt
+	return &a, nil
+}
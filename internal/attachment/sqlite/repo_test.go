@@ -0,0 +1,119 @@
+package sqlite_test
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+
+	"task_scheduler/internal/attachment"
+	attachmentsqlite "task_scheduler/internal/attachment/sqlite"
+	"task_scheduler/internal/task"
+	tasksqlite "task_scheduler/internal/task/sqlite"
+)
+
+func TestRepo_Create_ConcurrentUploadsStayWithinQuota(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "attachments.db")+"?_pragma=busy_timeout(5000)")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	require.NoError(t, tasksqlite.Migrate(db))
+	require.NoError(t, attachmentsqlite.Migrate(db))
+
+	ctx := t.Context()
+	repo := attachmentsqlite.New(db)
+	now := time.Now().UTC()
+	tsk := &task.Task{UserID: 1, CreatedBy: 1, Title: "T", Status: task.StatusPending, CreatedAt: now, UpdatedAt: now}
+	require.NoError(t, tasksqlite.New(db).Create(ctx, tsk, 0))
+
+	const (
+		quota   = 1000
+		size    = 300
+		uploads = 10
+	)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		stored   int
+		rejected int
+	)
+	for i := range uploads {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := repo.Create(ctx, &attachment.Attachment{
+				TaskID:      tsk.ID,
+				UploaderID:  1,
+				Filename:    "f" + strconv.Itoa(i),
+				ContentType: "text/plain",
+				Size:        size,
+				StorageKey:  "k" + strconv.Itoa(i),
+				CreatedAt:   now,
+			}, quota)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				stored++
+			case errors.Is(err, attachment.ErrQuotaExceeded):
+				rejected++
+			default:
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// влезает ровно три файла, сколько бы загрузок ни шло одновременно
+	require.Equal(t, quota/size, stored)
+	require.Equal(t, uploads-quota/size, rejected)
+	used, err := repo.UsedBytes(ctx, 1, tsk.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(quota/size*size), used)
+
+	// чужая квота не задета
+	other := &attachment.Attachment{TaskID: tsk.ID, UploaderID: 2, Filename: "g", ContentType: "text/plain", Size: size, StorageKey: "g", CreatedAt: now}
+	require.NoError(t, repo.Create(ctx, other, quota))
+}
+
+func TestRepo_Create_WorkspaceSharesQuota(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "attachments.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	require.NoError(t, tasksqlite.Migrate(db))
+	require.NoError(t, attachmentsqlite.Migrate(db))
+
+	ctx := t.Context()
+	repo := attachmentsqlite.New(db)
+	tasks := tasksqlite.New(db)
+	now := time.Now().UTC()
+	newTask := func(workspaceID int) int {
+		tsk := &task.Task{UserID: 1, CreatedBy: 1, WorkspaceID: workspaceID, Title: "T", Status: task.StatusPending, CreatedAt: now, UpdatedAt: now}
+		require.NoError(t, tasks.Create(ctx, tsk, 0))
+		return tsk.ID
+	}
+	upload := func(taskID, uploaderID int, key string) error {
+		return repo.Create(ctx, &attachment.Attachment{TaskID: taskID, UploaderID: uploaderID, Filename: key, ContentType: "text/plain", Size: 400, StorageKey: key, CreatedAt: now}, 1000)
+	}
+	first, second, personal, otherWorkspace := newTask(7), newTask(7), newTask(0), newTask(8)
+
+	// задачи workspace делят одну квоту на всех участников
+	require.NoError(t, upload(first, 1, "a"))
+	require.NoError(t, upload(second, 2, "b"))
+	require.ErrorIs(t, upload(first, 3, "c"), attachment.ErrQuotaExceeded)
+	used, err := repo.UsedBytes(ctx, 3, second)
+	require.NoError(t, err)
+	require.Equal(t, int64(800), used)
+
+	// личные задачи и другой workspace считаются отдельно
+	require.NoError(t, upload(personal, 1, "d"))
+	require.NoError(t, upload(otherWorkspace, 1, "e"))
+	used, err = repo.UsedBytes(ctx, 1, personal)
+	require.NoError(t, err)
+	require.Equal(t, int64(400), used)
+}
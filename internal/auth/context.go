@@ -4,7 +4,11 @@ import "context"
 
 type contextKey string
 
-const userIDKey contextKey = "user_id"
+const (
+	userIDKey contextKey = "user_id"
+	claimsKey contextKey = "claims"
+	scopesKey contextKey = "scopes"
+)
 
 func WithUserID(ctx context.Context, userID int) context.Context {
 	return context.WithValue(ctx, userIDKey, userID)
@@ -14,3 +18,21 @@ func UserIDFromContext(ctx context.Context) (int, bool) {
 	id, ok := ctx.Value(userIDKey).(int)
 	return id, ok
 }
+
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey, claims)
+}
+
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	c, ok := ctx.Value(claimsKey).(*Claims)
+	return c, ok
+}
+
+func WithScopes(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, scopesKey, scopes)
+}
+
+func ScopesFromContext(ctx context.Context) ([]string, bool) {
+	s, ok := ctx.Value(scopesKey).([]string)
+	return s, ok
+}
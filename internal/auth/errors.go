@@ -2,4 +2,10 @@ package auth
 
 import "errors"
 
-var ErrInvalidToken = errors.New("invalid token")
+var (
+	ErrInvalidToken        = errors.New("invalid token")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+	ErrRefreshNotFound     = errors.New("refresh token not found")
+	ErrInvalidMFAToken     = errors.New("invalid or expired mfa token")
+)
@@ -1,32 +1,90 @@
 package auth
 
 import (
+	"math"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt"
 )
 
 type JWTManager struct {
-	secret []byte
-	ttl    time.Duration
+	mu   sync.RWMutex
+	keys *KeySet
+	ttl  time.Duration
 }
 
+// Claims are the verified claims of an access token.
+type Claims struct {
+	UserID    int
+	ID        string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	Scopes    []string
+}
+
+// NewJWTManager signs with a single HS256 shared secret.
 func NewJWTManager(secret string, ttl time.Duration) *JWTManager {
+	keys, _ := NewKeySet("", HMACKey(secret))
+	return NewJWTManagerWithKeys(keys, ttl)
+}
+
+func NewJWTManagerWithKeys(keys *KeySet, ttl time.Duration) *JWTManager {
 	return &JWTManager{
-		secret: []byte(secret),
-		ttl:    ttl,
+		keys: keys,
+		ttl:  ttl,
 	}
 }
 
-func (j *JWTManager) Generate(userID int) (string, error) {
+// SetKeys swaps the key set; tokens are signed with the new active key from
+// now on and verified against whatever keys the new set contains.
+func (j *JWTManager) SetKeys(keys *KeySet) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.keys = keys
+}
+
+func (j *JWTManager) keySet() *KeySet {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.keys
+}
+
+// JWKS returns the public keys other services can verify our tokens with.
+func (j *JWTManager) JWKS() JWKS {
+	return j.keySet().JWKS()
+}
+
+func (j *JWTManager) Generate(userID int, scopes []string) (string, error) {
+	return j.GenerateWithTTL(userID, scopes, j.ttl)
+}
+
+// GenerateWithTTL is Generate with a custom lifetime, e.g. for MFA pending tokens.
+func (j *JWTManager) GenerateWithTTL(userID int, scopes []string, ttl time.Duration) (string, error) {
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
 	claims := jwt.MapClaims{
-		"sub": userID,
-		"exp": time.Now().Add(j.ttl).Unix(),
-		"iat": time.Now().Unix(),
+		"sub":   userID,
+		"jti":   jti,
+		"scope": strings.Join(scopes, " "),
+		"exp":   time.Now().Add(ttl).Unix(),
+		// iat с миллисекундами: иначе logout-all отзывает и токены,
+		// выданные в ту же секунду сразу после него (например, логин после сброса пароля)
+		"iat": float64(time.Now().UnixMilli()) / 1000,
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signed, err := token.SignedString(j.secret)
+	keys := j.keySet()
+	key := keys.keys[keys.active]
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	signed, err := token.SignedString(key.SignKey)
 	if err != nil {
 		return "", err
 	}
@@ -34,25 +92,57 @@ func (j *JWTManager) Generate(userID int) (string, error) {
 }
 
 func (j *JWTManager) Verify(tokenStr string) (int, error) {
+	claims, err := j.Parse(tokenStr)
+	if err != nil {
+		return 0, err
+	}
+	return claims.UserID, nil
+}
+
+// Parse verifies the token signature and expiry and returns its claims.
+func (j *JWTManager) Parse(tokenStr string) (*Claims, error) {
+	keys := j.keySet()
 	token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (any, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+		// без kid — старый HS256 токен (ключ с пустым id)
+		kid, _ := t.Header["kid"].(string)
+		key, ok := keys.keys[kid]
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		// алгоритм должен совпадать с ключом, иначе возможна подмена alg
+		if t.Method.Alg() != key.Method.Alg() {
 			return nil, ErrInvalidToken
 		}
-		return j.secret, nil
+		return key.VerifyKey, nil
 	})
 	if err != nil || !token.Valid {
-		return 0, ErrInvalidToken
+		return nil, ErrInvalidToken
 	}
-	claims, ok := token.Claims.(jwt.MapClaims)
+	mc, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		return 0, ErrInvalidToken
+		return nil, ErrInvalidToken
 	}
 
 	// sub
-	sub, ok := claims["sub"].(float64) // JSON number → float64
+	sub, ok := mc["sub"].(float64) // JSON number → float64
 	if !ok {
-		return 0, ErrInvalidToken
+		return nil, ErrInvalidToken
 	}
 
-	return int(sub), nil
+	claims := &Claims{UserID: int(sub)}
+	// jti может отсутствовать у токенов, выданных до его появления
+	claims.ID, _ = mc["jti"].(string)
+	if iat, ok := mc["iat"].(float64); ok {
+		claims.IssuedAt = time.UnixMilli(int64(math.Round(iat * 1000))).UTC()
+	}
+	if exp, ok := mc["exp"].(float64); ok {
+		claims.ExpiresAt = time.Unix(int64(exp), 0).UTC()
+	}
+	// токены без scope выданы до появления scopes и имели полный доступ
+	if scope, ok := mc["scope"].(string); ok {
+		claims.Scopes = strings.Fields(scope)
+	} else {
+		claims.Scopes = slices.Clone(AllScopes)
+	}
+	return claims, nil
 }
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt"
+)
+
+var (
+	ErrUnsupportedAlg = errors.New("unsupported jwt signing algorithm")
+	ErrUnknownKey     = errors.New("unknown jwt key id")
+	ErrNoSigningKey   = errors.New("active jwt key has no private key")
+)
+
+// Key is one JWT key identified by its kid. Keys without a private part can
+// only verify tokens, which is how retired or not-yet-active keys are kept
+// around during rotation.
+type Key struct {
+	ID        string
+	Method    jwt.SigningMethod
+	SignKey   any
+	VerifyKey any
+}
+
+// KeySet is an immutable set of keys with the kid used for new tokens.
+type KeySet struct {
+	active string
+	keys   map[string]*Key
+}
+
+func NewKeySet(active string, keys ...*Key) (*KeySet, error) {
+	ks := &KeySet{
+		active: active,
+		keys:   make(map[string]*Key, len(keys)),
+	}
+	for _, k := range keys {
+		ks.keys[k.ID] = k
+	}
+	k, ok := ks.keys[active]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if k.SignKey == nil {
+		return nil, ErrNoSigningKey
+	}
+	return ks, nil
+}
+
+// HMACKey is the legacy shared-secret key. It has an empty kid so tokens
+// issued before key ids existed still verify.
+func HMACKey(secret string) *Key {
+	return &Key{
+		ID:        "",
+		Method:    jwt.SigningMethodHS256,
+		SignKey:   []byte(secret),
+		VerifyKey: []byte(secret),
+	}
+}
+
+// LoadKey reads a PEM key for alg (RS256 or EdDSA). If privateFile is empty
+// the key is verification-only and publicFile is required.
+func LoadKey(kid, alg, privateFile, publicFile string) (*Key, error) {
+	k := &Key{ID: kid}
+
+	switch alg {
+	case "RS256":
+		k.Method = jwt.SigningMethodRS256
+		if privateFile != "" {
+			b, err := os.ReadFile(privateFile)
+			if err != nil {
+				return nil, err
+			}
+			priv, err := jwt.ParseRSAPrivateKeyFromPEM(b)
+			if err != nil {
+				return nil, err
+			}
+			k.SignKey, k.VerifyKey = priv, &priv.PublicKey
+			return k, nil
+		}
+		b, err := os.ReadFile(publicFile)
+		if err != nil {
+			return nil, err
+		}
+		pub, err := jwt.ParseRSAPublicKeyFromPEM(b)
+		if err != nil {
+			return nil, err
+		}
+		k.VerifyKey = pub
+	case "EdDSA":
+		k.Method = jwt.SigningMethodEdDSA
+		if privateFile != "" {
+			b, err := os.ReadFile(privateFile)
+			if err != nil {
+				return nil, err
+			}
+			priv, err := jwt.ParseEdPrivateKeyFromPEM(b)
+			if err != nil {
+				return nil, err
+			}
+			k.SignKey, k.VerifyKey = priv, priv.(crypto.Signer).Public()
+			return k, nil
+		}
+		b, err := os.ReadFile(publicFile)
+		if err != nil {
+			return nil, err
+		}
+		pub, err := jwt.ParseEdPublicKeyFromPEM(b)
+		if err != nil {
+			return nil, err
+		}
+		k.VerifyKey = pub
+	default:
+		return nil, ErrUnsupportedAlg
+	}
+	return k, nil
+}
+
+// JWK is a public key in RFC 7517 format.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public parts of all asymmetric keys.
+// The shared HMAC secret is never published.
+func (ks *KeySet) JWKS() JWKS {
+	out := JWKS{Keys: make([]JWK, 0, len(ks.keys))}
+	for _, k := range ks.keys {
+		switch pub := k.VerifyKey.(type) {
+		case *rsa.PublicKey:
+			out.Keys = append(out.Keys, JWK{
+				Kty: "RSA",
+				Kid: k.ID,
+				Use: "sig",
+				Alg: k.Method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			out.Keys = append(out.Keys, JWK{
+				Kty: "OKP",
+				Kid: k.ID,
+				Use: "sig",
+				Alg: k.Method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+	return out
+}
@@ -1,14 +1,32 @@
 package auth
 
 import (
+	"context"
 	"net/http"
+	"slices"
 	"strings"
 )
 
-func JWTMiddleware(jwtManager *JWTManager) func(http.Handler) http.Handler {
+// apiKeyPrefix must match apikey.KeyPrefix; auth does not import apikey.
+const apiKeyPrefix = "tsk_"
+
+// APIKeyAuthenticator resolves a raw personal API key to its owner and scopes.
+type APIKeyAuthenticator interface {
+	AuthenticateKey(ctx context.Context, raw string) (int, []string, error)
+}
+
+// JWTMiddleware authenticates requests with a Bearer JWT or, alternatively,
+// a personal API key (either "Authorization: Bearer tsk_..." or "X-API-Key").
+func JWTMiddleware(jwtManager *JWTManager, revocations *Revocations, apiKeys APIKeyAuthenticator) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
+			//0. API key вместо JWT
+			if key := r.Header.Get("X-API-Key"); key != "" {
+				serveWithAPIKey(w, r, next, apiKeys, key)
+				return
+			}
+
 			//1. Берем Authorization header
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" {
@@ -24,16 +42,31 @@ func JWTMiddleware(jwtManager *JWTManager) func(http.Handler) http.Handler {
 			}
 
 			tokenStr := parts[1]
+			if strings.HasPrefix(tokenStr, apiKeyPrefix) {
+				serveWithAPIKey(w, r, next, apiKeys, tokenStr)
+				return
+			}
 
 			//3. Проверяем JWT
-			userID, err := jwtManager.Verify(tokenStr)
+			claims, err := jwtManager.Parse(tokenStr)
 			if err != nil {
 				http.Error(w, "invalid token", http.StatusUnauthorized)
 				return
 			}
+			if revocations.IsRevoked(claims) {
+				http.Error(w, "token revoked", http.StatusUnauthorized)
+				return
+			}
+			// mfa pending токен годится только для POST /v1/auth/mfa
+			if slices.Contains(claims.Scopes, ScopeMFAPending) {
+				http.Error(w, "mfa required", http.StatusUnauthorized)
+				return
+			}
 
-			//4. Кладет user_id в context
-			ctx := WithUserID(r.Context(), userID)
+			//4. Кладет user_id, claims и scopes в context
+			ctx := WithUserID(r.Context(), claims.UserID)
+			ctx = WithClaims(ctx, claims)
+			ctx = WithScopes(ctx, claims.Scopes)
 
 			//5. Передаем управление дальше
 			next.ServeHTTP(w, r.WithContext(ctx))
@@ -41,3 +74,32 @@ func JWTMiddleware(jwtManager *JWTManager) func(http.Handler) http.Handler {
 		})
 	}
 }
+
+func serveWithAPIKey(w http.ResponseWriter, r *http.Request, next http.Handler, apiKeys APIKeyAuthenticator, key string) {
+	if apiKeys == nil {
+		http.Error(w, "invalid api key", http.StatusUnauthorized)
+		return
+	}
+	userID, scopes, err := apiKeys.AuthenticateKey(r.Context(), key)
+	if err != nil {
+		http.Error(w, "invalid api key", http.StatusUnauthorized)
+		return
+	}
+	ctx := WithUserID(r.Context(), userID)
+	ctx = WithScopes(ctx, scopes)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// RequireSession rejects API keys with 403 SESSION_REQUIRED: the route needs
+// a token from an interactive login. It must run after JWTMiddleware.
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := ClaimsFromContext(r.Context()); !ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"error":{"code":"SESSION_REQUIRED","message":"api keys cannot be used here; log in"}}` + "\n"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"context"
+	"time"
+)
+
+// RefreshToken is a stored (hashed) opaque refresh token. Tokens obtained by
+// rotating each other share a FamilyID, so replaying an old one lets us
+// revoke the whole chain.
+type RefreshToken struct {
+	ID        int
+	UserID    int
+	FamilyID  string
+	TokenHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+}
+
+type RefreshRepo interface {
+	Create(ctx context.Context, t *RefreshToken) error
+	GetByHash(ctx context.Context, hash string) (*RefreshToken, error)
+	// MarkUsed atomically marks an unused token as used.
+	// It returns false if the token was already used or revoked.
+	MarkUsed(ctx context.Context, id int, at time.Time) (bool, error)
+	RevokeFamily(ctx context.Context, familyID string, at time.Time) error
+	RevokeUser(ctx context.Context, userID int, at time.Time) error
+	// DeleteUser drops all of the user's refresh tokens.
+	DeleteUser(ctx context.Context, userID int) error
+}
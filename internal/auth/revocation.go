@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type RevocationRepo interface {
+	RevokeToken(ctx context.Context, jti string, userID int, expiresAt time.Time) error
+	// ListRevokedTokens returns jti -> expiry for tokens that have not expired yet.
+	ListRevokedTokens(ctx context.Context, now time.Time) (map[string]time.Time, error)
+	SetUserCutoff(ctx context.Context, userID int, at time.Time) error
+	ListUserCutoffs(ctx context.Context) (map[int]time.Time, error)
+}
+
+// Revocations answers "is this access token still allowed" from memory.
+// Every write goes to the repo first and then to the cache, so the cache is
+// authoritative for a single API process once Load has run.
+type Revocations struct {
+	repo RevocationRepo
+
+	mu      sync.RWMutex
+	tokens  map[string]time.Time
+	cutoffs map[int]time.Time
+}
+
+func NewRevocations(repo RevocationRepo) *Revocations {
+	return &Revocations{
+		repo:    repo,
+		tokens:  make(map[string]time.Time),
+		cutoffs: make(map[int]time.Time),
+	}
+}
+
+// Load warms the cache from the repo.
+func (r *Revocations) Load(ctx context.Context) error {
+	tokens, err := r.repo.ListRevokedTokens(ctx, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	cutoffs, err := r.repo.ListUserCutoffs(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens = tokens
+	r.cutoffs = cutoffs
+	return nil
+}
+
+// RevokeToken revokes a single access token until it would expire anyway.
+func (r *Revocations) RevokeToken(ctx context.Context, c *Claims) error {
+	if c.ID == "" {
+		return ErrInvalidToken
+	}
+	if err := r.repo.RevokeToken(ctx, c.ID, c.UserID, c.ExpiresAt); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// заодно выкидываем из памяти то, что уже истекло само
+	for jti, exp := range r.tokens {
+		if exp.Before(now) {
+			delete(r.tokens, jti)
+		}
+	}
+	r.tokens[c.ID] = c.ExpiresAt
+	return nil
+}
+
+// RevokeAllBefore invalidates every token of the user issued at or before at.
+func (r *Revocations) RevokeAllBefore(ctx context.Context, userID int, at time.Time) error {
+	at = at.UTC().Truncate(time.Millisecond)
+	if err := r.repo.SetUserCutoff(ctx, userID, at); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cutoffs[userID] = at
+	return nil
+}
+
+func (r *Revocations) IsRevoked(c *Claims) bool {
+	r.mu.RLock()
+	cutoff, hasCutoff := r.cutoffs[c.UserID]
+	_, revoked := r.tokens[c.ID]
+	r.mu.RUnlock()
+
+	if hasCutoff && !c.IssuedAt.After(cutoff) {
+		return true
+	}
+	return c.ID != "" && revoked
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+const (
+	ScopeTasksRead      = "tasks:read"
+	ScopeTasksWrite     = "tasks:write"
+	ScopeWebhooksManage = "webhooks:manage"
+	ScopeAPIKeysManage  = "api_keys:manage"
+	ScopeAccountManage  = "account:manage"
+	ScopeWorkspaces     = "workspaces:manage"
+
+	// ScopeMFAPending marks a token that only proves the password step of a
+	// login with 2FA. It is deliberately not part of AllScopes.
+	ScopeMFAPending = "mfa:pending"
+)
+
+// AllScopes is what an interactive login gets.
+var AllScopes = []string{
+	ScopeTasksRead,
+	ScopeTasksWrite,
+	ScopeWebhooksManage,
+	ScopeAPIKeysManage,
+	ScopeAccountManage,
+	ScopeWorkspaces,
+}
+
+func IsValidScope(scope string) bool {
+	return slices.Contains(AllScopes, scope)
+}
+
+// HasScopes reports whether granted contains every required scope.
+func HasScopes(granted []string, required ...string) bool {
+	for _, sc := range required {
+		if !slices.Contains(granted, sc) {
+			return false
+		}
+	}
+	return true
+}
+
+// RequireScopes rejects requests whose credentials lack any of the scopes
+// with 403 INSUFFICIENT_SCOPE. It must run after JWTMiddleware.
+func RequireScopes(scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			granted, _ := ScopesFromContext(r.Context())
+			if !HasScopes(granted, scopes...) {
+				WriteInsufficientScope(w, scopes)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// WriteInsufficientScope writes the 403 response in the API error format.
+func WriteInsufficientScope(w http.ResponseWriter, required []string) {
+	type apiError struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	scope := strings.Join(required, " ")
+
+	w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+scope+`"`)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	_ = json.NewEncoder(w).Encode(map[string]apiError{
+		"error": {
+			Code:    "INSUFFICIENT_SCOPE",
+			Message: "required scope: " + scope,
+		},
+	})
+}
@@ -0,0 +1,31 @@
+package sqlite
+
+import "database/sql"
+
+func Migrate(db *sql.DB) error {
+	const q = `
+	CREATE TABLE IF NOT EXISTS refresh_tokens(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	family_id TEXT NOT NULL,
+	token_hash TEXT NOT NULL UNIQUE,
+	expires_at TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	used_at TEXT NULL,
+	revoked_at TEXT NULL);
+	CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+	CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens(user_id);
+
+	CREATE TABLE IF NOT EXISTS revoked_tokens(
+	jti TEXT PRIMARY KEY,
+	user_id INTEGER NOT NULL,
+	expires_at TEXT NOT NULL,
+	revoked_at TEXT NOT NULL);
+
+	CREATE TABLE IF NOT EXISTS user_token_cutoffs(
+	user_id INTEGER PRIMARY KEY,
+	cutoff_at TEXT NOT NULL);
+	`
+	_, err := db.Exec(q)
+	return err
+}
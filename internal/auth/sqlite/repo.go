@@ -0,0 +1,137 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"task_scheduler/internal/auth"
+	"time"
+)
+
+type Repo struct {
+	db *sql.DB
+}
+
+func New(db *sql.DB) *Repo {
+	return &Repo{db: db}
+}
+
+func (r *Repo) Create(ctx context.Context, t *auth.RefreshToken) error {
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, created_at)
+		 VALUES (?, ?, ?, ?, ?)`,
+		t.UserID,
+		t.FamilyID,
+		t.TokenHash,
+		t.ExpiresAt.UTC().Format(time.RFC3339Nano),
+		t.CreatedAt.UTC().Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	t.ID = int(id)
+	return nil
+}
+
+func (r *Repo) GetByHash(ctx context.Context, hash string) (*auth.RefreshToken, error) {
+	var (
+		t            auth.RefreshToken
+		expiresAtStr string
+		createdAtStr string
+		usedAt       sql.NullString
+		revokedAt    sql.NullString
+	)
+
+	err := r.db.QueryRowContext(ctx,
+		`SELECT id, user_id, family_id, token_hash, expires_at, created_at, used_at, revoked_at
+		 FROM refresh_tokens
+		 WHERE token_hash = ?`,
+		hash,
+	).Scan(
+		&t.ID,
+		&t.UserID,
+		&t.FamilyID,
+		&t.TokenHash,
+		&expiresAtStr,
+		&createdAtStr,
+		&usedAt,
+		&revokedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, auth.ErrRefreshNotFound
+		}
+		return nil, err
+	}
+
+	if t.ExpiresAt, err = time.Parse(time.RFC3339Nano, expiresAtStr); err != nil {
+		return nil, err
+	}
+	if t.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAtStr); err != nil {
+		return nil, err
+	}
+	if t.UsedAt, err = parseNullTime(usedAt); err != nil {
+		return nil, err
+	}
+	if t.RevokedAt, err = parseNullTime(revokedAt); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *Repo) MarkUsed(ctx context.Context, id int, at time.Time) (bool, error) {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE refresh_tokens SET used_at = ?
+		 WHERE id = ? AND used_at IS NULL AND revoked_at IS NULL`,
+		at.UTC().Format(time.RFC3339Nano),
+		id,
+	)
+	if err != nil {
+		return false, err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return aff == 1, nil
+}
+
+func (r *Repo) RevokeFamily(ctx context.Context, familyID string, at time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at = ?
+		 WHERE family_id = ? AND revoked_at IS NULL`,
+		at.UTC().Format(time.RFC3339Nano),
+		familyID,
+	)
+	return err
+}
+
+func (r *Repo) RevokeUser(ctx context.Context, userID int, at time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE refresh_tokens SET revoked_at = ?
+		 WHERE user_id = ? AND revoked_at IS NULL`,
+		at.UTC().Format(time.RFC3339Nano),
+		userID,
+	)
+	return err
+}
+
+func (r *Repo) DeleteUser(ctx context.Context, userID int) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM refresh_tokens WHERE user_id = ?`, userID)
+	return err
+}
+
+func parseNullTime(s sql.NullString) (*time.Time, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
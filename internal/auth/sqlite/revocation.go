@@ -0,0 +1,91 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+)
+
+func (r *Repo) RevokeToken(ctx context.Context, jti string, userID int, expiresAt time.Time) error {
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+
+	// истёкшие записи больше не нужны — JWT и так не пройдёт проверку exp
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM revoked_tokens WHERE expires_at < ?`, now); err != nil {
+		return err
+	}
+
+	_, err := r.db.ExecContext(ctx,
+		`INSERT OR IGNORE INTO revoked_tokens (jti, user_id, expires_at, revoked_at)
+		 VALUES (?, ?, ?, ?)`,
+		jti,
+		userID,
+		expiresAt.UTC().Format(time.RFC3339Nano),
+		now,
+	)
+	return err
+}
+
+func (r *Repo) ListRevokedTokens(ctx context.Context, now time.Time) (map[string]time.Time, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT jti, expires_at FROM revoked_tokens WHERE expires_at >= ?`,
+		now.UTC().Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := make(map[string]time.Time)
+	for rows.Next() {
+		var jti, expStr string
+		if err := rows.Scan(&jti, &expStr); err != nil {
+			return nil, err
+		}
+		exp, err := time.Parse(time.RFC3339Nano, expStr)
+		if err != nil {
+			return nil, err
+		}
+		tokens[jti] = exp
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (r *Repo) SetUserCutoff(ctx context.Context, userID int, at time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO user_token_cutoffs (user_id, cutoff_at) VALUES (?, ?)
+		 ON CONFLICT(user_id) DO UPDATE SET cutoff_at = excluded.cutoff_at`,
+		userID,
+		at.UTC().Format(time.RFC3339Nano),
+	)
+	return err
+}
+
+func (r *Repo) ListUserCutoffs(ctx context.Context) (map[int]time.Time, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT user_id, cutoff_at FROM user_token_cutoffs`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cutoffs := make(map[int]time.Time)
+	for rows.Next() {
+		var (
+			userID int
+			atStr  string
+		)
+		if err := rows.Scan(&userID, &atStr); err != nil {
+			return nil, err
+		}
+		at, err := time.Parse(time.RFC3339Nano, atStr)
+		if err != nil {
+			return nil, err
+		}
+		cutoffs[userID] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return cutoffs, nil
+}
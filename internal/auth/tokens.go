@@ -0,0 +1,253 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// TokenPair is what a client gets after login or refresh.
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+}
+
+const (
+	mfaPendingTTL          = 5 * time.Minute
+	maxMFAAttemptsPerToken = 5
+)
+
+// TokenService issues short-lived access tokens together with rotating
+// refresh tokens, and revokes them on logout.
+type TokenService struct {
+	jwt         *JWTManager
+	repo        RefreshRepo
+	revocations *Revocations
+	refreshTTL  time.Duration
+
+	// неудачные попытки ввода кода по jti mfa pending токена
+	mfaMu       sync.Mutex
+	mfaFailures map[string]mfaFailure
+}
+
+type mfaFailure struct {
+	count     int
+	expiresAt time.Time
+}
+
+func NewTokenService(jwtManager *JWTManager, repo RefreshRepo, revocations *Revocations, refreshTTL time.Duration) *TokenService {
+	return &TokenService{
+		jwt:         jwtManager,
+		repo:        repo,
+		revocations: revocations,
+		refreshTTL:  refreshTTL,
+		mfaFailures: make(map[string]mfaFailure),
+	}
+}
+
+// Issue starts a new refresh token family for the user.
+func (s *TokenService) Issue(ctx context.Context, userID int) (*TokenPair, error) {
+	family, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	return s.issue(ctx, userID, family)
+}
+
+// Refresh exchanges a refresh token for a new pair. Each refresh token can be
+// used once; presenting a used one revokes its whole family.
+func (s *TokenService) Refresh(ctx context.Context, raw string) (*TokenPair, error) {
+	if raw == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+	now := time.Now().UTC()
+
+	// 1) ищем токен по хешу
+	rt, err := s.repo.GetByHash(ctx, hashToken(raw))
+	if err != nil {
+		if errors.Is(err, ErrRefreshNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	// 2) отозван (logout) — просто невалиден;
+	// повторное использование => отзываем всю цепочку
+	if rt.RevokedAt != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if rt.UsedAt != nil {
+		if err := s.repo.RevokeFamily(ctx, rt.FamilyID, now); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+	if !now.Before(rt.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// 3) помечаем использованным (гонка двух запросов тоже считается reuse)
+	ok, err := s.repo.MarkUsed(ctx, rt.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if err := s.repo.RevokeFamily(ctx, rt.FamilyID, now); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	// 4) выдаём новую пару в той же семье
+	return s.issue(ctx, rt.UserID, rt.FamilyID)
+}
+
+// IssueMFAPending returns a short-lived token proving that the password step
+// of a 2FA login succeeded. It can only be exchanged via CompleteMFA.
+func (s *TokenService) IssueMFAPending(userID int) (string, error) {
+	return s.jwt.GenerateWithTTL(userID, []string{ScopeMFAPending}, mfaPendingTTL)
+}
+
+// ParseMFAPending validates an MFA pending token.
+func (s *TokenService) ParseMFAPending(tokenStr string) (*Claims, error) {
+	claims, err := s.jwt.Parse(tokenStr)
+	if err != nil || claims.ID == "" || !slices.Equal(claims.Scopes, []string{ScopeMFAPending}) {
+		return nil, ErrInvalidMFAToken
+	}
+	if s.revocations.IsRevoked(claims) {
+		return nil, ErrInvalidMFAToken
+	}
+	return claims, nil
+}
+
+// CompleteMFA burns the pending token and issues a regular token pair.
+func (s *TokenService) CompleteMFA(ctx context.Context, pending *Claims) (*TokenPair, error) {
+	if err := s.revocations.RevokeToken(ctx, pending); err != nil {
+		return nil, err
+	}
+	s.mfaMu.Lock()
+	delete(s.mfaFailures, pending.ID)
+	s.mfaMu.Unlock()
+
+	return s.Issue(ctx, pending.UserID)
+}
+
+// RecordMFAFailure counts a wrong code; after too many the pending token is
+// revoked and the user has to enter the password again.
+func (s *TokenService) RecordMFAFailure(ctx context.Context, pending *Claims) error {
+	now := time.Now()
+
+	s.mfaMu.Lock()
+	// счётчики истёкших токенов больше не нужны
+	for jti, f := range s.mfaFailures {
+		if now.After(f.expiresAt) {
+			delete(s.mfaFailures, jti)
+		}
+	}
+	f := s.mfaFailures[pending.ID]
+	f.count++
+	f.expiresAt = pending.ExpiresAt
+	s.mfaFailures[pending.ID] = f
+	exhausted := f.count >= maxMFAAttemptsPerToken
+	if exhausted {
+		delete(s.mfaFailures, pending.ID)
+	}
+	s.mfaMu.Unlock()
+
+	if !exhausted {
+		return nil
+	}
+	return s.revocations.RevokeToken(ctx, pending)
+}
+
+// Logout revokes the access token described by claims and, if given, the
+// refresh token family it belongs to.
+func (s *TokenService) Logout(ctx context.Context, claims *Claims, refreshRaw string) error {
+	if err := s.revocations.RevokeToken(ctx, claims); err != nil {
+		return err
+	}
+	if refreshRaw == "" {
+		return nil
+	}
+
+	rt, err := s.repo.GetByHash(ctx, hashToken(refreshRaw))
+	if err != nil {
+		if errors.Is(err, ErrRefreshNotFound) {
+			return nil
+		}
+		return err
+	}
+	// чужой refresh токен не трогаем
+	if rt.UserID != claims.UserID {
+		return nil
+	}
+	return s.repo.RevokeFamily(ctx, rt.FamilyID, time.Now().UTC())
+}
+
+// LogoutAll invalidates every access and refresh token issued to the user so far.
+func (s *TokenService) LogoutAll(ctx context.Context, userID int) error {
+	now := time.Now().UTC()
+	if err := s.revocations.RevokeAllBefore(ctx, userID, now); err != nil {
+		return err
+	}
+	return s.repo.RevokeUser(ctx, userID, now)
+}
+
+// DeleteAccountData ends the sessions of an account being deleted and drops
+// its refresh tokens. The cutoff stays: it keeps issued JWTs invalid.
+func (s *TokenService) DeleteAccountData(ctx context.Context, userID int) error {
+	if err := s.LogoutAll(ctx, userID); err != nil {
+		return err
+	}
+	return s.repo.DeleteUser(ctx, userID)
+}
+
+func (s *TokenService) issue(ctx context.Context, userID int, family string) (*TokenPair, error) {
+	access, err := s.jwt.Generate(userID, AllScopes)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	rt := &RefreshToken{
+		UserID:    userID,
+		FamilyID:  family,
+		TokenHash: hashToken(raw),
+		ExpiresAt: now.Add(s.refreshTTL),
+		CreatedAt: now,
+	}
+	if err := s.repo.Create(ctx, rt); err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: raw,
+		ExpiresIn:    s.jwt.ttl,
+	}, nil
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken hashes high-entropy opaque tokens; bcrypt is unnecessary here.
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,217 @@
+package automation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"task_scheduler/internal/task"
+	"task_scheduler/internal/webhook"
+	"time"
+)
+
+const (
+	// maxChain bounds how many rules may fire one after another from a
+	// single change; a rule also never fires twice in the same chain.
+	maxChain = 5
+	// dueBatch bounds one query of SweepDue.
+	dueBatch = 100
+)
+
+// Engine runs the rules. It observes the task service, which it also uses
+// to act, so the service is set after both are built (SetTasks).
+// Webhooks are sent in the background; Close waits for them.
+type Engine struct {
+	repo   Repo
+	tasks  task.Service
+	client *http.Client
+	now    func() time.Time
+
+	hooks   chan delivery
+	workers sync.WaitGroup
+	mu      sync.RWMutex
+	closed  bool
+}
+
+// NewEngine uses client for webhooks; nil means WebhookClient with a 10s
+// timeout.
+func NewEngine(repo Repo, client *http.Client) *Engine {
+	if client == nil {
+		client = WebhookClient(10 * time.Second)
+	}
+	e := &Engine{repo: repo, client: client, now: time.Now, hooks: make(chan delivery, webhookQueue)}
+	e.workers.Add(webhookWorkers)
+	for range webhookWorkers {
+		go e.deliverWebhooks()
+	}
+	return e
+}
+
+// SetTasks must be called before the engine sees any event.
+func (e *Engine) SetTasks(tasks task.Service) {
+	e.tasks = tasks
+}
+
+type chainKey struct{}
+
+// chainFrom lists the rules that led to the current change, oldest first.
+func chainFrom(ctx context.Context) []int {
+	chain, _ := ctx.Value(chainKey{}).([]int)
+	return chain
+}
+
+// TaskChanged runs the rules covering the task for the event: the owner's
+// personal rules, or the rules of the task's workspace. Failures are logged:
+// the change itself is already saved.
+func (e *Engine) TaskChanged(ctx context.Context, ev task.Event) {
+	// действия не должны обрываться вместе с http-запросом
+	ctx = context.WithoutCancel(ctx)
+	chain := chainFrom(ctx)
+	if len(chain) >= maxChain {
+		log.Println("[AUTOMATION] chain of rules too long, stopped:", chain)
+		return
+	}
+
+	rules, err := e.repo.Enabled(ctx, ev.Task.UserID, ev.Task.WorkspaceID, ev.Type)
+	if err != nil {
+		log.Println("[AUTOMATION] load rules error:", err)
+		return
+	}
+	// правило workspace действует от имени автора, пока у того есть доступ
+	access := make(map[int]bool)
+	for _, r := range rules {
+		// правило уже сработало в этой цепочке — дальше был бы цикл
+		if slices.Contains(chain, r.ID) || !r.Matches(ev) {
+			continue
+		}
+		if r.WorkspaceID > 0 {
+			ok, seen := access[r.UserID]
+			if !seen {
+				ok = e.canAct(ctx, r.UserID, ev.Task.ID)
+				access[r.UserID] = ok
+			}
+			if !ok {
+				continue
+			}
+		}
+		e.run(context.WithValue(ctx, chainKey{}, append(slices.Clone(chain), r.ID)), r, ev)
+	}
+}
+
+// canAct reports whether the user still sees the task; an author who left
+// the workspace keeps their rules silent.
+func (e *Engine) canAct(ctx context.Context, userID, taskID int) bool {
+	if _, err := e.tasks.Get(ctx, userID, taskID); err != nil {
+		if !errors.Is(err, task.ErrNotFound) {
+			log.Println("[AUTOMATION] check access error:", err)
+		}
+		return false
+	}
+	return true
+}
+
+// SweepDue raises task.due for tasks whose due date has passed. Each
+// (rule, task, due date) is handled once, even if an action fails.
+func (e *Engine) SweepDue(ctx context.Context) error {
+	now := e.now().UTC()
+	for {
+		matches, err := e.repo.Due(ctx, now, dueBatch)
+		if err != nil {
+			return err
+		}
+		for _, m := range matches {
+			// отмечаем до запуска: лучше пропустить, чем выполнить дважды
+			if err := e.repo.MarkDue(ctx, m, now); err != nil {
+				return err
+			}
+			r, err := e.repo.Get(ctx, m.RuleID)
+			if err != nil {
+				if errors.Is(err, ErrNotFound) {
+					continue
+				}
+				return err
+			}
+			tsk, err := e.tasks.Get(ctx, m.UserID, m.TaskID)
+			if err != nil {
+				if errors.Is(err, task.ErrNotFound) {
+					continue
+				}
+				return err
+			}
+			ev := task.Event{Type: task.EventDue, Task: *tsk}
+			if r.Matches(ev) {
+				e.run(context.WithValue(ctx, chainKey{}, []int{r.ID}), *r, ev)
+			}
+		}
+		if len(matches) < dueBatch {
+			return nil
+		}
+	}
+}
+
+// run applies the actions in order; a failed one doesn't stop the rest.
+func (e *Engine) run(ctx context.Context, r Rule, ev task.Event) {
+	for _, a := range r.Actions {
+		if err := e.apply(ctx, r, a, ev); err != nil {
+			log.Println("[AUTOMATION] rule "+strconv.Itoa(r.ID)+" "+string(a.Type)+" on task "+strconv.Itoa(ev.Task.ID)+" error:", err)
+		}
+	}
+}
+
+func (e *Engine) apply(ctx context.Context, r Rule, a Action, ev task.Event) error {
+	switch a.Type {
+	case ActionSetStatus:
+		status := string(a.Status)
+		_, err := e.tasks.Update(ctx, r.UserID, ev.Task.ID, task.UpdateTaskInput{Status: &status})
+		return err
+	case ActionCreateTask:
+		in := task.CreateTaskInput{
+			Title:       strings.ReplaceAll(a.Title, "{{title}}", ev.Task.Title),
+			Description: a.Description,
+		}
+		if a.DueIn != "" {
+			d, err := time.ParseDuration(a.DueIn)
+			if err != nil {
+				return err
+			}
+			// правила, сохранённые до проверки в validate, не должны зацикливаться
+			if ev.Type == task.EventDue && d <= 0 {
+				return fmt.Errorf("automation: due_in %s would fire the rule again", a.DueIn)
+			}
+			due := e.now().UTC().Add(d)
+			in.DueAt = &due
+		}
+		_, err := e.tasks.Create(ctx, r.UserID, ev.Task.WorkspaceID, in)
+		return err
+	case ActionWebhook:
+		return e.enqueue(delivery{rule: r, action: a, ev: ev})
+	default:
+		return fmt.Errorf("automation: unknown action %q", a.Type)
+	}
+}
+
+// webhookPayload is the body of a webhook action.
+type webhookPayload struct {
+	Event      task.EventType `json:"event"`
+	RuleID     int            `json:"rule_id"`
+	Rule       string         `json:"rule"`
+	Task       task.Task      `json:"task"`
+	PrevStatus task.Status    `json:"previous_status,omitempty"`
+	SentAt     time.Time      `json:"sent_at"`
+}
+
+func (e *Engine) webhook(ctx context.Context, r Rule, a Action, ev task.Event) error {
+	return webhook.Send(ctx, e.client, a.URL, a.Secret, webhookPayload{
+		Event:      ev.Type,
+		RuleID:     r.ID,
+		Rule:       r.Name,
+		Task:       ev.Task,
+		PrevStatus: ev.PrevStatus,
+		SentAt:     e.now().UTC(),
+	})
+}
@@ -0,0 +1,40 @@
+package automation
+
+import (
+	"context"
+	"errors"
+	"task_scheduler/internal/task"
+	"time"
+)
+
+var ErrNotFound = errors.New("automation rule not found")
+
+// DueMatch is a task whose due date has passed, paired with a task.due rule
+// covering it that hasn't seen that due date yet. UserID is who the rule
+// acts as.
+type DueMatch struct {
+	RuleID int
+	UserID int
+	TaskID int
+	DueAt  time.Time
+}
+
+type Repo interface {
+	Create(ctx context.Context, r *Rule) error
+	Get(ctx context.Context, id int) (*Rule, error)
+	// List and Count cover the workspace's rules, or the user's personal
+	// ones if workspaceID is 0.
+	List(ctx context.Context, userID, workspaceID int) ([]Rule, error)
+	Count(ctx context.Context, userID, workspaceID int) (int, error)
+	Update(ctx context.Context, r *Rule) error
+	Delete(ctx context.Context, id int) error
+	// Enabled lists the enabled rules with the trigger that cover tasks of
+	// the workspace, or the user's personal tasks if workspaceID is 0.
+	Enabled(ctx context.Context, userID, workspaceID int, trigger task.EventType) ([]Rule, error)
+	// Due finds pending tasks due by now with the rules covering them. Only
+	// due dates after the rule was last saved count, so a new rule doesn't
+	// fire on old tasks.
+	Due(ctx context.Context, now time.Time, limit int) ([]DueMatch, error)
+	// MarkDue records the match so Due doesn't return it again.
+	MarkDue(ctx context.Context, m DueMatch, at time.Time) error
+}
@@ -0,0 +1,373 @@
+// Package automation runs "when X then Y" rules of users and workspaces on
+// task events: a trigger, conditions over task fields and a list of actions.
+package automation
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"task_scheduler/internal/task"
+	"time"
+)
+
+var ErrInvalidRule = errors.New("invalid rule")
+
+const (
+	maxNameLen    = 100
+	maxConditions = 20
+	maxActions    = 10
+	maxDueIn      = 366 * 24 * time.Hour
+)
+
+// Rule is personal (WorkspaceID 0) and runs on its owner's personal tasks,
+// or belongs to a workspace and runs on the workspace's tasks. Either way it
+// acts as UserID: the owner, or whoever last saved the workspace rule.
+type Rule struct {
+	ID          int            `json:"id"`
+	UserID      int            `json:"user_id"`
+	WorkspaceID int            `json:"workspace_id"`
+	Name        string         `json:"name"`
+	Enabled     bool           `json:"enabled"`
+	Trigger     task.EventType `json:"trigger"`
+	Conditions  []Condition    `json:"conditions"`
+	Actions     []Action       `json:"actions"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// covers reports whether the rule runs on the task's events.
+func (r Rule) covers(t task.Task) bool {
+	if r.WorkspaceID > 0 {
+		return t.WorkspaceID == r.WorkspaceID
+	}
+	return t.WorkspaceID == 0 && t.UserID == r.UserID
+}
+
+// Redacted returns a copy of the rule without webhook secrets. The secret is
+// shown only in the response that creates the rule.
+func (r Rule) Redacted() Rule {
+	actions := make([]Action, len(r.Actions))
+	for i, a := range r.Actions {
+		a.Secret = ""
+		actions[i] = a
+	}
+	r.Actions = actions
+	return r
+}
+
+type Op string
+
+const (
+	OpEq       Op = "eq"
+	OpNe       Op = "ne"
+	OpContains Op = "contains"
+	OpGt       Op = "gt"
+	OpLt       Op = "lt"
+	OpEmpty    Op = "empty"
+	OpNotEmpty Op = "not_empty"
+)
+
+// Condition compares a task field with Value. Strings compare
+// case-insensitively; due_at takes an RFC3339 value. empty and not_empty
+// take no value.
+type Condition struct {
+	Field string `json:"field"`
+	Op    Op     `json:"op"`
+	Value any    `json:"value,omitempty"`
+}
+
+type ActionType string
+
+const (
+	ActionSetStatus  ActionType = "set_status"
+	ActionCreateTask ActionType = "create_task"
+	ActionWebhook    ActionType = "webhook"
+)
+
+// Action is one step of a rule; which fields apply depends on Type.
+type Action struct {
+	Type ActionType `json:"type"`
+	// set_status
+	Status task.Status `json:"status,omitempty"`
+	// create_task: a follow-up next to the triggering task. "{{title}}" in
+	// Title is replaced with that task's title; DueIn is a duration from now.
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	DueIn       string `json:"due_in,omitempty"`
+	// webhook: POST JSON, signed with Secret in X-Signature-256 if set.
+	URL    string `json:"url,omitempty"`
+	Secret string `json:"secret,omitempty"`
+}
+
+type fieldKind int
+
+const (
+	kindString fieldKind = iota
+	kindNumber
+	kindTime
+	kindBool
+)
+
+// fields are what conditions can look at.
+var fields = map[string]fieldKind{
+	"title":            kindString,
+	"description":      kindString,
+	"status":           kindString,
+	"previous_status":  kindString,
+	"workspace_id":     kindNumber,
+	"assignee_id":      kindNumber,
+	"estimate_minutes": kindNumber,
+	"due_at":           kindTime,
+	"overdue":          kindBool,
+}
+
+func validTrigger(t task.EventType) bool {
+	switch t {
+	case task.EventCreated, task.EventStatusChanged, task.EventDue:
+		return true
+	}
+	return false
+}
+
+func validStatus(s task.Status) bool {
+	switch s {
+	case task.StatusPending, task.StatusDone, task.StatusCanceled:
+		return true
+	}
+	return false
+}
+
+// validate normalizes the rule and reports the first problem.
+func (r *Rule) validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" || len(r.Name) > maxNameLen {
+		return fmt.Errorf("%w: name is required, up to %d characters", ErrInvalidRule, maxNameLen)
+	}
+	if !validTrigger(r.Trigger) {
+		return fmt.Errorf("%w: unknown trigger %q", ErrInvalidRule, r.Trigger)
+	}
+	if len(r.Conditions) > maxConditions {
+		return fmt.Errorf("%w: at most %d conditions", ErrInvalidRule, maxConditions)
+	}
+	if len(r.Actions) == 0 || len(r.Actions) > maxActions {
+		return fmt.Errorf("%w: 1 to %d actions", ErrInvalidRule, maxActions)
+	}
+	if r.Conditions == nil {
+		r.Conditions = []Condition{}
+	}
+	for _, c := range r.Conditions {
+		if err := c.validate(); err != nil {
+			return err
+		}
+	}
+	for _, a := range r.Actions {
+		if err := a.validate(); err != nil {
+			return err
+		}
+		// задача, созданная со сроком "сейчас", тут же снова запустит это
+		// правило — и так без конца
+		if r.Trigger == task.EventDue && a.Type == ActionCreateTask && a.DueIn != "" {
+			if d, _ := time.ParseDuration(a.DueIn); d <= 0 {
+				return fmt.Errorf("%w: due_in of a task.due rule must be positive", ErrInvalidRule)
+			}
+		}
+	}
+	return nil
+}
+
+func (c Condition) validate() error {
+	kind, ok := fields[c.Field]
+	if !ok {
+		return fmt.Errorf("%w: unknown field %q", ErrInvalidRule, c.Field)
+	}
+	bad := fmt.Errorf("%w: op %q with value %v doesn't fit field %s", ErrInvalidRule, c.Op, c.Value, c.Field)
+	switch c.Op {
+	case OpEmpty, OpNotEmpty:
+		if c.Value != nil {
+			return bad
+		}
+		return nil
+	case OpEq, OpNe:
+		if kind == kindTime {
+			return bad
+		}
+	case OpContains:
+		if kind != kindString {
+			return bad
+		}
+	case OpGt, OpLt:
+		if kind != kindNumber && kind != kindTime {
+			return bad
+		}
+	default:
+		return fmt.Errorf("%w: unknown op %q", ErrInvalidRule, c.Op)
+	}
+
+	switch kind {
+	case kindString:
+		if _, ok := c.Value.(string); !ok {
+			return bad
+		}
+	case kindNumber:
+		if _, ok := c.Value.(float64); !ok {
+			return bad
+		}
+	case kindTime:
+		s, _ := c.Value.(string)
+		if _, err := time.Parse(time.RFC3339, s); err != nil {
+			return bad
+		}
+	case kindBool:
+		if _, ok := c.Value.(bool); !ok {
+			return bad
+		}
+	}
+	return nil
+}
+
+func (a Action) validate() error {
+	switch a.Type {
+	case ActionSetStatus:
+		if !validStatus(a.Status) {
+			return fmt.Errorf("%w: set_status needs a status", ErrInvalidRule)
+		}
+	case ActionCreateTask:
+		if strings.TrimSpace(a.Title) == "" || len(a.Description) > task.MaxDescriptionLength {
+			return fmt.Errorf("%w: create_task needs a title", ErrInvalidRule)
+		}
+		if a.DueIn != "" {
+			d, err := time.ParseDuration(a.DueIn)
+			if err != nil || d < 0 || d > maxDueIn {
+				return fmt.Errorf("%w: due_in must be a duration like 48h", ErrInvalidRule)
+			}
+		}
+	case ActionWebhook:
+		u, err := url.Parse(a.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w: webhook needs an http(s) url", ErrInvalidRule)
+		}
+	default:
+		return fmt.Errorf("%w: unknown action %q", ErrInvalidRule, a.Type)
+	}
+	return nil
+}
+
+// Matches reports whether the event fires the rule.
+func (r *Rule) Matches(ev task.Event) bool {
+	if ev.Type != r.Trigger {
+		return false
+	}
+	for _, c := range r.Conditions {
+		if !c.Matches(ev) {
+			return false
+		}
+	}
+	return true
+}
+
+// Matches evaluates the condition on the event's task.
+func (c Condition) Matches(ev task.Event) bool {
+	v := fieldValue(ev, c.Field)
+	switch c.Op {
+	case OpEmpty:
+		return v == nil
+	case OpNotEmpty:
+		return v != nil
+	}
+	// у пустого поля нет значения: равно ничему, отлично от всего
+	if v == nil {
+		return c.Op == OpNe
+	}
+
+	switch v := v.(type) {
+	case string:
+		want, _ := c.Value.(string)
+		switch c.Op {
+		case OpEq:
+			return strings.EqualFold(v, want)
+		case OpNe:
+			return !strings.EqualFold(v, want)
+		case OpContains:
+			return strings.Contains(strings.ToLower(v), strings.ToLower(want))
+		}
+	case float64:
+		want, _ := c.Value.(float64)
+		switch c.Op {
+		case OpEq:
+			return v == want
+		case OpNe:
+			return v != want
+		case OpGt:
+			return v > want
+		case OpLt:
+			return v < want
+		}
+	case time.Time:
+		s, _ := c.Value.(string)
+		want, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return false
+		}
+		switch c.Op {
+		case OpGt:
+			return v.After(want)
+		case OpLt:
+			return v.Before(want)
+		}
+	case bool:
+		want, _ := c.Value.(bool)
+		switch c.Op {
+		case OpEq:
+			return v == want
+		case OpNe:
+			return v != want
+		}
+	}
+	return false
+}
+
+// fieldValue is the field as string, float64, time.Time or bool; nil when
+// it isn't set.
+func fieldValue(ev task.Event, field string) any {
+	t := ev.Task
+	text := func(s string) any {
+		if s == "" {
+			return nil
+		}
+		return s
+	}
+	number := func(n *int) any {
+		if n == nil {
+			return nil
+		}
+		return float64(*n)
+	}
+	switch field {
+	case "title":
+		return text(t.Title)
+	case "description":
+		return text(t.Description)
+	case "status":
+		return text(string(t.Status))
+	case "previous_status":
+		return text(string(ev.PrevStatus))
+	case "workspace_id":
+		if t.WorkspaceID == 0 {
+			return nil
+		}
+		return float64(t.WorkspaceID)
+	case "assignee_id":
+		return number(t.AssigneeID)
+	case "estimate_minutes":
+		return number(t.EstimateMinutes)
+	case "due_at":
+		if t.DueAt == nil {
+			return nil
+		}
+		return *t.DueAt
+	case "overdue":
+		return t.Overdue
+	}
+	return nil
+}
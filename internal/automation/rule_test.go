@@ -0,0 +1,175 @@
+package automation
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"task_scheduler/internal/task"
+)
+
+// condition decodes the JSON form, so values arrive as an API client sends them.
+func condition(t *testing.T, raw string) Condition {
+	t.Helper()
+	var c Condition
+	require.NoError(t, json.Unmarshal([]byte(raw), &c))
+	return c
+}
+
+func TestCondition_Matches(t *testing.T) {
+	due := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	estimate, assignee := 45, 7
+	full := task.Event{
+		Type:       task.EventStatusChanged,
+		PrevStatus: task.StatusPending,
+		Task: task.Task{
+			Title:           "Send Invoice",
+			Description:     "for ACME",
+			Status:          task.StatusDone,
+			WorkspaceID:     3,
+			AssigneeID:      &assignee,
+			EstimateMinutes: &estimate,
+			DueAt:           &due,
+			Overdue:         true,
+		},
+	}
+	empty := task.Event{Type: task.EventCreated, Task: task.Task{Title: "Bare", Status: task.StatusPending}}
+
+	tests := []struct {
+		name string
+		cond string
+		ev   task.Event
+		want bool
+	}{
+		{"string eq ignores case", `{"field":"title","op":"eq","value":"send invoice"}`, full, true},
+		{"string eq", `{"field":"title","op":"eq","value":"other"}`, full, false},
+		{"string ne", `{"field":"title","op":"ne","value":"other"}`, full, true},
+		{"contains ignores case", `{"field":"description","op":"contains","value":"acme"}`, full, true},
+		{"contains", `{"field":"description","op":"contains","value":"globex"}`, full, false},
+		{"status", `{"field":"status","op":"eq","value":"done"}`, full, true},
+		{"previous status", `{"field":"previous_status","op":"eq","value":"pending"}`, full, true},
+		{"number gt", `{"field":"estimate_minutes","op":"gt","value":30}`, full, true},
+		{"number lt", `{"field":"estimate_minutes","op":"lt","value":30}`, full, false},
+		{"number eq", `{"field":"assignee_id","op":"eq","value":7}`, full, true},
+		{"workspace", `{"field":"workspace_id","op":"eq","value":3}`, full, true},
+		{"time gt", `{"field":"due_at","op":"gt","value":"2026-02-28T00:00:00Z"}`, full, true},
+		{"time lt", `{"field":"due_at","op":"lt","value":"2026-02-28T00:00:00Z"}`, full, false},
+		{"time with offset", `{"field":"due_at","op":"lt","value":"2026-03-01T13:30:00+01:00"}`, full, true},
+		{"bool eq", `{"field":"overdue","op":"eq","value":true}`, full, true},
+		{"bool ne", `{"field":"overdue","op":"ne","value":true}`, full, false},
+		{"not empty", `{"field":"due_at","op":"not_empty"}`, full, true},
+		{"empty", `{"field":"due_at","op":"empty"}`, empty, true},
+		{"empty workspace is 0", `{"field":"workspace_id","op":"empty"}`, empty, true},
+		{"unset field equals nothing", `{"field":"estimate_minutes","op":"eq","value":0}`, empty, false},
+		{"unset field differs from anything", `{"field":"description","op":"ne","value":"x"}`, empty, true},
+		{"unset field is not greater", `{"field":"assignee_id","op":"gt","value":0}`, empty, false},
+		{"missing previous status", `{"field":"previous_status","op":"empty"}`, empty, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := condition(t, tt.cond)
+			require.NoError(t, c.validate())
+			require.Equal(t, tt.want, c.Matches(tt.ev))
+		})
+	}
+}
+
+func TestCondition_Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		cond string
+		ok   bool
+	}{
+		{"string", `{"field":"title","op":"contains","value":"x"}`, true},
+		{"number from json", `{"field":"estimate_minutes","op":"gt","value":30}`, true},
+		{"fractional number", `{"field":"estimate_minutes","op":"lt","value":30.5}`, true},
+		{"rfc3339 time", `{"field":"due_at","op":"gt","value":"2026-03-01T12:00:00Z"}`, true},
+		{"bool", `{"field":"overdue","op":"eq","value":false}`, true},
+		{"empty without value", `{"field":"title","op":"empty"}`, true},
+		{"unknown field", `{"field":"color","op":"eq","value":"red"}`, false},
+		{"unknown op", `{"field":"title","op":"like","value":"x"}`, false},
+		{"number as string", `{"field":"estimate_minutes","op":"gt","value":"30"}`, false},
+		{"string as number", `{"field":"title","op":"eq","value":1}`, false},
+		{"bool as string", `{"field":"overdue","op":"eq","value":"true"}`, false},
+		{"date without time", `{"field":"due_at","op":"gt","value":"2026-03-01"}`, false},
+		{"eq on time", `{"field":"due_at","op":"eq","value":"2026-03-01T12:00:00Z"}`, false},
+		{"gt on string", `{"field":"title","op":"gt","value":"a"}`, false},
+		{"contains on number", `{"field":"assignee_id","op":"contains","value":1}`, false},
+		{"gt on bool", `{"field":"overdue","op":"gt","value":true}`, false},
+		{"empty with value", `{"field":"title","op":"empty","value":"x"}`, false},
+		{"missing value", `{"field":"title","op":"eq"}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := condition(t, tt.cond).validate()
+			if tt.ok {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrInvalidRule)
+		})
+	}
+}
+
+func TestRule_Validate(t *testing.T) {
+	setDone := Action{Type: ActionSetStatus, Status: task.StatusDone}
+	tests := []struct {
+		name string
+		rule Rule
+		ok   bool
+	}{
+		{"minimal", Rule{Name: "r", Trigger: task.EventCreated, Actions: []Action{setDone}}, true},
+		{"name is trimmed", Rule{Name: "   ", Trigger: task.EventCreated, Actions: []Action{setDone}}, false},
+		{"long name", Rule{Name: strings.Repeat("n", maxNameLen+1), Trigger: task.EventCreated, Actions: []Action{setDone}}, false},
+		{"unknown trigger", Rule{Name: "r", Trigger: "task.deleted", Actions: []Action{setDone}}, false},
+		{"no actions", Rule{Name: "r", Trigger: task.EventCreated}, false},
+		{"too many actions", Rule{Name: "r", Trigger: task.EventCreated, Actions: make([]Action, maxActions+1)}, false},
+		{"too many conditions", Rule{Name: "r", Trigger: task.EventCreated, Conditions: make([]Condition, maxConditions+1), Actions: []Action{setDone}}, false},
+		{"bad condition", Rule{Name: "r", Trigger: task.EventCreated, Conditions: []Condition{{Field: "color", Op: OpEq, Value: "red"}}, Actions: []Action{setDone}}, false},
+		{"bad status", Rule{Name: "r", Trigger: task.EventCreated, Actions: []Action{{Type: ActionSetStatus, Status: "archived"}}}, false},
+		{"unknown action", Rule{Name: "r", Trigger: task.EventCreated, Actions: []Action{{Type: "email"}}}, false},
+		{"create_task", Rule{Name: "r", Trigger: task.EventCreated, Actions: []Action{{Type: ActionCreateTask, Title: "t", DueIn: "48h"}}}, true},
+		{"create_task without title", Rule{Name: "r", Trigger: task.EventCreated, Actions: []Action{{Type: ActionCreateTask, Title: " "}}}, false},
+		{"bad due_in", Rule{Name: "r", Trigger: task.EventCreated, Actions: []Action{{Type: ActionCreateTask, Title: "t", DueIn: "2 days"}}}, false},
+		{"negative due_in", Rule{Name: "r", Trigger: task.EventCreated, Actions: []Action{{Type: ActionCreateTask, Title: "t", DueIn: "-1h"}}}, false},
+		{"due_in over a year", Rule{Name: "r", Trigger: task.EventCreated, Actions: []Action{{Type: ActionCreateTask, Title: "t", DueIn: "9000h"}}}, false},
+		{"zero due_in on created", Rule{Name: "r", Trigger: task.EventCreated, Actions: []Action{{Type: ActionCreateTask, Title: "t", DueIn: "0s"}}}, true},
+		{"zero due_in on due", Rule{Name: "r", Trigger: task.EventDue, Actions: []Action{{Type: ActionCreateTask, Title: "t", DueIn: "0s"}}}, false},
+		{"no due_in on due", Rule{Name: "r", Trigger: task.EventDue, Actions: []Action{{Type: ActionCreateTask, Title: "t"}}}, true},
+		{"positive due_in on due", Rule{Name: "r", Trigger: task.EventDue, Actions: []Action{{Type: ActionCreateTask, Title: "t", DueIn: "24h"}}}, true},
+		{"webhook", Rule{Name: "r", Trigger: task.EventCreated, Actions: []Action{{Type: ActionWebhook, URL: "https://example.com/hook"}}}, true},
+		{"webhook without scheme", Rule{Name: "r", Trigger: task.EventCreated, Actions: []Action{{Type: ActionWebhook, URL: "example.com/hook"}}}, false},
+		{"webhook over ftp", Rule{Name: "r", Trigger: task.EventCreated, Actions: []Action{{Type: ActionWebhook, URL: "ftp://example.com"}}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.validate()
+			if tt.ok {
+				require.NoError(t, err)
+				require.NotNil(t, tt.rule.Conditions)
+				return
+			}
+			require.True(t, errors.Is(err, ErrInvalidRule), "got %v", err)
+		})
+	}
+}
+
+func TestRule_Matches(t *testing.T) {
+	r := Rule{
+		Trigger: task.EventCreated,
+		Conditions: []Condition{
+			{Field: "title", Op: OpContains, Value: "invoice"},
+			{Field: "due_at", Op: OpEmpty},
+		},
+	}
+	require.True(t, r.Matches(task.Event{Type: task.EventCreated, Task: task.Task{Title: "Invoice"}}))
+	// другое событие и любое несовпавшее условие — мимо
+	require.False(t, r.Matches(task.Event{Type: task.EventStatusChanged, Task: task.Task{Title: "Invoice"}}))
+	due := time.Now()
+	require.False(t, r.Matches(task.Event{Type: task.EventCreated, Task: task.Task{Title: "Invoice", DueAt: &due}}))
+	require.False(t, r.Matches(task.Event{Type: task.EventCreated, Task: task.Task{Title: "Report"}}))
+}
@@ -0,0 +1,283 @@
+package automation
+
+import (
+	"context"
+	"errors"
+	"task_scheduler/internal/task"
+	"task_scheduler/internal/workspace"
+	"time"
+)
+
+var (
+	ErrInvalidInput = errors.New("invalid input")
+	ErrTooManyRules = errors.New("too many automation rules")
+	ErrForbidden    = errors.New("forbidden")
+)
+
+// maxRules bounds the rules of one personal space or workspace.
+const maxRules = 50
+
+// RuleInput is what the user sends to create, replace or dry-run a rule.
+type RuleInput struct {
+	Name       string
+	Enabled    bool
+	Trigger    task.EventType
+	Conditions []Condition
+	Actions    []Action
+}
+
+// DryRun shows how a rule would handle an event, without running anything.
+type DryRun struct {
+	Matched    bool              `json:"matched"`
+	Conditions []ConditionResult `json:"conditions"`
+	// Actions are the ones that would run; empty unless Matched.
+	Actions []Action `json:"actions"`
+}
+
+type ConditionResult struct {
+	Condition
+	Matched bool `json:"matched"`
+}
+
+// Tasks is the part of the task service that dry runs need.
+type Tasks interface {
+	Get(ctx context.Context, userID, id int) (*task.Task, error)
+}
+
+// Workspaces reports workspace membership. It returns workspace.ErrNotMember
+// for users outside the workspace.
+type Workspaces interface {
+	MemberRole(ctx context.Context, workspaceID, userID int) (workspace.Role, error)
+}
+
+// Service keeps personal rules for their owner and workspace rules for the
+// workspace: every member sees them, admins and members change them.
+type Service interface {
+	// Create adds a personal rule, or a rule of the workspace if
+	// workspaceID isn't 0.
+	Create(ctx context.Context, userID, workspaceID int, in RuleInput) (*Rule, error)
+	List(ctx context.Context, userID, workspaceID int) ([]Rule, error)
+	Get(ctx context.Context, userID, id int) (*Rule, error)
+	// Update replaces the rule; a workspace rule then acts as the user who
+	// saved it. A webhook action sent without a secret keeps the one stored
+	// for the same URL, since responses never show it.
+	Update(ctx context.Context, userID, id int, in RuleInput) (*Rule, error)
+	Delete(ctx context.Context, userID, id int) error
+	// DryRun evaluates the rule against a task it would cover (a personal
+	// task of the user, or a task of the workspace) as if its trigger had
+	// just fired; prevStatus stands in for a status change.
+	DryRun(ctx context.Context, userID, workspaceID int, in RuleInput, taskID int, prevStatus task.Status) (*DryRun, error)
+}
+
+type automationService struct {
+	repo       Repo
+	tasks      Tasks
+	workspaces Workspaces
+}
+
+func NewService(repo Repo, tasks Tasks, workspaces Workspaces) Service {
+	return &automationService{repo: repo, tasks: tasks, workspaces: workspaces}
+}
+
+func (s *automationService) Create(ctx context.Context, userID, workspaceID int, in RuleInput) (*Rule, error) {
+	if userID <= 0 || workspaceID < 0 {
+		return nil, ErrInvalidInput
+	}
+	if err := s.canWrite(ctx, userID, workspaceID); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	r := newRule(userID, workspaceID, in, now)
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+	n, err := s.repo.Count(ctx, userID, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	if n >= maxRules {
+		return nil, ErrTooManyRules
+	}
+	if err := s.repo.Create(ctx, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (s *automationService) List(ctx context.Context, userID, workspaceID int) ([]Rule, error) {
+	if userID <= 0 || workspaceID < 0 {
+		return nil, ErrInvalidInput
+	}
+	if workspaceID > 0 {
+		if _, err := s.memberRole(ctx, userID, workspaceID); err != nil {
+			return nil, err
+		}
+	}
+	return s.repo.List(ctx, userID, workspaceID)
+}
+
+func (s *automationService) Get(ctx context.Context, userID, id int) (*Rule, error) {
+	if userID <= 0 || id <= 0 {
+		return nil, ErrInvalidInput
+	}
+	return s.get(ctx, userID, id)
+}
+
+func (s *automationService) Update(ctx context.Context, userID, id int, in RuleInput) (*Rule, error) {
+	if userID <= 0 || id <= 0 {
+		return nil, ErrInvalidInput
+	}
+	old, err := s.get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.canWrite(ctx, userID, old.WorkspaceID); err != nil {
+		return nil, err
+	}
+	r := newRule(userID, old.WorkspaceID, in, time.Now().UTC())
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+	r.ID = old.ID
+	r.CreatedAt = old.CreatedAt
+	keepSecrets(r.Actions, old.Actions)
+	if err := s.repo.Update(ctx, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// keepSecrets fills in the secrets of webhook actions that came without one
+// from the stored actions with the same URL.
+func keepSecrets(actions, stored []Action) {
+	for i, a := range actions {
+		if a.Type != ActionWebhook || a.Secret != "" {
+			continue
+		}
+		for _, prev := range stored {
+			if prev.Type == ActionWebhook && prev.URL == a.URL {
+				actions[i].Secret = prev.Secret
+				break
+			}
+		}
+	}
+}
+
+func (s *automationService) Delete(ctx context.Context, userID, id int) error {
+	if userID <= 0 || id <= 0 {
+		return ErrInvalidInput
+	}
+	r, err := s.get(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if err := s.canWrite(ctx, userID, r.WorkspaceID); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+func (s *automationService) DryRun(ctx context.Context, userID, workspaceID int, in RuleInput, taskID int, prevStatus task.Status) (*DryRun, error) {
+	if userID <= 0 || workspaceID < 0 || taskID <= 0 {
+		return nil, ErrInvalidInput
+	}
+	r := newRule(userID, workspaceID, in, time.Now().UTC())
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+	if prevStatus != "" && !validStatus(prevStatus) {
+		return nil, ErrInvalidInput
+	}
+	if workspaceID > 0 {
+		if _, err := s.memberRole(ctx, userID, workspaceID); err != nil {
+			return nil, err
+		}
+	}
+	tsk, err := s.tasks.Get(ctx, userID, taskID)
+	if err != nil {
+		return nil, err
+	}
+	// правило срабатывает только на задачи своего пространства
+	if !r.covers(*tsk) {
+		return nil, task.ErrForbidden
+	}
+
+	ev := task.Event{Type: r.Trigger, ActorID: userID, Task: *tsk}
+	if r.Trigger == task.EventStatusChanged {
+		ev.PrevStatus = prevStatus
+	}
+	res := &DryRun{
+		Matched:    true,
+		Conditions: make([]ConditionResult, 0, len(r.Conditions)),
+		Actions:    []Action{},
+	}
+	for _, c := range r.Conditions {
+		ok := c.Matches(ev)
+		res.Conditions = append(res.Conditions, ConditionResult{Condition: c, Matched: ok})
+		res.Matched = res.Matched && ok
+	}
+	if res.Matched {
+		res.Actions = r.Actions
+	}
+	return res, nil
+}
+
+func newRule(userID, workspaceID int, in RuleInput, now time.Time) *Rule {
+	return &Rule{
+		UserID:      userID,
+		WorkspaceID: workspaceID,
+		Name:        in.Name,
+		Enabled:     in.Enabled,
+		Trigger:     in.Trigger,
+		Conditions:  in.Conditions,
+		Actions:     in.Actions,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+// get loads a rule the user may see; others' personal rules and those of
+// workspaces the user isn't in are ErrNotFound.
+func (s *automationService) get(ctx context.Context, userID, id int) (*Rule, error) {
+	r, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if r.WorkspaceID == 0 {
+		if r.UserID != userID {
+			return nil, ErrNotFound
+		}
+		return r, nil
+	}
+	if _, err := s.memberRole(ctx, userID, r.WorkspaceID); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// canWrite checks that the user may change rules of the workspace (or their
+// personal rules if workspaceID is 0): guests may not.
+func (s *automationService) canWrite(ctx context.Context, userID, workspaceID int) error {
+	if workspaceID == 0 {
+		return nil
+	}
+	role, err := s.memberRole(ctx, userID, workspaceID)
+	if err != nil {
+		return err
+	}
+	if role == workspace.RoleGuest {
+		return ErrForbidden
+	}
+	return nil
+}
+
+func (s *automationService) memberRole(ctx context.Context, userID, workspaceID int) (workspace.Role, error) {
+	role, err := s.workspaces.MemberRole(ctx, workspaceID, userID)
+	if err != nil {
+		if errors.Is(err, workspace.ErrNotMember) {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+	return role, nil
+}
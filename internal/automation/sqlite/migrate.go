@@ -0,0 +1,62 @@
+package sqlite
+
+import "database/sql"
+
+func Migrate(db *sql.DB) error {
+	const q = `
+	CREATE TABLE IF NOT EXISTS automation_rules(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	enabled INTEGER NOT NULL DEFAULT 1,
+	trigger_type TEXT NOT NULL,
+	definition TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	updated_at TEXT NOT NULL);
+	CREATE INDEX IF NOT EXISTS idx_automation_rules_user ON automation_rules(user_id, trigger_type);
+
+	CREATE TABLE IF NOT EXISTS automation_due_runs(
+	rule_id INTEGER NOT NULL,
+	task_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	due_at TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	PRIMARY KEY (rule_id, task_id, due_at));
+	`
+	if _, err := db.Exec(q); err != nil {
+		return err
+	}
+
+	// правила до workspace были личными
+	if _, err := addColumnIfMissing(db, "automation_rules", "workspace_id", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	_, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_automation_rules_workspace ON automation_rules(workspace_id, trigger_type)`)
+	return err
+}
+
+func addColumnIfMissing(db *sql.DB, table, column, definition string) (bool, error) {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return false, err
+		}
+		if name == column {
+			return false, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+
+	if _, err := db.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + definition); err != nil {
+		return false, err
+	}
+	return true, nil
+}
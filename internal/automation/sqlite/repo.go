@@ -0,0 +1,295 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"task_scheduler/internal/automation"
+	"task_scheduler/internal/task"
+	"time"
+)
+
+type Repo struct {
+	db *sql.DB
+}
+
+func New(db *sql.DB) *Repo {
+	return &Repo{db: db}
+}
+
+// definition is how conditions and actions are stored, as one JSON column.
+type definition struct {
+	Conditions []automation.Condition `json:"conditions"`
+	Actions    []automation.Action    `json:"actions"`
+}
+
+const selectColumns = `id, user_id, workspace_id, name, enabled, trigger_type, definition, created_at, updated_at`
+
+func (r *Repo) Create(ctx context.Context, rule *automation.Rule) error {
+	def, err := json.Marshal(definition{Conditions: rule.Conditions, Actions: rule.Actions})
+	if err != nil {
+		return err
+	}
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO automation_rules (user_id, workspace_id, name, enabled, trigger_type, definition, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		rule.UserID,
+		rule.WorkspaceID,
+		rule.Name,
+		rule.Enabled,
+		string(rule.Trigger),
+		string(def),
+		rule.CreatedAt.UTC().Format(time.RFC3339Nano),
+		rule.UpdatedAt.UTC().Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	rule.ID = int(id)
+	return nil
+}
+
+func (r *Repo) Get(ctx context.Context, id int) (*automation.Rule, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+selectColumns+` FROM automation_rules WHERE id = ?`, id)
+	rule, err := scanRule(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, automation.ErrNotFound
+		}
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (r *Repo) List(ctx context.Context, userID, workspaceID int) ([]automation.Rule, error) {
+	where, args := spaceFilter(userID, workspaceID)
+	return r.list(ctx,
+		`SELECT `+selectColumns+` FROM automation_rules WHERE `+where+` ORDER BY id`,
+		args...,
+	)
+}
+
+func (r *Repo) Enabled(ctx context.Context, userID, workspaceID int, trigger task.EventType) ([]automation.Rule, error) {
+	where, args := spaceFilter(userID, workspaceID)
+	return r.list(ctx,
+		`SELECT `+selectColumns+` FROM automation_rules
+		 WHERE `+where+` AND trigger_type = ? AND enabled = 1
+		 ORDER BY id`,
+		append(args, string(trigger))...,
+	)
+}
+
+func (r *Repo) Count(ctx context.Context, userID, workspaceID int) (int, error) {
+	where, args := spaceFilter(userID, workspaceID)
+	var n int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM automation_rules WHERE `+where, args...).Scan(&n)
+	return n, err
+}
+
+// spaceFilter selects the workspace's rules, or the user's personal ones if
+// workspaceID is 0.
+func spaceFilter(userID, workspaceID int) (string, []any) {
+	if workspaceID > 0 {
+		return `workspace_id = ?`, []any{workspaceID}
+	}
+	return `user_id = ? AND workspace_id = 0`, []any{userID}
+}
+
+func (r *Repo) Update(ctx context.Context, rule *automation.Rule) error {
+	def, err := json.Marshal(definition{Conditions: rule.Conditions, Actions: rule.Actions})
+	if err != nil {
+		return err
+	}
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE automation_rules
+		 SET user_id = ?, name = ?, enabled = ?, trigger_type = ?, definition = ?, updated_at = ?
+		 WHERE id = ?`,
+		rule.UserID,
+		rule.Name,
+		rule.Enabled,
+		string(rule.Trigger),
+		string(def),
+		rule.UpdatedAt.UTC().Format(time.RFC3339Nano),
+		rule.ID,
+	)
+	if err != nil {
+		return err
+	}
+	return requireAffected(res)
+}
+
+func (r *Repo) Delete(ctx context.Context, id int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM automation_rules WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(res); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM automation_due_runs WHERE rule_id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteAccountData deletes the user's rules and their due runs when the
+// account goes. Workspace rules go too: they act as the user, who can't act
+// any more.
+func (r *Repo) DeleteAccountData(ctx context.Context, userID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, table := range []string{"automation_due_runs", "automation_rules"} {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE user_id = ?`, userID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Due compares times with julianday: tasks store them as RFC3339Nano text,
+// which doesn't sort correctly within a second. Runs keep due_at exactly as
+// the tasks table does, so they match as text.
+func (r *Repo) Due(ctx context.Context, now time.Time, limit int) ([]automation.DueMatch, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT r.id, r.user_id, t.id, t.due_at
+		 FROM automation_rules r JOIN tasks t
+		   ON (r.workspace_id = 0 AND t.workspace_id = 0 AND t.user_id = r.user_id)
+		   OR (r.workspace_id > 0 AND t.workspace_id = r.workspace_id)
+		 WHERE r.enabled = 1 AND r.trigger_type = ?
+		   AND t.status = 'pending' AND t.due_at IS NOT NULL
+		   AND julianday(t.due_at) <= julianday(?)
+		   AND julianday(t.due_at) >= julianday(r.updated_at)
+		   AND NOT EXISTS (
+		     SELECT 1 FROM automation_due_runs d
+		     WHERE d.rule_id = r.id AND d.task_id = t.id AND d.due_at = t.due_at)
+		 ORDER BY t.id, r.id
+		 LIMIT ?`,
+		string(task.EventDue),
+		now.UTC().Format(time.RFC3339Nano),
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]automation.DueMatch, 0)
+	for rows.Next() {
+		var (
+			m        automation.DueMatch
+			dueAtStr string
+		)
+		if err := rows.Scan(&m.RuleID, &m.UserID, &m.TaskID, &dueAtStr); err != nil {
+			return nil, err
+		}
+		dueAt, err := time.Parse(time.RFC3339Nano, dueAtStr)
+		if err != nil {
+			return nil, err
+		}
+		m.DueAt = dueAt
+		list = append(list, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *Repo) MarkDue(ctx context.Context, m automation.DueMatch, at time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT OR IGNORE INTO automation_due_runs (rule_id, task_id, user_id, due_at, created_at) VALUES (?, ?, ?, ?, ?)`,
+		m.RuleID,
+		m.TaskID,
+		m.UserID,
+		m.DueAt.UTC().Format(time.RFC3339Nano),
+		at.UTC().Format(time.RFC3339Nano),
+	)
+	return err
+}
+
+func (r *Repo) list(ctx context.Context, query string, args ...any) ([]automation.Rule, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]automation.Rule, 0)
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func requireAffected(res sql.Result) error {
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if aff == 0 {
+		return automation.ErrNotFound
+	}
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRule(s scanner) (*automation.Rule, error) {
+	var (
+		rule         automation.Rule
+		trigger      string
+		defStr       string
+		createdAtStr string
+		updatedAtStr string
+	)
+	if err := s.Scan(&rule.ID, &rule.UserID, &rule.WorkspaceID, &rule.Name, &rule.Enabled, &trigger, &defStr, &createdAtStr, &updatedAtStr); err != nil {
+		return nil, err
+	}
+	rule.Trigger = task.EventType(trigger)
+
+	var def definition
+	if err := json.Unmarshal([]byte(defStr), &def); err != nil {
+		return nil, err
+	}
+	rule.Conditions = def.Conditions
+	rule.Actions = def.Actions
+	if rule.Conditions == nil {
+		rule.Conditions = []automation.Condition{}
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, createdAtStr)
+	if err != nil {
+		return nil, err
+	}
+	rule.CreatedAt = createdAt
+	updatedAt, err := time.Parse(time.RFC3339Nano, updatedAtStr)
+	if err != nil {
+		return nil, err
+	}
+	rule.UpdatedAt = updatedAt
+	return &rule, nil
+}
@@ -0,0 +1,132 @@
+package automation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"syscall"
+	"task_scheduler/internal/task"
+	"time"
+)
+
+var (
+	ErrAddressNotAllowed = errors.New("automation: webhook address is not public")
+	ErrWebhookQueueFull  = errors.New("automation: webhook queue is full")
+)
+
+const (
+	// webhookQueue bounds deliveries waiting for a worker; beyond it new
+	// ones are dropped rather than slowing down task changes.
+	webhookQueue   = 256
+	webhookWorkers = 4
+)
+
+// reservedPrefixes aren't reachable on the public internet, though
+// netip doesn't count them as private.
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("2002::/16"),
+}
+
+// publicAddr reports whether a webhook may connect to ip.
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, p := range reservedPrefixes {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// WebhookClient is the client for URLs that users put into rules. It
+// doesn't follow redirects and connects only to public addresses: the
+// check runs on every dialed IP, so DNS can't point it inside either.
+func WebhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip, err := netip.ParseAddr(host)
+			if err != nil {
+				return err
+			}
+			if !publicAddr(ip) {
+				return fmt.Errorf("%w: %s", ErrAddressNotAllowed, ip)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	// через прокси проверка адреса теряет смысл
+	transport.Proxy = nil
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// delivery is a webhook waiting to be sent.
+type delivery struct {
+	rule   Rule
+	action Action
+	ev     task.Event
+}
+
+// enqueue hands the webhook to the workers, so a slow endpoint doesn't
+// hold up the change that fired the rule.
+func (e *Engine) enqueue(d delivery) error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.closed {
+		return errors.New("automation: engine is closed")
+	}
+	select {
+	case e.hooks <- d:
+		return nil
+	default:
+		return ErrWebhookQueueFull
+	}
+}
+
+func (e *Engine) deliverWebhooks() {
+	defer e.workers.Done()
+	for d := range e.hooks {
+		if err := e.webhook(context.Background(), d.rule, d.action, d.ev); err != nil {
+			log.Println("[AUTOMATION] rule "+strconv.Itoa(d.rule.ID)+" webhook on task "+strconv.Itoa(d.ev.Task.ID)+" error:", err)
+		}
+	}
+}
+
+// Close stops taking webhooks and waits for the queued ones to be sent.
+func (e *Engine) Close() {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return
+	}
+	e.closed = true
+	close(e.hooks)
+	e.mu.Unlock()
+	e.workers.Wait()
+}
@@ -0,0 +1,61 @@
+package automation
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublicAddr(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"100.64.0.1", false},
+		{"224.0.0.1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			require.Equal(t, tt.want, publicAddr(netip.MustParseAddr(tt.ip)))
+		})
+	}
+}
+
+func TestWebhookClient_RefusesLoopback(t *testing.T) {
+	hit := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hit = true
+	}))
+	t.Cleanup(srv.Close)
+
+	resp, err := WebhookClient(time.Second).Post(srv.URL, "application/json", nil)
+	if resp != nil {
+		_ = resp.Body.Close()
+	}
+	require.True(t, errors.Is(err, ErrAddressNotAllowed), "got %v", err)
+	require.False(t, hit)
+}
+
+func TestWebhookClient_DoesNotFollowRedirects(t *testing.T) {
+	c := WebhookClient(time.Second)
+	require.ErrorIs(t, c.CheckRedirect(nil, nil), http.ErrUseLastResponse)
+}
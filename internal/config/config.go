@@ -2,6 +2,7 @@ package config
 
 import (
 	"errors"
+	"net/url"
 	"os"
 	"time"
 
@@ -12,6 +13,21 @@ var (
 	ErrMissingConfigPath = errors.New("CONFIG_PATH is not set")
 	ErrMissingJWTSecret  = errors.New("JWT_SECRET is not set")
 	ErrInvalidJWTTTL     = errors.New("invalid jwt.ttl (use duration like 15m, 24h)")
+	ErrInvalidRefreshTTL = errors.New("invalid jwt.refresh_ttl (use duration like 24h, 720h)")
+	ErrInvalidJWTKeys    = errors.New("invalid jwt.keys (each key needs kid, alg and a key file)")
+	ErrInvalidActiveKID  = errors.New("jwt.active_kid must name a key with private_key_file")
+	ErrInvalidMailDriver = errors.New("invalid mail.driver (use stdout or file)")
+	ErrInvalidResetTTL   = errors.New("invalid auth.password_reset_ttl (use duration like 30m, 1h)")
+	ErrInvalidVerifyTTL  = errors.New("invalid auth.verification_ttl (use duration like 24h, 48h)")
+	ErrInvalidResendRate = errors.New("invalid auth.verification_resend_interval (use duration like 1m)")
+	ErrInvalidInviteTTL  = errors.New("invalid workspace.invite_ttl (use duration like 72h, 168h)")
+	ErrInvalidExportTTL  = errors.New("invalid export.ttl (use duration like 24h)")
+	ErrInvalidLockout    = errors.New("invalid auth.lockout (attempts must be >= 0, durations like 1s, 15m)")
+	ErrInvalidAttachment = errors.New("invalid attachments (driver local or s3, sizes > 0, purge_interval like 10m)")
+	ErrMissingS3Config   = errors.New("attachments.s3 needs endpoint, bucket, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY")
+	ErrInvalidAutomation = errors.New("invalid automation.due_sweep_interval (use duration like 1m)")
+	ErrInvalidRanks      = errors.New("invalid ranks.rebalance_interval (use duration like 10m)")
+	ErrInvalidOverdue    = errors.New("invalid overdue (sweep_interval like 5m; rules need a unique name, after >= 0, action notify or webhook with an http(s) url)")
 )
 
 type Config struct {
@@ -24,14 +40,136 @@ type Config struct {
 	} `yaml:"db"`
 
 	JWT struct {
+		TTLRaw        string        `yaml:"ttl"`
+		TTL           time.Duration `yaml:"-"`
+		RefreshTTLRaw string        `yaml:"refresh_ttl"`
+		RefreshTTL    time.Duration `yaml:"-"`
+		Secret        string        `yaml:"-"`
+
+		// Asymmetric keys. When empty, tokens are signed with JWT_SECRET (HS256).
+		ActiveKID string   `yaml:"active_kid"`
+		Keys      []JWTKey `yaml:"keys"`
+	} `yaml:"jwt"`
+
+	Auth struct {
+		PasswordResetTTLRaw string        `yaml:"password_reset_ttl"`
+		PasswordResetTTL    time.Duration `yaml:"-"`
+
+		VerificationTTLRaw   string        `yaml:"verification_ttl"`
+		VerificationTTL      time.Duration `yaml:"-"`
+		ResendIntervalRaw    string        `yaml:"verification_resend_interval"`
+		ResendInterval       time.Duration `yaml:"-"`
+		RequireVerifiedEmail bool          `yaml:"require_verified_email"`
+
+		Lockout Lockout `yaml:"lockout"`
+	} `yaml:"auth"`
+
+	Workspace struct {
+		InviteTTLRaw string        `yaml:"invite_ttl"`
+		InviteTTL    time.Duration `yaml:"-"`
+	} `yaml:"workspace"`
+
+	Export struct {
+		Dir    string        `yaml:"dir"`
 		TTLRaw string        `yaml:"ttl"`
 		TTL    time.Duration `yaml:"-"`
-		Secret string        `yaml:"-"`
-	} `yaml:"jwt"`
+	} `yaml:"export"`
+
+	Attachments Attachments `yaml:"attachments"`
+
+	Overdue Overdue `yaml:"overdue"`
+
+	Automation struct {
+		// DueSweepInterval is how often task.due rules are checked.
+		DueSweepIntervalRaw string        `yaml:"due_sweep_interval"`
+		DueSweepInterval    time.Duration `yaml:"-"`
+	} `yaml:"automation"`
+
+	Ranks struct {
+		// RebalanceInterval is how often lists with overlong ranks are re-spaced.
+		RebalanceIntervalRaw string        `yaml:"rebalance_interval"`
+		RebalanceInterval    time.Duration `yaml:"-"`
+	} `yaml:"ranks"`
+
+	Mail struct {
+		Driver   string `yaml:"driver"` // stdout | file
+		FilePath string `yaml:"file_path"`
+		From     string `yaml:"from"`
+	} `yaml:"mail"`
+}
+
+// JWTKey describes one signing key. Keys with only public_key_file are used
+// for verification during rotation.
+type JWTKey struct {
+	ID             string `yaml:"kid"`
+	Alg            string `yaml:"alg"`
+	PrivateKeyFile string `yaml:"private_key_file"`
+	PublicKeyFile  string `yaml:"public_key_file"`
+}
+
+// Lockout throttles failed logins per email and per client IP.
+type Lockout struct {
+	MaxEmailFailures int `yaml:"max_email_failures"`
+	MaxIPFailures    int `yaml:"max_ip_failures"`
+	FreeAttempts     int `yaml:"free_attempts"`
+
+	WindowRaw      string        `yaml:"window"`
+	Window         time.Duration `yaml:"-"`
+	DelayRaw       string        `yaml:"delay"`
+	Delay          time.Duration `yaml:"-"`
+	MaxDelayRaw    string        `yaml:"max_delay"`
+	MaxDelay       time.Duration `yaml:"-"`
+	DurationRaw    string        `yaml:"duration"`
+	Duration       time.Duration `yaml:"-"`
+	MaxDurationRaw string        `yaml:"max_duration"`
+	MaxDuration    time.Duration `yaml:"-"`
+
+	// TrustForwardedFor takes the client IP from the last X-Forwarded-For
+	// entry, for a server behind exactly one proxy.
+	TrustForwardedFor bool `yaml:"trust_forwarded_for"`
+}
+
+// Attachments configure where task files are stored and how much a user
+// may upload.
+type Attachments struct {
+	Driver           string        `yaml:"driver"` // local | s3
+	Dir              string        `yaml:"dir"`
+	MaxSizeMB        int64         `yaml:"max_size_mb"`
+	QuotaMB          int64         `yaml:"quota_mb"`
+	PurgeIntervalRaw string        `yaml:"purge_interval"`
+	PurgeInterval    time.Duration `yaml:"-"`
+
+	S3 struct {
+		Endpoint        string `yaml:"endpoint"`
+		Region          string `yaml:"region"`
+		Bucket          string `yaml:"bucket"`
+		AccessKeyID     string `yaml:"-"`
+		SecretAccessKey string `yaml:"-"`
+	} `yaml:"s3"`
+}
+
+// Overdue configures the sweep that escalates overdue tasks.
+type Overdue struct {
+	SweepIntervalRaw string           `yaml:"sweep_interval"`
+	SweepInterval    time.Duration    `yaml:"-"`
+	Rules            []EscalationRule `yaml:"rules"`
+}
+
+// EscalationRule fires once a task has been overdue for After. The webhook
+// secret is read from the env variable named by SecretEnv.
+type EscalationRule struct {
+	Name      string        `yaml:"name"`
+	AfterRaw  string        `yaml:"after"`
+	After     time.Duration `yaml:"-"`
+	Action    string        `yaml:"action"` // notify | webhook
+	URL       string        `yaml:"url"`
+	SecretEnv string        `yaml:"secret_env"`
+	Secret    string        `yaml:"-"`
 }
 
 // Load reads YAML from CONFIG_PATH and secrets from ENV.
-// ENV overrides (optional): HTTP_ADDR, DB_PATH, JWT_TTL, JWT_SECRET.
+// ENV overrides (optional): HTTP_ADDR, DB_PATH, JWT_TTL, JWT_REFRESH_TTL, JWT_SECRET,
+// S3_ACCESS_KEY_ID, S3_SECRET_ACCESS_KEY.
 func Load() (Config, error) {
 	var cfg Config
 
@@ -60,15 +198,34 @@ func Load() (Config, error) {
 		cfg.JWT.TTLRaw = v
 	}
 
+	if v := os.Getenv("JWT_REFRESH_TTL"); v != "" {
+		cfg.JWT.RefreshTTLRaw = v
+	}
+
 	ttl, err := time.ParseDuration(cfg.JWT.TTLRaw)
 	if err != nil || ttl <= 0 {
 		return cfg, ErrInvalidJWTTTL
 	}
 	cfg.JWT.TTL = ttl
 
+	if cfg.JWT.RefreshTTLRaw == "" {
+		cfg.JWT.RefreshTTLRaw = "720h"
+	}
+	refreshTTL, err := time.ParseDuration(cfg.JWT.RefreshTTLRaw)
+	if err != nil || refreshTTL <= 0 {
+		return cfg, ErrInvalidRefreshTTL
+	}
+	cfg.JWT.RefreshTTL = refreshTTL
+
+	// JWT_SECRET обязателен только без asymmetric ключей;
+	// вместе с ними он лишь продолжает проверять старые HS256 токены
 	cfg.JWT.Secret = os.Getenv("JWT_SECRET")
-	if cfg.JWT.Secret == "" {
-		return cfg, ErrMissingJWTSecret
+	if len(cfg.JWT.Keys) == 0 {
+		if cfg.JWT.Secret == "" {
+			return cfg, ErrMissingJWTSecret
+		}
+	} else if err := validateJWTKeys(cfg.JWT.ActiveKID, cfg.JWT.Keys); err != nil {
+		return cfg, err
 	}
 	// Sensible defaults if YAML left empty
 	if cfg.HTTP.Addr == "" {
@@ -77,6 +234,97 @@ func Load() (Config, error) {
 	if cfg.DB.Path == "" {
 		cfg.DB.Path = "data/tasks.db"
 	}
+
+	if cfg.Auth.PasswordResetTTLRaw == "" {
+		cfg.Auth.PasswordResetTTLRaw = "1h"
+	}
+	resetTTL, err := time.ParseDuration(cfg.Auth.PasswordResetTTLRaw)
+	if err != nil || resetTTL <= 0 {
+		return cfg, ErrInvalidResetTTL
+	}
+	cfg.Auth.PasswordResetTTL = resetTTL
+
+	if cfg.Auth.VerificationTTLRaw == "" {
+		cfg.Auth.VerificationTTLRaw = "48h"
+	}
+	verifyTTL, err := time.ParseDuration(cfg.Auth.VerificationTTLRaw)
+	if err != nil || verifyTTL <= 0 {
+		return cfg, ErrInvalidVerifyTTL
+	}
+	cfg.Auth.VerificationTTL = verifyTTL
+
+	if cfg.Auth.ResendIntervalRaw == "" {
+		cfg.Auth.ResendIntervalRaw = "1m"
+	}
+	resend, err := time.ParseDuration(cfg.Auth.ResendIntervalRaw)
+	if err != nil || resend < 0 {
+		return cfg, ErrInvalidResendRate
+	}
+	cfg.Auth.ResendInterval = resend
+
+	if err := loadLockout(&cfg.Auth.Lockout); err != nil {
+		return cfg, err
+	}
+
+	if cfg.Workspace.InviteTTLRaw == "" {
+		cfg.Workspace.InviteTTLRaw = "168h"
+	}
+	inviteTTL, err := time.ParseDuration(cfg.Workspace.InviteTTLRaw)
+	if err != nil || inviteTTL <= 0 {
+		return cfg, ErrInvalidInviteTTL
+	}
+	cfg.Workspace.InviteTTL = inviteTTL
+
+	if cfg.Export.Dir == "" {
+		cfg.Export.Dir = "data/exports"
+	}
+	if cfg.Export.TTLRaw == "" {
+		cfg.Export.TTLRaw = "24h"
+	}
+	exportTTL, err := time.ParseDuration(cfg.Export.TTLRaw)
+	if err != nil || exportTTL <= 0 {
+		return cfg, ErrInvalidExportTTL
+	}
+	cfg.Export.TTL = exportTTL
+
+	if err := loadAttachments(&cfg.Attachments); err != nil {
+		return cfg, err
+	}
+
+	if err := loadOverdue(&cfg.Overdue); err != nil {
+		return cfg, err
+	}
+
+	if cfg.Automation.DueSweepIntervalRaw == "" {
+		cfg.Automation.DueSweepIntervalRaw = "1m"
+	}
+	dueSweep, err := time.ParseDuration(cfg.Automation.DueSweepIntervalRaw)
+	if err != nil || dueSweep <= 0 {
+		return cfg, ErrInvalidAutomation
+	}
+	cfg.Automation.DueSweepInterval = dueSweep
+
+	if cfg.Ranks.RebalanceIntervalRaw == "" {
+		cfg.Ranks.RebalanceIntervalRaw = "10m"
+	}
+	rebalance, err := time.ParseDuration(cfg.Ranks.RebalanceIntervalRaw)
+	if err != nil || rebalance <= 0 {
+		return cfg, ErrInvalidRanks
+	}
+	cfg.Ranks.RebalanceInterval = rebalance
+
+	if cfg.Mail.Driver == "" {
+		cfg.Mail.Driver = "stdout"
+	}
+	if cfg.Mail.Driver != "stdout" && cfg.Mail.Driver != "file" {
+		return cfg, ErrInvalidMailDriver
+	}
+	if cfg.Mail.FilePath == "" {
+		cfg.Mail.FilePath = "data/mail.log"
+	}
+	if cfg.Mail.From == "" {
+		cfg.Mail.From = "no-reply@task-scheduler.local"
+	}
 	// ttlStr := os.Getenv("JWT_TTL")
 	// if ttlStr == "" {
 	// 	ttlStr = "15m"
@@ -89,3 +337,143 @@ func Load() (Config, error) {
 
 	return cfg, nil
 }
+
+func validateJWTKeys(activeKID string, keys []JWTKey) error {
+	activeOK := false
+	seen := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		if k.ID == "" || k.Alg == "" || seen[k.ID] {
+			return ErrInvalidJWTKeys
+		}
+		if k.PrivateKeyFile == "" && k.PublicKeyFile == "" {
+			return ErrInvalidJWTKeys
+		}
+		seen[k.ID] = true
+		if k.ID == activeKID && k.PrivateKeyFile != "" {
+			activeOK = true
+		}
+	}
+	if !activeOK {
+		return ErrInvalidActiveKID
+	}
+	return nil
+}
+
+func loadLockout(l *Lockout) error {
+	if l.MaxEmailFailures == 0 {
+		l.MaxEmailFailures = 10
+	}
+	if l.MaxIPFailures == 0 {
+		l.MaxIPFailures = 50
+	}
+	if l.FreeAttempts == 0 {
+		l.FreeAttempts = 3
+	}
+	if l.MaxEmailFailures < 0 || l.MaxIPFailures < 0 || l.FreeAttempts < 0 {
+		return ErrInvalidLockout
+	}
+
+	durations := []struct {
+		raw *string
+		def string
+		dst *time.Duration
+	}{
+		{&l.WindowRaw, "15m", &l.Window},
+		{&l.DelayRaw, "1s", &l.Delay},
+		{&l.MaxDelayRaw, "30s", &l.MaxDelay},
+		{&l.DurationRaw, "15m", &l.Duration},
+		{&l.MaxDurationRaw, "24h", &l.MaxDuration},
+	}
+	for _, d := range durations {
+		if *d.raw == "" {
+			*d.raw = d.def
+		}
+		v, err := time.ParseDuration(*d.raw)
+		if err != nil || v <= 0 {
+			return ErrInvalidLockout
+		}
+		*d.dst = v
+	}
+	return nil
+}
+
+func loadAttachments(a *Attachments) error {
+	if a.Driver == "" {
+		a.Driver = "local"
+	}
+	if a.Dir == "" {
+		a.Dir = "data/attachments"
+	}
+	if a.MaxSizeMB == 0 {
+		a.MaxSizeMB = 25
+	}
+	if a.QuotaMB == 0 {
+		a.QuotaMB = 500
+	}
+	if a.PurgeIntervalRaw == "" {
+		a.PurgeIntervalRaw = "10m"
+	}
+	interval, err := time.ParseDuration(a.PurgeIntervalRaw)
+	if err != nil || interval <= 0 || a.MaxSizeMB < 0 || a.QuotaMB < 0 {
+		return ErrInvalidAttachment
+	}
+	a.PurgeInterval = interval
+
+	switch a.Driver {
+	case "local":
+		return nil
+	case "s3":
+		if a.S3.Region == "" {
+			a.S3.Region = "us-east-1"
+		}
+		a.S3.AccessKeyID = os.Getenv("S3_ACCESS_KEY_ID")
+		a.S3.SecretAccessKey = os.Getenv("S3_SECRET_ACCESS_KEY")
+		if a.S3.Endpoint == "" || a.S3.Bucket == "" || a.S3.AccessKeyID == "" || a.S3.SecretAccessKey == "" {
+			return ErrMissingS3Config
+		}
+		return nil
+	default:
+		return ErrInvalidAttachment
+	}
+}
+
+func loadOverdue(o *Overdue) error {
+	if o.SweepIntervalRaw == "" {
+		o.SweepIntervalRaw = "5m"
+	}
+	interval, err := time.ParseDuration(o.SweepIntervalRaw)
+	if err != nil || interval <= 0 {
+		return ErrInvalidOverdue
+	}
+	o.SweepInterval = interval
+
+	seen := make(map[string]bool, len(o.Rules))
+	for i := range o.Rules {
+		r := &o.Rules[i]
+		if r.Name == "" || seen[r.Name] {
+			return ErrInvalidOverdue
+		}
+		seen[r.Name] = true
+
+		after, err := time.ParseDuration(r.AfterRaw)
+		if err != nil || after < 0 {
+			return ErrInvalidOverdue
+		}
+		r.After = after
+
+		switch r.Action {
+		case "notify":
+		case "webhook":
+			u, err := url.Parse(r.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return ErrInvalidOverdue
+			}
+			if r.SecretEnv != "" {
+				r.Secret = os.Getenv(r.SecretEnv)
+			}
+		default:
+			return ErrInvalidOverdue
+		}
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"task_scheduler/internal/automation"
+	"task_scheduler/internal/task"
+	"task_scheduler/internal/template"
+	"task_scheduler/internal/timetrack"
+	"task_scheduler/internal/user"
+	"time"
+)
+
+// profile is the exported view of a user: everything but the password hash.
+type profile struct {
+	ID          int        `json:"id"`
+	Email       string     `json:"email"`
+	DisplayName string     `json:"display_name"`
+	Timezone    string     `json:"timezone"`
+	Locale      string     `json:"locale"`
+	CreatedAt   time.Time  `json:"created_at"`
+	VerifiedAt  *time.Time `json:"verified_at"`
+}
+
+// data is the user's data as of at.
+type data struct {
+	user        *user.User
+	tasks       []task.Task
+	comments    []task.Comment
+	timeEntries []timetrack.Entry
+	templates   []template.Template
+	automations []automation.Rule
+	at          time.Time
+}
+
+// writeZip writes profile, tasks, comments and time entries as JSON and CSV;
+// templates and automation rules are nested, so only as JSON.
+func writeZip(w io.Writer, d *data) error {
+	zw := zip.NewWriter(w)
+	u := d.user
+
+	p := profile{
+		ID:          u.ID,
+		Email:       u.Email,
+		DisplayName: u.DisplayName,
+		Timezone:    u.Timezone,
+		Locale:      u.Locale,
+		CreatedAt:   u.CreatedAt,
+		VerifiedAt:  u.VerifiedAt,
+	}
+	if err := writeJSON(zw, "profile.json", p); err != nil {
+		return err
+	}
+	if err := writeCSV(zw, "profile.csv",
+		[]string{"id", "email", "display_name", "timezone", "locale", "created_at", "verified_at"},
+		[][]string{{
+			strconv.Itoa(p.ID),
+			csvSafe(p.Email),
+			csvSafe(p.DisplayName),
+			p.Timezone,
+			p.Locale,
+			formatTime(&p.CreatedAt),
+			formatTime(p.VerifiedAt),
+		}},
+	); err != nil {
+		return err
+	}
+
+	if err := writeJSON(zw, "tasks.json", d.tasks); err != nil {
+		return err
+	}
+	rows := make([][]string, 0, len(d.tasks))
+	for _, t := range d.tasks {
+		rows = append(rows, []string{
+			strconv.Itoa(t.ID),
+			strconv.Itoa(t.WorkspaceID),
+			csvSafe(t.Title),
+			csvSafe(t.Description),
+			string(t.Status),
+			formatTime(t.DueAt),
+			formatTime(&t.CreatedAt),
+			formatTime(&t.UpdatedAt),
+		})
+	}
+	if err := writeCSV(zw, "tasks.csv",
+		[]string{"id", "workspace_id", "title", "description", "status", "due_at", "created_at", "updated_at"},
+		rows,
+	); err != nil {
+		return err
+	}
+
+	if err := writeJSON(zw, "comments.json", d.comments); err != nil {
+		return err
+	}
+	rows = make([][]string, 0, len(d.comments))
+	for _, c := range d.comments {
+		rows = append(rows, []string{
+			strconv.Itoa(c.ID),
+			strconv.Itoa(c.TaskID),
+			csvSafe(c.Body),
+			formatTime(&c.CreatedAt),
+			formatTime(c.EditedAt),
+		})
+	}
+	if err := writeCSV(zw, "comments.csv",
+		[]string{"id", "task_id", "body", "created_at", "edited_at"},
+		rows,
+	); err != nil {
+		return err
+	}
+
+	// длительность в репозитории не хранится — считаем, как сервис
+	for i := range d.timeEntries {
+		e := &d.timeEntries[i]
+		end := d.at
+		if e.EndedAt != nil {
+			end = *e.EndedAt
+		}
+		if end.After(e.StartedAt) {
+			e.Seconds = int64(end.Sub(e.StartedAt) / time.Second)
+		}
+	}
+	if err := writeJSON(zw, "time_entries.json", d.timeEntries); err != nil {
+		return err
+	}
+	rows = make([][]string, 0, len(d.timeEntries))
+	for _, e := range d.timeEntries {
+		rows = append(rows, []string{
+			strconv.Itoa(e.ID),
+			strconv.Itoa(e.TaskID),
+			string(e.Source),
+			csvSafe(e.Note),
+			formatTime(&e.StartedAt),
+			formatTime(e.EndedAt),
+			strconv.FormatInt(e.Seconds, 10),
+		})
+	}
+	if err := writeCSV(zw, "time_entries.csv",
+		[]string{"id", "task_id", "source", "note", "started_at", "ended_at", "duration_seconds"},
+		rows,
+	); err != nil {
+		return err
+	}
+
+	if err := writeJSON(zw, "templates.json", d.templates); err != nil {
+		return err
+	}
+	if err := writeJSON(zw, "automation_rules.json", d.automations); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+func writeJSON(zw *zip.Writer, name string, v any) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func writeCSV(zw *zip.Writer, name string, header []string, rows [][]string) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(f)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// csvSafe defuses cells that spreadsheets would run as formulas.
+func csvSafe(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
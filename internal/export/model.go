@@ -0,0 +1,26 @@
+package export
+
+import "time"
+
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusRunning Status = "running"
+	StatusReady   Status = "ready"
+	StatusFailed  Status = "failed"
+)
+
+// Export is one personal data export. The archive lives in the export
+// directory until ExpiresAt.
+type Export struct {
+	ID          int        `json:"id"`
+	UserID      int        `json:"user_id"`
+	Status      Status     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	FilePath    string     `json:"-"`
+	Size        int64      `json:"size"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
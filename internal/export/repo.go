@@ -0,0 +1,25 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrNotFound = errors.New("export not found")
+
+type Repo interface {
+	Create(ctx context.Context, e *Export) error
+	Get(ctx context.Context, userID, id int) (*Export, error)
+	// GetActive returns the user's pending or running export, if any.
+	GetActive(ctx context.Context, userID int) (*Export, error)
+	SetStatus(ctx context.Context, id int, status Status) error
+	// Complete returns ErrNotFound if the export was deleted meanwhile.
+	Complete(ctx context.Context, id int, filePath string, size int64, at, expiresAt time.Time) error
+	Fail(ctx context.Context, id int, msg string, at time.Time) error
+	// FailUnfinished fails exports interrupted by a restart.
+	FailUnfinished(ctx context.Context, msg string, at time.Time) error
+	ListExpired(ctx context.Context, now time.Time) ([]Export, error)
+	ListForUser(ctx context.Context, userID int) ([]Export, error)
+	Delete(ctx context.Context, id int) error
+}
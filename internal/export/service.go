@@ -0,0 +1,278 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"task_scheduler/internal/automation"
+	"task_scheduler/internal/task"
+	"task_scheduler/internal/template"
+	"task_scheduler/internal/timetrack"
+	"task_scheduler/internal/user"
+	"time"
+)
+
+var (
+	ErrInvalidInput = errors.New("invalid input")
+	ErrNotReady     = errors.New("export is not ready yet")
+	ErrExpired      = errors.New("export has expired")
+)
+
+// buildTimeout bounds a single archive build.
+const buildTimeout = 5 * time.Minute
+
+type Service interface {
+	// Request starts a new export in the background. If one is already in
+	// progress for the user, that one is returned instead.
+	Request(ctx context.Context, userID int) (*Export, error)
+	Get(ctx context.Context, userID, id int) (*Export, error)
+	// Open returns a ready export together with its archive. The caller
+	// closes the file.
+	Open(ctx context.Context, userID, id int) (*Export, *os.File, error)
+	// DeleteAccountData deletes the user's exports with their archives.
+	DeleteAccountData(ctx context.Context, userID int) error
+}
+
+// Options configure where archives go and how long they are kept.
+type Options struct {
+	Dir string
+	TTL time.Duration
+}
+
+// Sources is where the user's data is read from. Tasks are the ones the
+// user created or is assigned, workspace ones included; tasks only shared
+// with the user and other people's comments on them aren't exported.
+type Sources struct {
+	Users       user.Repo
+	Tasks       task.Repo
+	Comments    task.CommentRepo
+	TimeEntries timetrack.Repo
+	Templates   template.Repo
+	Automations automation.Repo
+}
+
+type exportService struct {
+	repo Repo
+	src  Sources
+	opts Options
+}
+
+func NewService(repo Repo, src Sources, opts Options) Service {
+	return &exportService{
+		repo: repo,
+		src:  src,
+		opts: opts,
+	}
+}
+
+func (s *exportService) Request(ctx context.Context, userID int) (*Export, error) {
+	if userID <= 0 {
+		return nil, ErrInvalidInput
+	}
+	// 1) заодно чистим просроченные архивы
+	s.purgeExpired(ctx)
+
+	// 2) не больше одной выгрузки в работе на пользователя
+	active, err := s.repo.GetActive(ctx, userID)
+	if err == nil {
+		return active, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	e := &Export{
+		UserID:    userID,
+		Status:    StatusPending,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.repo.Create(ctx, e); err != nil {
+		return nil, err
+	}
+
+	// 3) собираем в фоне: контекст запроса к этому моменту уже закончится
+	go s.build(e.ID, userID)
+	return e, nil
+}
+
+func (s *exportService) Get(ctx context.Context, userID, id int) (*Export, error) {
+	if userID <= 0 || id <= 0 {
+		return nil, ErrInvalidInput
+	}
+	return s.repo.Get(ctx, userID, id)
+}
+
+func (s *exportService) Open(ctx context.Context, userID, id int) (*Export, *os.File, error) {
+	e, err := s.Get(ctx, userID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if e.Status != StatusReady {
+		return nil, nil, ErrNotReady
+	}
+	if e.ExpiresAt != nil && !time.Now().Before(*e.ExpiresAt) {
+		return nil, nil, ErrExpired
+	}
+	f, err := os.Open(e.FilePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil, ErrExpired
+		}
+		return nil, nil, err
+	}
+	return e, f, nil
+}
+
+func (s *exportService) DeleteAccountData(ctx context.Context, userID int) error {
+	exports, err := s.repo.ListForUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, e := range exports {
+		// архив, который ещё собирается, build удалит сам: Complete не найдёт строку
+		if err := s.removeArchive(e); err != nil {
+			return err
+		}
+		if err := s.repo.Delete(ctx, e.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *exportService) build(id, userID int) {
+	ctx, cancel := context.WithTimeout(context.Background(), buildTimeout)
+	defer cancel()
+
+	if err := s.repo.SetStatus(ctx, id, StatusRunning); err != nil {
+		log.Println("[EXPORT] set running error:", err)
+	}
+
+	path, size, err := s.writeArchive(ctx, id, userID)
+	now := time.Now().UTC()
+	if err != nil {
+		log.Println("[EXPORT] build error:", err)
+		if err := s.repo.Fail(ctx, id, "failed to build export", now); err != nil {
+			log.Println("[EXPORT] mark failed error:", err)
+		}
+		return
+	}
+	if err := s.repo.Complete(ctx, id, path, size, now, now.Add(s.opts.TTL)); err != nil {
+		log.Println("[EXPORT] mark ready error:", err)
+		_ = os.Remove(path)
+	}
+}
+
+// writeArchive writes the ZIP next to its final name and renames it once
+// complete, so a half-written file is never served.
+func (s *exportService) writeArchive(ctx context.Context, id, userID int) (string, int64, error) {
+	d, err := s.collect(ctx, userID)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if err := os.MkdirAll(s.opts.Dir, 0o700); err != nil {
+		return "", 0, err
+	}
+	path := filepath.Join(s.opts.Dir, "export-"+strconv.Itoa(id)+".zip")
+	tmp := path + ".tmp"
+
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", 0, err
+	}
+	if err := writeZip(f, d); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return "", 0, err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return "", 0, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return "", 0, err
+	}
+
+	st, err := os.Stat(path)
+	if err != nil {
+		return "", 0, err
+	}
+	return path, st.Size(), nil
+}
+
+// collect reads everything that goes into the archive.
+func (s *exportService) collect(ctx context.Context, userID int) (*data, error) {
+	var (
+		d   data
+		err error
+	)
+	if d.user, err = s.src.Users.GetByID(ctx, userID); err != nil {
+		return nil, err
+	}
+	if d.tasks, err = s.allTasks(ctx, userID); err != nil {
+		return nil, err
+	}
+	if d.comments, err = s.src.Comments.ListCommentsByAuthor(ctx, userID); err != nil {
+		return nil, err
+	}
+	if d.timeEntries, err = s.src.TimeEntries.ListByUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	// шаблоны и правила workspace принадлежат workspace, в архив идут только личные
+	if d.templates, err = s.src.Templates.List(ctx, userID, 0); err != nil {
+		return nil, err
+	}
+	if d.automations, err = s.src.Automations.List(ctx, userID, 0); err != nil {
+		return nil, err
+	}
+	d.at = time.Now().UTC()
+	return &d, nil
+}
+
+func (s *exportService) allTasks(ctx context.Context, userID int) ([]task.Task, error) {
+	const pageSize = 100
+
+	all := make([]task.Task, 0)
+	for offset := 0; ; offset += pageSize {
+		page, total, err := s.src.Tasks.ListInvolved(ctx, userID, pageSize, offset)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if len(page) < pageSize || len(all) >= total {
+			return all, nil
+		}
+	}
+}
+
+func (s *exportService) purgeExpired(ctx context.Context) {
+	expired, err := s.repo.ListExpired(ctx, time.Now().UTC())
+	if err != nil {
+		log.Println("[EXPORT] list expired error:", err)
+		return
+	}
+	for _, e := range expired {
+		if err := s.removeArchive(e); err != nil {
+			log.Println("[EXPORT] remove archive error:", err)
+			continue
+		}
+		if err := s.repo.Delete(ctx, e.ID); err != nil {
+			log.Println("[EXPORT] delete expired error:", err)
+		}
+	}
+}
+
+func (s *exportService) removeArchive(e Export) error {
+	if e.FilePath == "" {
+		return nil
+	}
+	if err := os.Remove(e.FilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package sqlite
+
+import "database/sql"
+
+func Migrate(db *sql.DB) error {
+	const q = `
+	CREATE TABLE IF NOT EXISTS data_exports(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	status TEXT NOT NULL,
+	error TEXT NOT NULL DEFAULT '',
+	file_path TEXT NOT NULL DEFAULT '',
+	size INTEGER NOT NULL DEFAULT 0,
+	created_at TEXT NOT NULL,
+	completed_at TEXT NULL,
+	expires_at TEXT NULL);
+	CREATE INDEX IF NOT EXISTS idx_data_exports_user_id ON data_exports(user_id);
+	`
+	_, err := db.Exec(q)
+	return err
+}
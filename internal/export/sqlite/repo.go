@@ -0,0 +1,213 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"task_scheduler/internal/export"
+	"time"
+)
+
+type Repo struct {
+	db *sql.DB
+}
+
+func New(db *sql.DB) *Repo {
+	return &Repo{db: db}
+}
+
+const selectColumns = `id, user_id, status, error, file_path, size, created_at, completed_at, expires_at`
+
+func (r *Repo) Create(ctx context.Context, e *export.Export) error {
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO data_exports (user_id, status, created_at) VALUES (?, ?, ?)`,
+		e.UserID,
+		string(e.Status),
+		e.CreatedAt.UTC().Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	e.ID = int(id)
+	return nil
+}
+
+func (r *Repo) Get(ctx context.Context, userID, id int) (*export.Export, error) {
+	row := r.db.QueryRowContext(ctx,
+		`SELECT `+selectColumns+` FROM data_exports WHERE user_id = ? AND id = ?`,
+		userID,
+		id,
+	)
+	return scanExportRow(row)
+}
+
+func (r *Repo) GetActive(ctx context.Context, userID int) (*export.Export, error) {
+	row := r.db.QueryRowContext(ctx,
+		`SELECT `+selectColumns+` FROM data_exports
+		 WHERE user_id = ? AND status IN (?, ?)
+		 ORDER BY id DESC LIMIT 1`,
+		userID,
+		string(export.StatusPending),
+		string(export.StatusRunning),
+	)
+	return scanExportRow(row)
+}
+
+func (r *Repo) SetStatus(ctx context.Context, id int, status export.Status) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE data_exports SET status = ? WHERE id = ?`,
+		string(status),
+		id,
+	)
+	return err
+}
+
+func (r *Repo) Complete(ctx context.Context, id int, filePath string, size int64, at, expiresAt time.Time) error {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE data_exports SET status = ?, file_path = ?, size = ?, completed_at = ?, expires_at = ?
+		 WHERE id = ?`,
+		string(export.StatusReady),
+		filePath,
+		size,
+		at.UTC().Format(time.RFC3339Nano),
+		expiresAt.UTC().Format(time.RFC3339Nano),
+		id,
+	)
+	if err != nil {
+		return err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if aff == 0 {
+		return export.ErrNotFound
+	}
+	return nil
+}
+
+func (r *Repo) Fail(ctx context.Context, id int, msg string, at time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE data_exports SET status = ?, error = ?, completed_at = ? WHERE id = ?`,
+		string(export.StatusFailed),
+		msg,
+		at.UTC().Format(time.RFC3339Nano),
+		id,
+	)
+	return err
+}
+
+func (r *Repo) FailUnfinished(ctx context.Context, msg string, at time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE data_exports SET status = ?, error = ?, completed_at = ? WHERE status IN (?, ?)`,
+		string(export.StatusFailed),
+		msg,
+		at.UTC().Format(time.RFC3339Nano),
+		string(export.StatusPending),
+		string(export.StatusRunning),
+	)
+	return err
+}
+
+func (r *Repo) ListExpired(ctx context.Context, now time.Time) ([]export.Export, error) {
+	return r.list(ctx,
+		`SELECT `+selectColumns+` FROM data_exports WHERE expires_at IS NOT NULL AND expires_at <= ?`,
+		now.UTC().Format(time.RFC3339Nano),
+	)
+}
+
+func (r *Repo) ListForUser(ctx context.Context, userID int) ([]export.Export, error) {
+	return r.list(ctx, `SELECT `+selectColumns+` FROM data_exports WHERE user_id = ?`, userID)
+}
+
+func (r *Repo) list(ctx context.Context, query string, args ...any) ([]export.Export, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exports := make([]export.Export, 0)
+	for rows.Next() {
+		e, err := scanExport(rows)
+		if err != nil {
+			return nil, err
+		}
+		exports = append(exports, *e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return exports, nil
+}
+
+func (r *Repo) Delete(ctx context.Context, id int) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM data_exports WHERE id = ?`, id)
+	return err
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanExportRow(row *sql.Row) (*export.Export, error) {
+	e, err := scanExport(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, export.ErrNotFound
+		}
+		return nil, err
+	}
+	return e, nil
+}
+
+func scanExport(s scanner) (*export.Export, error) {
+	var (
+		e            export.Export
+		status       string
+		createdAtStr string
+		completedAt  sql.NullString
+		expiresAt    sql.NullString
+	)
+	if err := s.Scan(
+		&e.ID,
+		&e.UserID,
+		&status,
+		&e.Error,
+		&e.FilePath,
+		&e.Size,
+		&createdAtStr,
+		&completedAt,
+		&expiresAt,
+	); err != nil {
+		return nil, err
+	}
+	e.Status = export.Status(status)
+
+	var err error
+	if e.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAtStr); err != nil {
+		return nil, err
+	}
+	if e.CompletedAt, err = parseNullTime(completedAt); err != nil {
+		return nil, err
+	}
+	if e.ExpiresAt, err = parseNullTime(expiresAt); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func parseNullTime(s sql.NullString) (*time.Time, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
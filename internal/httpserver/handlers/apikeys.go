@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"task_scheduler/internal/apikey"
+	"task_scheduler/internal/auth"
+	"time"
+)
+
+type APIKeysHandler struct {
+	svc apikey.Service
+}
+
+func NewAPIKeysHandler(svc apikey.Service) *APIKeysHandler {
+	return &APIKeysHandler{
+		svc: svc,
+	}
+}
+
+type createAPIKeyRequest struct {
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	ExpiresAt *string  `json:"expires_at"`
+}
+
+type createAPIKeyResponse struct {
+	*apikey.APIKey
+	// Key is returned only once, on creation.
+	Key string `json:"key"`
+}
+
+type listAPIKeysResponse struct {
+	Data []apikey.APIKey `json:"data"`
+}
+
+func (h *APIKeysHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+
+	var req createAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_JSON", "invalid json")
+		return
+	}
+
+	// ключ не может получить больше прав, чем у того, кто его создаёт
+	granted, _ := auth.ScopesFromContext(r.Context())
+	if !auth.HasScopes(granted, req.Scopes...) {
+		auth.WriteInsufficientScope(w, req.Scopes)
+		return
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresAt != nil {
+		t, err := time.Parse(time.RFC3339, *req.ExpiresAt)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "VALIDATION_ERROR", "expires_at must be RFC3339")
+			return
+		}
+		expiresAt = &t
+	}
+
+	k, raw, err := h.svc.Create(r.Context(), userID, req.Name, req.Scopes, expiresAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, apikey.ErrInvalidInput):
+			WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+		default:
+			WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+			log.Println("[API_KEYS] create error:", err)
+		}
+		return
+	}
+	WriteJSON(w, http.StatusCreated, createAPIKeyResponse{APIKey: k, Key: raw})
+}
+
+func (h *APIKeysHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+
+	keys, err := h.svc.List(r.Context(), userID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		log.Println("[API_KEYS] list error:", err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, listAPIKeysResponse{Data: keys})
+}
+
+func (h *APIKeysHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		WriteError(w, http.StatusBadRequest, "INVALID_ID", "invalid id")
+		return
+	}
+
+	if err := h.svc.Revoke(r.Context(), userID, id); err != nil {
+		switch {
+		case errors.Is(err, apikey.ErrNotFound):
+			WriteError(w, http.StatusNotFound, "NOT_FOUND", err.Error())
+		case errors.Is(err, apikey.ErrInvalidInput):
+			WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+		default:
+			WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+			log.Println("[API_KEYS] revoke error:", err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
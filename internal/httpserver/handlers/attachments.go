@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"task_scheduler/internal/attachment"
+	"task_scheduler/internal/auth"
+	"task_scheduler/internal/task"
+	"time"
+)
+
+// multipartMemory is how much of an upload is kept in memory; the rest is
+// spooled to a temp file.
+const multipartMemory = 8 << 20
+
+// transferTimeout replaces the server's short read/write deadlines for
+// uploads and downloads of large files.
+const transferTimeout = 10 * time.Minute
+
+type AttachmentsHandler struct {
+	svc attachment.Service
+}
+
+func NewAttachmentsHandler(svc attachment.Service) *AttachmentsHandler {
+	return &AttachmentsHandler{svc: svc}
+}
+
+type listAttachmentsResponse struct {
+	Data []attachment.Attachment `json:"data"`
+}
+
+func (h *AttachmentsHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+
+	taskID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || taskID <= 0 {
+		WriteError(w, http.StatusBadRequest, "INVALID_ID", "invalid id")
+		return
+	}
+
+	_ = http.NewResponseController(w).SetReadDeadline(time.Now().Add(transferTimeout))
+	// запас на заголовки multipart сверх самого файла
+	r.Body = http.MaxBytesReader(w, r.Body, h.svc.MaxSize()+1<<20)
+	if err := r.ParseMultipartForm(multipartMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			WriteError(w, http.StatusRequestEntityTooLarge, "FILE_TOO_LARGE", attachment.ErrTooLarge.Error())
+			return
+		}
+		WriteError(w, http.StatusBadRequest, "INVALID_MULTIPART", "expected multipart/form-data with a file field")
+		return
+	}
+	defer func() { _ = r.MultipartForm.RemoveAll() }()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_MULTIPART", "expected multipart/form-data with a file field")
+		return
+	}
+	defer file.Close()
+
+	a, err := h.svc.Upload(r.Context(), userID, taskID, header.Filename, file, header.Size)
+	if err != nil {
+		writeAttachmentError(w, err, "upload")
+		return
+	}
+	WriteJSON(w, http.StatusCreated, a)
+}
+
+func (h *AttachmentsHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+
+	taskID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || taskID <= 0 {
+		WriteError(w, http.StatusBadRequest, "INVALID_ID", "invalid id")
+		return
+	}
+
+	list, err := h.svc.List(r.Context(), userID, taskID)
+	if err != nil {
+		writeAttachmentError(w, err, "list")
+		return
+	}
+	WriteJSON(w, http.StatusOK, listAttachmentsResponse{Data: list})
+}
+
+// Download serves the file; Range requests are handled by http.ServeContent.
+func (h *AttachmentsHandler) Download(w http.ResponseWriter, r *http.Request) {
+	userID, taskID, id, ok := attachmentPath(w, r)
+	if !ok {
+		return
+	}
+
+	a, content, err := h.svc.Open(r.Context(), userID, taskID, id)
+	if err != nil {
+		writeAttachmentError(w, err, "download")
+		return
+	}
+	defer content.Close()
+
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(transferTimeout))
+	w.Header().Set("Content-Type", a.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename}))
+	http.ServeContent(w, r, "", a.CreatedAt, content)
+}
+
+func (h *AttachmentsHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	userID, taskID, id, ok := attachmentPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Delete(r.Context(), userID, taskID, id); err != nil {
+		writeAttachmentError(w, err, "delete")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func attachmentPath(w http.ResponseWriter, r *http.Request) (userID, taskID, id int, ok bool) {
+	userID, ok = auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return 0, 0, 0, false
+	}
+
+	taskID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || taskID <= 0 {
+		WriteError(w, http.StatusBadRequest, "INVALID_ID", "invalid id")
+		return 0, 0, 0, false
+	}
+	id, err = strconv.Atoi(r.PathValue("attachment_id"))
+	if err != nil || id <= 0 {
+		WriteError(w, http.StatusBadRequest, "INVALID_ID", "invalid attachment id")
+		return 0, 0, 0, false
+	}
+	return userID, taskID, id, true
+}
+
+func writeAttachmentError(w http.ResponseWriter, err error, op string) {
+	switch {
+	case errors.Is(err, task.ErrNotFound):
+		WriteError(w, http.StatusNotFound, "NOT_FOUND", err.Error())
+	case errors.Is(err, attachment.ErrNotFound):
+		WriteError(w, http.StatusNotFound, "ATTACHMENT_NOT_FOUND", err.Error())
+	case errors.Is(err, task.ErrForbidden):
+		WriteError(w, http.StatusForbidden, "FORBIDDEN", err.Error())
+	case errors.Is(err, attachment.ErrTooLarge):
+		WriteError(w, http.StatusRequestEntityTooLarge, "FILE_TOO_LARGE", err.Error())
+	case errors.Is(err, attachment.ErrQuotaExceeded):
+		WriteError(w, http.StatusRequestEntityTooLarge, "QUOTA_EXCEEDED", err.Error())
+	case errors.Is(err, attachment.ErrInvalidInput), errors.Is(err, task.ErrInvalidInput):
+		WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+	default:
+		WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		log.Println("[ATTACHMENTS] "+op+" error:", err)
+	}
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"task_scheduler/internal/attachment"
+	"task_scheduler/internal/task"
+)
+
+type attachmentsFixture struct {
+	services testServices
+	h        *AttachmentsHandler
+	owner    int
+	viewer   int
+	taskID   int
+}
+
+// newAttachmentsFixture: задача владельца, расшаренная viewer'у; MaxSize 1024, квота 1536.
+func newAttachmentsFixture(t *testing.T) attachmentsFixture {
+	t.Helper()
+	services := newTestServices(t)
+	owner, err := services.users.Register("owner@example.com", "secret123")
+	require.NoError(t, err)
+	viewer, err := services.users.Register("viewer@example.com", "secret123")
+	require.NoError(t, err)
+	tsk, err := services.tasks.Create(t.Context(), owner.ID, 0, task.CreateTaskInput{Title: "With files"})
+	require.NoError(t, err)
+	_, err = services.tasks.Share(t.Context(), owner.ID, tsk.ID, "viewer@example.com", task.RoleViewer)
+	require.NoError(t, err)
+	return attachmentsFixture{
+		services: services,
+		h:        NewAttachmentsHandler(services.attachments),
+		owner:    owner.ID,
+		viewer:   viewer.ID,
+		taskID:   tsk.ID,
+	}
+}
+
+func (f attachmentsFixture) upload(t *testing.T, filename string, content []byte, asUser int) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("file", filename)
+	require.NoError(t, err)
+	_, err = part.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, mw.Close())
+
+	id := strconv.Itoa(f.taskID)
+	req := httptest.NewRequest(http.MethodPost, "/v1/tasks/"+id+"/attachments", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.SetPathValue("id", id)
+	rr := httptest.NewRecorder()
+	f.h.Upload(rr, withUser(req, asUser))
+	return rr
+}
+
+// uploadPNG кладёт 1000-байтный PNG под именем notes.txt.
+func (f attachmentsFixture) uploadPNG(t *testing.T) attachment.Attachment {
+	t.Helper()
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 992)...)
+	rr := f.upload(t, "../../notes.txt", png, f.owner)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var a attachment.Attachment
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&a))
+	return a
+}
+
+func TestAttachmentsHandler_Upload_DetectsTypeAndCleansName(t *testing.T) {
+	f := newAttachmentsFixture(t)
+
+	// PNG под видом .txt: тип берётся из содержимого
+	a := f.uploadPNG(t)
+	require.Equal(t, "notes.txt", a.Filename)
+	require.Equal(t, "image/png", a.ContentType)
+	require.EqualValues(t, 1000, a.Size)
+}
+
+func TestAttachmentsHandler_Upload_ViewerForbidden(t *testing.T) {
+	f := newAttachmentsFixture(t)
+
+	rr := f.upload(t, "more.bin", []byte("hello"), f.viewer)
+	require.Equal(t, http.StatusForbidden, rr.Code)
+}
+
+func TestAttachmentsHandler_Upload_TooLarge(t *testing.T) {
+	f := newAttachmentsFixture(t)
+
+	rr := f.upload(t, "huge.bin", bytes.Repeat([]byte("x"), 2048), f.owner)
+	require.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	require.Contains(t, rr.Body.String(), "FILE_TOO_LARGE")
+}
+
+func TestAttachmentsHandler_Upload_QuotaExceeded(t *testing.T) {
+	f := newAttachmentsFixture(t)
+	f.uploadPNG(t)
+
+	// сам файл меньше MaxSize, но вместе с первым не влезает в квоту
+	rr := f.upload(t, "big.bin", bytes.Repeat([]byte("x"), 600), f.owner)
+	require.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	require.Contains(t, rr.Body.String(), "QUOTA_EXCEEDED")
+}
+
+func TestAttachmentsHandler_Download_Range(t *testing.T) {
+	f := newAttachmentsFixture(t)
+	a := f.uploadPNG(t)
+
+	id := strconv.Itoa(f.taskID)
+	req := httptest.NewRequest(http.MethodGet, "/v1/tasks/"+id+"/attachments/"+strconv.Itoa(a.ID), nil)
+	req.SetPathValue("id", id)
+	req.SetPathValue("attachment_id", strconv.Itoa(a.ID))
+	req.Header.Set("Range", "bytes=1-3")
+	rr := httptest.NewRecorder()
+	f.h.Download(rr, withUser(req, f.viewer))
+	require.Equal(t, http.StatusPartialContent, rr.Code)
+	require.Equal(t, "PNG", rr.Body.String())
+	require.Equal(t, "image/png", rr.Header().Get("Content-Type"))
+	require.Contains(t, rr.Header().Get("Content-Disposition"), "notes.txt")
+}
+
+func TestAttachmentsHandler_PurgeOrphaned_AfterTaskDelete(t *testing.T) {
+	f := newAttachmentsFixture(t)
+	f.uploadPNG(t)
+	countBlobs := func() int {
+		n := 0
+		require.NoError(t, filepath.WalkDir(f.services.blobDir, func(_ string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				n++
+			}
+			return err
+		}))
+		return n
+	}
+	require.Equal(t, 1, countBlobs())
+
+	// после удаления задачи её файлы уходят из хранилища
+	require.NoError(t, f.services.tasks.Delete(t.Context(), f.owner, f.taskID))
+	require.NoError(t, f.services.attachments.PurgeOrphaned(t.Context()))
+	require.Zero(t, countBlobs())
+}
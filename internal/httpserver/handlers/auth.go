@@ -4,8 +4,14 @@ import (
 	"encoding/json"
 	"errors"
 	"log"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"task_scheduler/internal/auth"
+	"task_scheduler/internal/lockout"
+	"task_scheduler/internal/mfa"
 	"task_scheduler/internal/user"
 )
 
@@ -20,18 +26,86 @@ type loginRequest struct {
 }
 
 type loginResponse struct {
-	AccessToken string `json:"access-token"`
+	AccessToken  string `json:"access-token"`
+	RefreshToken string `json:"refresh-token"`
+	ExpiresIn    int    `json:"expires-in"`
+}
+
+// refreshTokenField accepts the refresh token as login responses spell it
+// ("refresh-token") and as refresh_token.
+type refreshTokenField struct {
+	RefreshToken      string `json:"refresh_token"`
+	RefreshTokenKebab string `json:"refresh-token"`
+}
+
+func (f refreshTokenField) token() string {
+	if f.RefreshToken != "" {
+		return f.RefreshToken
+	}
+	return f.RefreshTokenKebab
+}
+
+type refreshRequest struct {
+	refreshTokenField
+}
+
+type logoutRequest struct {
+	refreshTokenField
+}
+
+type forgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type verifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+type resendVerificationRequest struct {
+	Email string `json:"email"`
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+type mfaRequiredResponse struct {
+	MFARequired bool   `json:"mfa-required"`
+	MFAToken    string `json:"mfa-token"`
+}
+
+type mfaLoginRequest struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type mfaConfirmRequest struct {
+	Code string `json:"code"`
+}
+
+type mfaConfirmResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
 }
 
 type AuthHandler struct {
-	userSvc    user.Service
-	jwtManager *auth.JWTManager
+	userSvc user.Service
+	mfaSvc  mfa.Service
+	tokens  *auth.TokenService
+	guard   *lockout.Guard
+
+	// trustForwardedFor takes the client IP from X-Forwarded-For.
+	trustForwardedFor bool
 }
 
-func NewAuthHandler(svc user.Service, jwtManager *auth.JWTManager) *AuthHandler {
+func NewAuthHandler(svc user.Service, mfaSvc mfa.Service, tokens *auth.TokenService, guard *lockout.Guard, trustForwardedFor bool) *AuthHandler {
 	return &AuthHandler{
-		userSvc:    svc,
-		jwtManager: jwtManager,
+		userSvc:           svc,
+		mfaSvc:            mfaSvc,
+		tokens:            tokens,
+		guard:             guard,
+		trustForwardedFor: trustForwardedFor,
 	}
 }
 
@@ -47,6 +121,8 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		switch {
 		case errors.Is(err, user.ErrInvalidInput):
 			WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+		case errors.Is(err, user.ErrInvalidEmail):
+			WriteError(w, http.StatusBadRequest, "INVALID_EMAIL", err.Error())
 		case errors.Is(err, user.ErrEmailExists):
 			WriteError(w, http.StatusConflict, "EMAIL_EXISTS", err.Error())
 		default:
@@ -65,12 +141,22 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 1) email/IP под блокировкой или ещё не истекла пауза — 429 без проверки пароля
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	ip := clientIP(r, h.trustForwardedFor)
+	if wait := h.guard.Check(email, ip); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		WriteError(w, http.StatusTooManyRequests, "TOO_MANY_ATTEMPTS", "too many failed login attempts, try again later")
+		return
+	}
+
 	u, err := h.userSvc.Authenticate(req.Email, req.Password)
 	if err != nil {
 		switch {
 		case errors.Is(err, user.ErrInvalidInput):
 			WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
 		case errors.Is(err, user.ErrAuthFailed):
+			h.guard.Fail(r.Context(), email, ip)
 			WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED_USER", err.Error())
 		default:
 			WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
@@ -78,10 +164,350 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
-	token, err := h.jwtManager.Generate(u.ID)
+
+	// с 2FA пароль — только первый шаг: отдаём mfa pending токен, а счётчик
+	// неудач сбрасываем только после кода (иначе код можно перебирать,
+	// заново вводя пароль)
+	mfaEnabled, err := h.mfaSvc.IsEnabled(r.Context(), u.ID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		log.Println("[AUTH] mfa status error:", err)
+		return
+	}
+	if mfaEnabled {
+		pending, err := h.tokens.IssueMFAPending(u.ID)
+		if err != nil {
+			WriteError(w, http.StatusInternalServerError, "TOKEN_ERROR", "failed to generate token")
+			log.Println("[AUTH] issue mfa token error:", err)
+			return
+		}
+		WriteJSON(w, http.StatusOK, mfaRequiredResponse{MFARequired: true, MFAToken: pending})
+		return
+	}
+
+	pair, err := h.tokens.Issue(r.Context(), u.ID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "TOKEN_ERROR", "failed to generate token")
+		log.Println("[AUTH] issue tokens error:", err)
+		return
+	}
+	h.guard.Succeed(email)
+	WriteJSON(w, http.StatusOK, newLoginResponse(pair))
+}
+
+func (h *AuthHandler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var req mfaLoginRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_JSON", "invalid json")
+		return
+	}
+
+	pending, err := h.tokens.ParseMFAPending(req.MFAToken)
+	if err != nil {
+		WriteError(w, http.StatusUnauthorized, "INVALID_MFA_TOKEN", err.Error())
+		return
+	}
+
+	// неверные коды считаются теми же счётчиками, что и неверные пароли
+	u, err := h.userSvc.Get(r.Context(), pending.UserID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		log.Println("[AUTH] mfa get user error:", err)
+		return
+	}
+	email := strings.ToLower(u.Email)
+	ip := clientIP(r, h.trustForwardedFor)
+	if wait := h.guard.Check(email, ip); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		WriteError(w, http.StatusTooManyRequests, "TOO_MANY_ATTEMPTS", "too many failed login attempts, try again later")
+		return
+	}
+
+	if err := h.mfaSvc.Verify(r.Context(), pending.UserID, req.Code, req.RecoveryCode); err != nil {
+		switch {
+		case errors.Is(err, mfa.ErrInvalidInput):
+			WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+		case errors.Is(err, mfa.ErrInvalidCode):
+			h.guard.Fail(r.Context(), email, ip)
+			if err := h.tokens.RecordMFAFailure(r.Context(), pending); err != nil {
+				log.Println("[AUTH] record mfa failure error:", err)
+			}
+			WriteError(w, http.StatusUnauthorized, "INVALID_MFA_CODE", err.Error())
+		default:
+			WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+			log.Println("[AUTH] mfa verify error:", err)
+		}
+		return
+	}
+
+	pair, err := h.tokens.CompleteMFA(r.Context(), pending)
 	if err != nil {
 		WriteError(w, http.StatusInternalServerError, "TOKEN_ERROR", "failed to generate token")
+		log.Println("[AUTH] complete mfa error:", err)
+		return
+	}
+	h.guard.Succeed(email)
+	WriteJSON(w, http.StatusOK, newLoginResponse(pair))
+}
+
+func (h *AuthHandler) EnrollMFA(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+
+	u, err := h.userSvc.Get(r.Context(), userID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		log.Println("[AUTH] mfa enroll get user error:", err)
+		return
+	}
+
+	enrollment, err := h.mfaSvc.Enroll(r.Context(), userID, u.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, mfa.ErrAlreadyEnabled):
+			WriteError(w, http.StatusConflict, "MFA_ALREADY_ENABLED", err.Error())
+		default:
+			WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+			log.Println("[AUTH] mfa enroll error:", err)
+		}
+		return
+	}
+	WriteJSON(w, http.StatusOK, enrollment)
+}
+
+func (h *AuthHandler) ConfirmMFA(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
 		return
 	}
-	WriteJSON(w, http.StatusOK, loginResponse{AccessToken: token})
+
+	var req mfaConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_JSON", "invalid json")
+		return
+	}
+
+	codes, err := h.mfaSvc.Confirm(r.Context(), userID, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, mfa.ErrInvalidInput):
+			WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+		case errors.Is(err, mfa.ErrInvalidCode):
+			WriteError(w, http.StatusBadRequest, "INVALID_MFA_CODE", err.Error())
+		case errors.Is(err, mfa.ErrNotEnrolled):
+			WriteError(w, http.StatusConflict, "MFA_NOT_ENROLLED", err.Error())
+		case errors.Is(err, mfa.ErrAlreadyEnabled):
+			WriteError(w, http.StatusConflict, "MFA_ALREADY_ENABLED", err.Error())
+		default:
+			WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+			log.Println("[AUTH] mfa confirm error:", err)
+		}
+		return
+	}
+	WriteJSON(w, http.StatusOK, mfaConfirmResponse{RecoveryCodes: codes})
+}
+
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_JSON", "invalid json")
+		return
+	}
+
+	pair, err := h.tokens.Refresh(r.Context(), req.token())
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidRefreshToken):
+			WriteError(w, http.StatusUnauthorized, "INVALID_REFRESH_TOKEN", err.Error())
+		case errors.Is(err, auth.ErrRefreshTokenReused):
+			WriteError(w, http.StatusUnauthorized, "REFRESH_TOKEN_REUSED", err.Error())
+		default:
+			WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+			log.Println("[AUTH] refresh error:", err)
+		}
+		return
+	}
+	WriteJSON(w, http.StatusOK, newLoginResponse(pair))
+}
+
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	claims, ok := auth.ClaimsFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+
+	// тело необязательное: refresh_token нужен, только чтобы отозвать и его
+	var req logoutRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteError(w, http.StatusBadRequest, "INVALID_JSON", "invalid json")
+			return
+		}
+	}
+
+	if err := h.tokens.Logout(r.Context(), claims, req.token()); err != nil {
+		switch {
+		case errors.Is(err, auth.ErrInvalidToken):
+			WriteError(w, http.StatusBadRequest, "INVALID_TOKEN", "token cannot be revoked")
+		default:
+			WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+			log.Println("[AUTH] logout error:", err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AuthHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+
+	if err := h.tokens.LogoutAll(r.Context(), userID); err != nil {
+		WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		log.Println("[AUTH] logout all error:", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req forgotPasswordRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_JSON", "invalid json")
+		return
+	}
+
+	if err := h.userSvc.RequestPasswordReset(r.Context(), req.Email); err != nil {
+		switch {
+		case errors.Is(err, user.ErrInvalidInput):
+			WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+		default:
+			WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+			log.Println("[AUTH] forgot password error:", err)
+		}
+		return
+	}
+	// одинаковый ответ для существующих и несуществующих email
+	WriteJSON(w, http.StatusAccepted, map[string]string{
+		"message": "if the email is registered, a reset token has been sent",
+	})
+}
+
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req resetPasswordRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_JSON", "invalid json")
+		return
+	}
+
+	userID, err := h.userSvc.ResetPassword(r.Context(), req.Token, req.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrInvalidInput):
+			WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+		case errors.Is(err, user.ErrInvalidResetToken):
+			WriteError(w, http.StatusBadRequest, "INVALID_RESET_TOKEN", err.Error())
+		default:
+			WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+			log.Println("[AUTH] reset password error:", err)
+		}
+		return
+	}
+
+	// после сброса пароля все старые сессии недействительны
+	if err := h.tokens.LogoutAll(r.Context(), userID); err != nil {
+		WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		log.Println("[AUTH] revoke sessions after reset error:", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req verifyEmailRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_JSON", "invalid json")
+		return
+	}
+
+	if err := h.userSvc.VerifyEmail(r.Context(), req.Token); err != nil {
+		switch {
+		case errors.Is(err, user.ErrInvalidInput):
+			WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+		case errors.Is(err, user.ErrInvalidVerificationToken):
+			WriteError(w, http.StatusBadRequest, "INVALID_VERIFICATION_TOKEN", err.Error())
+		default:
+			WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+			log.Println("[AUTH] verify email error:", err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AuthHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var req resendVerificationRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_JSON", "invalid json")
+		return
+	}
+
+	if err := h.userSvc.ResendVerification(r.Context(), req.Email); err != nil {
+		switch {
+		case errors.Is(err, user.ErrInvalidInput):
+			WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+		default:
+			WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+			log.Println("[AUTH] resend verification error:", err)
+		}
+		return
+	}
+	// ответ не зависит от того, есть ли такой email и сработал ли throttling
+	WriteJSON(w, http.StatusAccepted, map[string]string{
+		"message": "if the email is registered and unverified, a new token has been sent",
+	})
+}
+
+func newLoginResponse(pair *auth.TokenPair) loginResponse {
+	return loginResponse{
+		AccessToken:  pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    int(pair.ExpiresIn.Seconds()),
+	}
+}
+
+// clientIP returns the caller's address without the port. With
+// trustForwardedFor it takes the last X-Forwarded-For entry: the one our
+// proxy appended. Entries before it come from the client and can be anything.
+func clientIP(r *http.Request, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		if fwd := r.Header.Values("X-Forwarded-For"); len(fwd) > 0 {
+			last := fwd[len(fwd)-1]
+			if i := strings.LastIndexByte(last, ','); i >= 0 {
+				last = last[i+1:]
+			}
+			if ip := strings.TrimSpace(last); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
 }
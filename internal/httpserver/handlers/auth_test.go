@@ -0,0 +1,685 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+
+	"task_scheduler/internal/auth"
+	authsqlite "task_scheduler/internal/auth/sqlite"
+	automationsqlite "task_scheduler/internal/automation/sqlite"
+	"task_scheduler/internal/export"
+	exportsqlite "task_scheduler/internal/export/sqlite"
+	"task_scheduler/internal/lockout"
+	"task_scheduler/internal/mail"
+	"task_scheduler/internal/mfa"
+	mfasqlite "task_scheduler/internal/mfa/sqlite"
+	"task_scheduler/internal/task"
+	tasksqlite "task_scheduler/internal/task/sqlite"
+	"task_scheduler/internal/template"
+	templatesqlite "task_scheduler/internal/template/sqlite"
+	"task_scheduler/internal/timetrack"
+	timetracksqlite "task_scheduler/internal/timetrack/sqlite"
+	"task_scheduler/internal/user"
+	usersqlite "task_scheduler/internal/user/sqlite"
+)
+
+func newTestAuthHandler(t *testing.T) (*AuthHandler, func(http.Handler) http.Handler) {
+	t.Helper()
+	return newTestAuthHandlerWithMail(t, io.Discard)
+}
+
+var testLockoutLimits = lockout.Limits{
+	MaxEmailFailures: 5,
+	MaxIPFailures:    50,
+	FreeAttempts:     3,
+	Window:           15 * time.Minute,
+	DelayBase:        time.Second,
+	MaxDelay:         30 * time.Second,
+	Lockout:          15 * time.Minute,
+	MaxLockout:       24 * time.Hour,
+}
+
+func newTestAuthHandlerWithMail(t *testing.T, mailOut io.Writer) (*AuthHandler, func(http.Handler) http.Handler) {
+	t.Helper()
+	return newTestAuthHandlerWith(t, mailOut, testLockoutLimits, false)
+}
+
+func newTestAuthHandlerWith(t *testing.T, mailOut io.Writer, limits lockout.Limits, trustForwardedFor bool) (*AuthHandler, func(http.Handler) http.Handler) {
+	t.Helper()
+
+	dbPath := filepath.Join(t.TempDir(), "auth.db")
+
+	db, err := sql.Open("sqlite", dbPath)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	require.NoError(t, db.Ping())
+	require.NoError(t, usersqlite.Migrate(db))
+	require.NoError(t, authsqlite.Migrate(db))
+	require.NoError(t, mfasqlite.Migrate(db))
+
+	userRepo := usersqlite.New(db)
+	userSvc := user.NewService(userRepo, userRepo, userRepo, mail.NewWriterMailer(mailOut, "test@example.com"), user.Options{
+		ResetTTL:        time.Hour,
+		VerificationTTL: time.Hour,
+		ResendInterval:  time.Minute,
+	})
+	jwtManager := auth.NewJWTManager("test-secret", 15*time.Minute)
+	authRepo := authsqlite.New(db)
+	revocations := auth.NewRevocations(authRepo)
+	tokens := auth.NewTokenService(jwtManager, authRepo, revocations, time.Hour)
+	mfaRepo := mfasqlite.New(db)
+	userSvc.SetAccountData(tokens, mfaRepo)
+	guard := lockout.NewGuard(limits, nil)
+	return NewAuthHandler(userSvc, mfa.NewService(mfaRepo), tokens, guard, trustForwardedFor), auth.JWTMiddleware(jwtManager, revocations, nil)
+}
+
+func doAuthRequest(h http.HandlerFunc, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader([]byte(body)))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	h(rr, req)
+	return rr
+}
+
+func loginTestUser(t *testing.T, h *AuthHandler) loginResponse {
+	t.Helper()
+
+	creds := `{"email":"user@example.com","password":"secret123"}`
+	rr := doAuthRequest(h.Register, "/v1/auth/register", creds)
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	rr = doAuthRequest(h.Login, "/v1/auth/login", creds)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var resp loginResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.NotEmpty(t, resp.AccessToken)
+	require.NotEmpty(t, resp.RefreshToken)
+	return resp
+}
+
+func TestAuthHandler_Refresh_Rotates(t *testing.T) {
+	h, _ := newTestAuthHandler(t)
+	first := loginTestUser(t, h)
+
+	rr := doAuthRequest(h.Refresh, "/v1/auth/refresh", `{"refresh_token":"`+first.RefreshToken+`"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var second loginResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&second))
+	require.NotEmpty(t, second.AccessToken)
+	require.NotEqual(t, first.RefreshToken, second.RefreshToken)
+}
+
+func TestAuthHandler_Refresh_AcceptsLoginResponseSpelling(t *testing.T) {
+	h, _ := newTestAuthHandler(t)
+	first := loginTestUser(t, h)
+
+	// клиент отправляет обратно поле ровно так, как получил
+	body, err := json.Marshal(map[string]string{"refresh-token": first.RefreshToken})
+	require.NoError(t, err)
+	rr := doAuthRequest(h.Refresh, "/v1/auth/refresh", string(body))
+	require.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestAuthHandler_Refresh_ReuseRevokesFamily(t *testing.T) {
+	h, _ := newTestAuthHandler(t)
+	first := loginTestUser(t, h)
+
+	rr := doAuthRequest(h.Refresh, "/v1/auth/refresh", `{"refresh_token":"`+first.RefreshToken+`"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var second loginResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&second))
+
+	// старый токен ещё раз => reuse
+	rr = doAuthRequest(h.Refresh, "/v1/auth/refresh", `{"refresh_token":"`+first.RefreshToken+`"}`)
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	var er ErrorResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&er))
+	require.Equal(t, "REFRESH_TOKEN_REUSED", er.Error.Code)
+
+	// вся семья отозвана — новый токен тоже не работает
+	rr = doAuthRequest(h.Refresh, "/v1/auth/refresh", `{"refresh_token":"`+second.RefreshToken+`"}`)
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestAuthHandler_Logout_RevokesTokens(t *testing.T) {
+	h, authMW := newTestAuthHandler(t)
+	tokens := loginTestUser(t, h)
+
+	protected := authMW(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	call := func(handler http.Handler, body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(body)))
+		req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	require.Equal(t, http.StatusOK, call(protected, ""))
+	require.Equal(t, http.StatusNoContent, call(authMW(http.HandlerFunc(h.Logout)), `{"refresh_token":"`+tokens.RefreshToken+`"}`))
+
+	// access токен больше не принимается
+	require.Equal(t, http.StatusUnauthorized, call(protected, ""))
+
+	// refresh токен тоже отозван
+	rr := doAuthRequest(h.Refresh, "/v1/auth/refresh", `{"refresh_token":"`+tokens.RefreshToken+`"}`)
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestRequireScopes_InsufficientScope(t *testing.T) {
+	protected := auth.RequireScopes(auth.ScopeTasksWrite)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/tasks", nil)
+	req = req.WithContext(auth.WithScopes(req.Context(), []string{auth.ScopeTasksRead}))
+	rr := httptest.NewRecorder()
+
+	protected.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusForbidden, rr.Code)
+
+	var er ErrorResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&er))
+	require.Equal(t, "INSUFFICIENT_SCOPE", er.Error.Code)
+}
+
+func TestRequireSession_RejectsAPIKeys(t *testing.T) {
+	protected := auth.RequireSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// API ключ: пользователь и scopes есть, claims нет
+	req := httptest.NewRequest(http.MethodPost, "/v1/auth/mfa/enroll", nil)
+	ctx := auth.WithScopes(auth.WithUserID(req.Context(), userID), auth.AllScopes)
+	rr := httptest.NewRecorder()
+	protected.ServeHTTP(rr, req.WithContext(ctx))
+	require.Equal(t, http.StatusForbidden, rr.Code)
+	var er ErrorResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&er))
+	require.Equal(t, "SESSION_REQUIRED", er.Error.Code)
+
+	rr = httptest.NewRecorder()
+	protected.ServeHTTP(rr, req.WithContext(auth.WithClaims(ctx, &auth.Claims{UserID: userID})))
+	require.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestAuthHandler_ResetPassword(t *testing.T) {
+	var mailbox bytes.Buffer
+	h, authMW := newTestAuthHandlerWithMail(t, &mailbox)
+	tokens := loginTestUser(t, h)
+
+	rr := doAuthRequest(h.ForgotPassword, "/v1/auth/password/forgot", `{"email":"user@example.com"}`)
+	require.Equal(t, http.StatusAccepted, rr.Code)
+
+	// последнее письмо — сброс пароля (до него было подтверждение email)
+	sent := mailedTokens(mailbox.String())
+	require.NotEmpty(t, sent)
+	resetToken := sent[len(sent)-1]
+
+	rr = doAuthRequest(h.ResetPassword, "/v1/auth/password/reset", `{"token":"`+resetToken+`","password":"newsecret"}`)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+
+	// токен одноразовый
+	rr = doAuthRequest(h.ResetPassword, "/v1/auth/password/reset", `{"token":"`+resetToken+`","password":"another1"}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// старые сессии закрыты
+	protected := authMW(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	rr = httptest.NewRecorder()
+	protected.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	rr = doAuthRequest(h.Login, "/v1/auth/login", `{"email":"user@example.com","password":"newsecret"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	// новый токен, выданный сразу после сброса, работает
+	var fresh loginResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&fresh))
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+fresh.AccessToken)
+	rr = httptest.NewRecorder()
+	protected.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestAuthHandler_ForgotPassword_UnknownEmail(t *testing.T) {
+	var mailbox bytes.Buffer
+	h, _ := newTestAuthHandlerWithMail(t, &mailbox)
+
+	rr := doAuthRequest(h.ForgotPassword, "/v1/auth/password/forgot", `{"email":"nobody@example.com"}`)
+	require.Equal(t, http.StatusAccepted, rr.Code)
+	require.Zero(t, mailbox.Len())
+}
+
+// mailedTokens extracts tokens (a line of 43 base64url chars) from mail output.
+func mailedTokens(out string) []string {
+	var tokens []string
+	for _, line := range strings.Split(out, "\n") {
+		if len(line) == 43 && !strings.ContainsAny(line, " :") {
+			tokens = append(tokens, line)
+		}
+	}
+	return tokens
+}
+
+func TestAuthHandler_VerifyEmail(t *testing.T) {
+	var mailbox bytes.Buffer
+	h, _ := newTestAuthHandlerWithMail(t, &mailbox)
+
+	rr := doAuthRequest(h.Register, "/v1/auth/register", `{"email":"user@example.com","password":"secret123"}`)
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	var u user.User
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&u))
+
+	verified, err := h.userSvc.IsVerified(t.Context(), u.ID)
+	require.NoError(t, err)
+	require.False(t, verified)
+
+	sent := mailedTokens(mailbox.String())
+	require.Len(t, sent, 1)
+
+	rr = doAuthRequest(h.VerifyEmail, "/v1/auth/verify", `{"token":"`+sent[0]+`"}`)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+
+	verified, err = h.userSvc.IsVerified(t.Context(), u.ID)
+	require.NoError(t, err)
+	require.True(t, verified)
+
+	// повторно тот же токен не принимается
+	rr = doAuthRequest(h.VerifyEmail, "/v1/auth/verify", `{"token":"`+sent[0]+`"}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestAuthHandler_Register_InvalidEmail(t *testing.T) {
+	h, _ := newTestAuthHandler(t)
+
+	rr := doAuthRequest(h.Register, "/v1/auth/register", `{"email":"not-an-email","password":"secret123"}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	var er ErrorResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&er))
+	require.Equal(t, "INVALID_EMAIL", er.Error.Code)
+}
+
+// totpAt computes an RFC 6238 code the way an authenticator app would.
+func totpAt(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	require.NoError(t, err)
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(at.Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	off := sum[len(sum)-1] & 0x0f
+	bin := binary.BigEndian.Uint32(sum[off:off+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", bin%1_000_000)
+}
+
+func TestAuthHandler_LoginMFA(t *testing.T) {
+	h, authMW := newTestAuthHandler(t)
+	tokens := loginTestUser(t, h)
+
+	call := func(handler http.HandlerFunc, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(body)))
+		req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+		rr := httptest.NewRecorder()
+		authMW(handler).ServeHTTP(rr, req)
+		return rr
+	}
+
+	// 1) enroll + confirm
+	rr := call(h.EnrollMFA, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var enrollment mfa.Enrollment
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&enrollment))
+	require.True(t, strings.HasPrefix(enrollment.URI, "otpauth://totp/"))
+
+	rr = call(h.ConfirmMFA, `{"code":"`+totpAt(t, enrollment.Secret, time.Now())+`"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var confirmed mfaConfirmResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&confirmed))
+	require.Len(t, confirmed.RecoveryCodes, 10)
+
+	// 2) логин теперь требует второй шаг
+	rr = doAuthRequest(h.Login, "/v1/auth/login", `{"email":"user@example.com","password":"secret123"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var pending mfaRequiredResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&pending))
+	require.True(t, pending.MFARequired)
+
+	// pending токен не пускает к API
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+pending.MFAToken)
+	rr = httptest.NewRecorder()
+	authMW(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rr, req)
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	// 3) неверный код, затем код восстановления
+	rr = doAuthRequest(h.LoginMFA, "/v1/auth/mfa", `{"mfa_token":"`+pending.MFAToken+`","recovery_code":"nope"}`)
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	body := `{"mfa_token":"` + pending.MFAToken + `","recovery_code":"` + confirmed.RecoveryCodes[0] + `"}`
+	rr = doAuthRequest(h.LoginMFA, "/v1/auth/mfa", body)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var done loginResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&done))
+	require.NotEmpty(t, done.AccessToken)
+
+	// pending токен и код восстановления одноразовые
+	rr = doAuthRequest(h.LoginMFA, "/v1/auth/mfa", body)
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestLoginMFA_WrongCodesCountAsFailedLogins(t *testing.T) {
+	h, authMW := newTestAuthHandler(t)
+	tokens := loginTestUser(t, h)
+
+	call := func(handler http.HandlerFunc, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(body)))
+		req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+		rr := httptest.NewRecorder()
+		authMW(handler).ServeHTTP(rr, req)
+		return rr
+	}
+	rr := call(h.EnrollMFA, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var enrollment mfa.Enrollment
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&enrollment))
+	rr = call(h.ConfirmMFA, `{"code":"`+totpAt(t, enrollment.Secret, time.Now())+`"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	// верный пароль не сбрасывает счётчик, пока не введён код
+	creds := `{"email":"user@example.com","password":"secret123"}`
+	for i := 0; i < 4; i++ {
+		rr = doAuthRequest(h.Login, "/v1/auth/login", creds)
+		require.Equal(t, http.StatusOK, rr.Code)
+		var pending mfaRequiredResponse
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&pending))
+		rr = doAuthRequest(h.LoginMFA, "/v1/auth/mfa", `{"mfa_token":"`+pending.MFAToken+`","code":"000000"}`)
+		require.Equal(t, http.StatusUnauthorized, rr.Code)
+	}
+
+	rr = doAuthRequest(h.Login, "/v1/auth/login", creds)
+	require.Equal(t, http.StatusTooManyRequests, rr.Code)
+}
+
+func TestLogin_ThrottlesFailedAttempts(t *testing.T) {
+	h, _ := newTestAuthHandler(t)
+	loginTestUser(t, h)
+
+	wrong := `{"email":"user@example.com","password":"wrong-password"}`
+	for i := 0; i < 3; i++ {
+		rr := doAuthRequest(h.Login, "/v1/auth/login", wrong)
+		require.Equal(t, http.StatusUnauthorized, rr.Code)
+	}
+
+	// после бесплатных попыток включается пауза
+	rr := doAuthRequest(h.Login, "/v1/auth/login", wrong)
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	rr = doAuthRequest(h.Login, "/v1/auth/login", `{"email":"user@example.com","password":"secret123"}`)
+	require.Equal(t, http.StatusTooManyRequests, rr.Code)
+	require.Equal(t, "1", rr.Header().Get("Retry-After"))
+
+	// другой email с того же IP пока не заблокирован
+	rr = doAuthRequest(h.Login, "/v1/auth/login", `{"email":"other@example.com","password":"secret123"}`)
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		trusted bool
+		fwd     []string
+		want    string
+	}{
+		{"not behind a proxy", false, []string{"203.0.113.7"}, "192.0.2.1"},
+		{"no header", true, nil, "192.0.2.1"},
+		{"proxy entry", true, []string{"203.0.113.7"}, "203.0.113.7"},
+		{"spoofed prefix", true, []string{"1.2.3.4, 203.0.113.7"}, "203.0.113.7"},
+		{"spoofed header before the proxy's", true, []string{"1.2.3.4", "203.0.113.7"}, "203.0.113.7"},
+		{"empty last entry", true, []string{"1.2.3.4, "}, "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/auth/login", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			for _, v := range tt.fwd {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			require.Equal(t, tt.want, clientIP(req, tt.trusted))
+		})
+	}
+}
+
+func TestLogin_SpoofedForwardedForDoesNotDodgeIPLockout(t *testing.T) {
+	limits := testLockoutLimits
+	limits.MaxIPFailures = 3
+	limits.FreeAttempts = 10
+	h, _ := newTestAuthHandlerWith(t, io.Discard, limits, true)
+
+	// каждый раз новый email и новый поддельный адрес в начале заголовка,
+	// а прокси дописывает настоящий
+	login := func(i int) int {
+		req := httptest.NewRequest(http.MethodPost, "/v1/auth/login",
+			strings.NewReader(`{"email":"u`+strconv.Itoa(i)+`@example.com","password":"wrong-password"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", "10.0.0."+strconv.Itoa(i)+", 203.0.113.7")
+		rr := httptest.NewRecorder()
+		h.Login(rr, req)
+		return rr.Code
+	}
+	for i := range limits.MaxIPFailures {
+		require.Equal(t, http.StatusUnauthorized, login(i))
+	}
+	require.Equal(t, http.StatusTooManyRequests, login(limits.MaxIPFailures))
+}
+
+func TestMeHandler_UpdateChangePasswordDelete(t *testing.T) {
+	h, authMW := newTestAuthHandler(t)
+	me := NewMeHandler(h.userSvc, h.tokens)
+	tokens := loginTestUser(t, h)
+
+	call := func(handler http.HandlerFunc, method, body, access string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/v1/me", bytes.NewReader([]byte(body)))
+		req.Header.Set("Authorization", "Bearer "+access)
+		rr := httptest.NewRecorder()
+		authMW(handler).ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := call(me.Update, http.MethodPatch, `{"display_name":"Ann","timezone":"Europe/Berlin","locale":"de-DE"}`, tokens.AccessToken)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var profile meResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&profile))
+	require.Equal(t, "Ann", profile.DisplayName)
+	require.Equal(t, "Europe/Berlin", profile.Timezone)
+	require.Equal(t, "de-DE", profile.Locale)
+
+	rr = call(me.Update, http.MethodPatch, `{"timezone":"Mars/Olympus"}`, tokens.AccessToken)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// неверный текущий пароль
+	rr = call(me.ChangePassword, http.MethodPost, `{"current_password":"nope","new_password":"newsecret"}`, tokens.AccessToken)
+	require.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr = call(me.ChangePassword, http.MethodPost, `{"current_password":"secret123","new_password":"newsecret"}`, tokens.AccessToken)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var fresh loginResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&fresh))
+
+	// старый access токен отозван, новый работает
+	rr = call(me.Get, http.MethodGet, "", tokens.AccessToken)
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+	rr = call(me.Get, http.MethodGet, "", fresh.AccessToken)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	// удаление требует текущий пароль
+	rr = call(me.Delete, http.MethodDelete, `{}`, fresh.AccessToken)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = call(me.Delete, http.MethodDelete, `{"current_password":"secret123"}`, fresh.AccessToken)
+	require.Equal(t, http.StatusForbidden, rr.Code)
+	rr = call(me.Get, http.MethodGet, "", fresh.AccessToken)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	rr = call(me.Delete, http.MethodDelete, `{"current_password":"newsecret"}`, fresh.AccessToken)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	rr = call(me.Get, http.MethodGet, "", fresh.AccessToken)
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	rr = doAuthRequest(h.Login, "/v1/auth/login", `{"email":"user@example.com","password":"newsecret"}`)
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestExportHandler_BuildsAndServesZip(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "export.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	require.NoError(t, usersqlite.Migrate(db))
+	require.NoError(t, tasksqlite.Migrate(db))
+	require.NoError(t, exportsqlite.Migrate(db))
+	require.NoError(t, timetracksqlite.Migrate(db))
+	require.NoError(t, templatesqlite.Migrate(db))
+	require.NoError(t, automationsqlite.Migrate(db))
+
+	ctx := t.Context()
+	userRepo := usersqlite.New(db)
+	taskRepo := tasksqlite.New(db)
+	timeRepo := timetracksqlite.New(db)
+	templateRepo := templatesqlite.New(db)
+	u := &user.User{Email: "user@example.com", PasswordHash: "x", CreatedAt: time.Now().UTC()}
+	require.NoError(t, userRepo.Create(ctx, u))
+	_, err = task.NewService(taskRepo, taskRepo, taskRepo, nil, nil, nil, nil, task.Options{}).Create(ctx, u.ID, 0, task.CreateTaskInput{Title: "=SUM(A1)"})
+	require.NoError(t, err)
+
+	// задачи воркспейса: созданная пользователем и чужая, где он исполнитель
+	now := time.Now().UTC()
+	created := &task.Task{UserID: u.ID, WorkspaceID: 7, CreatedBy: u.ID, Title: "created in workspace", Status: task.StatusPending, CreatedAt: now, UpdatedAt: now}
+	require.NoError(t, taskRepo.Create(ctx, created, 0))
+	assigned := &task.Task{UserID: u.ID + 1, WorkspaceID: 7, CreatedBy: u.ID + 1, AssigneeID: &u.ID, Title: "assigned in workspace", Status: task.StatusPending, CreatedAt: now, UpdatedAt: now}
+	require.NoError(t, taskRepo.Create(ctx, assigned, 0))
+	foreign := &task.Task{UserID: u.ID + 1, WorkspaceID: 7, CreatedBy: u.ID + 1, Title: "not mine", Status: task.StatusPending, CreatedAt: now, UpdatedAt: now}
+	require.NoError(t, taskRepo.Create(ctx, foreign, 0))
+
+	require.NoError(t, taskRepo.CreateComment(ctx, &task.Comment{TaskID: assigned.ID, AuthorID: u.ID, Body: "my comment", CreatedAt: now}))
+	ended := now.Add(-time.Hour)
+	require.NoError(t, timeRepo.Create(ctx, &timetrack.Entry{
+		TaskID: created.ID, UserID: u.ID, Source: timetrack.SourceManual, Note: "tracked",
+		StartedAt: ended.Add(-30 * time.Minute), EndedAt: &ended, CreatedAt: now,
+	}))
+	require.NoError(t, templateRepo.Create(ctx, &template.Template{
+		UserID: u.ID, Name: "my template", Items: []template.Item{{Title: "step"}}, CreatedAt: now, UpdatedAt: now,
+	}))
+
+	dir := t.TempDir()
+	exports := export.NewService(exportsqlite.New(db), export.Sources{
+		Users:       userRepo,
+		Tasks:       taskRepo,
+		Comments:    taskRepo,
+		TimeEntries: timeRepo,
+		Templates:   templateRepo,
+		Automations: automationsqlite.New(db),
+	}, export.Options{
+		Dir: dir,
+		TTL: time.Hour,
+	})
+	h := NewExportHandler(exports)
+	call := func(handler http.HandlerFunc, method, target, id string) *httptest.ResponseRecorder {
+		req := withUser(httptest.NewRequest(method, target, nil), u.ID)
+		req.SetPathValue("id", id)
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		return rr
+	}
+
+	rr := call(h.Request, http.MethodPost, "/v1/me/export", "")
+	require.Equal(t, http.StatusAccepted, rr.Code)
+	var started export.Export
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&started))
+	id := fmt.Sprint(started.ID)
+
+	// сборка асинхронная — ждём готовности
+	require.Eventually(t, func() bool {
+		rr := call(h.Get, http.MethodGet, "/v1/me/export/"+id, id)
+		var e export.Export
+		return json.NewDecoder(rr.Body).Decode(&e) == nil && e.Status == export.StatusReady
+	}, 5*time.Second, 20*time.Millisecond)
+
+	rr = call(h.Get, http.MethodGet, "/v1/me/export/"+id+"?download=1", id)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "application/zip", rr.Header().Get("Content-Type"))
+
+	zr, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+	require.NoError(t, err)
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		b, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		_ = rc.Close()
+		files[f.Name] = string(b)
+	}
+	require.Contains(t, files["profile.json"], "user@example.com")
+	require.Contains(t, files["tasks.json"], "=SUM(A1)")
+	require.Contains(t, files["tasks.json"], "created in workspace")
+	require.Contains(t, files["tasks.json"], "assigned in workspace")
+	require.NotContains(t, files["tasks.json"], "not mine")
+	require.Contains(t, files["comments.csv"], "my comment")
+	require.Contains(t, files["time_entries.csv"], "tracked")
+	require.Contains(t, files["time_entries.json"], `"duration_seconds": 1800`)
+	require.Contains(t, files["templates.json"], "my template")
+	require.Equal(t, "[]\n", files["automation_rules.json"])
+	// в CSV формула обезврежена
+	require.Contains(t, files["tasks.csv"], "'=SUM(A1)")
+
+	// чужую выгрузку не видно
+	req := withUser(httptest.NewRequest(http.MethodGet, "/v1/me/export/"+id, nil), u.ID+1)
+	req.SetPathValue("id", id)
+	rr = httptest.NewRecorder()
+	h.Get(rr, req)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+
+	// с аккаунтом уходят и выгрузки, и их архивы
+	require.NoError(t, exports.DeleteAccountData(ctx, u.ID))
+	rr = call(h.Get, http.MethodGet, "/v1/me/export/"+id, id)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	archives, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, archives)
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"task_scheduler/internal/auth"
+	"task_scheduler/internal/automation"
+	"task_scheduler/internal/task"
+)
+
+type AutomationsHandler struct {
+	svc automation.Service
+}
+
+func NewAutomationsHandler(svc automation.Service) *AutomationsHandler {
+	return &AutomationsHandler{svc: svc}
+}
+
+type automationRuleRequest struct {
+	Name string `json:"name"`
+	// Enabled defaults to true.
+	Enabled    *bool                  `json:"enabled"`
+	Trigger    string                 `json:"trigger"`
+	Conditions []automation.Condition `json:"conditions"`
+	Actions    []automation.Action    `json:"actions"`
+}
+
+func (req automationRuleRequest) input() automation.RuleInput {
+	enabled := req.Enabled == nil || *req.Enabled
+	return automation.RuleInput{
+		Name:       req.Name,
+		Enabled:    enabled,
+		Trigger:    task.EventType(req.Trigger),
+		Conditions: req.Conditions,
+		Actions:    req.Actions,
+	}
+}
+
+type dryRunRequest struct {
+	Rule           automationRuleRequest `json:"rule"`
+	TaskID         int                   `json:"task_id"`
+	PreviousStatus string                `json:"previous_status"`
+}
+
+type listAutomationsResponse struct {
+	Data []automation.Rule `json:"data"`
+}
+
+func (h *AutomationsHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+
+	workspaceID, err := workspaceFromRequest(r)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_WORKSPACE", err.Error())
+		return
+	}
+
+	var req automationRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_JSON", "invalid json")
+		return
+	}
+	if !webhookScope(w, r, req.Actions) {
+		return
+	}
+
+	rule, err := h.svc.Create(r.Context(), userID, workspaceID, req.input())
+	if err != nil {
+		writeAutomationError(w, err, "create")
+		return
+	}
+	WriteJSON(w, http.StatusCreated, rule)
+}
+
+func (h *AutomationsHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+
+	workspaceID, err := workspaceFromRequest(r)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_WORKSPACE", err.Error())
+		return
+	}
+
+	rules, err := h.svc.List(r.Context(), userID, workspaceID)
+	if err != nil {
+		writeAutomationError(w, err, "list")
+		return
+	}
+	for i := range rules {
+		rules[i] = rules[i].Redacted()
+	}
+	WriteJSON(w, http.StatusOK, listAutomationsResponse{Data: rules})
+}
+
+func (h *AutomationsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := automationPath(w, r)
+	if !ok {
+		return
+	}
+
+	rule, err := h.svc.Get(r.Context(), userID, id)
+	if err != nil {
+		writeAutomationError(w, err, "get")
+		return
+	}
+	WriteJSON(w, http.StatusOK, rule.Redacted())
+}
+
+// Update replaces the rule with the body.
+func (h *AutomationsHandler) Update(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := automationPath(w, r)
+	if !ok {
+		return
+	}
+
+	var req automationRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_JSON", "invalid json")
+		return
+	}
+	if !webhookScope(w, r, req.Actions) {
+		return
+	}
+
+	rule, err := h.svc.Update(r.Context(), userID, id, req.input())
+	if err != nil {
+		writeAutomationError(w, err, "update")
+		return
+	}
+	WriteJSON(w, http.StatusOK, rule.Redacted())
+}
+
+func (h *AutomationsHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := automationPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Delete(r.Context(), userID, id); err != nil {
+		writeAutomationError(w, err, "delete")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DryRun evaluates {"rule": {...}, "task_id": N, "previous_status": "..."}
+// and reports which conditions hold and what would run, changing nothing.
+func (h *AutomationsHandler) DryRun(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+
+	workspaceID, err := workspaceFromRequest(r)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_WORKSPACE", err.Error())
+		return
+	}
+
+	var req dryRunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_JSON", "invalid json")
+		return
+	}
+
+	res, err := h.svc.DryRun(r.Context(), userID, workspaceID, req.Rule.input(), req.TaskID, task.Status(req.PreviousStatus))
+	if err != nil {
+		writeAutomationError(w, err, "dry run")
+		return
+	}
+	WriteJSON(w, http.StatusOK, res)
+}
+
+// webhookScope lets a rule with a webhook action through only with the
+// webhooks:manage scope: the rule sends task data to an arbitrary URL.
+func webhookScope(w http.ResponseWriter, r *http.Request, actions []automation.Action) bool {
+	for _, a := range actions {
+		if a.Type != automation.ActionWebhook {
+			continue
+		}
+		granted, _ := auth.ScopesFromContext(r.Context())
+		if !auth.HasScopes(granted, auth.ScopeWebhooksManage) {
+			auth.WriteInsufficientScope(w, []string{auth.ScopeWebhooksManage})
+			return false
+		}
+		return true
+	}
+	return true
+}
+
+func automationPath(w http.ResponseWriter, r *http.Request) (userID, id int, ok bool) {
+	userID, ok = auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return 0, 0, false
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		WriteError(w, http.StatusBadRequest, "INVALID_ID", "invalid id")
+		return 0, 0, false
+	}
+	return userID, id, true
+}
+
+func writeAutomationError(w http.ResponseWriter, err error, op string) {
+	switch {
+	case errors.Is(err, automation.ErrNotFound):
+		WriteError(w, http.StatusNotFound, "AUTOMATION_NOT_FOUND", err.Error())
+	case errors.Is(err, task.ErrNotFound):
+		WriteError(w, http.StatusNotFound, "NOT_FOUND", err.Error())
+	case errors.Is(err, automation.ErrForbidden):
+		WriteError(w, http.StatusForbidden, "FORBIDDEN", err.Error())
+	case errors.Is(err, task.ErrForbidden):
+		WriteError(w, http.StatusForbidden, "FORBIDDEN", "rules only run on tasks of their own space")
+	case errors.Is(err, automation.ErrInvalidRule):
+		WriteError(w, http.StatusBadRequest, "INVALID_RULE", err.Error())
+	case errors.Is(err, automation.ErrInvalidInput), errors.Is(err, task.ErrInvalidInput):
+		WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+	case errors.Is(err, automation.ErrTooManyRules):
+		WriteError(w, http.StatusConflict, "TOO_MANY_RULES", err.Error())
+	default:
+		WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		log.Println("[AUTOMATION] "+op+" error:", err)
+	}
+}
@@ -0,0 +1,314 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"task_scheduler/internal/auth"
+	"task_scheduler/internal/automation"
+	"task_scheduler/internal/task"
+	"task_scheduler/internal/workspace"
+)
+
+type automationsFixture struct {
+	services testServices
+	mux      *http.ServeMux
+}
+
+func newAutomationsFixture(t *testing.T) automationsFixture {
+	t.Helper()
+	services := newTestServices(t)
+	h := NewAutomationsHandler(services.automations)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/automations", h.Create)
+	mux.HandleFunc("GET /v1/automations", h.List)
+	mux.HandleFunc("GET /v1/automations/{id}", h.Get)
+	mux.HandleFunc("PUT /v1/automations/{id}", h.Update)
+	mux.HandleFunc("POST /v1/automations/dry-run", h.DryRun)
+	mux.HandleFunc("DELETE /v1/automations/{id}", h.Delete)
+	mux.HandleFunc("GET /v1/workspaces/{workspace_id}/automations", h.List)
+	mux.HandleFunc("POST /v1/workspaces/{workspace_id}/automations", h.Create)
+	return automationsFixture{services: services, mux: mux}
+}
+
+// do sends the request with the scopes of an interactive login.
+func (f automationsFixture) do(method, target, body string) *httptest.ResponseRecorder {
+	return f.doScoped(method, target, body, auth.AllScopes...)
+}
+
+func (f automationsFixture) doScoped(method, target, body string, scopes ...string) *httptest.ResponseRecorder {
+	return f.doAs(method, target, body, userID, scopes...)
+}
+
+func (f automationsFixture) doAs(method, target, body string, asUser int, scopes ...string) *httptest.ResponseRecorder {
+	req := withUser(httptest.NewRequest(method, target, strings.NewReader(body)), asUser)
+	req = req.WithContext(auth.WithScopes(req.Context(), scopes))
+	rr := httptest.NewRecorder()
+	f.mux.ServeHTTP(rr, req)
+	return rr
+}
+
+func (f automationsFixture) createRule(t *testing.T, body string) automation.Rule {
+	t.Helper()
+	rr := f.do(http.MethodPost, "/v1/automations", body)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var rule automation.Rule
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&rule))
+	return rule
+}
+
+func (f automationsFixture) createTask(t *testing.T, in task.CreateTaskInput) *task.Task {
+	t.Helper()
+	tsk, err := f.services.tasks.Create(t.Context(), userID, 0, in)
+	require.NoError(t, err)
+	return tsk
+}
+
+func (f automationsFixture) titles(t *testing.T) []string {
+	t.Helper()
+	tasks, _, _, err := f.services.tasks.List(t.Context(), userID, task.ListOwned, task.ListOptions{IncludeHidden: true}, 100, 0)
+	require.NoError(t, err)
+	out := make([]string, 0, len(tasks))
+	for _, tsk := range tasks {
+		out = append(out, tsk.Title)
+	}
+	return out
+}
+
+// hookServer collects webhook bodies.
+func hookServer(t *testing.T) (string, chan map[string]any) {
+	t.Helper()
+	hooks := make(chan map[string]any, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		hooks <- body
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, hooks
+}
+
+const invoicesRule = `{"name":"invoices","trigger":"task.created",
+	"conditions":[{"field":"title","op":"contains","value":"invoice"}],
+	"actions":[{"type":"create_task","title":"Send {{title}}","due_in":"48h"}]}`
+
+func TestAutomationsHandler_Create_InvalidRule(t *testing.T) {
+	f := newAutomationsFixture(t)
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"unknown field", `{"name":"bad","trigger":"task.created","conditions":[{"field":"color","op":"eq","value":"red"}],"actions":[{"type":"set_status","status":"done"}]}`},
+		{"op doesn't fit field", `{"name":"bad","trigger":"task.created","conditions":[{"field":"title","op":"gt","value":1}],"actions":[{"type":"set_status","status":"done"}]}`},
+		// задача «в срок сейчас» запускала бы правило снова и снова
+		{"due follow-up due at once", `{"name":"loop","trigger":"task.due","actions":[{"type":"create_task","title":"Again","due_in":"0s"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := f.do(http.MethodPost, "/v1/automations", tt.body)
+			require.Equal(t, http.StatusBadRequest, rr.Code)
+			require.Contains(t, rr.Body.String(), "INVALID_RULE")
+		})
+	}
+}
+
+func TestAutomationsHandler_Created_AddsFollowUp(t *testing.T) {
+	f := newAutomationsFixture(t)
+	rule := f.createRule(t, invoicesRule)
+	require.True(t, rule.Enabled)
+
+	f.createTask(t, task.CreateTaskInput{Title: "Invoice ACME"})
+	f.createTask(t, task.CreateTaskInput{Title: "Groceries"})
+	// follow-up создан правилом, но само правило на нём не повторяется
+	require.ElementsMatch(t, []string{"Invoice ACME", "Send Invoice ACME", "Groceries"}, f.titles(t))
+}
+
+func TestAutomationsHandler_Created_SendsWebhook(t *testing.T) {
+	f := newAutomationsFixture(t)
+	url, hooks := hookServer(t)
+	f.createRule(t, `{"name":"notify","trigger":"task.created","actions":[{"type":"webhook","url":"`+url+`"}]}`)
+
+	f.createTask(t, task.CreateTaskInput{Title: "Invoice ACME"})
+	select {
+	case hook := <-hooks:
+		require.Equal(t, "task.created", hook["event"])
+		require.Equal(t, "Invoice ACME", hook["task"].(map[string]any)["title"])
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not sent")
+	}
+}
+
+func TestAutomationsHandler_WebhookNeedsWebhooksScope(t *testing.T) {
+	f := newAutomationsFixture(t)
+	webhook := `{"name":"notify","trigger":"task.created","actions":[{"type":"webhook","url":"https://example.com/hook"}]}`
+
+	rr := f.doScoped(http.MethodPost, "/v1/automations", webhook, auth.ScopeTasksRead, auth.ScopeTasksWrite)
+	require.Equal(t, http.StatusForbidden, rr.Code)
+	require.Contains(t, rr.Body.String(), "INSUFFICIENT_SCOPE")
+
+	// правила без вебхука обходятся tasks:write
+	rr = f.doScoped(http.MethodPost, "/v1/automations", invoicesRule, auth.ScopeTasksRead, auth.ScopeTasksWrite)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var rule automation.Rule
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&rule))
+
+	// и вебхук нельзя добавить заменой правила
+	rr = f.doScoped(http.MethodPut, "/v1/automations/"+strconv.Itoa(rule.ID), webhook, auth.ScopeTasksRead, auth.ScopeTasksWrite)
+	require.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr = f.doScoped(http.MethodPost, "/v1/automations", webhook, auth.ScopeTasksWrite, auth.ScopeWebhooksManage)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+}
+
+func TestAutomationsHandler_WebhookSecret_OnlyShownAtCreation(t *testing.T) {
+	f := newAutomationsFixture(t)
+	rule := f.createRule(t, `{"name":"notify","trigger":"task.created","actions":[{"type":"webhook","url":"https://example.com/hook","secret":"s3cr3t"}]}`)
+	require.Equal(t, "s3cr3t", rule.Actions[0].Secret)
+	target := "/v1/automations/" + strconv.Itoa(rule.ID)
+
+	for _, path := range []string{"/v1/automations", target} {
+		rr := f.do(http.MethodGet, path, "")
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.NotContains(t, rr.Body.String(), "s3cr3t")
+	}
+
+	// замена без secret — как после GET — подпись не теряет
+	rr := f.do(http.MethodPut, target, `{"name":"renamed","trigger":"task.created","actions":[{"type":"webhook","url":"https://example.com/hook"}]}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.NotContains(t, rr.Body.String(), "s3cr3t")
+	stored, err := f.services.automations.Get(t.Context(), userID, rule.ID)
+	require.NoError(t, err)
+	require.Equal(t, "renamed", stored.Name)
+	require.Equal(t, "s3cr3t", stored.Actions[0].Secret)
+}
+
+func TestAutomationsHandler_Delete_StopsRule(t *testing.T) {
+	f := newAutomationsFixture(t)
+	rule := f.createRule(t, invoicesRule)
+
+	require.Equal(t, http.StatusNoContent, f.do(http.MethodDelete, "/v1/automations/"+strconv.Itoa(rule.ID), "").Code)
+	require.Equal(t, http.StatusNotFound, f.do(http.MethodDelete, "/v1/automations/"+strconv.Itoa(rule.ID), "").Code)
+	f.createTask(t, task.CreateTaskInput{Title: "Invoice ACME"})
+	require.Equal(t, []string{"Invoice ACME"}, f.titles(t))
+}
+
+func TestAutomationsHandler_StatusRules_DoNotLoop(t *testing.T) {
+	f := newAutomationsFixture(t)
+	// правила перекидывают статус друг другу
+	f.createRule(t, `{"name":"reopen","trigger":"task.status_changed","conditions":[{"field":"status","op":"eq","value":"done"},{"field":"title","op":"contains","value":"ping"}],"actions":[{"type":"set_status","status":"pending"}]}`)
+	f.createRule(t, `{"name":"close","trigger":"task.status_changed","conditions":[{"field":"status","op":"eq","value":"pending"},{"field":"title","op":"contains","value":"ping"}],"actions":[{"type":"set_status","status":"done"}]}`)
+
+	svc := f.services.tasks
+	ping := f.createTask(t, task.CreateTaskInput{Title: "ping"})
+	done := string(task.StatusDone)
+	_, err := svc.Update(t.Context(), userID, ping.ID, task.UpdateTaskInput{Status: &done})
+	require.NoError(t, err)
+	ping, err = svc.Get(t.Context(), userID, ping.ID)
+	require.NoError(t, err)
+	require.Equal(t, task.StatusDone, ping.Status)
+}
+
+func TestAutomationsHandler_DryRun_ShowsEachCondition(t *testing.T) {
+	f := newAutomationsFixture(t)
+	tsk := f.createTask(t, task.CreateTaskInput{Title: "ping"})
+
+	rr := f.do(http.MethodPost, "/v1/automations/dry-run", `{"task_id":`+strconv.Itoa(tsk.ID)+`,"previous_status":"pending",
+		"rule":{"name":"check","trigger":"task.status_changed","conditions":[{"field":"previous_status","op":"eq","value":"pending"},{"field":"due_at","op":"not_empty"}],"actions":[{"type":"set_status","status":"canceled"}]}}`)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var dry automation.DryRun
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&dry))
+	require.False(t, dry.Matched)
+	require.Len(t, dry.Conditions, 2)
+	require.True(t, dry.Conditions[0].Matched)
+	require.False(t, dry.Conditions[1].Matched)
+	require.Empty(t, dry.Actions)
+
+	// dry run ничего не меняет
+	got, err := f.services.tasks.Get(t.Context(), userID, tsk.ID)
+	require.NoError(t, err)
+	require.Equal(t, task.StatusPending, got.Status)
+}
+
+func TestAutomationsHandler_DryRun_OtherUsersTask(t *testing.T) {
+	f := newAutomationsFixture(t)
+	other, err := f.services.tasks.Create(t.Context(), userID+1, 0, task.CreateTaskInput{Title: "Someone else's"})
+	require.NoError(t, err)
+
+	rr := f.do(http.MethodPost, "/v1/automations/dry-run", `{"task_id":`+strconv.Itoa(other.ID)+`,"rule":{"name":"x","trigger":"task.created","actions":[{"type":"set_status","status":"done"}]}}`)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestAutomationsHandler_Due_FiresOncePerDueDate(t *testing.T) {
+	f := newAutomationsFixture(t)
+	f.createRule(t, `{"name":"chase","trigger":"task.due","actions":[{"type":"create_task","title":"Chase {{title}}"}]}`)
+	due := time.Now().UTC()
+	f.createTask(t, task.CreateTaskInput{Title: "Report", DueAt: &due})
+
+	require.NoError(t, f.services.automationEngine.SweepDue(t.Context()))
+	require.NoError(t, f.services.automationEngine.SweepDue(t.Context()))
+	require.ElementsMatch(t, []string{"Report", "Chase Report"}, f.titles(t))
+}
+
+func TestAutomationsHandler_WorkspaceRules(t *testing.T) {
+	f := newAutomationsFixture(t)
+	ctx := t.Context()
+	admin, err := f.services.users.Register("admin@example.com", "secret123")
+	require.NoError(t, err)
+	ws, err := f.services.workspaces.Create(ctx, admin.ID, "Acme")
+	require.NoError(t, err)
+	member := f.services.join(t, admin.ID, ws.ID, "member@example.com", workspace.RoleMember)
+	guest := f.services.join(t, admin.ID, ws.ID, "guest@example.com", workspace.RoleGuest)
+	outsider, err := f.services.users.Register("outsider@example.com", "secret123")
+	require.NoError(t, err)
+	wsRules := "/v1/workspaces/" + strconv.Itoa(ws.ID) + "/automations"
+	doAs := func(method, target, body string, asUser int) *httptest.ResponseRecorder {
+		return f.doAs(method, target, body, asUser, auth.AllScopes...)
+	}
+
+	require.Equal(t, http.StatusForbidden, doAs(http.MethodPost, wsRules, invoicesRule, guest).Code)
+	require.Equal(t, http.StatusNotFound, doAs(http.MethodPost, wsRules, invoicesRule, outsider.ID).Code)
+	rr := doAs(http.MethodPost, wsRules, invoicesRule, admin.ID)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var rule automation.Rule
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&rule))
+	require.Equal(t, ws.ID, rule.WorkspaceID)
+	ruleURL := "/v1/automations/" + strconv.Itoa(rule.ID)
+	rr = doAs(http.MethodPost, wsRules, `{"name":"chase","trigger":"task.due","actions":[{"type":"create_task","title":"Chase {{title}}"}]}`, member)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	// личное правило участника на задачи workspace не срабатывает
+	rr = doAs(http.MethodPost, "/v1/automations", `{"name":"mine","trigger":"task.created","actions":[{"type":"create_task","title":"Mine {{title}}"}]}`, member)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	// правила workspace срабатывают на задачи любого участника
+	_, err = f.services.tasks.Create(ctx, member, ws.ID, task.CreateTaskInput{Title: "invoice ACME"})
+	require.NoError(t, err)
+	due := time.Now().UTC()
+	_, err = f.services.tasks.Create(ctx, member, ws.ID, task.CreateTaskInput{Title: "Report", DueAt: &due})
+	require.NoError(t, err)
+	require.NoError(t, f.services.automationEngine.SweepDue(ctx))
+	tasks, _, _, err := f.services.tasks.ListWorkspace(ctx, admin.ID, ws.ID, task.ListOptions{IncludeHidden: true}, 100, 0)
+	require.NoError(t, err)
+	titles := make([]string, 0, len(tasks))
+	for _, tsk := range tasks {
+		titles = append(titles, tsk.Title)
+	}
+	require.ElementsMatch(t, []string{"invoice ACME", "Send invoice ACME", "Report", "Chase Report"}, titles)
+
+	// правила видят все участники, и только в списке workspace
+	rr = doAs(http.MethodGet, wsRules, "", guest)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), "invoices")
+	require.NotContains(t, doAs(http.MethodGet, "/v1/automations", "", member).Body.String(), "invoices")
+	require.Equal(t, http.StatusNotFound, doAs(http.MethodGet, wsRules, "", outsider.ID).Code)
+	require.Equal(t, http.StatusNotFound, doAs(http.MethodGet, ruleURL, "", outsider.ID).Code)
+
+	require.Equal(t, http.StatusForbidden, doAs(http.MethodDelete, ruleURL, "", guest).Code)
+	require.Equal(t, http.StatusNoContent, doAs(http.MethodDelete, ruleURL, "", member).Code)
+}
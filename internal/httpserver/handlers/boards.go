@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"task_scheduler/internal/auth"
+	"task_scheduler/internal/task"
+	"task_scheduler/internal/workspace"
+)
+
+// BoardsHandler serves the kanban board of a workspace (the {project} of
+// the routes): tasks in status columns, with WIP limits per column.
+type BoardsHandler struct {
+	tasks      task.Service
+	workspaces workspace.Service
+}
+
+func NewBoardsHandler(tasks task.Service, workspaces workspace.Service) *BoardsHandler {
+	return &BoardsHandler{tasks: tasks, workspaces: workspaces}
+}
+
+// wipLimitsRequest replaces the limits: {"limits": {"pending": 5}}; 0 or a
+// missing column means no limit.
+type wipLimitsRequest struct {
+	Limits map[string]int `json:"limits"`
+}
+
+type wipLimitsResponse struct {
+	Limits map[string]int `json:"limits"`
+}
+
+// Get returns the board; ?limit= caps the tasks listed per column.
+func (h *BoardsHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID, workspaceID, ok := boardPath(w, r)
+	if !ok {
+		return
+	}
+
+	perColumn := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "VALIDATION_ERROR", "limit must be a number")
+			return
+		}
+		perColumn = n
+	}
+
+	board, err := h.tasks.Board(r.Context(), userID, workspaceID, perColumn)
+	if err != nil {
+		switch {
+		case errors.Is(err, task.ErrNotFound):
+			WriteError(w, http.StatusNotFound, "NOT_FOUND", "workspace not found")
+		case errors.Is(err, task.ErrInvalidInput):
+			WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+		default:
+			WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+			log.Println("[BOARDS] get error:", err)
+		}
+		return
+	}
+	WriteJSON(w, http.StatusOK, board)
+}
+
+// SetWIPLimits replaces the board's WIP limits. Workspace admins only.
+func (h *BoardsHandler) SetWIPLimits(w http.ResponseWriter, r *http.Request) {
+	userID, workspaceID, ok := boardPath(w, r)
+	if !ok {
+		return
+	}
+
+	var req wipLimitsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_JSON", "invalid json")
+		return
+	}
+	// колонки доски — статусы задач
+	for column := range req.Limits {
+		if !task.Status(column).Valid() {
+			WriteError(w, http.StatusBadRequest, "VALIDATION_ERROR", "unknown board column \""+column+"\"")
+			return
+		}
+	}
+
+	limits, err := h.workspaces.SetWIPLimits(r.Context(), userID, workspaceID, req.Limits)
+	if err != nil {
+		writeWorkspaceError(w, err, "set wip limits")
+		return
+	}
+	WriteJSON(w, http.StatusOK, wipLimitsResponse{Limits: limits})
+}
+
+func boardPath(w http.ResponseWriter, r *http.Request) (userID, workspaceID int, ok bool) {
+	userID, ok = auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return 0, 0, false
+	}
+
+	workspaceID, err := strconv.Atoi(r.PathValue("project"))
+	if err != nil || workspaceID <= 0 {
+		WriteError(w, http.StatusBadRequest, "INVALID_WORKSPACE", errInvalidWorkspaceID.Error())
+		return 0, 0, false
+	}
+	return userID, workspaceID, true
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"task_scheduler/internal/automation"
+	"task_scheduler/internal/task"
+	"task_scheduler/internal/template"
+)
+
+type boardsFixture struct {
+	services testServices
+	mux      *http.ServeMux
+	admin    int
+	outsider int
+	ws       int
+	// tasks: One, Two, Three — все в pending
+	tasks []int
+}
+
+func newBoardsFixture(t *testing.T) boardsFixture {
+	t.Helper()
+	services := newTestServices(t)
+	ctx := t.Context()
+	admin, err := services.users.Register("admin@example.com", "secret123")
+	require.NoError(t, err)
+	outsider, err := services.users.Register("outsider@example.com", "secret123")
+	require.NoError(t, err)
+	ws, err := services.workspaces.Create(ctx, admin.ID, "Acme")
+	require.NoError(t, err)
+
+	th := NewTasksHandler(services.tasks)
+	bh := NewBoardsHandler(services.tasks, services.workspaces)
+	mux := http.NewServeMux()
+	mux.HandleFunc("PATCH /v1/tasks/{id}", th.Update)
+	mux.HandleFunc("POST /v1/workspaces/{workspace_id}/tasks", th.Create)
+	mux.HandleFunc("POST /v1/templates/{id}/instantiate", NewTemplatesHandler(services.templates).Instantiate)
+	mux.HandleFunc("GET /v1/boards/{project}", bh.Get)
+	mux.HandleFunc("PUT /v1/boards/{project}/wip-limits", bh.SetWIPLimits)
+
+	f := boardsFixture{services: services, mux: mux, admin: admin.ID, outsider: outsider.ID, ws: ws.ID}
+	for _, title := range []string{"One", "Two", "Three"} {
+		tsk, err := services.tasks.Create(ctx, admin.ID, ws.ID, task.CreateTaskInput{Title: title})
+		require.NoError(t, err)
+		f.tasks = append(f.tasks, tsk.ID)
+	}
+	return f
+}
+
+func (f boardsFixture) do(method, target, body string, asUser int) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	f.mux.ServeHTTP(rr, withUser(req, asUser))
+	return rr
+}
+
+func (f boardsFixture) board() string {
+	return "/v1/boards/" + strconv.Itoa(f.ws)
+}
+
+func (f boardsFixture) get(t *testing.T) task.Board {
+	t.Helper()
+	rr := f.do(http.MethodGet, f.board(), "", f.admin)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var b task.Board
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&b))
+	return b
+}
+
+func (f boardsFixture) setLimits(t *testing.T, limits string) {
+	t.Helper()
+	rr := f.do(http.MethodPut, f.board()+"/wip-limits", `{"limits":`+limits+`}`, f.admin)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+}
+
+func (f boardsFixture) setStatus(id int, status string) *httptest.ResponseRecorder {
+	return f.do(http.MethodPatch, "/v1/tasks/"+strconv.Itoa(id), `{"status":"`+status+`"}`, f.admin)
+}
+
+func TestBoardsHandler_Get_ColumnsAreStatuses(t *testing.T) {
+	f := newBoardsFixture(t)
+
+	b := f.get(t)
+	require.Equal(t, f.ws, b.WorkspaceID)
+	require.Len(t, b.Columns, 3)
+	require.Equal(t, task.StatusPending, b.Columns[0].Status)
+	require.Equal(t, 3, b.Columns[0].Count)
+	require.Nil(t, b.Columns[0].WIPLimit)
+	require.Equal(t, "One", b.Columns[0].Tasks[0].Title)
+	require.Empty(t, b.Columns[1].Tasks)
+}
+
+func TestBoardsHandler_Get_OutsiderNotFound(t *testing.T) {
+	f := newBoardsFixture(t)
+
+	require.Equal(t, http.StatusNotFound, f.do(http.MethodGet, f.board(), "", f.outsider).Code)
+}
+
+func TestBoardsHandler_SetWIPLimits_Invalid(t *testing.T) {
+	f := newBoardsFixture(t)
+	tests := []struct {
+		name   string
+		limits string
+		asUser int
+		want   int
+	}{
+		// лимиты задаёт только админ
+		{"outsider", `{"done":1}`, f.outsider, http.StatusNotFound},
+		{"unknown column", `{"review":1}`, f.admin, http.StatusBadRequest},
+		{"negative", `{"done":-1}`, f.admin, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := f.do(http.MethodPut, f.board()+"/wip-limits", `{"limits":`+tt.limits+`}`, tt.asUser)
+			require.Equal(t, tt.want, rr.Code)
+		})
+	}
+	require.Nil(t, f.get(t).Columns[1].WIPLimit)
+}
+
+func TestBoardsHandler_SetWIPLimits_ShownOnBoard(t *testing.T) {
+	f := newBoardsFixture(t)
+	// 0 снимает лимит
+	f.setLimits(t, `{"done":1,"canceled":0}`)
+
+	b := f.get(t)
+	require.Nil(t, b.Columns[0].WIPLimit)
+	require.NotNil(t, b.Columns[1].WIPLimit)
+	require.Equal(t, 1, *b.Columns[1].WIPLimit)
+	require.Nil(t, b.Columns[2].WIPLimit)
+}
+
+func TestBoardsHandler_WIPLimit_BlocksMovesIntoFullColumn(t *testing.T) {
+	f := newBoardsFixture(t)
+	f.setLimits(t, `{"done":1}`)
+
+	require.Equal(t, http.StatusOK, f.setStatus(f.tasks[0], "done").Code)
+	rr := f.setStatus(f.tasks[1], "done")
+	require.Equal(t, http.StatusConflict, rr.Code)
+	require.Contains(t, rr.Body.String(), "WIP_LIMIT_EXCEEDED")
+	// из заполненной колонки уходить можно
+	require.Equal(t, http.StatusOK, f.setStatus(f.tasks[1], "canceled").Code)
+
+	b := f.get(t)
+	require.Equal(t, []int{1, 1, 1}, []int{b.Columns[0].Count, b.Columns[1].Count, b.Columns[2].Count})
+}
+
+func TestBoardsHandler_WIPLimit_ClearedLimitAllowsMoves(t *testing.T) {
+	f := newBoardsFixture(t)
+	f.setLimits(t, `{"done":1}`)
+	require.Equal(t, http.StatusOK, f.setStatus(f.tasks[0], "done").Code)
+
+	f.setLimits(t, `{}`)
+	require.Equal(t, http.StatusOK, f.setStatus(f.tasks[1], "done").Code)
+	require.Equal(t, 2, f.get(t).Columns[1].Count)
+}
+
+func TestBoardsHandler_WIPLimit_AppliesToEveryWayToCreate(t *testing.T) {
+	f := newBoardsFixture(t)
+	ctx := t.Context()
+	f.setLimits(t, `{"pending":4}`)
+	_, err := f.services.automations.Create(ctx, f.admin, f.ws, automation.RuleInput{
+		Name:    "follow-up",
+		Enabled: true,
+		Trigger: task.EventCreated,
+		Actions: []automation.Action{{Type: automation.ActionCreateTask, Title: "Follow up"}},
+	})
+	require.NoError(t, err)
+	tasksURL := "/v1/workspaces/" + strconv.Itoa(f.ws) + "/tasks"
+
+	// 1) четвёртая задача влезает, а follow-up от правила — уже нет
+	require.Equal(t, http.StatusCreated, f.do(http.MethodPost, tasksURL, `{"title":"Four"}`, f.admin).Code)
+	b := f.get(t)
+	require.Equal(t, 4, b.Columns[0].Count)
+	for _, tsk := range b.Columns[0].Tasks {
+		require.NotEqual(t, "Follow up", tsk.Title)
+	}
+
+	// 2) напрямую
+	rr := f.do(http.MethodPost, tasksURL, `{"title":"Five"}`, f.admin)
+	require.Equal(t, http.StatusConflict, rr.Code)
+	require.Contains(t, rr.Body.String(), "WIP_LIMIT_EXCEEDED")
+
+	// 3) из шаблона
+	tpl, err := f.services.templates.Create(ctx, f.admin, 0, template.Input{Name: "Release", Items: []template.Item{{Title: "Build"}}})
+	require.NoError(t, err)
+	rr = f.do(http.MethodPost, "/v1/templates/"+strconv.Itoa(tpl.ID)+"/instantiate", `{"workspace_id":`+strconv.Itoa(f.ws)+`}`, f.admin)
+	require.Equal(t, http.StatusConflict, rr.Code)
+	require.Contains(t, rr.Body.String(), "WIP_LIMIT_EXCEEDED")
+
+	require.Equal(t, 4, f.get(t).Columns[0].Count)
+}
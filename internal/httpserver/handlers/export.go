@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"task_scheduler/internal/auth"
+	"task_scheduler/internal/export"
+)
+
+type ExportHandler struct {
+	svc export.Service
+}
+
+func NewExportHandler(svc export.Service) *ExportHandler {
+	return &ExportHandler{
+		svc: svc,
+	}
+}
+
+type exportResponse struct {
+	*export.Export
+	// DownloadURL is set once the archive is ready.
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+func newExportResponse(e *export.Export) exportResponse {
+	resp := exportResponse{Export: e}
+	if e.Status == export.StatusReady {
+		resp.DownloadURL = "/v1/me/export/" + strconv.Itoa(e.ID) + "?download=1"
+	}
+	return resp
+}
+
+func (h *ExportHandler) Request(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+
+	e, err := h.svc.Request(r.Context(), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, export.ErrInvalidInput):
+			WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+		default:
+			WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+			log.Println("[EXPORT] request error:", err)
+		}
+		return
+	}
+	w.Header().Set("Location", "/v1/me/export/"+strconv.Itoa(e.ID))
+	WriteJSON(w, http.StatusAccepted, newExportResponse(e))
+}
+
+// Get reports the export status; with ?download=1 it serves the ZIP.
+func (h *ExportHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		WriteError(w, http.StatusBadRequest, "INVALID_ID", "invalid id")
+		return
+	}
+
+	if r.URL.Query().Get("download") == "" {
+		e, err := h.svc.Get(r.Context(), userID, id)
+		if err != nil {
+			writeExportError(w, err)
+			return
+		}
+		WriteJSON(w, http.StatusOK, newExportResponse(e))
+		return
+	}
+
+	e, f, err := h.svc.Open(r.Context(), userID, id)
+	if err != nil {
+		writeExportError(w, err)
+		return
+	}
+	defer f.Close()
+
+	name := "export-" + strconv.Itoa(e.ID) + ".zip"
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	http.ServeContent(w, r, name, *e.CompletedAt, f)
+}
+
+func writeExportError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, export.ErrInvalidInput):
+		WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+	case errors.Is(err, export.ErrNotFound):
+		WriteError(w, http.StatusNotFound, "NOT_FOUND", "export not found")
+	case errors.Is(err, export.ErrNotReady):
+		WriteError(w, http.StatusConflict, "EXPORT_NOT_READY", err.Error())
+	case errors.Is(err, export.ErrExpired):
+		WriteError(w, http.StatusGone, "EXPORT_EXPIRED", err.Error())
+	default:
+		WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		log.Println("[EXPORT] get error:", err)
+	}
+}
@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"net/http"
+	"task_scheduler/internal/auth"
+)
+
+// JWKS publishes the public keys that verify our access tokens.
+func JWKS(jwtManager *auth.JWTManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		WriteJSON(w, http.StatusOK, jwtManager.JWKS())
+	}
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"task_scheduler/internal/auth"
+	"task_scheduler/internal/user"
+	"task_scheduler/internal/workspace"
+	"time"
+)
+
+type MeHandler struct {
+	userSvc user.Service
+	tokens  *auth.TokenService
+}
+
+func NewMeHandler(userSvc user.Service, tokens *auth.TokenService) *MeHandler {
+	return &MeHandler{
+		userSvc: userSvc,
+		tokens:  tokens,
+	}
+}
+
+type meResponse struct {
+	ID          int        `json:"id"`
+	Email       string     `json:"email"`
+	DisplayName string     `json:"display_name"`
+	Timezone    string     `json:"timezone"`
+	Locale      string     `json:"locale"`
+	CreatedAt   time.Time  `json:"created_at"`
+	VerifiedAt  *time.Time `json:"verified_at"`
+}
+
+type updateMeRequest struct {
+	DisplayName *string `json:"display_name"`
+	Timezone    *string `json:"timezone"`
+	Locale      *string `json:"locale"`
+}
+
+type deleteMeRequest struct {
+	CurrentPassword string `json:"current_password"`
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+func newMeResponse(u *user.User) meResponse {
+	return meResponse{
+		ID:          u.ID,
+		Email:       u.Email,
+		DisplayName: u.DisplayName,
+		Timezone:    u.Timezone,
+		Locale:      u.Locale,
+		CreatedAt:   u.CreatedAt,
+		VerifiedAt:  u.VerifiedAt,
+	}
+}
+
+func (h *MeHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+
+	u, err := h.userSvc.Get(r.Context(), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrNotFound):
+			WriteError(w, http.StatusNotFound, "NOT_FOUND", "user not found")
+		default:
+			WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+			log.Println("[ME] get error:", err)
+		}
+		return
+	}
+	WriteJSON(w, http.StatusOK, newMeResponse(u))
+}
+
+func (h *MeHandler) Update(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+
+	var req updateMeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_JSON", "invalid json")
+		return
+	}
+
+	u, err := h.userSvc.UpdateProfile(r.Context(), userID, user.ProfileUpdate{
+		DisplayName: req.DisplayName,
+		Timezone:    req.Timezone,
+		Locale:      req.Locale,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrInvalidDisplayName):
+			WriteError(w, http.StatusBadRequest, "INVALID_DISPLAY_NAME", err.Error())
+		case errors.Is(err, user.ErrInvalidTimezone):
+			WriteError(w, http.StatusBadRequest, "INVALID_TIMEZONE", err.Error())
+		case errors.Is(err, user.ErrInvalidLocale):
+			WriteError(w, http.StatusBadRequest, "INVALID_LOCALE", err.Error())
+		case errors.Is(err, user.ErrNotFound):
+			WriteError(w, http.StatusNotFound, "NOT_FOUND", "user not found")
+		default:
+			WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+			log.Println("[ME] update error:", err)
+		}
+		return
+	}
+	WriteJSON(w, http.StatusOK, newMeResponse(u))
+}
+
+func (h *MeHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+
+	var req changePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_JSON", "invalid json")
+		return
+	}
+
+	if err := h.userSvc.ChangePassword(r.Context(), userID, req.CurrentPassword, req.NewPassword); err != nil {
+		switch {
+		case errors.Is(err, user.ErrInvalidInput):
+			WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+		case errors.Is(err, user.ErrWrongPassword):
+			WriteError(w, http.StatusForbidden, "WRONG_PASSWORD", err.Error())
+		case errors.Is(err, user.ErrNotFound):
+			WriteError(w, http.StatusNotFound, "NOT_FOUND", "user not found")
+		default:
+			WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+			log.Println("[ME] change password error:", err)
+		}
+		return
+	}
+
+	// 1) старые сессии (в том числе текущая) больше не действуют
+	if err := h.tokens.LogoutAll(r.Context(), userID); err != nil {
+		WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		log.Println("[ME] revoke sessions after password change error:", err)
+		return
+	}
+	// 2) взамен — новая пара, чтобы клиента не выкидывало
+	pair, err := h.tokens.Issue(r.Context(), userID)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "TOKEN_ERROR", "failed to generate token")
+		log.Println("[ME] issue tokens error:", err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, newLoginResponse(pair))
+}
+
+func (h *MeHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+
+	var req deleteMeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_JSON", "invalid json")
+		return
+	}
+
+	// сессии отзывает сам Delete (TokenService — один из AccountData), и
+	// только если удаление не отклонено
+	if err := h.userSvc.Delete(r.Context(), userID, req.CurrentPassword); err != nil {
+		switch {
+		case errors.Is(err, user.ErrInvalidInput):
+			WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", "current_password is required")
+		case errors.Is(err, user.ErrWrongPassword):
+			WriteError(w, http.StatusForbidden, "WRONG_PASSWORD", err.Error())
+		case errors.Is(err, user.ErrNotFound):
+			WriteError(w, http.StatusNotFound, "NOT_FOUND", "user not found")
+		case errors.Is(err, workspace.ErrLastAdmin):
+			WriteError(w, http.StatusConflict, "LAST_ADMIN", "make someone else a workspace admin before deleting the account")
+		default:
+			WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+			log.Println("[ME] delete error:", err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
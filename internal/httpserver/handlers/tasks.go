@@ -3,9 +3,11 @@ package handlers
 import (
 	"encoding/json"
 	"errors"
+	"mime"
 	"net/http"
 	"strconv"
 	"task_scheduler/internal/auth"
+	"task_scheduler/internal/markdown"
 	"task_scheduler/internal/task"
 	"time"
 )
@@ -21,8 +23,11 @@ func NewTasksHandler(svc task.Service) *TasksHandler {
 }
 
 type createTaskRequest struct {
-	Title string  `json:"title"`
-	DueAt *string `json:"due_at"`
+	Title           string  `json:"title"`
+	Description     string  `json:"description"`
+	DueAt           *string `json:"due_at"`
+	DeferUntil      *string `json:"defer_until"`
+	EstimateMinutes *int    `json:"estimate_minutes"`
 }
 
 //---------------------------------------------------------------//
@@ -38,12 +43,27 @@ type listTasksResponse struct {
 	Meta listTasksMeta `json:"meta"`
 }
 
+// renderedTask is a task with its description rendered (?render=html).
+type renderedTask struct {
+	task.Task
+	DescriptionHTML string `json:"description_html"`
+}
+
+type listRenderedTasksResponse struct {
+	Data []renderedTask `json:"data"`
+	Meta listTasksMeta  `json:"meta"`
+}
+
 //---------------------------------------------------------------//
 
 type updateTaskRequest struct {
-	Title  *string         `json:"title,omitempty"`
-	DueAt  json.RawMessage `json:"due_at,omitempty"`
-	Status *string         `json:"status.omitempty"`
+	Title           *string         `json:"title,omitempty"`
+	Description     *string         `json:"description,omitempty"`
+	DueAt           json.RawMessage `json:"due_at,omitempty"`
+	DeferUntil      json.RawMessage `json:"defer_until,omitempty"`
+	Status          *string         `json:"status,omitempty"`
+	AssigneeID      json.RawMessage `json:"assignee_id,omitempty"`
+	EstimateMinutes json.RawMessage `json:"estimate_minutes,omitempty"`
 }
 
 //--------------------------------------------------------------//
@@ -77,9 +97,40 @@ func (h *TasksHandler) Create(w http.ResponseWriter, r *http.Request) {
 		dueAt = &t
 
 	}
-	tsk, err := h.svc.Create(r.Context(), userID, req.Title, dueAt)
+	var deferUntil *time.Time
+	if req.DeferUntil != nil {
+		t, err := time.Parse(time.RFC3339, *req.DeferUntil)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "VALIDATION_ERROR", "defer_until must be RFC3339")
+			return
+		}
+		deferUntil = &t
+	}
+	workspaceID, err := workspaceFromRequest(r)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_WORKSPACE", err.Error())
+		return
+	}
+
+	tsk, err := h.svc.Create(r.Context(), userID, workspaceID, task.CreateTaskInput{
+		Title:           req.Title,
+		Description:     req.Description,
+		DueAt:           dueAt,
+		DeferUntil:      deferUntil,
+		EstimateMinutes: req.EstimateMinutes,
+	})
 	if err != nil {
 		switch {
+		case errors.Is(err, task.ErrDescriptionTooLong):
+			WriteError(w, http.StatusUnprocessableEntity, "DESCRIPTION_TOO_LONG", err.Error())
+		case errors.Is(err, task.ErrNotFound):
+			WriteError(w, http.StatusNotFound, "WORKSPACE_NOT_FOUND", "workspace not found")
+		case errors.Is(err, task.ErrForbidden):
+			WriteError(w, http.StatusForbidden, "FORBIDDEN", err.Error())
+		case errors.Is(err, task.ErrEmailNotVerified):
+			WriteError(w, http.StatusForbidden, "EMAIL_NOT_VERIFIED", err.Error())
+		case errors.Is(err, task.ErrWIPLimit):
+			WriteError(w, http.StatusConflict, "WIP_LIMIT_EXCEEDED", err.Error())
 		case errors.Is(err, task.ErrInvalidInput):
 			WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
 		default:
@@ -104,11 +155,17 @@ func (h *TasksHandler) Get(w http.ResponseWriter, r *http.Request) {
 		WriteError(w, http.StatusBadRequest, "INVALID_ID", "invalid id")
 		return
 	}
+	renderHTML, ok := wantsRenderedHTML(w, r)
+	if !ok {
+		return
+	}
 	tsk, err := h.svc.Get(r.Context(), userID, int(id))
 	if err != nil {
 		switch {
 		case errors.Is(err, task.ErrNotFound):
 			WriteError(w, http.StatusNotFound, "NOT_FOUND", err.Error())
+		case errors.Is(err, task.ErrForbidden):
+			WriteError(w, http.StatusForbidden, "FORBIDDEN", err.Error())
 		case errors.Is(err, task.ErrInvalidInput):
 			WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
 		default:
@@ -118,6 +175,10 @@ func (h *TasksHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if renderHTML {
+		WriteJSON(w, http.StatusOK, renderTask(*tsk))
+		return
+	}
 	WriteJSON(w, http.StatusOK, tsk)
 }
 
@@ -161,19 +222,79 @@ func (h *TasksHandler) List(w http.ResponseWriter, r *http.Request) {
 		offset = numOffset
 	}
 
-	tasks, total, effLimit, err := h.svc.List(r.Context(), userID, limit, offset)
+	workspaceID, err := workspaceFromRequest(r)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_WORKSPACE", err.Error())
+		return
+	}
+	renderHTML, ok := wantsRenderedHTML(w, r)
+	if !ok {
+		return
+	}
+	// отложенные и snoozed задачи по умолчанию скрыты
+	var opts task.ListOptions
+	if v := q.Get("include_hidden"); v != "" {
+		includeHidden, err := strconv.ParseBool(v)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "VALIDATION_ERROR", "include_hidden must be a boolean")
+			return
+		}
+		opts.IncludeHidden = includeHidden
+	}
+	// sort=rank — ручной порядок, по умолчанию новые сверху
+	opts.Sort = task.ListSort(q.Get("sort"))
+	if !opts.Sort.Valid() {
+		WriteError(w, http.StatusBadRequest, "VALIDATION_ERROR", "sort must be \"created\" or \"rank\"")
+		return
+	}
+
+	var (
+		tasks    []task.Task
+		total    int
+		effLimit int
+	)
+	switch assignee := q.Get("assignee"); {
+	case assignee == "me":
+		tasks, total, effLimit, err = h.svc.ListAssigned(r.Context(), userID, opts, limit, offset)
+	case assignee != "":
+		WriteError(w, http.StatusBadRequest, "VALIDATION_ERROR", "assignee supports only \"me\"")
+		return
+	case q.Has("q"):
+		// поиск по названию и комментариям — в workspace или в личных и расшаренных
+		tasks, total, effLimit, err = h.svc.Search(r.Context(), userID, workspaceID, q.Get("q"), limit, offset)
+	case workspaceID > 0:
+		tasks, total, effLimit, err = h.svc.ListWorkspace(r.Context(), userID, workspaceID, opts, limit, offset)
+	default:
+		// scope: owned (по умолчанию), shared — расшаренные со мной, all — и то и другое
+		scope := task.ListScope(q.Get("scope"))
+		tasks, total, effLimit, err = h.svc.List(r.Context(), userID, scope, opts, limit, offset)
+	}
 	if err != nil {
-		WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+		switch {
+		case errors.Is(err, task.ErrNotFound):
+			WriteError(w, http.StatusNotFound, "WORKSPACE_NOT_FOUND", "workspace not found")
+		default:
+			WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+		}
 		return
 	}
 
+	meta := listTasksMeta{
+		Total:  total,
+		Limit:  effLimit,
+		Offset: offset,
+	}
+	if renderHTML {
+		rendered := make([]renderedTask, 0, len(tasks))
+		for _, t := range tasks {
+			rendered = append(rendered, renderTask(t))
+		}
+		WriteJSON(w, http.StatusOK, listRenderedTasksResponse{Data: rendered, Meta: meta})
+		return
+	}
 	WriteJSON(w, http.StatusOK, listTasksResponse{
 		Data: tasks,
-		Meta: listTasksMeta{
-			Total:  total,
-			Limit:  effLimit,
-			Offset: offset,
-		},
+		Meta: meta,
 	})
 }
 
@@ -191,13 +312,20 @@ func (h *TasksHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// merge-patch / json-patch применяются к представлению задачи целиком
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == mediaTypeMergePatch || mediaType == mediaTypeJSONPatch {
+		h.patch(w, r, userID, id, mediaType)
+		return
+	}
+
 	var req updateTaskRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		WriteError(w, http.StatusBadRequest, "INVALID_JSON", "invalid json")
 		return
 	}
 
-	if req.Title == nil && req.Status == nil && req.DueAt == nil {
+	if req.Title == nil && req.Description == nil && req.Status == nil && req.DueAt == nil && req.DeferUntil == nil && req.AssigneeID == nil && req.EstimateMinutes == nil {
 		WriteError(w, http.StatusBadRequest, "EMPTY_PATCH", "no fields to update")
 		return
 	}
@@ -227,10 +355,60 @@ func (h *TasksHandler) Update(w http.ResponseWriter, r *http.Request) {
 		dueAt.Value = &t
 	}
 
+	// defer_until: как due_at — нет поля / null (снять) / время
+	var deferUntil task.OptionalTime
+	if req.DeferUntil != nil {
+		deferUntil.Set = true
+		if string(req.DeferUntil) != "null" {
+			var s string
+			if err := json.Unmarshal(req.DeferUntil, &s); err != nil {
+				WriteError(w, http.StatusBadRequest, "INVALID_DEFER_UNTIL", "defer_until must be RFC3339 string or null")
+				return
+			}
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				WriteError(w, http.StatusBadRequest, "INVALID_DEFER_UNTIL", "defer_until must be RFC3339 format")
+				return
+			}
+			deferUntil.Value = &t
+		}
+	}
+
+	// assignee_id: как due_at — нет поля / null (снять) / id
+	var assignee task.OptionalInt
+	if req.AssigneeID != nil {
+		assignee.Set = true
+		if string(req.AssigneeID) != "null" {
+			var v int
+			if err := json.Unmarshal(req.AssigneeID, &v); err != nil {
+				WriteError(w, http.StatusBadRequest, "INVALID_ASSIGNEE", "assignee_id must be a user id or null")
+				return
+			}
+			assignee.Value = &v
+		}
+	}
+
+	var estimate task.OptionalInt
+	if req.EstimateMinutes != nil {
+		estimate.Set = true
+		if string(req.EstimateMinutes) != "null" {
+			var v int
+			if err := json.Unmarshal(req.EstimateMinutes, &v); err != nil {
+				WriteError(w, http.StatusBadRequest, "INVALID_ESTIMATE", "estimate_minutes must be a number of minutes or null")
+				return
+			}
+			estimate.Value = &v
+		}
+	}
+
 	input := task.UpdateTaskInput{
-		Title:  req.Title,
-		Status: req.Status,
-		DueAt:  dueAt,
+		Title:           req.Title,
+		Description:     req.Description,
+		Status:          req.Status,
+		DueAt:           dueAt,
+		DeferUntil:      deferUntil,
+		AssigneeID:      assignee,
+		EstimateMinutes: estimate,
 	}
 
 	updated, err := h.svc.Update(r.Context(), userID, id, input)
@@ -238,7 +416,19 @@ func (h *TasksHandler) Update(w http.ResponseWriter, r *http.Request) {
 		switch {
 		case errors.Is(err, task.ErrNotFound):
 			WriteError(w, http.StatusNotFound, "NOT_FOUND", err.Error())
+		case errors.Is(err, task.ErrForbidden):
+			WriteError(w, http.StatusForbidden, "FORBIDDEN", err.Error())
+		case errors.Is(err, task.ErrInvalidAssignee):
+			WriteError(w, http.StatusUnprocessableEntity, "INVALID_ASSIGNEE", err.Error())
+		case errors.Is(err, task.ErrDescriptionTooLong):
+			WriteError(w, http.StatusUnprocessableEntity, "DESCRIPTION_TOO_LONG", err.Error())
+		case errors.Is(err, task.ErrWIPLimit):
+			WriteError(w, http.StatusConflict, "WIP_LIMIT_EXCEEDED", err.Error())
+		case errors.Is(err, task.ErrConflict):
+			WriteError(w, http.StatusConflict, "TASK_CHANGED", err.Error())
 		case errors.Is(err, task.ErrInvalidInput):
+			WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+		default:
 			WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
 		}
 		return
@@ -266,6 +456,8 @@ func (h *TasksHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		switch {
 		case errors.Is(err, task.ErrNotFound):
 			WriteError(w, http.StatusNotFound, "NOT_FOUND", err.Error())
+		case errors.Is(err, task.ErrForbidden):
+			WriteError(w, http.StatusForbidden, "FORBIDDEN", err.Error())
 		case errors.Is(err, task.ErrInvalidInput):
 			WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
 		default:
@@ -276,3 +468,20 @@ func (h *TasksHandler) Delete(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// wantsRenderedHTML reads ?render=; only "html" is supported.
+func wantsRenderedHTML(w http.ResponseWriter, r *http.Request) (bool, bool) {
+	switch r.URL.Query().Get("render") {
+	case "":
+		return false, true
+	case "html":
+		return true, true
+	default:
+		WriteError(w, http.StatusBadRequest, "VALIDATION_ERROR", "render supports only \"html\"")
+		return false, false
+	}
+}
+
+func renderTask(t task.Task) renderedTask {
+	return renderedTask{Task: t, DescriptionHTML: markdown.ToHTML(t.Description)}
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"task_scheduler/internal/auth"
+	"task_scheduler/internal/task"
+)
+
+type createCommentRequest struct {
+	Body     string `json:"body"`
+	ParentID *int   `json:"parent_id"`
+}
+
+type editCommentRequest struct {
+	Body string `json:"body"`
+}
+
+type listCommentsResponse struct {
+	Data []task.Comment `json:"data"`
+}
+
+type commentHistoryResponse struct {
+	Data []task.CommentRevision `json:"data"`
+}
+
+type activityResponse struct {
+	Data []task.Activity `json:"data"`
+}
+
+func (h *TasksHandler) AddComment(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		WriteError(w, http.StatusBadRequest, "INVALID_ID", "invalid id")
+		return
+	}
+
+	var req createCommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_JSON", "invalid json")
+		return
+	}
+
+	c, err := h.svc.AddComment(r.Context(), userID, id, req.ParentID, req.Body)
+	if err != nil {
+		writeCommentError(w, err, "add comment")
+		return
+	}
+	WriteJSON(w, http.StatusCreated, c)
+}
+
+func (h *TasksHandler) ListComments(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		WriteError(w, http.StatusBadRequest, "INVALID_ID", "invalid id")
+		return
+	}
+
+	comments, err := h.svc.ListComments(r.Context(), userID, id)
+	if err != nil {
+		writeCommentError(w, err, "list comments")
+		return
+	}
+	WriteJSON(w, http.StatusOK, listCommentsResponse{Data: comments})
+}
+
+func (h *TasksHandler) EditComment(w http.ResponseWriter, r *http.Request) {
+	userID, id, commentID, ok := commentPath(w, r)
+	if !ok {
+		return
+	}
+
+	var req editCommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_JSON", "invalid json")
+		return
+	}
+
+	c, err := h.svc.EditComment(r.Context(), userID, id, commentID, req.Body)
+	if err != nil {
+		writeCommentError(w, err, "edit comment")
+		return
+	}
+	WriteJSON(w, http.StatusOK, c)
+}
+
+func (h *TasksHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	userID, id, commentID, ok := commentPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.DeleteComment(r.Context(), userID, id, commentID); err != nil {
+		writeCommentError(w, err, "delete comment")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *TasksHandler) CommentHistory(w http.ResponseWriter, r *http.Request) {
+	userID, id, commentID, ok := commentPath(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := h.svc.CommentHistory(r.Context(), userID, id, commentID)
+	if err != nil {
+		writeCommentError(w, err, "comment history")
+		return
+	}
+	WriteJSON(w, http.StatusOK, commentHistoryResponse{Data: revisions})
+}
+
+func (h *TasksHandler) Activity(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		WriteError(w, http.StatusBadRequest, "INVALID_ID", "invalid id")
+		return
+	}
+
+	feed, err := h.svc.Activity(r.Context(), userID, id)
+	if err != nil {
+		writeCommentError(w, err, "activity")
+		return
+	}
+	WriteJSON(w, http.StatusOK, activityResponse{Data: feed})
+}
+
+// commentPath reads the caller and the task and comment ids, writing the
+// error response itself when something is missing.
+func commentPath(w http.ResponseWriter, r *http.Request) (userID, id, commentID int, ok bool) {
+	userID, ok = auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return 0, 0, 0, false
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		WriteError(w, http.StatusBadRequest, "INVALID_ID", "invalid id")
+		return 0, 0, 0, false
+	}
+	commentID, err = strconv.Atoi(r.PathValue("comment_id"))
+	if err != nil || commentID <= 0 {
+		WriteError(w, http.StatusBadRequest, "INVALID_ID", "invalid comment id")
+		return 0, 0, 0, false
+	}
+	return userID, id, commentID, true
+}
+
+func writeCommentError(w http.ResponseWriter, err error, op string) {
+	switch {
+	case errors.Is(err, task.ErrNotFound):
+		WriteError(w, http.StatusNotFound, "NOT_FOUND", err.Error())
+	case errors.Is(err, task.ErrCommentNotFound):
+		WriteError(w, http.StatusNotFound, "COMMENT_NOT_FOUND", err.Error())
+	case errors.Is(err, task.ErrForbidden):
+		WriteError(w, http.StatusForbidden, "FORBIDDEN", err.Error())
+	case errors.Is(err, task.ErrInvalidInput):
+		WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+	default:
+		WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		log.Println("[TASKS] "+op+" error:", err)
+	}
+}
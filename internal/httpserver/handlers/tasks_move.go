@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"task_scheduler/internal/auth"
+	"task_scheduler/internal/task"
+)
+
+// moveRequest places the task right before and/or right after other tasks
+// of its list; at least one is required.
+type moveRequest struct {
+	Before *int `json:"before"`
+	After  *int `json:"after"`
+}
+
+func (h *TasksHandler) Move(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		WriteError(w, http.StatusBadRequest, "INVALID_ID", "invalid id")
+		return
+	}
+
+	var req moveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_JSON", "invalid json")
+		return
+	}
+	if req.Before == nil && req.After == nil {
+		WriteError(w, http.StatusBadRequest, "VALIDATION_ERROR", "before or after is required")
+		return
+	}
+
+	tsk, err := h.svc.Move(r.Context(), userID, id, req.Before, req.After)
+	if err != nil {
+		switch {
+		case errors.Is(err, task.ErrNotFound):
+			WriteError(w, http.StatusNotFound, "NOT_FOUND", err.Error())
+		case errors.Is(err, task.ErrForbidden):
+			WriteError(w, http.StatusForbidden, "FORBIDDEN", err.Error())
+		case errors.Is(err, task.ErrInvalidAnchor):
+			WriteError(w, http.StatusBadRequest, "INVALID_ANCHOR", err.Error())
+		case errors.Is(err, task.ErrInvalidInput):
+			WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+		default:
+			WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+			log.Println("[TASKS] move error:", err)
+		}
+		return
+	}
+	WriteJSON(w, http.StatusOK, tsk)
+}
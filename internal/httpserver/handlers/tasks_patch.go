@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"task_scheduler/internal/jsonpatch"
+	"task_scheduler/internal/task"
+	"time"
+)
+
+const (
+	mediaTypeMergePatch = "application/merge-patch+json"
+	mediaTypeJSONPatch  = "application/json-patch+json"
+)
+
+var (
+	errInvalidTaskDocument = errors.New("patched task is not a valid task")
+	errReadOnlyField       = errors.New("patch modifies a read-only field")
+)
+
+// patch applies a JSON Merge Patch or JSON Patch document to the current
+// JSON representation of the task and saves the result.
+func (h *TasksHandler) patch(w http.ResponseWriter, r *http.Request, userID, id int, mediaType string) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_JSON", "invalid json")
+		return
+	}
+
+	// 1) Текущее состояние задачи (сразу проверка ownership)
+	current, err := h.svc.Get(r.Context(), userID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, task.ErrNotFound):
+			WriteError(w, http.StatusNotFound, "NOT_FOUND", err.Error())
+		case errors.Is(err, task.ErrForbidden):
+			WriteError(w, http.StatusForbidden, "FORBIDDEN", err.Error())
+		case errors.Is(err, task.ErrInvalidInput):
+			WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+		default:
+			WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		}
+		return
+	}
+	doc, err := json.Marshal(current)
+	if err != nil {
+		WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		return
+	}
+
+	// 2) Применяем патч к представлению
+	var patched []byte
+	if mediaType == mediaTypeMergePatch {
+		patched, err = jsonpatch.MergePatch(doc, body)
+	} else {
+		patched, err = jsonpatch.Apply(doc, body)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, jsonpatch.ErrTestFailed):
+			WriteError(w, http.StatusConflict, "PATCH_TEST_FAILED", err.Error())
+		case errors.Is(err, jsonpatch.ErrPathNotFound):
+			WriteError(w, http.StatusUnprocessableEntity, "INVALID_PATCH_PATH", err.Error())
+		default:
+			WriteError(w, http.StatusBadRequest, "INVALID_PATCH", jsonpatch.ErrInvalidPatch.Error())
+		}
+		return
+	}
+
+	// 3) Результат -> полный набор изменяемых полей
+	input, err := taskUpdateFromDocument(current, patched)
+	if err != nil {
+		switch {
+		case errors.Is(err, errReadOnlyField):
+			WriteError(w, http.StatusUnprocessableEntity, "READ_ONLY_FIELD", err.Error())
+		default:
+			WriteError(w, http.StatusUnprocessableEntity, "VALIDATION_ERROR", err.Error())
+		}
+		return
+	}
+
+	updated, err := h.svc.Update(r.Context(), userID, id, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, task.ErrNotFound):
+			WriteError(w, http.StatusNotFound, "NOT_FOUND", err.Error())
+		case errors.Is(err, task.ErrForbidden):
+			WriteError(w, http.StatusForbidden, "FORBIDDEN", err.Error())
+		case errors.Is(err, task.ErrInvalidAssignee):
+			WriteError(w, http.StatusUnprocessableEntity, "INVALID_ASSIGNEE", err.Error())
+		case errors.Is(err, task.ErrDescriptionTooLong):
+			WriteError(w, http.StatusUnprocessableEntity, "DESCRIPTION_TOO_LONG", err.Error())
+		case errors.Is(err, task.ErrWIPLimit):
+			WriteError(w, http.StatusConflict, "WIP_LIMIT_EXCEEDED", err.Error())
+		case errors.Is(err, task.ErrConflict):
+			// задачу изменили после чтения — test-операции проверялись на устаревшей версии
+			WriteError(w, http.StatusConflict, "TASK_CHANGED", err.Error())
+		case errors.Is(err, task.ErrInvalidInput):
+			WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+		default:
+			WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		}
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, updated)
+}
+
+// taskUpdateFromDocument decodes a patched task representation and turns it
+// into an update that sets every mutable field. Server-managed fields must
+// come back unchanged (snoozed_until and rank have their own endpoints); the checklist
+// and the overdue flag are derived and ignored.
+func taskUpdateFromDocument(current *task.Task, doc []byte) (task.UpdateTaskInput, error) {
+	var patched task.Task
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&patched); err != nil {
+		return task.UpdateTaskInput{}, errInvalidTaskDocument
+	}
+
+	if patched.ID != current.ID ||
+		patched.UserID != current.UserID ||
+		patched.WorkspaceID != current.WorkspaceID ||
+		patched.CreatedBy != current.CreatedBy ||
+		!sameInt(patched.ParentID, current.ParentID) ||
+		patched.Rank != current.Rank ||
+		!sameTime(patched.SnoozedUntil, current.SnoozedUntil) ||
+		!patched.CreatedAt.Equal(current.CreatedAt) ||
+		!patched.UpdatedAt.Equal(current.UpdatedAt) {
+		return task.UpdateTaskInput{}, errReadOnlyField
+	}
+
+	status := string(patched.Status)
+	return task.UpdateTaskInput{
+		Title:       &patched.Title,
+		Description: &patched.Description,
+		Status:      &status,
+		DueAt:       task.OptionalTime{Set: true, Value: patched.DueAt},
+		DeferUntil:  task.OptionalTime{Set: true, Value: patched.DeferUntil},
+		// переназначение и уведомление — только если исполнитель реально сменился
+		AssigneeID:      task.OptionalInt{Set: true, Value: patched.AssigneeID},
+		EstimateMinutes: task.OptionalInt{Set: true, Value: patched.EstimateMinutes},
+		// сохраняем, только если задача не менялась с момента чтения
+		IfUpdatedAt: &current.UpdatedAt,
+	}, nil
+}
+
+func sameInt(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"task_scheduler/internal/auth"
+	"task_scheduler/internal/task"
+)
+
+type shareTaskRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type listSharesResponse struct {
+	Data []task.Share `json:"data"`
+}
+
+func (h *TasksHandler) Share(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		WriteError(w, http.StatusBadRequest, "INVALID_ID", "invalid id")
+		return
+	}
+
+	var req shareTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_JSON", "invalid json")
+		return
+	}
+
+	sh, err := h.svc.Share(r.Context(), userID, id, req.Email, task.Role(req.Role))
+	if err != nil {
+		writeShareError(w, err, "share")
+		return
+	}
+	WriteJSON(w, http.StatusOK, sh)
+}
+
+func (h *TasksHandler) ListShares(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		WriteError(w, http.StatusBadRequest, "INVALID_ID", "invalid id")
+		return
+	}
+
+	shares, err := h.svc.ListShares(r.Context(), userID, id)
+	if err != nil {
+		writeShareError(w, err, "list shares")
+		return
+	}
+	WriteJSON(w, http.StatusOK, listSharesResponse{Data: shares})
+}
+
+func (h *TasksHandler) Unshare(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		WriteError(w, http.StatusBadRequest, "INVALID_ID", "invalid id")
+		return
+	}
+	targetID, err := strconv.Atoi(r.PathValue("user_id"))
+	if err != nil || targetID <= 0 {
+		WriteError(w, http.StatusBadRequest, "INVALID_ID", "invalid user id")
+		return
+	}
+
+	if err := h.svc.Unshare(r.Context(), userID, id, targetID); err != nil {
+		writeShareError(w, err, "unshare")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeShareError(w http.ResponseWriter, err error, op string) {
+	switch {
+	case errors.Is(err, task.ErrNotFound):
+		WriteError(w, http.StatusNotFound, "NOT_FOUND", err.Error())
+	case errors.Is(err, task.ErrForbidden):
+		WriteError(w, http.StatusForbidden, "FORBIDDEN", err.Error())
+	case errors.Is(err, task.ErrUserNotFound):
+		WriteError(w, http.StatusNotFound, "USER_NOT_FOUND", err.Error())
+	case errors.Is(err, task.ErrInvalidInput):
+		WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+	default:
+		WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		log.Println("[TASKS] "+op+" error:", err)
+	}
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"task_scheduler/internal/auth"
+	"task_scheduler/internal/task"
+	"time"
+)
+
+// maxSnooze bounds how far ahead a task can be snoozed.
+const maxSnooze = 366 * 24 * time.Hour
+
+// snoozeRequest sets either a duration ("90m", "2h") or an absolute time.
+type snoozeRequest struct {
+	Duration string `json:"duration"`
+	Until    string `json:"until"`
+}
+
+func (h *TasksHandler) Snooze(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		WriteError(w, http.StatusBadRequest, "INVALID_ID", "invalid id")
+		return
+	}
+
+	var req snoozeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_JSON", "invalid json")
+		return
+	}
+
+	// ровно одно из двух
+	var until time.Time
+	switch {
+	case req.Duration != "" && req.Until != "":
+		WriteError(w, http.StatusBadRequest, "VALIDATION_ERROR", "set either duration or until, not both")
+		return
+	case req.Duration != "":
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil || d <= 0 || d > maxSnooze {
+			WriteError(w, http.StatusBadRequest, "VALIDATION_ERROR", "duration must be a positive duration like \"90m\", at most a year")
+			return
+		}
+		until = time.Now().UTC().Add(d)
+	case req.Until != "":
+		until, err = time.Parse(time.RFC3339, req.Until)
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "VALIDATION_ERROR", "until must be RFC3339")
+			return
+		}
+		if until.After(time.Now().Add(maxSnooze)) {
+			WriteError(w, http.StatusBadRequest, "VALIDATION_ERROR", "until must be within a year")
+			return
+		}
+	default:
+		WriteError(w, http.StatusBadRequest, "VALIDATION_ERROR", "duration or until is required")
+		return
+	}
+
+	tsk, err := h.svc.Snooze(r.Context(), userID, id, &until)
+	if err != nil {
+		writeSnoozeError(w, err, "snooze")
+		return
+	}
+	WriteJSON(w, http.StatusOK, tsk)
+}
+
+// Unsnooze brings the task back into the default lists right away.
+func (h *TasksHandler) Unsnooze(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		WriteError(w, http.StatusBadRequest, "INVALID_ID", "invalid id")
+		return
+	}
+
+	tsk, err := h.svc.Snooze(r.Context(), userID, id, nil)
+	if err != nil {
+		writeSnoozeError(w, err, "unsnooze")
+		return
+	}
+	WriteJSON(w, http.StatusOK, tsk)
+}
+
+func writeSnoozeError(w http.ResponseWriter, err error, op string) {
+	switch {
+	case errors.Is(err, task.ErrNotFound):
+		WriteError(w, http.StatusNotFound, "NOT_FOUND", err.Error())
+	case errors.Is(err, task.ErrForbidden):
+		WriteError(w, http.StatusForbidden, "FORBIDDEN", err.Error())
+	case errors.Is(err, task.ErrConflict):
+		WriteError(w, http.StatusConflict, "TASK_CHANGED", err.Error())
+	case errors.Is(err, task.ErrInvalidInput):
+		WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", "snooze time must be in the future")
+	default:
+		WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		log.Println("[TASKS] "+op+" error:", err)
+	}
+}
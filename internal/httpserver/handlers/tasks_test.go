@@ -8,14 +8,32 @@ import (
 	"net/http/httptest"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	_ "modernc.org/sqlite"
 
+	"task_scheduler/internal/attachment"
+	attachmentsqlite "task_scheduler/internal/attachment/sqlite"
 	"task_scheduler/internal/auth"
+	"task_scheduler/internal/automation"
+	automationsqlite "task_scheduler/internal/automation/sqlite"
+	"task_scheduler/internal/jsonpatch"
+	"task_scheduler/internal/mail"
+	"task_scheduler/internal/notify"
+	"task_scheduler/internal/storage"
 	"task_scheduler/internal/task"
 	tasksqlite "task_scheduler/internal/task/sqlite"
+	"task_scheduler/internal/template"
+	templatesqlite "task_scheduler/internal/template/sqlite"
+	"task_scheduler/internal/timetrack"
+	timetracksqlite "task_scheduler/internal/timetrack/sqlite"
+	"task_scheduler/internal/user"
+	usersqlite "task_scheduler/internal/user/sqlite"
+	"task_scheduler/internal/workspace"
+	workspacesqlite "task_scheduler/internal/workspace/sqlite"
 )
 
 const userID = 1
@@ -28,6 +46,30 @@ func withUser(req *http.Request, userID int) *http.Request {
 func newTestService(t *testing.T) task.Service {
 	t.Helper()
 
+	return newTestServices(t).tasks
+}
+
+type testServices struct {
+	tasks            task.Service
+	users            user.Service
+	workspaces       workspace.Service
+	attachments      attachment.Service
+	timeTracking     timetrack.Service
+	automations      automation.Service
+	automationEngine *automation.Engine
+	templates        template.Service
+	blobDir          string
+	mailbox          *bytes.Buffer
+}
+
+func newTestServices(t *testing.T) testServices {
+	t.Helper()
+	return newTestServicesWith(t, task.Options{})
+}
+
+func newTestServicesWith(t *testing.T, taskOpts task.Options) testServices {
+	t.Helper()
+
 	dbPath := filepath.Join(t.TempDir(), "tasks.db")
 
 	db, err := sql.Open("sqlite", dbPath)
@@ -37,9 +79,57 @@ func newTestService(t *testing.T) task.Service {
 
 	require.NoError(t, db.Ping())
 	require.NoError(t, tasksqlite.Migrate(db))
+	require.NoError(t, usersqlite.Migrate(db))
+	require.NoError(t, workspacesqlite.Migrate(db))
+	require.NoError(t, attachmentsqlite.Migrate(db))
+	require.NoError(t, timetracksqlite.Migrate(db))
+	require.NoError(t, automationsqlite.Migrate(db))
+	require.NoError(t, templatesqlite.Migrate(db))
+
+	mailbox := &bytes.Buffer{}
+	mailer := mail.NewWriterMailer(mailbox, "test@example.com")
+	userRepo := usersqlite.New(db)
+	userSvc := user.NewService(userRepo, userRepo, userRepo, mailer, user.Options{VerificationTTL: time.Hour})
+	workspaceSvc := workspace.NewService(workspacesqlite.New(db), userSvc, mailer, time.Hour)
 
 	repo := tasksqlite.New(db)
-	return task.NewService(repo)
+	automationRepo := automationsqlite.New(db)
+	// вебхуки в тестах ходят на localhost, который WebhookClient не пускает
+	automationEngine := automation.NewEngine(automationRepo, &http.Client{Timeout: 5 * time.Second})
+	t.Cleanup(automationEngine.Close)
+	taskSvc := task.NewService(repo, repo, repo, userSvc, workspaceSvc, notify.NewMailNotifier(userSvc, mailer), automationEngine, taskOpts)
+	automationEngine.SetTasks(taskSvc)
+	blobDir := t.TempDir()
+	attachmentSvc := attachment.NewService(attachmentsqlite.New(db), storage.NewLocalStore(blobDir), taskSvc, attachment.Options{
+		MaxSize: 1024,
+		Quota:   1536,
+	})
+	return testServices{
+		tasks:            taskSvc,
+		users:            userSvc,
+		workspaces:       workspaceSvc,
+		attachments:      attachmentSvc,
+		timeTracking:     timetrack.NewService(timetracksqlite.New(db), taskSvc, workspaceSvc),
+		automations:      automation.NewService(automationRepo, taskSvc, workspaceSvc),
+		automationEngine: automationEngine,
+		templates:        template.NewService(templatesqlite.New(db), taskSvc, workspaceSvc),
+		blobDir:          blobDir,
+		mailbox:          mailbox,
+	}
+}
+
+// join registers a user and adds them to the workspace with the role.
+func (s testServices) join(t *testing.T, adminID, workspaceID int, email string, role workspace.Role) int {
+	t.Helper()
+	ctx := t.Context()
+	u, err := s.users.Register(email, "secret123")
+	require.NoError(t, err)
+	_, err = s.workspaces.Invite(ctx, adminID, workspaceID, email, role)
+	require.NoError(t, err)
+	sent := mailedTokens(s.mailbox.String())
+	_, err = s.workspaces.AcceptInvite(ctx, u.ID, sent[len(sent)-1])
+	require.NoError(t, err)
+	return u.ID
 }
 
 func TestTasksHandler_Create_OK(t *testing.T) {
@@ -142,7 +232,7 @@ func TestTaskHandler_Get_OK(t *testing.T) {
 	svc := newTestService(t)
 	h := NewTasksHandler(svc)
 
-	createdTask, err := svc.Create(t.Context(), userID, "Task for get", nil)
+	createdTask, err := svc.Create(t.Context(), userID, 0, task.CreateTaskInput{Title: "Task for get"})
 	require.NoError(t, err)
 
 	mux := http.NewServeMux()
@@ -238,7 +328,7 @@ func TestTasksHandler_List_Limit(t *testing.T) {
 	h := NewTasksHandler(svc)
 
 	for i := 0; i < 12; i++ {
-		_, err := svc.Create(t.Context(), userID, "Task "+strconv.Itoa(i+1), nil)
+		_, err := svc.Create(t.Context(), userID, 0, task.CreateTaskInput{Title: "Task " + strconv.Itoa(i+1)})
 		require.NoError(t, err)
 	}
 	mux := http.NewServeMux()
@@ -267,7 +357,7 @@ func TestTasksHandler_List_Offset(t *testing.T) {
 	h := NewTasksHandler(svc)
 
 	for i := 0; i < 12; i++ {
-		_, err := svc.Create(t.Context(), userID, "Task "+strconv.Itoa(i+1), nil)
+		_, err := svc.Create(t.Context(), userID, 0, task.CreateTaskInput{Title: "Task " + strconv.Itoa(i+1)})
 		require.NoError(t, err)
 	}
 
@@ -312,3 +402,888 @@ func TestTasksHandler_List_InvalidLimit(t *testing.T) {
 	require.NoError(t, json.NewDecoder(rr.Body).Decode(&er))
 	require.Equal(t, "VALIDATION_ERROR", er.Error.Code)
 }
+
+func TestTasksHandler_Update_MergePatch(t *testing.T) {
+	svc := newTestService(t)
+	h := NewTasksHandler(svc)
+
+	due := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	createdTask, err := svc.Create(t.Context(), userID, 0, task.CreateTaskInput{Title: "Task for patch", DueAt: &due})
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("PATCH /v1/tasks/{id}", h.Update)
+
+	body := []byte(`{"title":"Patched","due_at":null}`)
+	req := httptest.NewRequest(http.MethodPatch, "/v1/tasks/"+strconv.Itoa(createdTask.ID), bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req = withUser(req, userID)
+
+	rr := httptest.NewRecorder()
+
+	mux.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var got task.Task
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&got))
+	require.Equal(t, "Patched", got.Title)
+	require.Nil(t, got.DueAt)
+	require.Equal(t, task.StatusPending, got.Status)
+
+	stored, err := svc.Get(t.Context(), userID, createdTask.ID)
+	require.NoError(t, err)
+	require.Equal(t, "Patched", stored.Title)
+	require.Nil(t, stored.DueAt)
+}
+
+func TestTasksHandler_Update_JSONPatch(t *testing.T) {
+	svc := newTestService(t)
+	h := NewTasksHandler(svc)
+
+	createdTask, err := svc.Create(t.Context(), userID, 0, task.CreateTaskInput{Title: "Task for patch"})
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("PATCH /v1/tasks/{id}", h.Update)
+
+	body := []byte(`[
+		{"op":"test","path":"/status","value":"pending"},
+		{"op":"replace","path":"/status","value":"done"},
+		{"op":"add","path":"/due_at","value":"2030-01-02T03:04:05Z"}
+	]`)
+	req := httptest.NewRequest(http.MethodPatch, "/v1/tasks/"+strconv.Itoa(createdTask.ID), bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json-patch+json")
+	req = withUser(req, userID)
+
+	rr := httptest.NewRecorder()
+
+	mux.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var got task.Task
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&got))
+	require.Equal(t, task.StatusDone, got.Status)
+	require.NotNil(t, got.DueAt)
+	require.True(t, got.DueAt.Equal(time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)))
+}
+
+func TestTasksHandler_Update_JSONPatchTestFailed(t *testing.T) {
+	svc := newTestService(t)
+	h := NewTasksHandler(svc)
+
+	createdTask, err := svc.Create(t.Context(), userID, 0, task.CreateTaskInput{Title: "Task for patch"})
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("PATCH /v1/tasks/{id}", h.Update)
+
+	body := []byte(`[
+		{"op":"test","path":"/status","value":"done"},
+		{"op":"replace","path":"/title","value":"never"}
+	]`)
+	req := httptest.NewRequest(http.MethodPatch, "/v1/tasks/"+strconv.Itoa(createdTask.ID), bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json-patch+json")
+	req = withUser(req, userID)
+
+	rr := httptest.NewRecorder()
+
+	mux.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusConflict, rr.Code)
+
+	var er ErrorResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&er))
+	require.Equal(t, "PATCH_TEST_FAILED", er.Error.Code)
+}
+
+func TestTasksHandler_Update_JSONPatchOnStaleVersion(t *testing.T) {
+	svc := newTestService(t)
+
+	createdTask, err := svc.Create(t.Context(), userID, 0, task.CreateTaskInput{Title: "Task for patch"})
+	require.NoError(t, err)
+
+	// патч прочитал задачу, его test-операция прошла на этой версии
+	stale, err := svc.Get(t.Context(), userID, createdTask.ID)
+	require.NoError(t, err)
+	doc, err := json.Marshal(stale)
+	require.NoError(t, err)
+	patched, err := jsonpatch.Apply(doc, []byte(`[
+		{"op":"test","path":"/status","value":"pending"},
+		{"op":"replace","path":"/title","value":"from patch"}
+	]`))
+	require.NoError(t, err)
+	input, err := taskUpdateFromDocument(stale, patched)
+	require.NoError(t, err)
+
+	// а до записи задачу успел закрыть другой запрос
+	done := string(task.StatusDone)
+	_, err = svc.Update(t.Context(), userID, createdTask.ID, task.UpdateTaskInput{Status: &done})
+	require.NoError(t, err)
+
+	_, err = svc.Update(t.Context(), userID, createdTask.ID, input)
+	require.ErrorIs(t, err, task.ErrConflict)
+
+	stored, err := svc.Get(t.Context(), userID, createdTask.ID)
+	require.NoError(t, err)
+	require.Equal(t, task.StatusDone, stored.Status)
+	require.Equal(t, "Task for patch", stored.Title)
+}
+
+func TestTasksHandler_Update_ReadOnlyField(t *testing.T) {
+	svc := newTestService(t)
+	h := NewTasksHandler(svc)
+
+	createdTask, err := svc.Create(t.Context(), userID, 0, task.CreateTaskInput{Title: "Task for patch"})
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("PATCH /v1/tasks/{id}", h.Update)
+
+	body := []byte(`{"user_id":2}`)
+	req := httptest.NewRequest(http.MethodPatch, "/v1/tasks/"+strconv.Itoa(createdTask.ID), bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req = withUser(req, userID)
+
+	rr := httptest.NewRecorder()
+
+	mux.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+
+	var er ErrorResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&er))
+	require.Equal(t, "READ_ONLY_FIELD", er.Error.Code)
+}
+
+func TestTasksHandler_Share_EnforcesRoles(t *testing.T) {
+	services := newTestServices(t)
+	svc, userSvc := services.tasks, services.users
+	h := NewTasksHandler(svc)
+
+	owner, err := userSvc.Register("owner@example.com", "secret123")
+	require.NoError(t, err)
+	other, err := userSvc.Register("other@example.com", "secret123")
+	require.NoError(t, err)
+
+	tsk, err := svc.Create(t.Context(), owner.ID, 0, task.CreateTaskInput{Title: "Shared task"})
+	require.NoError(t, err)
+	id := strconv.Itoa(tsk.ID)
+
+	do := func(handler http.HandlerFunc, method, target, body string, asUser int) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetPathValue("id", id)
+		req.SetPathValue("user_id", strconv.Itoa(other.ID))
+		rr := httptest.NewRecorder()
+		handler(rr, withUser(req, asUser))
+		return rr
+	}
+
+	// до шаринга задачи для другого юзера не существует
+	rr := do(h.Get, http.MethodGet, "/v1/tasks/"+id, "", other.ID)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = do(h.Share, http.MethodPost, "/v1/tasks/"+id+"/shares", `{"email":"other@example.com","role":"viewer"}`, owner.ID)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	rr = do(h.Get, http.MethodGet, "/v1/tasks/"+id, "", other.ID)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	// viewer не может редактировать и делиться
+	rr = do(h.Update, http.MethodPatch, "/v1/tasks/"+id, `{"title":"Hijacked"}`, other.ID)
+	require.Equal(t, http.StatusForbidden, rr.Code)
+	rr = do(h.Share, http.MethodPost, "/v1/tasks/"+id+"/shares", `{"email":"owner@example.com","role":"owner"}`, other.ID)
+	require.Equal(t, http.StatusForbidden, rr.Code)
+
+	// editor может
+	rr = do(h.Share, http.MethodPost, "/v1/tasks/"+id+"/shares", `{"email":"other@example.com","role":"editor"}`, owner.ID)
+	require.Equal(t, http.StatusOK, rr.Code)
+	rr = do(h.Update, http.MethodPatch, "/v1/tasks/"+id, `{"title":"Edited"}`, other.ID)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	// shared with me
+	rr = do(h.List, http.MethodGet, "/v1/tasks?scope=shared", "", other.ID)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var list listTasksResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+	require.Equal(t, 1, list.Meta.Total)
+	require.Equal(t, "Edited", list.Data[0].Title)
+
+	rr = do(h.List, http.MethodGet, "/v1/tasks", "", other.ID)
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+	require.Equal(t, 0, list.Meta.Total)
+
+	// получатель может сам отказаться от доступа
+	rr = do(h.Unshare, http.MethodDelete, "/v1/tasks/"+id+"/shares/"+strconv.Itoa(other.ID), "", other.ID)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	rr = do(h.Get, http.MethodGet, "/v1/tasks/"+id, "", other.ID)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestTasksHandler_RequireVerifiedEmail_CoversEveryWayToCreate(t *testing.T) {
+	services := newTestServicesWith(t, task.Options{RequireVerifiedEmail: true})
+	ctx := t.Context()
+	u, err := services.users.Register("new@example.com", "secret123")
+	require.NoError(t, err)
+	verifyToken := mailedTokens(services.mailbox.String())[0]
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/tasks", NewTasksHandler(services.tasks).Create)
+	mux.HandleFunc("POST /v1/templates/{id}/instantiate", NewTemplatesHandler(services.templates).Instantiate)
+	do := func(target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, withUser(req, u.ID))
+		return rr
+	}
+	count := func() int {
+		_, total, _, err := services.tasks.List(ctx, u.ID, task.ListOwned, task.ListOptions{IncludeHidden: true}, 100, 0)
+		require.NoError(t, err)
+		return total
+	}
+
+	// 1) напрямую
+	rr := do("/v1/tasks", `{"title":"Direct"}`)
+	require.Equal(t, http.StatusForbidden, rr.Code)
+	require.Contains(t, rr.Body.String(), "EMAIL_NOT_VERIFIED")
+
+	// 2) из шаблона
+	tpl, err := services.templates.Create(ctx, u.ID, 0, template.Input{Name: "Onboarding", Items: []template.Item{{Title: "Step"}}})
+	require.NoError(t, err)
+	rr = do("/v1/templates/"+strconv.Itoa(tpl.ID)+"/instantiate", "")
+	require.Equal(t, http.StatusForbidden, rr.Code)
+	require.Contains(t, rr.Body.String(), "EMAIL_NOT_VERIFIED")
+
+	// 3) правилом: действие create_task тоже не проходит
+	_, err = services.automations.Create(ctx, u.ID, 0, automation.RuleInput{
+		Name:    "follow-up",
+		Enabled: true,
+		Trigger: task.EventCreated,
+		Actions: []automation.Action{{Type: automation.ActionCreateTask, Title: "Follow up"}},
+	})
+	require.NoError(t, err)
+	services.automationEngine.TaskChanged(ctx, task.Event{Type: task.EventCreated, ActorID: u.ID, Task: task.Task{ID: 1, UserID: u.ID, CreatedBy: u.ID, Title: "Imported"}})
+	require.Zero(t, count())
+
+	// после подтверждения всё работает
+	require.NoError(t, services.users.VerifyEmail(ctx, verifyToken))
+	require.Equal(t, http.StatusCreated, do("/v1/tasks", `{"title":"Direct"}`).Code)
+	require.Equal(t, http.StatusCreated, do("/v1/templates/"+strconv.Itoa(tpl.ID)+"/instantiate", "").Code)
+	// Direct и Step, а правило добавило по задаче к каждой
+	require.Equal(t, 4, count())
+}
+
+func TestTasksHandler_Workspace_ScopesTasksByMembership(t *testing.T) {
+	services := newTestServices(t)
+	h := NewTasksHandler(services.tasks)
+	wh := NewWorkspacesHandler(services.workspaces)
+
+	admin, err := services.users.Register("admin@example.com", "secret123")
+	require.NoError(t, err)
+	guest, err := services.users.Register("guest@example.com", "secret123")
+	require.NoError(t, err)
+	outsider, err := services.users.Register("outsider@example.com", "secret123")
+	require.NoError(t, err)
+
+	do := func(handler http.HandlerFunc, method, target, body string, asUser int, wsID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		if wsID != "" {
+			req.Header.Set(WorkspaceHeader, wsID)
+		}
+		rr := httptest.NewRecorder()
+		handler(rr, withUser(req, asUser))
+		return rr
+	}
+
+	rr := do(wh.Create, http.MethodPost, "/v1/workspaces", `{"name":"Acme"}`, admin.ID, "")
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var ws workspace.Workspace
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&ws))
+	wsID := strconv.Itoa(ws.ID)
+
+	rr = do(h.Create, http.MethodPost, "/v1/tasks", `{"title":"Team task"}`, admin.ID, wsID)
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	// приглашение гостя: токен приходит письмом
+	req := httptest.NewRequest(http.MethodPost, "/v1/workspaces/"+wsID+"/invitations", bytes.NewReader([]byte(`{"email":"guest@example.com","role":"guest"}`)))
+	req.SetPathValue("workspace_id", wsID)
+	rr = httptest.NewRecorder()
+	wh.Invite(rr, withUser(req, admin.ID))
+	require.Equal(t, http.StatusCreated, rr.Code)
+	sent := mailedTokens(services.mailbox.String())
+	inviteToken := sent[len(sent)-1]
+
+	// чужой email приглашение не примет
+	rr = do(wh.AcceptInvite, http.MethodPost, "/v1/invitations/accept", `{"token":"`+inviteToken+`"}`, outsider.ID, "")
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = do(wh.AcceptInvite, http.MethodPost, "/v1/invitations/accept", `{"token":"`+inviteToken+`"}`, guest.ID, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	// гость видит задачи workspace, но не создаёт их
+	rr = do(h.List, http.MethodGet, "/v1/tasks", "", guest.ID, wsID)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var list listTasksResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+	require.Equal(t, 1, list.Meta.Total)
+	require.Equal(t, ws.ID, list.Data[0].WorkspaceID)
+
+	rr = do(h.Create, http.MethodPost, "/v1/tasks", `{"title":"Guest task"}`, guest.ID, wsID)
+	require.Equal(t, http.StatusForbidden, rr.Code)
+
+	// вне workspace его не видно, а в личном списке админа задачи нет
+	rr = do(h.List, http.MethodGet, "/v1/tasks", "", outsider.ID, wsID)
+	require.Equal(t, http.StatusNotFound, rr.Code)
+	rr = do(h.List, http.MethodGet, "/v1/tasks", "", admin.ID, "")
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+	require.Equal(t, 0, list.Meta.Total)
+
+	// ещё раз тот же токен не сработает
+	rr = do(wh.AcceptInvite, http.MethodPost, "/v1/invitations/accept", `{"token":"`+inviteToken+`"}`, guest.ID, "")
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestTasksHandler_Workspace_CreatorKeepsNoRightsAfterRemoval(t *testing.T) {
+	services := newTestServices(t)
+	ctx := t.Context()
+	svc := services.tasks
+
+	admin, err := services.users.Register("admin@example.com", "secret123")
+	require.NoError(t, err)
+	member, err := services.users.Register("member@example.com", "secret123")
+	require.NoError(t, err)
+	ws, err := services.workspaces.Create(ctx, admin.ID, "Acme")
+	require.NoError(t, err)
+	_, err = services.workspaces.Invite(ctx, admin.ID, ws.ID, "member@example.com", workspace.RoleMember)
+	require.NoError(t, err)
+	sent := mailedTokens(services.mailbox.String())
+	_, err = services.workspaces.AcceptInvite(ctx, member.ID, sent[len(sent)-1])
+	require.NoError(t, err)
+
+	// правило workspace работает, пока его автор в workspace
+	_, err = services.automations.Create(ctx, member.ID, ws.ID, automation.RuleInput{
+		Name:    "follow-up",
+		Enabled: true,
+		Trigger: task.EventStatusChanged,
+		Actions: []automation.Action{{Type: automation.ActionCreateTask, Title: "Follow up {{title}}"}},
+	})
+	require.NoError(t, err)
+	tsk, err := svc.Create(ctx, member.ID, ws.ID, task.CreateTaskInput{Title: "Team task"})
+	require.NoError(t, err)
+	role, err := svc.Role(ctx, member.ID, tsk.ID)
+	require.NoError(t, err)
+	require.Equal(t, task.RoleEditor, role)
+	done := string(task.StatusDone)
+	_, err = svc.Update(ctx, admin.ID, tsk.ID, task.UpdateTaskInput{Status: &done})
+	require.NoError(t, err)
+	_, total, _, err := svc.ListWorkspace(ctx, admin.ID, ws.ID, task.ListOptions{}, 100, 0)
+	require.NoError(t, err)
+	require.Equal(t, 2, total)
+
+	// исключённый создатель теряет и задачу, и свои правила в workspace
+	require.NoError(t, services.workspaces.RemoveMember(ctx, admin.ID, ws.ID, member.ID))
+	_, err = svc.Get(ctx, member.ID, tsk.ID)
+	require.ErrorIs(t, err, task.ErrNotFound)
+	require.ErrorIs(t, svc.Delete(ctx, member.ID, tsk.ID), task.ErrNotFound)
+	pending := string(task.StatusPending)
+	_, err = svc.Update(ctx, admin.ID, tsk.ID, task.UpdateTaskInput{Status: &pending})
+	require.NoError(t, err)
+	_, total, _, err = svc.ListWorkspace(ctx, admin.ID, ws.ID, task.ListOptions{}, 100, 0)
+	require.NoError(t, err)
+	require.Equal(t, 2, total)
+}
+
+func TestWorkspacesHandler_ChangeRole_KeepsLastAdmin(t *testing.T) {
+	services := newTestServices(t)
+	ctx := t.Context()
+	wh := NewWorkspacesHandler(services.workspaces)
+
+	admin, err := services.users.Register("admin@example.com", "secret123")
+	require.NoError(t, err)
+	member, err := services.users.Register("member@example.com", "secret123")
+	require.NoError(t, err)
+	ws, err := services.workspaces.Create(ctx, admin.ID, "Acme")
+	require.NoError(t, err)
+	_, err = services.workspaces.Invite(ctx, admin.ID, ws.ID, "member@example.com", workspace.RoleMember)
+	require.NoError(t, err)
+	sent := mailedTokens(services.mailbox.String())
+	_, err = services.workspaces.AcceptInvite(ctx, member.ID, sent[len(sent)-1])
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("PATCH /v1/workspaces/{workspace_id}/members/{user_id}", wh.ChangeRole)
+	changeRole := func(asUser, target int, role string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/v1/workspaces/"+strconv.Itoa(ws.ID)+"/members/"+strconv.Itoa(target), strings.NewReader(`{"role":"`+role+`"}`))
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, withUser(req, asUser))
+		return rr
+	}
+
+	// роли меняет только админ, и только на существующие
+	require.Equal(t, http.StatusForbidden, changeRole(member.ID, member.ID, "admin").Code)
+	require.Equal(t, http.StatusBadRequest, changeRole(admin.ID, member.ID, "owner").Code)
+	// единственный админ не может себя понизить
+	rr := changeRole(admin.ID, admin.ID, "member")
+	require.Equal(t, http.StatusConflict, rr.Code)
+	require.Contains(t, rr.Body.String(), "LAST_ADMIN")
+
+	rr = changeRole(admin.ID, member.ID, "admin")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var m workspace.Member
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&m))
+	require.Equal(t, workspace.RoleAdmin, m.Role)
+
+	// теперь админов двое — первый может стать гостем
+	require.Equal(t, http.StatusOK, changeRole(admin.ID, admin.ID, "guest").Code)
+	role, err := services.workspaces.MemberRole(ctx, ws.ID, admin.ID)
+	require.NoError(t, err)
+	require.Equal(t, workspace.RoleGuest, role)
+	require.Equal(t, http.StatusForbidden, changeRole(admin.ID, member.ID, "member").Code)
+}
+
+func TestTasksHandler_Assign_NotifiesAssignee(t *testing.T) {
+	services := newTestServices(t)
+	svc, userSvc := services.tasks, services.users
+	h := NewTasksHandler(svc)
+
+	owner, err := userSvc.Register("owner@example.com", "secret123")
+	require.NoError(t, err)
+	editor, err := userSvc.Register("editor@example.com", "secret123")
+	require.NoError(t, err)
+	viewer, err := userSvc.Register("viewer@example.com", "secret123")
+	require.NoError(t, err)
+
+	tsk, err := svc.Create(t.Context(), owner.ID, 0, task.CreateTaskInput{Title: "Write report"})
+	require.NoError(t, err)
+	require.Equal(t, owner.ID, tsk.CreatedBy)
+	require.Nil(t, tsk.AssigneeID)
+	_, err = svc.Share(t.Context(), owner.ID, tsk.ID, "editor@example.com", task.RoleEditor)
+	require.NoError(t, err)
+	_, err = svc.Share(t.Context(), owner.ID, tsk.ID, "viewer@example.com", task.RoleViewer)
+	require.NoError(t, err)
+	id := strconv.Itoa(tsk.ID)
+
+	do := func(handler http.HandlerFunc, method, target, body string, asUser int) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetPathValue("id", id)
+		rr := httptest.NewRecorder()
+		handler(rr, withUser(req, asUser))
+		return rr
+	}
+
+	// viewer не может быть исполнителем
+	rr := do(h.Update, http.MethodPatch, "/v1/tasks/"+id, `{"assignee_id":`+strconv.Itoa(viewer.ID)+`}`, owner.ID)
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+
+	services.mailbox.Reset()
+	rr = do(h.Update, http.MethodPatch, "/v1/tasks/"+id, `{"assignee_id":`+strconv.Itoa(editor.ID)+`}`, owner.ID)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var got task.Task
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&got))
+	require.NotNil(t, got.AssigneeID)
+	require.Equal(t, editor.ID, *got.AssigneeID)
+	require.Equal(t, owner.ID, got.CreatedBy)
+	require.Contains(t, services.mailbox.String(), "editor@example.com")
+	require.Contains(t, services.mailbox.String(), "Task assigned to you")
+
+	// правка других полей не переотправляет уведомление
+	services.mailbox.Reset()
+	rr = do(h.Update, http.MethodPatch, "/v1/tasks/"+id, `{"title":"Write final report"}`, editor.ID)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Zero(t, services.mailbox.Len())
+
+	rr = do(h.List, http.MethodGet, "/v1/tasks?assignee=me", "", editor.ID)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var list listTasksResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+	require.Equal(t, 1, list.Meta.Total)
+	require.Equal(t, "Write final report", list.Data[0].Title)
+
+	// снять исполнителя
+	rr = do(h.Update, http.MethodPatch, "/v1/tasks/"+id, `{"assignee_id":null}`, owner.ID)
+	require.Equal(t, http.StatusOK, rr.Code)
+	rr = do(h.List, http.MethodGet, "/v1/tasks?assignee=me", "", editor.ID)
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+	require.Equal(t, 0, list.Meta.Total)
+}
+
+func TestTasksHandler_Assigned_HidesTasksAfterAccessIsRevoked(t *testing.T) {
+	services := newTestServices(t)
+	ctx := t.Context()
+	svc := services.tasks
+	h := NewTasksHandler(svc)
+
+	owner, err := services.users.Register("owner@example.com", "secret123")
+	require.NoError(t, err)
+	bob, err := services.users.Register("bob@example.com", "secret123")
+	require.NoError(t, err)
+	ws, err := services.workspaces.Create(ctx, owner.ID, "Acme")
+	require.NoError(t, err)
+	_, err = services.workspaces.Invite(ctx, owner.ID, ws.ID, "bob@example.com", workspace.RoleMember)
+	require.NoError(t, err)
+	sent := mailedTokens(services.mailbox.String())
+	_, err = services.workspaces.AcceptInvite(ctx, bob.ID, sent[len(sent)-1])
+	require.NoError(t, err)
+
+	do := func(method, target, body string, asUser int) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		mux := http.NewServeMux()
+		mux.HandleFunc("PATCH /v1/tasks/{id}", h.Update)
+		mux.HandleFunc("GET /v1/tasks", h.List)
+		mux.ServeHTTP(rr, withUser(req, asUser))
+		return rr
+	}
+	assignToBob := func(id int) {
+		rr := do(http.MethodPatch, "/v1/tasks/"+strconv.Itoa(id), `{"assignee_id":`+strconv.Itoa(bob.ID)+`}`, owner.ID)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	}
+	assigned := func() []string {
+		rr := do(http.MethodGet, "/v1/tasks?assignee=me", "", bob.ID)
+		require.Equal(t, http.StatusOK, rr.Code)
+		var list listTasksResponse
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+		require.Equal(t, len(list.Data), list.Meta.Total)
+		titles := make([]string, 0, len(list.Data))
+		for _, tsk := range list.Data {
+			titles = append(titles, tsk.Title)
+		}
+		return titles
+	}
+
+	personal, err := svc.Create(ctx, owner.ID, 0, task.CreateTaskInput{Title: "Personal"})
+	require.NoError(t, err)
+	_, err = svc.Share(ctx, owner.ID, personal.ID, "bob@example.com", task.RoleEditor)
+	require.NoError(t, err)
+	assignToBob(personal.ID)
+	team, err := svc.Create(ctx, owner.ID, ws.ID, task.CreateTaskInput{Title: "Team"})
+	require.NoError(t, err)
+	assignToBob(team.ID)
+	require.ElementsMatch(t, []string{"Personal", "Team"}, assigned())
+
+	// исполнитель остаётся записан, но без доступа задачу уже не видно
+	require.NoError(t, svc.Unshare(ctx, owner.ID, personal.ID, bob.ID))
+	require.Equal(t, []string{"Team"}, assigned())
+	require.NoError(t, services.workspaces.RemoveMember(ctx, owner.ID, ws.ID, bob.ID))
+	require.Empty(t, assigned())
+}
+
+func TestTasksHandler_Comments_ThreadHistoryAndMentions(t *testing.T) {
+	services := newTestServices(t)
+	svc, userSvc := services.tasks, services.users
+	h := NewTasksHandler(svc)
+
+	owner, err := userSvc.Register("owner@example.com", "secret123")
+	require.NoError(t, err)
+	viewer, err := userSvc.Register("viewer@example.com", "secret123")
+	require.NoError(t, err)
+	_, err = userSvc.Register("stranger@example.com", "secret123")
+	require.NoError(t, err)
+
+	tsk, err := svc.Create(t.Context(), owner.ID, 0, task.CreateTaskInput{Title: "Quarterly plan"})
+	require.NoError(t, err)
+	_, err = svc.Share(t.Context(), owner.ID, tsk.ID, "viewer@example.com", task.RoleViewer)
+	require.NoError(t, err)
+	id := strconv.Itoa(tsk.ID)
+
+	do := func(handler http.HandlerFunc, method, target, body string, asUser int, commentID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.SetPathValue("id", id)
+		req.SetPathValue("comment_id", commentID)
+		rr := httptest.NewRecorder()
+		handler(rr, withUser(req, asUser))
+		return rr
+	}
+
+	// упоминание: письмо только тому, кто видит задачу
+	services.mailbox.Reset()
+	rr := do(h.AddComment, http.MethodPost, "/v1/tasks/"+id+"/comments",
+		`{"body":"**Draft** is ready, @viewer@example.com and @stranger@example.com please look"}`, owner.ID, "")
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var root task.Comment
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&root))
+	require.Equal(t, []string{"viewer@example.com", "stranger@example.com"}, root.Mentions)
+	require.Contains(t, services.mailbox.String(), "To: viewer@example.com")
+	require.NotContains(t, services.mailbox.String(), "To: stranger@example.com")
+	rootID := strconv.Itoa(root.ID)
+
+	// viewer может отвечать
+	rr = do(h.AddComment, http.MethodPost, "/v1/tasks/"+id+"/comments", `{"body":"Looks good","parent_id":`+rootID+`}`, viewer.ID, "")
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var reply task.Comment
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&reply))
+	require.Equal(t, root.ID, *reply.ParentID)
+
+	// править может только автор, старый текст попадает в историю
+	rr = do(h.EditComment, http.MethodPatch, "/v1/tasks/"+id+"/comments/"+rootID, `{"body":"Rewritten"}`, viewer.ID, rootID)
+	require.Equal(t, http.StatusForbidden, rr.Code)
+	rr = do(h.EditComment, http.MethodPatch, "/v1/tasks/"+id+"/comments/"+rootID, `{"body":"Final draft is ready"}`, owner.ID, rootID)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	rr = do(h.CommentHistory, http.MethodGet, "/v1/tasks/"+id+"/comments/"+rootID+"/history", "", viewer.ID, rootID)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var history commentHistoryResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&history))
+	require.Len(t, history.Data, 1)
+	require.Contains(t, history.Data[0].Body, "**Draft** is ready")
+
+	// поиск находит задачу по тексту комментария
+	rr = do(h.List, http.MethodGet, "/v1/tasks?q=final+draft", "", viewer.ID, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var list listTasksResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+	require.Equal(t, 1, list.Meta.Total)
+	require.Equal(t, tsk.ID, list.Data[0].ID)
+
+	// owner задачи может удалить чужой комментарий
+	rr = do(h.DeleteComment, http.MethodDelete, "/v1/tasks/"+id+"/comments/"+strconv.Itoa(reply.ID), "", owner.ID, strconv.Itoa(reply.ID))
+	require.Equal(t, http.StatusNoContent, rr.Code)
+
+	rr = do(h.ListComments, http.MethodGet, "/v1/tasks/"+id+"/comments", "", viewer.ID, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var comments listCommentsResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&comments))
+	require.Len(t, comments.Data, 2)
+	require.NotNil(t, comments.Data[1].DeletedAt)
+	require.Empty(t, comments.Data[1].Body)
+
+	rr = do(h.Activity, http.MethodGet, "/v1/tasks/"+id+"/activity", "", viewer.ID, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var feed activityResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&feed))
+	types := make([]task.ActivityType, 0, len(feed.Data))
+	for _, a := range feed.Data {
+		types = append(types, a.Type)
+	}
+	require.Equal(t, []task.ActivityType{
+		task.ActivityTaskCreated,
+		task.ActivityCommentCreated,
+		task.ActivityCommentCreated,
+		task.ActivityCommentEdited,
+		task.ActivityCommentDeleted,
+	}, types)
+}
+
+func TestTasksHandler_Description_ChecklistAndRenderedHTML(t *testing.T) {
+	svc := newTestService(t)
+	h := NewTasksHandler(svc)
+
+	body := `{"title":"Release","description":"Steps:\n\n- [x] tag\n- [ ] publish <script>alert(1)</script>\n\n[notes](javascript:alert(1))"}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/tasks", bytes.NewReader([]byte(body)))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	h.Create(rr, withUser(req, userID))
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	var created task.Task
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&created))
+	require.Equal(t, task.Checklist{Total: 2, Done: 1}, created.Checklist)
+	id := strconv.Itoa(created.ID)
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/tasks/"+id+"?render=html", nil)
+	req.SetPathValue("id", id)
+	rr = httptest.NewRecorder()
+	h.Get(rr, withUser(req, userID))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var rendered renderedTask
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&rendered))
+	require.Contains(t, rendered.DescriptionHTML, `<li><input type="checkbox" checked disabled> tag</li>`)
+	require.Contains(t, rendered.DescriptionHTML, "&lt;script&gt;")
+	require.NotContains(t, rendered.DescriptionHTML, "<script>")
+	require.NotContains(t, rendered.DescriptionHTML, "javascript:")
+
+	// merge-patch по описанию пересчитывает чек-лист
+	req = httptest.NewRequest(http.MethodPatch, "/v1/tasks/"+id, bytes.NewReader([]byte(`{"description":"- [x] tag\n- [x] publish"}`)))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req.SetPathValue("id", id)
+	rr = httptest.NewRecorder()
+	h.Update(rr, withUser(req, userID))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var updated task.Task
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&updated))
+	require.Equal(t, task.Checklist{Total: 2, Done: 2}, updated.Checklist)
+
+	// лимит размера
+	long, err := json.Marshal(strings.Repeat("a", task.MaxDescriptionLength+1))
+	require.NoError(t, err)
+	req = httptest.NewRequest(http.MethodPatch, "/v1/tasks/"+id, bytes.NewReader([]byte(`{"description":`+string(long)+`}`)))
+	req.Header.Set("Content-Type", "application/json")
+	req.SetPathValue("id", id)
+	rr = httptest.NewRecorder()
+	h.Update(rr, withUser(req, userID))
+	require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+}
+
+func TestTasksHandler_SnoozeAndDefer_HideFromDefaultLists(t *testing.T) {
+	svc := newTestService(t)
+	h := NewTasksHandler(svc)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/tasks", h.Create)
+	mux.HandleFunc("GET /v1/tasks", h.List)
+	mux.HandleFunc("POST /v1/tasks/{id}/snooze", h.Snooze)
+	mux.HandleFunc("DELETE /v1/tasks/{id}/snooze", h.Unsnooze)
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, withUser(req, userID))
+		return rr
+	}
+	listTitles := func(target string) []string {
+		rr := do(http.MethodGet, target, "")
+		require.Equal(t, http.StatusOK, rr.Code)
+		var resp listTasksResponse
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+		titles := make([]string, 0, len(resp.Data))
+		for _, tsk := range resp.Data {
+			titles = append(titles, tsk.Title)
+		}
+		return titles
+	}
+
+	now := time.Now().UTC()
+	require.Equal(t, http.StatusCreated, do(http.MethodPost, "/v1/tasks", `{"title":"Visible"}`).Code)
+	rr := do(http.MethodPost, "/v1/tasks", `{"title":"Planned","defer_until":"`+now.Add(48*time.Hour).Format(time.RFC3339)+`"}`)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	// начало позже срока — ошибка
+	rr = do(http.MethodPost, "/v1/tasks", `{"title":"Bad","due_at":"`+now.Add(time.Hour).Format(time.RFC3339)+`","defer_until":"`+now.Add(2*time.Hour).Format(time.RFC3339)+`"}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = do(http.MethodPost, "/v1/tasks", `{"title":"Later"}`)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var later task.Task
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&later))
+	snooze := "/v1/tasks/" + strconv.Itoa(later.ID) + "/snooze"
+
+	require.Equal(t, http.StatusBadRequest, do(http.MethodPost, snooze, `{"duration":"1h","until":"2030-01-01T00:00:00Z"}`).Code)
+	require.Equal(t, http.StatusBadRequest, do(http.MethodPost, snooze, `{"until":"2001-01-01T00:00:00Z"}`).Code)
+	rr = do(http.MethodPost, snooze, `{"duration":"2h"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var snoozed task.Task
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&snoozed))
+	require.NotNil(t, snoozed.SnoozedUntil)
+	require.WithinDuration(t, now.Add(2*time.Hour), *snoozed.SnoozedUntil, time.Minute)
+
+	require.Equal(t, []string{"Visible"}, listTitles("/v1/tasks"))
+	require.ElementsMatch(t, []string{"Visible", "Planned", "Later"}, listTitles("/v1/tasks?include_hidden=true"))
+
+	require.Equal(t, http.StatusOK, do(http.MethodDelete, snooze, "").Code)
+	require.ElementsMatch(t, []string{"Visible", "Later"}, listTitles("/v1/tasks"))
+}
+
+func TestTasksHandler_OverdueFlag(t *testing.T) {
+	svc := newTestService(t)
+	h := NewTasksHandler(svc)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/tasks", h.Create)
+	mux.HandleFunc("GET /v1/tasks/{id}", h.Get)
+	mux.HandleFunc("PATCH /v1/tasks/{id}", h.Update)
+	do := func(method, target, contentType, body string) task.Task {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, withUser(req, userID))
+		require.Less(t, rr.Code, 300, rr.Body.String())
+		var tsk task.Task
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&tsk))
+		return tsk
+	}
+
+	past := time.Now().UTC().Add(-time.Hour).Format(time.RFC3339)
+	late := do(http.MethodPost, "/v1/tasks", "", `{"title":"Late","due_at":"`+past+`"}`)
+	require.True(t, late.Overdue)
+	upcoming := do(http.MethodPost, "/v1/tasks", "", `{"title":"Upcoming","due_at":"`+time.Now().UTC().Add(time.Hour).Format(time.RFC3339)+`"}`)
+	require.False(t, upcoming.Overdue)
+
+	target := "/v1/tasks/" + strconv.Itoa(late.ID)
+	require.True(t, do(http.MethodGet, target, "", "").Overdue)
+	// флаг вычисляемый: в patch игнорируется, выполненная задача не просрочена
+	done := do(http.MethodPatch, target, "application/merge-patch+json", `{"status":"done","overdue":false}`)
+	require.False(t, done.Overdue)
+	require.False(t, do(http.MethodGet, target, "", "").Overdue)
+}
+
+func TestTasksHandler_MoveAndSortByRank(t *testing.T) {
+	svc := newTestService(t)
+	h := NewTasksHandler(svc)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/tasks", h.Create)
+	mux.HandleFunc("GET /v1/tasks", h.List)
+	mux.HandleFunc("POST /v1/tasks/{id}/move", h.Move)
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, withUser(req, userID))
+		return rr
+	}
+	create := func(title string) int {
+		rr := do(http.MethodPost, "/v1/tasks", `{"title":"`+title+`"}`)
+		require.Equal(t, http.StatusCreated, rr.Code)
+		var tsk task.Task
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&tsk))
+		require.NotEmpty(t, tsk.Rank)
+		return tsk.ID
+	}
+	move := func(id int, body string) int {
+		return do(http.MethodPost, "/v1/tasks/"+strconv.Itoa(id)+"/move", body).Code
+	}
+	ranked := func() []string {
+		rr := do(http.MethodGet, "/v1/tasks?sort=rank", "")
+		require.Equal(t, http.StatusOK, rr.Code)
+		var resp listTasksResponse
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+		titles := make([]string, 0, len(resp.Data))
+		for _, tsk := range resp.Data {
+			titles = append(titles, tsk.Title)
+		}
+		return titles
+	}
+
+	a, b, c := create("A"), create("B"), create("C")
+	// новые задачи — в конец списка
+	require.Equal(t, []string{"A", "B", "C"}, ranked())
+
+	require.Equal(t, http.StatusOK, move(c, `{"before":`+strconv.Itoa(a)+`}`))
+	require.Equal(t, []string{"C", "A", "B"}, ranked())
+	require.Equal(t, http.StatusOK, move(c, `{"after":`+strconv.Itoa(b)+`}`))
+	require.Equal(t, []string{"A", "B", "C"}, ranked())
+	require.Equal(t, http.StatusOK, move(a, `{"after":`+strconv.Itoa(b)+`,"before":`+strconv.Itoa(c)+`}`))
+	require.Equal(t, []string{"B", "A", "C"}, ranked())
+
+	require.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/v1/tasks?sort=priority", "").Code)
+	require.Equal(t, http.StatusBadRequest, move(a, `{}`))
+	require.Equal(t, http.StatusBadRequest, move(a, `{"before":`+strconv.Itoa(a)+`}`))
+	// якоря перепутаны местами
+	require.Equal(t, http.StatusBadRequest, move(a, `{"after":`+strconv.Itoa(c)+`,"before":`+strconv.Itoa(b)+`}`))
+	require.Equal(t, http.StatusBadRequest, move(a, `{"before":999999}`))
+
+	// постоянные вставки в одну щель удлиняют ранги, ребалансировка их укорачивает
+	for range 60 {
+		require.Equal(t, http.StatusOK, move(c, `{"after":`+strconv.Itoa(b)+`}`))
+		require.Equal(t, http.StatusOK, move(a, `{"after":`+strconv.Itoa(b)+`}`))
+	}
+	require.Equal(t, []string{"B", "A", "C"}, ranked())
+	longest := func() int {
+		list, _, _, err := svc.List(t.Context(), userID, task.ListOwned, task.ListOptions{Sort: task.SortRank}, 10, 0)
+		require.NoError(t, err)
+		n := 0
+		for _, tsk := range list {
+			n = max(n, len(tsk.Rank))
+		}
+		return n
+	}
+	require.Greater(t, longest(), task.MaxRankLength)
+	require.NoError(t, svc.RebalanceRanks(t.Context()))
+	require.Equal(t, []string{"B", "A", "C"}, ranked())
+	require.LessOrEqual(t, longest(), 2)
+}
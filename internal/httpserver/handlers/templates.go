@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"task_scheduler/internal/auth"
+	"task_scheduler/internal/task"
+	"task_scheduler/internal/template"
+	"time"
+)
+
+type TemplatesHandler struct {
+	svc template.Service
+}
+
+func NewTemplatesHandler(svc template.Service) *TemplatesHandler {
+	return &TemplatesHandler{svc: svc}
+}
+
+type templateRequest struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Items       []template.Item `json:"items"`
+}
+
+func (req templateRequest) input() template.Input {
+	return template.Input{Name: req.Name, Description: req.Description, Items: req.Items}
+}
+
+type instantiateTemplateRequest struct {
+	// Anchor is RFC3339 or YYYY-MM-DD (midnight UTC); now if empty.
+	Anchor      string `json:"anchor"`
+	WorkspaceID int    `json:"workspace_id"`
+}
+
+type listTemplatesResponse struct {
+	Data []template.Template `json:"data"`
+}
+
+type instantiateTemplateResponse struct {
+	Data []task.Task `json:"data"`
+}
+
+func (h *TemplatesHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+
+	workspaceID, err := workspaceFromRequest(r)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_WORKSPACE", err.Error())
+		return
+	}
+
+	var req templateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_JSON", "invalid json")
+		return
+	}
+
+	t, err := h.svc.Create(r.Context(), userID, workspaceID, req.input())
+	if err != nil {
+		writeTemplateError(w, err, "create")
+		return
+	}
+	WriteJSON(w, http.StatusCreated, t)
+}
+
+func (h *TemplatesHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+
+	workspaceID, err := workspaceFromRequest(r)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_WORKSPACE", err.Error())
+		return
+	}
+
+	list, err := h.svc.List(r.Context(), userID, workspaceID)
+	if err != nil {
+		writeTemplateError(w, err, "list")
+		return
+	}
+	WriteJSON(w, http.StatusOK, listTemplatesResponse{Data: list})
+}
+
+func (h *TemplatesHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := templatePath(w, r)
+	if !ok {
+		return
+	}
+
+	t, err := h.svc.Get(r.Context(), userID, id)
+	if err != nil {
+		writeTemplateError(w, err, "get")
+		return
+	}
+	WriteJSON(w, http.StatusOK, t)
+}
+
+// Update replaces the template with the body.
+func (h *TemplatesHandler) Update(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := templatePath(w, r)
+	if !ok {
+		return
+	}
+
+	var req templateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_JSON", "invalid json")
+		return
+	}
+
+	t, err := h.svc.Update(r.Context(), userID, id, req.input())
+	if err != nil {
+		writeTemplateError(w, err, "update")
+		return
+	}
+	WriteJSON(w, http.StatusOK, t)
+}
+
+func (h *TemplatesHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := templatePath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Delete(r.Context(), userID, id); err != nil {
+		writeTemplateError(w, err, "delete")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Instantiate creates the template's tasks: {"anchor": "...", "workspace_id": N},
+// both optional.
+func (h *TemplatesHandler) Instantiate(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := templatePath(w, r)
+	if !ok {
+		return
+	}
+
+	var req instantiateTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		WriteError(w, http.StatusBadRequest, "INVALID_JSON", "invalid json")
+		return
+	}
+	anchor := time.Now().UTC()
+	if req.Anchor != "" {
+		var err error
+		anchor, err = time.Parse(time.RFC3339, req.Anchor)
+		if err != nil {
+			anchor, err = time.Parse(time.DateOnly, req.Anchor)
+		}
+		if err != nil {
+			WriteError(w, http.StatusBadRequest, "VALIDATION_ERROR", "anchor must be RFC3339 or YYYY-MM-DD")
+			return
+		}
+	}
+
+	tasks, err := h.svc.Instantiate(r.Context(), userID, id, req.WorkspaceID, anchor)
+	if err != nil {
+		writeTemplateError(w, err, "instantiate")
+		return
+	}
+	WriteJSON(w, http.StatusCreated, instantiateTemplateResponse{Data: tasks})
+}
+
+func templatePath(w http.ResponseWriter, r *http.Request) (userID, id int, ok bool) {
+	userID, ok = auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return 0, 0, false
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		WriteError(w, http.StatusBadRequest, "INVALID_ID", "invalid id")
+		return 0, 0, false
+	}
+	return userID, id, true
+}
+
+func writeTemplateError(w http.ResponseWriter, err error, op string) {
+	switch {
+	case errors.Is(err, template.ErrNotFound):
+		WriteError(w, http.StatusNotFound, "TEMPLATE_NOT_FOUND", err.Error())
+	case errors.Is(err, task.ErrNotFound):
+		// задачи создаются в workspace, где пользователь не состоит
+		WriteError(w, http.StatusNotFound, "NOT_FOUND", "workspace not found")
+	case errors.Is(err, template.ErrForbidden), errors.Is(err, task.ErrForbidden):
+		WriteError(w, http.StatusForbidden, "FORBIDDEN", err.Error())
+	case errors.Is(err, task.ErrEmailNotVerified):
+		WriteError(w, http.StatusForbidden, "EMAIL_NOT_VERIFIED", err.Error())
+	case errors.Is(err, task.ErrWIPLimit):
+		WriteError(w, http.StatusConflict, "WIP_LIMIT_EXCEEDED", err.Error())
+	case errors.Is(err, template.ErrInvalidTemplate):
+		WriteError(w, http.StatusBadRequest, "INVALID_TEMPLATE", err.Error())
+	case errors.Is(err, template.ErrInvalidInput), errors.Is(err, task.ErrInvalidInput), errors.Is(err, task.ErrDescriptionTooLong):
+		WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+	case errors.Is(err, template.ErrTooManyTemplates):
+		WriteError(w, http.StatusConflict, "TOO_MANY_TEMPLATES", err.Error())
+	default:
+		WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		log.Println("[TEMPLATES] "+op+" error:", err)
+	}
+}
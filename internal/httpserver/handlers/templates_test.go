@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"task_scheduler/internal/task"
+	"task_scheduler/internal/template"
+	"task_scheduler/internal/workspace"
+)
+
+const onboardingTemplate = `{"name":"Onboard client","items":[
+	{"title":"Onboarding","due_offset":"336h","children":[
+		{"title":"Kickoff call","due_offset":"24h","estimate_minutes":60},
+		{"title":"Set up access","due_offset":"72h","defer_offset":"24h","children":[{"title":"VPN"}]}]},
+	{"title":"Send welcome pack","due_offset":"-24h"}]}`
+
+type templatesFixture struct {
+	services testServices
+	mux      *http.ServeMux
+}
+
+func newTemplatesFixture(t *testing.T) templatesFixture {
+	t.Helper()
+	services := newTestServices(t)
+	h := NewTemplatesHandler(services.templates)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/templates", h.Create)
+	mux.HandleFunc("GET /v1/templates", h.List)
+	mux.HandleFunc("GET /v1/templates/{id}", h.Get)
+	mux.HandleFunc("PUT /v1/templates/{id}", h.Update)
+	mux.HandleFunc("DELETE /v1/templates/{id}", h.Delete)
+	mux.HandleFunc("GET /v1/workspaces/{workspace_id}/templates", h.List)
+	mux.HandleFunc("POST /v1/workspaces/{workspace_id}/templates", h.Create)
+	mux.HandleFunc("POST /v1/templates/{id}/instantiate", h.Instantiate)
+	return templatesFixture{services: services, mux: mux}
+}
+
+func (f templatesFixture) do(method, target, body string, asUser int) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	f.mux.ServeHTTP(rr, withUser(req, asUser))
+	return rr
+}
+
+// onboarding creates onboardingTemplate and returns its instantiate URL.
+func (f templatesFixture) onboarding(t *testing.T) string {
+	t.Helper()
+	rr := f.do(http.MethodPost, "/v1/templates", onboardingTemplate, userID)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var tmpl template.Template
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&tmpl))
+	return "/v1/templates/" + strconv.Itoa(tmpl.ID) + "/instantiate"
+}
+
+// instantiate returns the created tasks by title.
+func (f templatesFixture) instantiate(t *testing.T, target, body string) map[string]task.Task {
+	t.Helper()
+	rr := f.do(http.MethodPost, target, body, userID)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var created instantiateTemplateResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&created))
+	byTitle := make(map[string]task.Task, len(created.Data))
+	for _, tsk := range created.Data {
+		byTitle[tsk.Title] = tsk
+	}
+	require.Len(t, byTitle, len(created.Data))
+	return byTitle
+}
+
+func TestTemplatesHandler_Create_InvalidOffsets(t *testing.T) {
+	f := newTemplatesFixture(t)
+
+	// отложить позже срока нельзя
+	rr := f.do(http.MethodPost, "/v1/templates", `{"name":"Broken","items":[{"title":"A","due_offset":"1h","defer_offset":"2h"}]}`, userID)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Contains(t, rr.Body.String(), "INVALID_TEMPLATE")
+}
+
+func TestTemplatesHandler_ListAndGet_OwnOnly(t *testing.T) {
+	f := newTemplatesFixture(t)
+	instantiate := f.onboarding(t)
+	tmplURL := strings.TrimSuffix(instantiate, "/instantiate")
+
+	rr := f.do(http.MethodGet, "/v1/templates", "", userID)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), "Onboard client")
+	require.Equal(t, http.StatusOK, f.do(http.MethodGet, tmplURL, "", userID).Code)
+
+	rr = f.do(http.MethodGet, "/v1/templates", "", userID+1)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NotContains(t, rr.Body.String(), "Onboard client")
+	require.Equal(t, http.StatusNotFound, f.do(http.MethodGet, tmplURL, "", userID+1).Code)
+	require.Equal(t, http.StatusNotFound, f.do(http.MethodDelete, tmplURL, "", userID+1).Code)
+	require.Equal(t, http.StatusNotFound, f.do(http.MethodPost, instantiate, "", userID+1).Code)
+}
+
+func TestTemplatesHandler_WorkspaceTemplates(t *testing.T) {
+	f := newTemplatesFixture(t)
+	ctx := t.Context()
+	admin, err := f.services.users.Register("admin@example.com", "secret123")
+	require.NoError(t, err)
+	ws, err := f.services.workspaces.Create(ctx, admin.ID, "Acme")
+	require.NoError(t, err)
+	member := f.services.join(t, admin.ID, ws.ID, "member@example.com", workspace.RoleMember)
+	guest := f.services.join(t, admin.ID, ws.ID, "guest@example.com", workspace.RoleGuest)
+	outsider, err := f.services.users.Register("outsider@example.com", "secret123")
+	require.NoError(t, err)
+	wsTemplates := "/v1/workspaces/" + strconv.Itoa(ws.ID) + "/templates"
+
+	rr := f.do(http.MethodPost, wsTemplates, onboardingTemplate, member)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var tmpl template.Template
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&tmpl))
+	require.Equal(t, ws.ID, tmpl.WorkspaceID)
+	tmplURL := "/v1/templates/" + strconv.Itoa(tmpl.ID)
+
+	// шаблон workspace видят все его участники, но не в личном списке
+	for _, id := range []int{admin.ID, member, guest} {
+		rr = f.do(http.MethodGet, wsTemplates, "", id)
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.Contains(t, rr.Body.String(), "Onboard client")
+		require.Equal(t, http.StatusOK, f.do(http.MethodGet, tmplURL, "", id).Code)
+	}
+	require.NotContains(t, f.do(http.MethodGet, "/v1/templates", "", member).Body.String(), "Onboard client")
+
+	// гость только читает
+	require.Equal(t, http.StatusForbidden, f.do(http.MethodPost, wsTemplates, onboardingTemplate, guest).Code)
+	require.Equal(t, http.StatusForbidden, f.do(http.MethodPut, tmplURL, onboardingTemplate, guest).Code)
+	require.Equal(t, http.StatusForbidden, f.do(http.MethodDelete, tmplURL, "", guest).Code)
+
+	// чужим workspace не существует
+	require.Equal(t, http.StatusNotFound, f.do(http.MethodGet, wsTemplates, "", outsider.ID).Code)
+	require.Equal(t, http.StatusNotFound, f.do(http.MethodGet, tmplURL, "", outsider.ID).Code)
+	require.Equal(t, http.StatusNotFound, f.do(http.MethodPost, tmplURL+"/instantiate", "", outsider.ID).Code)
+
+	// админ правит шаблон, сделанный участником
+	require.Equal(t, http.StatusOK, f.do(http.MethodPut, tmplURL, `{"name":"Renamed","items":[{"title":"A"}]}`, admin.ID).Code)
+	require.Equal(t, http.StatusNoContent, f.do(http.MethodDelete, tmplURL, "", admin.ID).Code)
+}
+
+func TestTemplatesHandler_Instantiate_InvalidRequest(t *testing.T) {
+	f := newTemplatesFixture(t)
+	instantiate := f.onboarding(t)
+
+	// в чужой workspace нельзя
+	require.Equal(t, http.StatusNotFound, f.do(http.MethodPost, instantiate, `{"workspace_id":42}`, userID).Code)
+	require.Equal(t, http.StatusBadRequest, f.do(http.MethodPost, instantiate, `{"anchor":"next monday"}`, userID).Code)
+	require.Equal(t, http.StatusNotFound, f.do(http.MethodPost, "/v1/templates/999/instantiate", "", userID).Code)
+}
+
+func TestTemplatesHandler_Instantiate_CreatesTreeFromAnchor(t *testing.T) {
+	f := newTemplatesFixture(t)
+	byTitle := f.instantiate(t, f.onboarding(t), `{"anchor":"2030-03-01"}`)
+	require.Len(t, byTitle, 5)
+
+	anchor := time.Date(2030, 3, 1, 0, 0, 0, 0, time.UTC)
+	root := byTitle["Onboarding"]
+	require.Nil(t, root.ParentID)
+	require.True(t, anchor.Add(14*24*time.Hour).Equal(*root.DueAt))
+	require.Equal(t, root.ID, *byTitle["Kickoff call"].ParentID)
+	require.Equal(t, 60, *byTitle["Kickoff call"].EstimateMinutes)
+	access := byTitle["Set up access"]
+	require.Equal(t, root.ID, *access.ParentID)
+	require.True(t, anchor.Add(24*time.Hour).Equal(*access.DeferUntil))
+	require.Equal(t, access.ID, *byTitle["VPN"].ParentID)
+	require.Nil(t, byTitle["VPN"].DueAt)
+	require.True(t, anchor.Add(-24*time.Hour).Equal(*byTitle["Send welcome pack"].DueAt))
+}
+
+func TestTemplatesHandler_Instantiate_ChildrenOutliveParent(t *testing.T) {
+	f := newTemplatesFixture(t)
+	byTitle := f.instantiate(t, f.onboarding(t), `{"anchor":"2030-03-01"}`)
+	svc := f.services.tasks
+
+	require.NoError(t, svc.Delete(t.Context(), userID, byTitle["Onboarding"].ID))
+	kickoff, err := svc.Get(t.Context(), userID, byTitle["Kickoff call"].ID)
+	require.NoError(t, err)
+	require.Nil(t, kickoff.ParentID)
+}
+
+func TestTemplatesHandler_CreateTree_CreatesNothingOnError(t *testing.T) {
+	f := newTemplatesFixture(t)
+	svc := f.services.tasks
+	count := func() int {
+		_, total, _, err := svc.List(t.Context(), userID, task.ListOwned, task.ListOptions{IncludeHidden: true}, 100, 0)
+		require.NoError(t, err)
+		return total
+	}
+	f.instantiate(t, f.onboarding(t), "")
+	before := count()
+
+	// ошибка в глубине дерева — не создаётся ничего
+	_, err := svc.CreateTree(t.Context(), userID, 0, []task.TreeInput{{
+		CreateTaskInput: task.CreateTaskInput{Title: "Root"},
+		Children:        []task.TreeInput{{CreateTaskInput: task.CreateTaskInput{Title: ""}}},
+	}})
+	require.ErrorIs(t, err, task.ErrInvalidInput)
+	require.Equal(t, before, count())
+}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"task_scheduler/internal/auth"
+	"task_scheduler/internal/task"
+	"task_scheduler/internal/timetrack"
+	"time"
+)
+
+type TimeEntriesHandler struct {
+	svc timetrack.Service
+}
+
+func NewTimeEntriesHandler(svc timetrack.Service) *TimeEntriesHandler {
+	return &TimeEntriesHandler{svc: svc}
+}
+
+type startTimerRequest struct {
+	Note string `json:"note"`
+}
+
+type createTimeEntryRequest struct {
+	StartedAt string `json:"started_at"`
+	EndedAt   string `json:"ended_at"`
+	Note      string `json:"note"`
+}
+
+type listTimeEntriesResponse struct {
+	Data []timetrack.Entry `json:"data"`
+}
+
+func (h *TimeEntriesHandler) Start(w http.ResponseWriter, r *http.Request) {
+	userID, taskID, ok := timeEntriesPath(w, r)
+	if !ok {
+		return
+	}
+
+	// тело необязательно: {"note": "..."}
+	var req startTimerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		WriteError(w, http.StatusBadRequest, "INVALID_JSON", "invalid json")
+		return
+	}
+
+	e, err := h.svc.Start(r.Context(), userID, taskID, req.Note)
+	if err != nil {
+		writeTimeEntryError(w, err, "start timer")
+		return
+	}
+	WriteJSON(w, http.StatusCreated, e)
+}
+
+func (h *TimeEntriesHandler) Stop(w http.ResponseWriter, r *http.Request) {
+	userID, taskID, ok := timeEntriesPath(w, r)
+	if !ok {
+		return
+	}
+
+	e, err := h.svc.Stop(r.Context(), userID, taskID)
+	if err != nil {
+		writeTimeEntryError(w, err, "stop timer")
+		return
+	}
+	WriteJSON(w, http.StatusOK, e)
+}
+
+// Create records a manual entry.
+func (h *TimeEntriesHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, taskID, ok := timeEntriesPath(w, r)
+	if !ok {
+		return
+	}
+
+	var req createTimeEntryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_JSON", "invalid json")
+		return
+	}
+	startedAt, err := time.Parse(time.RFC3339, req.StartedAt)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "VALIDATION_ERROR", "started_at must be RFC3339")
+		return
+	}
+	endedAt, err := time.Parse(time.RFC3339, req.EndedAt)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "VALIDATION_ERROR", "ended_at must be RFC3339")
+		return
+	}
+
+	e, err := h.svc.Add(r.Context(), userID, taskID, startedAt, endedAt, req.Note)
+	if err != nil {
+		writeTimeEntryError(w, err, "add entry")
+		return
+	}
+	WriteJSON(w, http.StatusCreated, e)
+}
+
+func (h *TimeEntriesHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, taskID, ok := timeEntriesPath(w, r)
+	if !ok {
+		return
+	}
+
+	entries, err := h.svc.List(r.Context(), userID, taskID)
+	if err != nil {
+		writeTimeEntryError(w, err, "list entries")
+		return
+	}
+	WriteJSON(w, http.StatusOK, listTimeEntriesResponse{Data: entries})
+}
+
+func (h *TimeEntriesHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	userID, taskID, ok := timeEntriesPath(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(r.PathValue("entry_id"))
+	if err != nil || id <= 0 {
+		WriteError(w, http.StatusBadRequest, "INVALID_ID", "invalid time entry id")
+		return
+	}
+
+	if err := h.svc.Delete(r.Context(), userID, taskID, id); err != nil {
+		writeTimeEntryError(w, err, "delete entry")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Report summarizes the caller's tracked time:
+// ?from=&to= (YYYY-MM-DD), tz=, group_by=day|task|workspace|user,
+// workspace_id=, format=json|csv. With a workspace selected (path or
+// header) it covers the workspace's tasks, with everyone's time for admins.
+func (h *TimeEntriesHandler) Report(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+
+	q := r.URL.Query()
+	format := q.Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		WriteError(w, http.StatusBadRequest, "VALIDATION_ERROR", "format must be json or csv")
+		return
+	}
+	query := timetrack.ReportQuery{
+		From:     q.Get("from"),
+		To:       q.Get("to"),
+		Timezone: q.Get("tz"),
+		GroupBy:  timetrack.GroupBy(q.Get("group_by")),
+	}
+	if q.Has("workspace_id") {
+		workspaceID, err := strconv.Atoi(q.Get("workspace_id"))
+		if err != nil || workspaceID < 0 {
+			WriteError(w, http.StatusBadRequest, "VALIDATION_ERROR", "workspace_id must be a number")
+			return
+		}
+		query.WorkspaceID = &workspaceID
+	}
+
+	workspaceID, err := workspaceFromRequest(r)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_WORKSPACE", err.Error())
+		return
+	}
+
+	var report *timetrack.Report
+	if workspaceID > 0 {
+		report, err = h.svc.WorkspaceReport(r.Context(), userID, workspaceID, query)
+	} else {
+		report, err = h.svc.Report(r.Context(), userID, query)
+	}
+	if err != nil {
+		writeTimeEntryError(w, err, "report")
+		return
+	}
+
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="time-report-`+report.From+`-`+report.To+`.csv"`)
+		w.WriteHeader(http.StatusOK)
+		if err := report.WriteCSV(w); err != nil {
+			log.Println("[TIME] write csv error:", err)
+		}
+		return
+	}
+	WriteJSON(w, http.StatusOK, report)
+}
+
+func timeEntriesPath(w http.ResponseWriter, r *http.Request) (userID, taskID int, ok bool) {
+	userID, ok = auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return 0, 0, false
+	}
+
+	taskID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || taskID <= 0 {
+		WriteError(w, http.StatusBadRequest, "INVALID_ID", "invalid id")
+		return 0, 0, false
+	}
+	return userID, taskID, true
+}
+
+func writeTimeEntryError(w http.ResponseWriter, err error, op string) {
+	switch {
+	case errors.Is(err, task.ErrNotFound):
+		WriteError(w, http.StatusNotFound, "NOT_FOUND", err.Error())
+	case errors.Is(err, timetrack.ErrNotFound):
+		WriteError(w, http.StatusNotFound, "TIME_ENTRY_NOT_FOUND", err.Error())
+	case errors.Is(err, task.ErrForbidden):
+		WriteError(w, http.StatusForbidden, "FORBIDDEN", err.Error())
+	case errors.Is(err, timetrack.ErrTimerRunning):
+		WriteError(w, http.StatusConflict, "TIMER_RUNNING", err.Error())
+	case errors.Is(err, timetrack.ErrNoTimer):
+		WriteError(w, http.StatusConflict, "NO_RUNNING_TIMER", err.Error())
+	case errors.Is(err, timetrack.ErrOverlap):
+		WriteError(w, http.StatusConflict, "TIME_ENTRY_OVERLAP", err.Error())
+	case errors.Is(err, timetrack.ErrInvalidInput), errors.Is(err, task.ErrInvalidInput):
+		WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+	default:
+		WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		log.Println("[TIME] "+op+" error:", err)
+	}
+}
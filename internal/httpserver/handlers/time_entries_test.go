@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"task_scheduler/internal/task"
+	"task_scheduler/internal/timetrack"
+	"task_scheduler/internal/workspace"
+)
+
+type timeEntriesFixture struct {
+	h      *TimeEntriesHandler
+	owner  int
+	viewer int
+	// billable оценена в 90 минут и расшарена viewer'у
+	billable int
+	other    int
+}
+
+func newTimeEntriesFixture(t *testing.T) timeEntriesFixture {
+	t.Helper()
+	services := newTestServices(t)
+	owner, err := services.users.Register("owner@example.com", "secret123")
+	require.NoError(t, err)
+	viewer, err := services.users.Register("viewer@example.com", "secret123")
+	require.NoError(t, err)
+
+	estimate := 90
+	billable, err := services.tasks.Create(t.Context(), owner.ID, 0, task.CreateTaskInput{Title: "=Billable", EstimateMinutes: &estimate})
+	require.NoError(t, err)
+	other, err := services.tasks.Create(t.Context(), owner.ID, 0, task.CreateTaskInput{Title: "Other"})
+	require.NoError(t, err)
+	_, err = services.tasks.Share(t.Context(), owner.ID, billable.ID, "viewer@example.com", task.RoleViewer)
+	require.NoError(t, err)
+	return timeEntriesFixture{
+		h:        NewTimeEntriesHandler(services.timeTracking),
+		owner:    owner.ID,
+		viewer:   viewer.ID,
+		billable: billable.ID,
+		other:    other.ID,
+	}
+}
+
+// call runs a /v1/tasks/{id}/time-entries handler; suffix is "", "/start" or "/stop".
+func (f timeEntriesFixture) call(handler http.HandlerFunc, method string, taskID int, suffix, body string, asUser int) *httptest.ResponseRecorder {
+	id := strconv.Itoa(taskID)
+	req := httptest.NewRequest(method, "/v1/tasks/"+id+"/time-entries"+suffix, strings.NewReader(body))
+	req.SetPathValue("id", id)
+	rr := httptest.NewRecorder()
+	handler(rr, withUser(req, asUser))
+	return rr
+}
+
+func (f timeEntriesFixture) report(query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/v1/reports/time?"+query, nil)
+	rr := httptest.NewRecorder()
+	f.h.Report(rr, withUser(req, f.owner))
+	return rr
+}
+
+// lateFix: ручная запись 2026-01-05 23:00 – 2026-01-06 01:00, через полночь.
+func (f timeEntriesFixture) lateFix(t *testing.T) {
+	t.Helper()
+	rr := f.call(f.h.Create, http.MethodPost, f.billable, "",
+		`{"started_at":"2026-01-05T23:00:00Z","ended_at":"2026-01-06T01:00:00Z","note":"late fix"}`, f.owner)
+	require.Equal(t, http.StatusCreated, rr.Code)
+}
+
+func TestTimeEntriesHandler_Start_OneTimerPerUser(t *testing.T) {
+	f := newTimeEntriesFixture(t)
+
+	rr := f.call(f.h.Start, http.MethodPost, f.billable, "/start", `{"note":"call"}`, f.owner)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	rr = f.call(f.h.Start, http.MethodPost, f.other, "/start", "", f.owner)
+	require.Equal(t, http.StatusConflict, rr.Code)
+	require.Contains(t, rr.Body.String(), "TIMER_RUNNING")
+}
+
+func TestTimeEntriesHandler_Start_ViewerForbidden(t *testing.T) {
+	f := newTimeEntriesFixture(t)
+
+	rr := f.call(f.h.Start, http.MethodPost, f.billable, "/start", "", f.viewer)
+	require.Equal(t, http.StatusForbidden, rr.Code)
+}
+
+func TestTimeEntriesHandler_Stop(t *testing.T) {
+	f := newTimeEntriesFixture(t)
+	require.Equal(t, http.StatusCreated, f.call(f.h.Start, http.MethodPost, f.billable, "/start", "", f.owner).Code)
+
+	rr := f.call(f.h.Stop, http.MethodPost, f.billable, "/stop", "", f.owner)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var stopped timetrack.Entry
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&stopped))
+	require.NotNil(t, stopped.EndedAt)
+
+	rr = f.call(f.h.Stop, http.MethodPost, f.billable, "/stop", "", f.owner)
+	require.Equal(t, http.StatusConflict, rr.Code)
+	require.Contains(t, rr.Body.String(), "NO_RUNNING_TIMER")
+}
+
+func TestTimeEntriesHandler_Create_RejectsOverlapAcrossTasks(t *testing.T) {
+	f := newTimeEntriesFixture(t)
+	f.lateFix(t)
+
+	rr := f.call(f.h.Create, http.MethodPost, f.other, "",
+		`{"started_at":"2026-01-06T00:30:00Z","ended_at":"2026-01-06T02:00:00Z"}`, f.owner)
+	require.Equal(t, http.StatusConflict, rr.Code)
+	require.Contains(t, rr.Body.String(), "TIME_ENTRY_OVERLAP")
+}
+
+func TestTimeEntriesHandler_List_SharedWithViewer(t *testing.T) {
+	f := newTimeEntriesFixture(t)
+	f.lateFix(t)
+
+	rr := f.call(f.h.List, http.MethodGet, f.billable, "", "", f.viewer)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var list listTimeEntriesResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+	require.Len(t, list.Data, 1)
+	require.EqualValues(t, 7200, list.Data[0].Seconds)
+	require.Equal(t, "late fix", list.Data[0].Note)
+}
+
+func TestTimeEntriesHandler_Report_SplitsEntriesByDay(t *testing.T) {
+	f := newTimeEntriesFixture(t)
+	f.lateFix(t)
+
+	// запись делится по границе суток, оценка задачи — на каждый день
+	rr := f.report("from=2026-01-05&to=2026-01-06")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var report timetrack.Report
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&report))
+	require.Equal(t, []timetrack.ReportRow{
+		{Key: "2026-01-05", Tasks: 1, TrackedSeconds: 3600, EstimatedSeconds: 5400},
+		{Key: "2026-01-06", Tasks: 1, TrackedSeconds: 3600, EstimatedSeconds: 5400},
+	}, report.Rows)
+	require.Equal(t, timetrack.ReportRow{Key: "total", Tasks: 1, TrackedSeconds: 7200, EstimatedSeconds: 5400}, report.Total)
+}
+
+func TestTimeEntriesHandler_Report_CSVByTask(t *testing.T) {
+	f := newTimeEntriesFixture(t)
+	f.lateFix(t)
+
+	rr := f.report("from=2026-01-05&to=2026-01-06&group_by=task&format=csv")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Equal(t, "text/csv; charset=utf-8", rr.Header().Get("Content-Type"))
+	// заголовок задачи, похожий на формулу, экранируется
+	require.Equal(t,
+		"task,title,tasks,tracked_seconds,estimated_seconds,tracked_hours,estimated_hours\n"+
+			strconv.Itoa(f.billable)+",'=Billable,1,7200,5400,2.00,1.50\n"+
+			"total,,1,7200,5400,2.00,1.50\n",
+		rr.Body.String())
+}
+
+func TestTimeEntriesHandler_WorkspaceReport(t *testing.T) {
+	services := newTestServices(t)
+	ctx := t.Context()
+	h := NewTimeEntriesHandler(services.timeTracking)
+	admin, err := services.users.Register("admin@example.com", "secret123")
+	require.NoError(t, err)
+	ws, err := services.workspaces.Create(ctx, admin.ID, "Acme")
+	require.NoError(t, err)
+	member := services.join(t, admin.ID, ws.ID, "member@example.com", workspace.RoleMember)
+	outsider, err := services.users.Register("outsider@example.com", "secret123")
+	require.NoError(t, err)
+	tsk, err := services.tasks.Create(ctx, admin.ID, ws.ID, task.CreateTaskInput{Title: "Release"})
+	require.NoError(t, err)
+	for _, u := range []struct{ id, hours int }{{admin.ID, 1}, {member, 2}} {
+		_, err := services.timeTracking.Add(ctx, u.id, tsk.ID,
+			time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC), time.Date(2026, 1, 5, 9+u.hours, 0, 0, 0, time.UTC), "")
+		require.NoError(t, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/workspaces/{workspace_id}/reports/time", h.Report)
+	report := func(asUser int) (int, timetrack.Report) {
+		req := httptest.NewRequest(http.MethodGet, "/v1/workspaces/"+strconv.Itoa(ws.ID)+"/reports/time?from=2026-01-05&to=2026-01-05&group_by=user", nil)
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, withUser(req, asUser))
+		var r timetrack.Report
+		if rr.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&r))
+		}
+		return rr.Code, r
+	}
+
+	// админ видит время всех участников
+	code, r := report(admin.ID)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, []timetrack.ReportRow{
+		{Key: strconv.Itoa(admin.ID), Tasks: 1, TrackedSeconds: 3600},
+		{Key: strconv.Itoa(member), Tasks: 1, TrackedSeconds: 7200},
+	}, r.Rows)
+
+	// участник — только своё
+	code, r = report(member)
+	require.Equal(t, http.StatusOK, code)
+	require.EqualValues(t, 7200, r.Total.TrackedSeconds)
+	require.Len(t, r.Rows, 1)
+
+	code, _ = report(outsider.ID)
+	require.Equal(t, http.StatusNotFound, code)
+}
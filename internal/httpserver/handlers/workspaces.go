@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"task_scheduler/internal/auth"
+	"task_scheduler/internal/workspace"
+)
+
+// WorkspaceHeader selects the workspace for routes outside /v1/workspaces/{workspace_id}.
+const WorkspaceHeader = "X-Workspace-ID"
+
+var errInvalidWorkspaceID = errors.New("workspace id must be a positive number")
+
+// workspaceFromRequest returns the workspace from the path or the
+// X-Workspace-ID header, or 0 for the personal space.
+func workspaceFromRequest(r *http.Request) (int, error) {
+	raw := r.PathValue("workspace_id")
+	if raw == "" {
+		raw = r.Header.Get(WorkspaceHeader)
+	}
+	if raw == "" {
+		return 0, nil
+	}
+	id, err := strconv.Atoi(raw)
+	if err != nil || id <= 0 {
+		return 0, errInvalidWorkspaceID
+	}
+	return id, nil
+}
+
+type WorkspacesHandler struct {
+	svc workspace.Service
+}
+
+func NewWorkspacesHandler(svc workspace.Service) *WorkspacesHandler {
+	return &WorkspacesHandler{
+		svc: svc,
+	}
+}
+
+type createWorkspaceRequest struct {
+	Name string `json:"name"`
+}
+
+type inviteRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type changeRoleRequest struct {
+	Role string `json:"role"`
+}
+
+type acceptInviteRequest struct {
+	Token string `json:"token"`
+}
+
+type listWorkspacesResponse struct {
+	Data []workspace.Membership `json:"data"`
+}
+
+type listMembersResponse struct {
+	Data []workspace.Member `json:"data"`
+}
+
+func (h *WorkspacesHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+
+	var req createWorkspaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_JSON", "invalid json")
+		return
+	}
+
+	ws, err := h.svc.Create(r.Context(), userID, req.Name)
+	if err != nil {
+		writeWorkspaceError(w, err, "create")
+		return
+	}
+	WriteJSON(w, http.StatusCreated, ws)
+}
+
+func (h *WorkspacesHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+
+	list, err := h.svc.ListForUser(r.Context(), userID)
+	if err != nil {
+		writeWorkspaceError(w, err, "list")
+		return
+	}
+	WriteJSON(w, http.StatusOK, listWorkspacesResponse{Data: list})
+}
+
+func (h *WorkspacesHandler) Members(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+	workspaceID, err := workspaceFromRequest(r)
+	if err != nil || workspaceID == 0 {
+		WriteError(w, http.StatusBadRequest, "INVALID_ID", "invalid workspace id")
+		return
+	}
+
+	members, err := h.svc.Members(r.Context(), userID, workspaceID)
+	if err != nil {
+		writeWorkspaceError(w, err, "members")
+		return
+	}
+	WriteJSON(w, http.StatusOK, listMembersResponse{Data: members})
+}
+
+func (h *WorkspacesHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+	workspaceID, err := workspaceFromRequest(r)
+	if err != nil || workspaceID == 0 {
+		WriteError(w, http.StatusBadRequest, "INVALID_ID", "invalid workspace id")
+		return
+	}
+	targetID, err := strconv.Atoi(r.PathValue("user_id"))
+	if err != nil || targetID <= 0 {
+		WriteError(w, http.StatusBadRequest, "INVALID_ID", "invalid user id")
+		return
+	}
+
+	if err := h.svc.RemoveMember(r.Context(), userID, workspaceID, targetID); err != nil {
+		writeWorkspaceError(w, err, "remove member")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *WorkspacesHandler) ChangeRole(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+	workspaceID, err := workspaceFromRequest(r)
+	if err != nil || workspaceID == 0 {
+		WriteError(w, http.StatusBadRequest, "INVALID_ID", "invalid workspace id")
+		return
+	}
+	targetID, err := strconv.Atoi(r.PathValue("user_id"))
+	if err != nil || targetID <= 0 {
+		WriteError(w, http.StatusBadRequest, "INVALID_ID", "invalid user id")
+		return
+	}
+
+	var req changeRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_JSON", "invalid json")
+		return
+	}
+
+	m, err := h.svc.ChangeRole(r.Context(), userID, workspaceID, targetID, workspace.Role(req.Role))
+	if err != nil {
+		writeWorkspaceError(w, err, "change role")
+		return
+	}
+	WriteJSON(w, http.StatusOK, m)
+}
+
+func (h *WorkspacesHandler) Invite(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+	workspaceID, err := workspaceFromRequest(r)
+	if err != nil || workspaceID == 0 {
+		WriteError(w, http.StatusBadRequest, "INVALID_ID", "invalid workspace id")
+		return
+	}
+
+	var req inviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_JSON", "invalid json")
+		return
+	}
+
+	inv, err := h.svc.Invite(r.Context(), userID, workspaceID, req.Email, workspace.Role(req.Role))
+	if err != nil {
+		writeWorkspaceError(w, err, "invite")
+		return
+	}
+	WriteJSON(w, http.StatusCreated, inv)
+}
+
+func (h *WorkspacesHandler) AcceptInvite(w http.ResponseWriter, r *http.Request) {
+	userID, ok := auth.UserIDFromContext(r.Context())
+	if !ok {
+		WriteError(w, http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized")
+		return
+	}
+
+	var req acceptInviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "INVALID_JSON", "invalid json")
+		return
+	}
+
+	m, err := h.svc.AcceptInvite(r.Context(), userID, req.Token)
+	if err != nil {
+		writeWorkspaceError(w, err, "accept invite")
+		return
+	}
+	WriteJSON(w, http.StatusOK, m)
+}
+
+func writeWorkspaceError(w http.ResponseWriter, err error, op string) {
+	switch {
+	case errors.Is(err, workspace.ErrInvalidInput):
+		WriteError(w, http.StatusBadRequest, "INVALID_REQUEST", err.Error())
+	case errors.Is(err, workspace.ErrNotMember), errors.Is(err, workspace.ErrNotFound):
+		// не участнику не раскрываем, что workspace существует
+		WriteError(w, http.StatusNotFound, "NOT_FOUND", "workspace not found")
+	case errors.Is(err, workspace.ErrForbidden):
+		WriteError(w, http.StatusForbidden, "FORBIDDEN", err.Error())
+	case errors.Is(err, workspace.ErrLastAdmin):
+		WriteError(w, http.StatusConflict, "LAST_ADMIN", err.Error())
+	case errors.Is(err, workspace.ErrAlreadyMember):
+		WriteError(w, http.StatusConflict, "ALREADY_MEMBER", err.Error())
+	case errors.Is(err, workspace.ErrInvalidInvitation):
+		WriteError(w, http.StatusBadRequest, "INVALID_INVITATION", err.Error())
+	default:
+		WriteError(w, http.StatusInternalServerError, "INTERNAL_ERROR", "internal error")
+		log.Println("[WORKSPACES] "+op+" error:", err)
+	}
+}
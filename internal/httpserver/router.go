@@ -4,22 +4,121 @@ import (
 	"net/http"
 	"task_scheduler/internal/auth"
 	"task_scheduler/internal/httpserver/handlers"
-	"task_scheduler/internal/task"
-	"task_scheduler/internal/user"
 )
 
-func registerRoutes(mux *http.ServeMux, taskSvc task.Service, userSvc user.Service, jwtManager *auth.JWTManager) {
+func registerRoutes(mux *http.ServeMux, deps Deps) {
 	mux.HandleFunc("GET /healthz", handlers.Health)
+	mux.HandleFunc("GET /.well-known/jwks.json", handlers.JWKS(deps.JWT))
 
-	authMW := auth.JWTMiddleware(jwtManager)
-	taskHandler := handlers.NewTasksHandler(taskSvc)
+	authMW := auth.JWTMiddleware(deps.JWT, deps.Revocations, deps.APIKeys)
+	// scoped — auth + проверка scopes для конкретного роута
+	scoped := func(h http.HandlerFunc, scopes ...string) http.Handler {
+		return authMW(auth.RequireScopes(scopes...)(h))
+	}
+	// session — как scoped, но только для входа по логину, не по API ключу
+	session := func(h http.HandlerFunc, scopes ...string) http.Handler {
+		return authMW(auth.RequireScopes(scopes...)(auth.RequireSession(h)))
+	}
+	taskHandler := handlers.NewTasksHandler(deps.Tasks)
 
-	mux.Handle("POST /v1/tasks", authMW(http.HandlerFunc(taskHandler.Create)))
-	mux.Handle("GET /v1/tasks/{id}", authMW(http.HandlerFunc(taskHandler.Get)))
-	mux.Handle("GET /v1/tasks", authMW(http.HandlerFunc(taskHandler.List)))
+	// подтверждённый email (если он требуется) проверяет сам сервис задач
+	mux.Handle("POST /v1/tasks", scoped(taskHandler.Create, auth.ScopeTasksWrite))
+	mux.Handle("POST /v1/workspaces/{workspace_id}/tasks", scoped(taskHandler.Create, auth.ScopeTasksWrite))
+	mux.Handle("GET /v1/workspaces/{workspace_id}/tasks", scoped(taskHandler.List, auth.ScopeTasksRead))
+	mux.Handle("GET /v1/tasks/{id}", scoped(taskHandler.Get, auth.ScopeTasksRead))
+	mux.Handle("GET /v1/tasks", scoped(taskHandler.List, auth.ScopeTasksRead))
+	mux.Handle("PATCH /v1/tasks/{id}", scoped(taskHandler.Update, auth.ScopeTasksWrite))
+	mux.Handle("DELETE /v1/tasks/{id}", scoped(taskHandler.Delete, auth.ScopeTasksWrite))
+	mux.Handle("POST /v1/tasks/{id}/snooze", scoped(taskHandler.Snooze, auth.ScopeTasksWrite))
+	mux.Handle("DELETE /v1/tasks/{id}/snooze", scoped(taskHandler.Unsnooze, auth.ScopeTasksWrite))
+	mux.Handle("POST /v1/tasks/{id}/move", scoped(taskHandler.Move, auth.ScopeTasksWrite))
+	mux.Handle("GET /v1/tasks/{id}/shares", scoped(taskHandler.ListShares, auth.ScopeTasksRead))
+	mux.Handle("POST /v1/tasks/{id}/shares", scoped(taskHandler.Share, auth.ScopeTasksWrite))
+	mux.Handle("DELETE /v1/tasks/{id}/shares/{user_id}", scoped(taskHandler.Unshare, auth.ScopeTasksWrite))
+	mux.Handle("GET /v1/tasks/{id}/comments", scoped(taskHandler.ListComments, auth.ScopeTasksRead))
+	mux.Handle("POST /v1/tasks/{id}/comments", scoped(taskHandler.AddComment, auth.ScopeTasksWrite))
+	mux.Handle("PATCH /v1/tasks/{id}/comments/{comment_id}", scoped(taskHandler.EditComment, auth.ScopeTasksWrite))
+	mux.Handle("DELETE /v1/tasks/{id}/comments/{comment_id}", scoped(taskHandler.DeleteComment, auth.ScopeTasksWrite))
+	mux.Handle("GET /v1/tasks/{id}/comments/{comment_id}/history", scoped(taskHandler.CommentHistory, auth.ScopeTasksRead))
+	mux.Handle("GET /v1/tasks/{id}/activity", scoped(taskHandler.Activity, auth.ScopeTasksRead))
 
-	authHandler := handlers.NewAuthHandler(userSvc, jwtManager)
+	attachmentsHandler := handlers.NewAttachmentsHandler(deps.Attachments)
+	mux.Handle("POST /v1/tasks/{id}/attachments", scoped(attachmentsHandler.Upload, auth.ScopeTasksWrite))
+	mux.Handle("GET /v1/tasks/{id}/attachments", scoped(attachmentsHandler.List, auth.ScopeTasksRead))
+	mux.Handle("GET /v1/tasks/{id}/attachments/{attachment_id}", scoped(attachmentsHandler.Download, auth.ScopeTasksRead))
+	mux.Handle("DELETE /v1/tasks/{id}/attachments/{attachment_id}", scoped(attachmentsHandler.Delete, auth.ScopeTasksWrite))
+
+	timeHandler := handlers.NewTimeEntriesHandler(deps.TimeTracking)
+	mux.Handle("GET /v1/tasks/{id}/time-entries", scoped(timeHandler.List, auth.ScopeTasksRead))
+	mux.Handle("POST /v1/tasks/{id}/time-entries", scoped(timeHandler.Create, auth.ScopeTasksWrite))
+	mux.Handle("POST /v1/tasks/{id}/time-entries/start", scoped(timeHandler.Start, auth.ScopeTasksWrite))
+	mux.Handle("POST /v1/tasks/{id}/time-entries/stop", scoped(timeHandler.Stop, auth.ScopeTasksWrite))
+	mux.Handle("DELETE /v1/tasks/{id}/time-entries/{entry_id}", scoped(timeHandler.Delete, auth.ScopeTasksWrite))
+	mux.Handle("GET /v1/reports/time", scoped(timeHandler.Report, auth.ScopeTasksRead))
+	mux.Handle("GET /v1/workspaces/{workspace_id}/reports/time", scoped(timeHandler.Report, auth.ScopeTasksRead))
+
+	automationsHandler := handlers.NewAutomationsHandler(deps.Automations)
+	mux.Handle("GET /v1/automations", scoped(automationsHandler.List, auth.ScopeTasksRead))
+	mux.Handle("POST /v1/automations", scoped(automationsHandler.Create, auth.ScopeTasksWrite))
+	mux.Handle("POST /v1/automations/dry-run", scoped(automationsHandler.DryRun, auth.ScopeTasksRead))
+	mux.Handle("GET /v1/automations/{id}", scoped(automationsHandler.Get, auth.ScopeTasksRead))
+	mux.Handle("PUT /v1/automations/{id}", scoped(automationsHandler.Update, auth.ScopeTasksWrite))
+	mux.Handle("DELETE /v1/automations/{id}", scoped(automationsHandler.Delete, auth.ScopeTasksWrite))
+	mux.Handle("GET /v1/workspaces/{workspace_id}/automations", scoped(automationsHandler.List, auth.ScopeTasksRead))
+	mux.Handle("POST /v1/workspaces/{workspace_id}/automations", scoped(automationsHandler.Create, auth.ScopeTasksWrite))
+	mux.Handle("POST /v1/workspaces/{workspace_id}/automations/dry-run", scoped(automationsHandler.DryRun, auth.ScopeTasksRead))
+
+	templatesHandler := handlers.NewTemplatesHandler(deps.Templates)
+	mux.Handle("GET /v1/templates", scoped(templatesHandler.List, auth.ScopeTasksRead))
+	mux.Handle("POST /v1/templates", scoped(templatesHandler.Create, auth.ScopeTasksWrite))
+	mux.Handle("GET /v1/templates/{id}", scoped(templatesHandler.Get, auth.ScopeTasksRead))
+	mux.Handle("PUT /v1/templates/{id}", scoped(templatesHandler.Update, auth.ScopeTasksWrite))
+	mux.Handle("DELETE /v1/templates/{id}", scoped(templatesHandler.Delete, auth.ScopeTasksWrite))
+	mux.Handle("POST /v1/templates/{id}/instantiate", scoped(templatesHandler.Instantiate, auth.ScopeTasksWrite))
+	mux.Handle("GET /v1/workspaces/{workspace_id}/templates", scoped(templatesHandler.List, auth.ScopeTasksRead))
+	mux.Handle("POST /v1/workspaces/{workspace_id}/templates", scoped(templatesHandler.Create, auth.ScopeTasksWrite))
+
+	authHandler := handlers.NewAuthHandler(deps.Users, deps.MFA, deps.Tokens, deps.LoginGuard, deps.TrustForwardedFor)
 	mux.HandleFunc("POST /v1/auth/register", authHandler.Register)
 	mux.HandleFunc("POST /v1/auth/login", authHandler.Login)
+	mux.HandleFunc("POST /v1/auth/mfa", authHandler.LoginMFA)
+	mux.Handle("POST /v1/auth/mfa/enroll", session(authHandler.EnrollMFA, auth.ScopeAccountManage))
+	mux.Handle("POST /v1/auth/mfa/confirm", session(authHandler.ConfirmMFA, auth.ScopeAccountManage))
+	mux.HandleFunc("POST /v1/auth/refresh", authHandler.Refresh)
+	mux.Handle("POST /v1/auth/logout", authMW(http.HandlerFunc(authHandler.Logout)))
+	mux.Handle("POST /v1/auth/logout-all", scoped(authHandler.LogoutAll, auth.ScopeAccountManage))
+	mux.HandleFunc("POST /v1/auth/password/forgot", authHandler.ForgotPassword)
+	mux.HandleFunc("POST /v1/auth/password/reset", authHandler.ResetPassword)
+	mux.HandleFunc("POST /v1/auth/verify", authHandler.VerifyEmail)
+	mux.HandleFunc("POST /v1/auth/verify/resend", authHandler.ResendVerification)
+
+	apiKeysHandler := handlers.NewAPIKeysHandler(deps.APIKeys)
+	mux.Handle("POST /v1/api-keys", scoped(apiKeysHandler.Create, auth.ScopeAPIKeysManage))
+	mux.Handle("GET /v1/api-keys", scoped(apiKeysHandler.List, auth.ScopeAPIKeysManage))
+	mux.Handle("DELETE /v1/api-keys/{id}", scoped(apiKeysHandler.Revoke, auth.ScopeAPIKeysManage))
+
+	meHandler := handlers.NewMeHandler(deps.Users, deps.Tokens)
+	mux.Handle("GET /v1/me", scoped(meHandler.Get, auth.ScopeAccountManage))
+	mux.Handle("PATCH /v1/me", scoped(meHandler.Update, auth.ScopeAccountManage))
+	mux.Handle("POST /v1/me/password", scoped(meHandler.ChangePassword, auth.ScopeAccountManage))
+	mux.Handle("DELETE /v1/me", session(meHandler.Delete, auth.ScopeAccountManage))
+
+	workspacesHandler := handlers.NewWorkspacesHandler(deps.Workspaces)
+	mux.Handle("POST /v1/workspaces", scoped(workspacesHandler.Create, auth.ScopeWorkspaces))
+	mux.Handle("GET /v1/workspaces", scoped(workspacesHandler.List, auth.ScopeWorkspaces))
+	mux.Handle("GET /v1/workspaces/{workspace_id}/members", scoped(workspacesHandler.Members, auth.ScopeWorkspaces))
+	mux.Handle("PATCH /v1/workspaces/{workspace_id}/members/{user_id}", scoped(workspacesHandler.ChangeRole, auth.ScopeWorkspaces))
+	mux.Handle("DELETE /v1/workspaces/{workspace_id}/members/{user_id}", scoped(workspacesHandler.RemoveMember, auth.ScopeWorkspaces))
+	mux.Handle("POST /v1/workspaces/{workspace_id}/invitations", scoped(workspacesHandler.Invite, auth.ScopeWorkspaces))
+	mux.Handle("POST /v1/invitations/accept", scoped(workspacesHandler.AcceptInvite, auth.ScopeWorkspaces))
+
+	// доска — задачи workspace по колонкам-статусам
+	boardsHandler := handlers.NewBoardsHandler(deps.Tasks, deps.Workspaces)
+	mux.Handle("GET /v1/boards/{project}", scoped(boardsHandler.Get, auth.ScopeTasksRead))
+	mux.Handle("PUT /v1/boards/{project}/wip-limits", scoped(boardsHandler.SetWIPLimits, auth.ScopeWorkspaces))
+
+	exportHandler := handlers.NewExportHandler(deps.Exports)
+	mux.Handle("POST /v1/me/export", scoped(exportHandler.Request, auth.ScopeAccountManage))
+	mux.Handle("GET /v1/me/export/{id}", scoped(exportHandler.Get, auth.ScopeAccountManage))
 
 }
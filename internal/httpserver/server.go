@@ -3,9 +3,18 @@ package httpserver
 import (
 	"context"
 	"net/http"
+	"task_scheduler/internal/apikey"
+	"task_scheduler/internal/attachment"
 	"task_scheduler/internal/auth"
+	"task_scheduler/internal/automation"
+	"task_scheduler/internal/export"
+	"task_scheduler/internal/lockout"
+	"task_scheduler/internal/mfa"
 	"task_scheduler/internal/task"
+	"task_scheduler/internal/template"
+	"task_scheduler/internal/timetrack"
 	"task_scheduler/internal/user"
+	"task_scheduler/internal/workspace"
 	"time"
 )
 
@@ -13,9 +22,30 @@ type Server struct {
 	s *http.Server
 }
 
-func New(addr string, taskSvc task.Service, userSvc user.Service, jwtManager *auth.JWTManager) *Server {
+// Deps are the services the HTTP layer is built on.
+type Deps struct {
+	Tasks        task.Service
+	Users        user.Service
+	APIKeys      apikey.Service
+	MFA          mfa.Service
+	Exports      export.Service
+	Workspaces   workspace.Service
+	Attachments  attachment.Service
+	TimeTracking timetrack.Service
+	Automations  automation.Service
+	Templates    template.Service
+	JWT          *auth.JWTManager
+	Revocations  *auth.Revocations
+	Tokens       *auth.TokenService
+	LoginGuard   *lockout.Guard
+
+	// TrustForwardedFor takes the login client IP from X-Forwarded-For.
+	TrustForwardedFor bool
+}
+
+func New(addr string, deps Deps) *Server {
 	mux := http.NewServeMux()
-	registerRoutes(mux, taskSvc, userSvc, jwtManager)
+	registerRoutes(mux, deps)
 
 	return &Server{
 		s: &http.Server{
@@ -0,0 +1,278 @@
+// Package jsonpatch applies JSON Merge Patch (RFC 7386) and JSON Patch
+// (RFC 6902) documents to arbitrary JSON values.
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+)
+
+var (
+	ErrInvalidPatch = errors.New("invalid patch")
+	ErrPathNotFound = errors.New("patch path not found")
+	ErrTestFailed   = errors.New("patch test operation failed")
+)
+
+// Operation is a single RFC 6902 operation.
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// MergePatch applies an RFC 7386 merge patch to doc and returns the result.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	var target any
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+	var p any
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, ErrInvalidPatch
+	}
+	return json.Marshal(mergePatch(target, p))
+}
+
+func mergePatch(target, patch any) any {
+	pm, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	tm, ok := target.(map[string]any)
+	if !ok {
+		tm = map[string]any{}
+	}
+	for k, v := range pm {
+		if v == nil {
+			delete(tm, k)
+			continue
+		}
+		tm[k] = mergePatch(tm[k], v)
+	}
+	return tm
+}
+
+// Apply applies an RFC 6902 patch to doc and returns the result.
+// Operations are applied in order and the patch is atomic: if any of them
+// fails, the error is returned and no partial result is produced.
+func Apply(doc, patch []byte) ([]byte, error) {
+	var target any
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+
+	ops, err := decodeOps(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, op := range ops {
+		var err error
+		if target, err = applyOp(target, op); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(target)
+}
+
+// rawOperation tells a missing member apart from an empty one.
+type rawOperation struct {
+	Op    *string         `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// decodeOps reads the operations of a patch. Members an operation doesn't
+// define are ignored (RFC 6902, section 4), but the ones it needs must be there;
+// "value" is checked when the operation is applied.
+func decodeOps(patch []byte) ([]Operation, error) {
+	var raw []rawOperation
+	if err := json.Unmarshal(patch, &raw); err != nil {
+		return nil, ErrInvalidPatch
+	}
+	ops := make([]Operation, 0, len(raw))
+	for _, r := range raw {
+		if r.Op == nil || r.Path == nil {
+			return nil, ErrInvalidPatch
+		}
+		op := Operation{Op: *r.Op, Path: *r.Path, Value: r.Value}
+		switch op.Op {
+		case "move", "copy":
+			if r.From == nil {
+				return nil, ErrInvalidPatch
+			}
+			op.From = *r.From
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+func applyOp(doc any, op Operation) (any, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add":
+		v, err := opValue(op)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, v)
+	case "remove":
+		_, doc, err := remove(doc, path)
+		return doc, err
+	case "replace":
+		v, err := opValue(op)
+		if err != nil {
+			return nil, err
+		}
+		return set(doc, path, v)
+	case "move":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		if isProperPrefix(from, path) {
+			return nil, ErrInvalidPatch
+		}
+		v, doc, err := remove(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, v)
+	case "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		v, err := get(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if v, err = deepCopy(v); err != nil {
+			return nil, err
+		}
+		return add(doc, path, v)
+	case "test":
+		want, err := opValue(op)
+		if err != nil {
+			return nil, err
+		}
+		got, err := get(doc, path)
+		if err != nil {
+			return nil, ErrTestFailed
+		}
+		if !reflect.DeepEqual(got, want) {
+			return nil, ErrTestFailed
+		}
+		return doc, nil
+	default:
+		return nil, ErrInvalidPatch
+	}
+}
+
+// opValue decodes the "value" member, which is mandatory for add, replace and test.
+func opValue(op Operation) (any, error) {
+	if op.Value == nil {
+		return nil, ErrInvalidPatch
+	}
+	var v any
+	if err := json.Unmarshal(op.Value, &v); err != nil {
+		return nil, ErrInvalidPatch
+	}
+	return v, nil
+}
+
+func add(doc any, tokens []string, value any) (any, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	parentPath := tokens[:len(tokens)-1]
+	parent, err := get(doc, parentPath)
+	if err != nil {
+		return nil, err
+	}
+	last := tokens[len(tokens)-1]
+
+	switch p := parent.(type) {
+	case map[string]any:
+		p[last] = value
+		return doc, nil
+	case []any:
+		i := len(p)
+		if last != "-" {
+			if i, err = arrayIndex(last, len(p)); err != nil {
+				return nil, err
+			}
+		}
+		grown := make([]any, 0, len(p)+1)
+		grown = append(grown, p[:i]...)
+		grown = append(grown, value)
+		grown = append(grown, p[i:]...)
+		return set(doc, parentPath, grown)
+	default:
+		return nil, ErrPathNotFound
+	}
+}
+
+// remove deletes the value referenced by tokens and returns it along with the new root.
+func remove(doc any, tokens []string) (any, any, error) {
+	if len(tokens) == 0 {
+		return nil, nil, ErrInvalidPatch
+	}
+	parentPath := tokens[:len(tokens)-1]
+	parent, err := get(doc, parentPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	last := tokens[len(tokens)-1]
+
+	switch p := parent.(type) {
+	case map[string]any:
+		v, ok := p[last]
+		if !ok {
+			return nil, nil, ErrPathNotFound
+		}
+		delete(p, last)
+		return v, doc, nil
+	case []any:
+		i, err := arrayIndex(last, len(p)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		v := p[i]
+		shrunk := append(p[:i:i], p[i+1:]...)
+		doc, err := set(doc, parentPath, shrunk)
+		return v, doc, err
+	default:
+		return nil, nil, ErrPathNotFound
+	}
+}
+
+func isProperPrefix(prefix, tokens []string) bool {
+	if len(prefix) >= len(tokens) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != tokens[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func deepCopy(v any) (any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out any
+	err = json.Unmarshal(b, &out)
+	return out, err
+}
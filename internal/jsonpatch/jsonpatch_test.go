@@ -0,0 +1,105 @@
+package jsonpatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApply(t *testing.T) {
+	cases := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+		err   error
+	}{
+		// экранирование в указателях: ~1 — "/", ~0 — "~"
+		{"escaped slash", `{"a/b":1}`, `[{"op":"replace","path":"/a~1b","value":2}]`, `{"a/b":2}`, nil},
+		{"escaped tilde", `{"m~n":1}`, `[{"op":"remove","path":"/m~0n"}]`, `{}`, nil},
+		{"tilde then one", `{"~1":1,"/":2}`, `[{"op":"remove","path":"/~01"}]`, `{"/":2}`, nil},
+		{"bad escape", `{"a":1}`, `[{"op":"remove","path":"/~2"}]`, ``, ErrInvalidPatch},
+		{"pointer without slash", `{"a":1}`, `[{"op":"remove","path":"a"}]`, ``, ErrInvalidPatch},
+
+		// массивы: "-" — конец, индекс не дальше длины
+		{"append with dash", `{"a":[1,2]}`, `[{"op":"add","path":"/a/-","value":3}]`, `{"a":[1,2,3]}`, nil},
+		{"insert at length", `{"a":[1,2]}`, `[{"op":"add","path":"/a/2","value":3}]`, `{"a":[1,2,3]}`, nil},
+		{"insert in the middle", `{"a":[1,2]}`, `[{"op":"add","path":"/a/1","value":9}]`, `{"a":[1,9,2]}`, nil},
+		{"insert past length", `{"a":[1,2]}`, `[{"op":"add","path":"/a/3","value":3}]`, ``, ErrPathNotFound},
+		{"replace at length", `{"a":[1,2]}`, `[{"op":"replace","path":"/a/2","value":3}]`, ``, ErrPathNotFound},
+		{"remove dash", `{"a":[1,2]}`, `[{"op":"remove","path":"/a/-"}]`, ``, ErrPathNotFound},
+		{"leading zero index", `{"a":[1,2]}`, `[{"op":"remove","path":"/a/01"}]`, ``, ErrPathNotFound},
+		{"negative index", `{"a":[1,2]}`, `[{"op":"remove","path":"/a/-1"}]`, ``, ErrPathNotFound},
+		{"remove last", `{"a":[1,2]}`, `[{"op":"remove","path":"/a/1"}]`, `{"a":[1]}`, nil},
+
+		// move и copy внутрь себя
+		{"move into own child", `{"a":{"b":1}}`, `[{"op":"move","from":"/a","path":"/a/c"}]`, ``, ErrInvalidPatch},
+		{"move onto itself", `{"a":{"b":1}}`, `[{"op":"move","from":"/a","path":"/a"}]`, `{"a":{"b":1}}`, nil},
+		{"move to sibling prefix", `{"a":1,"ab":2}`, `[{"op":"move","from":"/a","path":"/abc"}]`, `{"ab":2,"abc":1}`, nil},
+		{"copy into own child", `{"a":{"b":1}}`, `[{"op":"copy","from":"/a","path":"/a/c"}]`, `{"a":{"b":1,"c":{"b":1}}}`, nil},
+		{"copy is deep", `{"a":{"b":[1]}}`, `[{"op":"copy","from":"/a","path":"/c"},{"op":"add","path":"/c/b/-","value":2}]`, `{"a":{"b":[1]},"c":{"b":[1,2]}}`, nil},
+		{"move missing", `{"a":1}`, `[{"op":"move","from":"/x","path":"/y"}]`, ``, ErrPathNotFound},
+
+		// test сравнивает значения целиком
+		{"test nested equal", `{"a":{"b":[1,{"c":"d"}],"e":null}}`, `[{"op":"test","path":"/a","value":{"e":null,"b":[1,{"c":"d"}]}}]`, `{"a":{"b":[1,{"c":"d"}],"e":null}}`, nil},
+		{"test nested differs", `{"a":{"b":[1,{"c":"d"}]}}`, `[{"op":"test","path":"/a","value":{"b":[1,{"c":"x"}]}}]`, ``, ErrTestFailed},
+		{"test array order", `{"a":[1,2]}`, `[{"op":"test","path":"/a","value":[2,1]}]`, ``, ErrTestFailed},
+		{"test number forms", `{"a":1}`, `[{"op":"test","path":"/a","value":1.0}]`, `{"a":1}`, nil},
+		{"test missing path", `{"a":1}`, `[{"op":"test","path":"/b","value":1}]`, ``, ErrTestFailed},
+		{"test without value", `{"a":1}`, `[{"op":"test","path":"/a"}]`, ``, ErrInvalidPatch},
+
+		{"unknown op", `{"a":1}`, `[{"op":"increment","path":"/a"}]`, ``, ErrInvalidPatch},
+		// лишние члены игнорируются, нужные операции — обязательны
+		{"unknown member", `{"a":1}`, `[{"op":"remove","path":"/a","extra":1}]`, `{}`, nil},
+		{"member of another op", `{"a":1}`, `[{"op":"replace","path":"/a","value":2,"from":"/b"}]`, `{"a":2}`, nil},
+		{"missing op", `{"a":1}`, `[{"path":"/a"}]`, ``, ErrInvalidPatch},
+		{"missing path", `{"a":1}`, `[{"op":"replace","value":[1]}]`, ``, ErrInvalidPatch},
+		{"copy without from", `{"a":1}`, `[{"op":"copy","path":"/b"}]`, ``, ErrInvalidPatch},
+		{"move without from", `{"a":1}`, `[{"op":"move","path":"/b"}]`, ``, ErrInvalidPatch},
+		{"replace root", `{"a":1}`, `[{"op":"replace","path":"","value":[1]}]`, `[1]`, nil},
+		{"failure keeps nothing", `{"a":1}`, `[{"op":"remove","path":"/a"},{"op":"remove","path":"/a"}]`, ``, ErrPathNotFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Apply([]byte(tc.doc), []byte(tc.patch))
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				require.Nil(t, got)
+				return
+			}
+			require.NoError(t, err)
+			require.JSONEq(t, tc.want, string(got))
+		})
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	// примеры из RFC 7386, приложение A
+	cases := []struct {
+		doc, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tc := range cases {
+		got, err := MergePatch([]byte(tc.doc), []byte(tc.patch))
+		require.NoError(t, err, tc.patch)
+		require.JSONEq(t, tc.want, string(got), tc.patch)
+	}
+
+	_, err := MergePatch([]byte(`{}`), []byte(`{`))
+	require.ErrorIs(t, err, ErrInvalidPatch)
+}
@@ -0,0 +1,91 @@
+package jsonpatch
+
+import (
+	"strconv"
+	"strings"
+)
+
+// parsePointer splits an RFC 6901 JSON Pointer into unescaped reference tokens.
+func parsePointer(ptr string) ([]string, error) {
+	if ptr == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(ptr, "/") {
+		return nil, ErrInvalidPatch
+	}
+	parts := strings.Split(ptr[1:], "/")
+	for i, p := range parts {
+		// "~" бывает только в ~0 и ~1
+		if strings.Count(p, "~") != strings.Count(p, "~0")+strings.Count(p, "~1") {
+			return nil, ErrInvalidPatch
+		}
+		p = strings.ReplaceAll(p, "~1", "/")
+		parts[i] = strings.ReplaceAll(p, "~0", "~")
+	}
+	return parts, nil
+}
+
+// arrayIndex parses a reference token as an array index in [0, max].
+func arrayIndex(token string, max int) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, ErrPathNotFound
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > max {
+		return 0, ErrPathNotFound
+	}
+	return i, nil
+}
+
+// get returns the value referenced by tokens.
+func get(doc any, tokens []string) (any, error) {
+	cur := doc
+	for _, tok := range tokens {
+		switch c := cur.(type) {
+		case map[string]any:
+			v, ok := c[tok]
+			if !ok {
+				return nil, ErrPathNotFound
+			}
+			cur = v
+		case []any:
+			i, err := arrayIndex(tok, len(c)-1)
+			if err != nil {
+				return nil, err
+			}
+			cur = c[i]
+		default:
+			return nil, ErrPathNotFound
+		}
+	}
+	return cur, nil
+}
+
+// set replaces the existing value referenced by tokens and returns the new root.
+func set(doc any, tokens []string, value any) (any, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	parent, err := get(doc, tokens[:len(tokens)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := tokens[len(tokens)-1]
+
+	switch p := parent.(type) {
+	case map[string]any:
+		if _, ok := p[last]; !ok {
+			return nil, ErrPathNotFound
+		}
+		p[last] = value
+	case []any:
+		i, err := arrayIndex(last, len(p)-1)
+		if err != nil {
+			return nil, err
+		}
+		p[i] = value
+	default:
+		return nil, ErrPathNotFound
+	}
+	return doc, nil
+}
@@ -0,0 +1,25 @@
+package lockout
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	KeyEmail = "email"
+	KeyIP    = "ip"
+)
+
+// Event is a recorded lockout, kept for admins to review.
+type Event struct {
+	ID          int
+	KeyType     string
+	KeyValue    string
+	Failures    int
+	LockedUntil time.Time
+	CreatedAt   time.Time
+}
+
+type EventRepo interface {
+	RecordLockout(ctx context.Context, ev *Event) error
+}
@@ -0,0 +1,182 @@
+// Package lockout slows down and temporarily blocks password guessing.
+package lockout
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Limits configure the guard. Counting is per email and per client IP.
+type Limits struct {
+	MaxEmailFailures int
+	MaxIPFailures    int
+	// FreeAttempts failures per email are allowed without any delay.
+	// IPs get no delay, only the MaxIPFailures lockout, so users behind a
+	// shared NAT don't slow each other down.
+	FreeAttempts int
+	// Window after the first failure in which failures are counted.
+	Window time.Duration
+	// DelayBase doubles with every failure after FreeAttempts, up to MaxDelay.
+	DelayBase time.Duration
+	MaxDelay  time.Duration
+	// Lockout doubles with every repeated lockout of the same key, up to MaxLockout.
+	Lockout    time.Duration
+	MaxLockout time.Duration
+}
+
+type entry struct {
+	failures    int
+	firstAt     time.Time
+	nextAllowed time.Time
+	lockedUntil time.Time
+	lockouts    int
+}
+
+// Guard keeps failure counters in memory; lockout events go to the repo.
+type Guard struct {
+	limits Limits
+	events EventRepo
+
+	mu       sync.Mutex
+	entries  map[string]*entry
+	prunedAt time.Time
+	now      func() time.Time
+}
+
+func NewGuard(limits Limits, events EventRepo) *Guard {
+	return &Guard{
+		limits:  limits,
+		events:  events,
+		entries: make(map[string]*entry),
+		now:     time.Now,
+	}
+}
+
+func emailKey(email string) string { return "email:" + email }
+func ipKey(ip string) string       { return "ip:" + ip }
+
+// Check returns how long the caller must wait before the next attempt,
+// or 0 if the attempt may proceed.
+func (g *Guard) Check(email, ip string) time.Duration {
+	now := g.now()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.pruneLocked(now)
+
+	var wait time.Duration
+	for _, key := range []string{emailKey(email), ipKey(ip)} {
+		e, ok := g.entries[key]
+		if !ok {
+			continue
+		}
+		for _, until := range []time.Time{e.lockedUntil, e.nextAllowed} {
+			if d := until.Sub(now); d > wait {
+				wait = d
+			}
+		}
+	}
+	return wait
+}
+
+// Fail records a failed login for both keys.
+func (g *Guard) Fail(ctx context.Context, email, ip string) {
+	now := g.now()
+
+	var locked []Event
+	g.mu.Lock()
+	if ev, ok := g.failLocked(KeyEmail, email, g.limits.MaxEmailFailures, true, now); ok {
+		locked = append(locked, ev)
+	}
+	if ev, ok := g.failLocked(KeyIP, ip, g.limits.MaxIPFailures, false, now); ok {
+		locked = append(locked, ev)
+	}
+	g.mu.Unlock()
+
+	// события пишем вне мьютекса
+	for _, ev := range locked {
+		log.Printf("[AUTH] lockout %s=%s until %s after %d failures", ev.KeyType, ev.KeyValue, ev.LockedUntil.Format(time.RFC3339), ev.Failures)
+		if g.events == nil {
+			continue
+		}
+		if err := g.events.RecordLockout(ctx, &ev); err != nil {
+			log.Println("[AUTH] record lockout error:", err)
+		}
+	}
+}
+
+// Succeed clears the email counter. The IP counter is kept on purpose, so an
+// attacker can't reset it by logging into their own account in between.
+func (g *Guard) Succeed(email string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.entries, emailKey(email))
+}
+
+func (g *Guard) failLocked(keyType, value string, max int, delay bool, now time.Time) (Event, bool) {
+	key := keyType + ":" + value
+	e, ok := g.entries[key]
+	if !ok {
+		e = &entry{}
+		g.entries[key] = e
+	}
+	if e.failures == 0 || now.Sub(e.firstAt) > g.limits.Window {
+		e.failures = 0
+		e.firstAt = now
+	}
+	e.failures++
+
+	// 1) порог — временная блокировка, каждая следующая вдвое дольше
+	if max > 0 && e.failures >= max {
+		d := backoff(g.limits.Lockout, e.lockouts, g.limits.MaxLockout)
+		e.lockedUntil = now.Add(d)
+		e.lockouts++
+		failures := e.failures
+		e.failures = 0
+		e.nextAllowed = time.Time{}
+		return Event{
+			KeyType:     keyType,
+			KeyValue:    value,
+			Failures:    failures,
+			LockedUntil: e.lockedUntil,
+			CreatedAt:   now,
+		}, true
+	}
+
+	// 2) до порога — растущая пауза между попытками
+	if over := e.failures - g.limits.FreeAttempts; delay && over > 0 {
+		e.nextAllowed = now.Add(backoff(g.limits.DelayBase, over-1, g.limits.MaxDelay))
+	}
+	return Event{}, false
+}
+
+// pruneLocked drops idle entries, at most once a minute.
+func (g *Guard) pruneLocked(now time.Time) {
+	if now.Sub(g.prunedAt) < time.Minute {
+		return
+	}
+	g.prunedAt = now
+	for key, e := range g.entries {
+		idleSince := e.firstAt
+		if e.lockedUntil.After(idleSince) {
+			idleSince = e.lockedUntil
+		}
+		// lockouts "забываются" после окна тишины
+		if now.Sub(idleSince) > g.limits.Window && now.After(e.nextAllowed) {
+			delete(g.entries, key)
+		}
+	}
+}
+
+func backoff(base time.Duration, n int, max time.Duration) time.Duration {
+	d := base
+	for i := 0; i < n && d < max; i++ {
+		d *= 2
+	}
+	if max > 0 && d > max {
+		d = max
+	}
+	return d
+}
@@ -0,0 +1,18 @@
+package sqlite
+
+import "database/sql"
+
+func Migrate(db *sql.DB) error {
+	const q = `
+	CREATE TABLE IF NOT EXISTS login_lockouts(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	key_type TEXT NOT NULL,
+	key_value TEXT NOT NULL,
+	failures INTEGER NOT NULL,
+	locked_until TEXT NOT NULL,
+	created_at TEXT NOT NULL);
+	CREATE INDEX IF NOT EXISTS idx_login_lockouts_created_at ON login_lockouts(created_at);
+	`
+	_, err := db.Exec(q)
+	return err
+}
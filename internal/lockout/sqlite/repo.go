@@ -0,0 +1,37 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"task_scheduler/internal/lockout"
+	"time"
+)
+
+type Repo struct {
+	db *sql.DB
+}
+
+func New(db *sql.DB) *Repo {
+	return &Repo{db: db}
+}
+
+func (r *Repo) RecordLockout(ctx context.Context, ev *lockout.Event) error {
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO login_lockouts (key_type, key_value, failures, locked_until, created_at)
+		 VALUES (?, ?, ?, ?, ?)`,
+		ev.KeyType,
+		ev.KeyValue,
+		ev.Failures,
+		ev.LockedUntil.UTC().Format(time.RFC3339Nano),
+		ev.CreatedAt.UTC().Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	ev.ID = int(id)
+	return nil
+}
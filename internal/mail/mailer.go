@@ -0,0 +1,66 @@
+// Package mail delivers transactional emails (password reset, verification).
+package mail
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends a single message. Implementations must be safe for concurrent use.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// WriterMailer "sends" messages by writing them to an io.Writer.
+// It is meant for local development: stdout or a file instead of SMTP.
+type WriterMailer struct {
+	mu   sync.Mutex
+	w    io.Writer
+	from string
+}
+
+func NewWriterMailer(w io.Writer, from string) *WriterMailer {
+	return &WriterMailer{w: w, from: from}
+}
+
+func NewStdoutMailer(from string) *WriterMailer {
+	return NewWriterMailer(os.Stdout, from)
+}
+
+// NewFileMailer appends messages to the file at path.
+// The caller owns the returned file and should close it on shutdown.
+func NewFileMailer(path, from string) (*WriterMailer, *os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewWriterMailer(f, from), f, nil
+}
+
+func (m *WriterMailer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, err := fmt.Fprintf(m.w,
+		"From: %s\nTo: %s\nDate: %s\nSubject: %s\n\n%s\n\n",
+		m.from,
+		msg.To,
+		time.Now().UTC().Format(time.RFC1123Z),
+		msg.Subject,
+		msg.Body,
+	)
+	return err
+}
@@ -0,0 +1,395 @@
+// Package markdown renders the Markdown subset used in task descriptions
+// and comments.
+//
+// The output is safe to embed as is: raw HTML in the source is escaped,
+// never passed through, and links may only point to http(s), mailto or
+// relative URLs. Supported: ATX headings, paragraphs, fenced code, block
+// quotes, rules, flat bullet/numbered lists with task items ("- [ ]"),
+// code spans, emphasis, strong and links.
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Item is a checklist entry ("- [ ] text" or "- [x] text").
+type Item struct {
+	Text string `json:"text"`
+	Done bool   `json:"done"`
+}
+
+var (
+	headingRe  = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	ruleRe     = regexp.MustCompile(`^ {0,3}(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	listItemRe = regexp.MustCompile(`^ {0,3}([-*+]|\d{1,9}[.)])[ \t]+(.*)$`)
+	taskRe     = regexp.MustCompile(`^\[([ xX])\](?:[ \t]+(.*))?$`)
+	fenceRe    = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+	quoteRe    = regexp.MustCompile(`^ {0,3}>[ ]?(.*)$`)
+)
+
+// TaskItems returns the checklist items of src, outside code blocks.
+func TaskItems(src string) []Item {
+	items := make([]Item, 0)
+	fence := ""
+	for _, line := range splitLines(src) {
+		if fence != "" {
+			if closesFence(line, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if m := fenceRe.FindStringSubmatch(line); m != nil {
+			fence = m[1]
+			continue
+		}
+		// чек-листы внутри цитат тоже считаются
+		for {
+			m := quoteRe.FindStringSubmatch(line)
+			if m == nil {
+				break
+			}
+			line = m[1]
+		}
+		if item, ok := parseTaskItem(line); ok {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// ToHTML renders src as sanitized HTML.
+func ToHTML(src string) string {
+	var b strings.Builder
+	renderBlocks(&b, splitLines(src))
+	return b.String()
+}
+
+func renderBlocks(b *strings.Builder, lines []string) {
+	var para []string
+	flush := func() {
+		if len(para) > 0 {
+			b.WriteString("<p>")
+			b.WriteString(renderInline(strings.Join(para, "\n")))
+			b.WriteString("</p>\n")
+			para = nil
+		}
+	}
+
+	for i := 0; i < len(lines); {
+		line := lines[i]
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			flush()
+			i++
+
+		case fenceRe.MatchString(line):
+			flush()
+			fence := fenceRe.FindStringSubmatch(line)[1]
+			i++
+			var code []string
+			for i < len(lines) && !closesFence(lines[i], fence) {
+				code = append(code, lines[i])
+				i++
+			}
+			i++ // закрывающая ограда (или конец текста)
+			b.WriteString("<pre><code>")
+			if len(code) > 0 {
+				b.WriteString(html.EscapeString(strings.Join(code, "\n")))
+				b.WriteString("\n")
+			}
+			b.WriteString("</code></pre>\n")
+
+		case headingRe.MatchString(line):
+			flush()
+			m := headingRe.FindStringSubmatch(line)
+			tag := "h" + string(rune('0'+len(m[1])))
+			b.WriteString("<" + tag + ">" + renderInline(m[2]) + "</" + tag + ">\n")
+			i++
+
+		case ruleRe.MatchString(line):
+			flush()
+			b.WriteString("<hr>\n")
+			i++
+
+		case quoteRe.MatchString(line):
+			flush()
+			var inner []string
+			for i < len(lines) {
+				m := quoteRe.FindStringSubmatch(lines[i])
+				if m == nil {
+					break
+				}
+				inner = append(inner, m[1])
+				i++
+			}
+			b.WriteString("<blockquote>\n")
+			renderBlocks(b, inner)
+			b.WriteString("</blockquote>\n")
+
+		case listItemRe.MatchString(line):
+			flush()
+			i = renderList(b, lines, i)
+
+		default:
+			para = append(para, strings.TrimSpace(line))
+			i++
+		}
+	}
+	flush()
+}
+
+// renderList renders consecutive items of one list kind starting at lines[i]
+// and returns the index of the first line after the list.
+func renderList(b *strings.Builder, lines []string, i int) int {
+	ordered := isOrdered(listItemRe.FindStringSubmatch(lines[i])[1])
+	if ordered {
+		b.WriteString("<ol>\n")
+	} else {
+		b.WriteString("<ul>\n")
+	}
+
+	for i < len(lines) {
+		m := listItemRe.FindStringSubmatch(lines[i])
+		if m == nil || isOrdered(m[1]) != ordered {
+			break
+		}
+		text := m[2]
+		i++
+		// продолжение пункта — строки с отступом
+		for i < len(lines) && strings.TrimSpace(lines[i]) != "" &&
+			(strings.HasPrefix(lines[i], "  ") || strings.HasPrefix(lines[i], "\t")) &&
+			!listItemRe.MatchString(lines[i]) {
+			text += "\n" + strings.TrimSpace(lines[i])
+			i++
+		}
+
+		b.WriteString("<li>")
+		if tm := taskRe.FindStringSubmatch(text); tm != nil {
+			if tm[1] == " " {
+				b.WriteString(`<input type="checkbox" disabled> `)
+			} else {
+				b.WriteString(`<input type="checkbox" checked disabled> `)
+			}
+			text = tm[2]
+		}
+		b.WriteString(renderInline(text))
+		b.WriteString("</li>\n")
+	}
+
+	if ordered {
+		b.WriteString("</ol>\n")
+	} else {
+		b.WriteString("</ul>\n")
+	}
+	return i
+}
+
+func renderInline(s string) string {
+	return renderSpan(s, true)
+}
+
+// renderSpan renders inline markup. Inside link text links is false: links
+// don't nest.
+func renderSpan(s string, links bool) string {
+	var (
+		b     strings.Builder
+		index *linkIndex
+	)
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]):
+			b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == '`':
+			n := runLength(s[i:], '`')
+			delim := s[i : i+n]
+			if end := strings.Index(s[i+n:], delim); end >= 0 {
+				code := strings.TrimSpace(s[i+n : i+n+end])
+				b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+				i += n + end + n
+				continue
+			}
+			b.WriteString(delim)
+			i += n
+			continue
+
+		case c == '[' && links:
+			if index == nil {
+				index = newLinkIndex(s)
+			}
+			if text, href, n, ok := index.link(i); ok {
+				if safeURL(href) {
+					b.WriteString(`<a href="` + html.EscapeString(href) + `" rel="nofollow noopener noreferrer">`)
+					b.WriteString(renderSpan(text, false))
+					b.WriteString("</a>")
+				} else {
+					b.WriteString(renderSpan(text, false))
+				}
+				i += n
+				continue
+			}
+
+		case c == '*' || c == '_':
+			// _ внутри слова (snake_case) — не выделение
+			if c == '_' && i > 0 && isWordByte(s[i-1]) {
+				break
+			}
+			n := min(runLength(s[i:], c), 2)
+			delim := s[i : i+n]
+			rest := s[i+n:]
+			if end := strings.Index(rest, delim); end > 0 && !unicode.IsSpace(rune(rest[0])) && !unicode.IsSpace(rune(rest[end-1])) {
+				tag := "em"
+				if n == 2 {
+					tag = "strong"
+				}
+				b.WriteString("<" + tag + ">" + renderSpan(rest[:end], links) + "</" + tag + ">")
+				i += n + end + n
+				continue
+			}
+		}
+		b.WriteString(html.EscapeString(s[i : i+1]))
+		i++
+	}
+	return b.String()
+}
+
+// linkIndex pairs up the brackets and parentheses of a span in one pass, so
+// trying each '[' as a link costs O(1) instead of a scan to the end of the
+// span: text full of unclosed '[' would otherwise render in quadratic time.
+type linkIndex struct {
+	s string
+	// brackets maps '[' to its ']'; '\' escapes the next byte
+	brackets map[int]int
+	// parens maps '(' to its ')'
+	parens map[int]int
+	// space[i] is the first ' ', '\t' or '\n' at or after i
+	space []int
+}
+
+func newLinkIndex(s string) *linkIndex {
+	x := &linkIndex{s: s, brackets: make(map[int]int), parens: make(map[int]int), space: make([]int, len(s)+1)}
+	var open, parens []int
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			parens = append(parens, i)
+		case ')':
+			if n := len(parens); n > 0 {
+				x.parens[parens[n-1]] = i
+				parens = parens[:n-1]
+			}
+		}
+	}
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			open = append(open, i)
+		case ']':
+			if n := len(open); n > 0 {
+				x.brackets[open[n-1]] = i
+				open = open[:n-1]
+			}
+		}
+	}
+	x.space[len(s)] = len(s)
+	for i := len(s) - 1; i >= 0; i-- {
+		x.space[i] = x.space[i+1]
+		if s[i] == ' ' || s[i] == '\t' || s[i] == '\n' {
+			x.space[i] = i
+		}
+	}
+	return x
+}
+
+// link matches "[text](url)" at s[i] and returns the length of the match.
+func (x *linkIndex) link(i int) (text, href string, n int, ok bool) {
+	closeText, ok := x.brackets[i]
+	if !ok || closeText+1 >= len(x.s) || x.s[closeText+1] != '(' {
+		return "", "", 0, false
+	}
+	// скобки внутри адреса должны быть парными
+	end, ok := x.parens[closeText+1]
+	if !ok {
+		return "", "", 0, false
+	}
+	raw := x.s[closeText+2 : end]
+	trimmed := strings.TrimLeftFunc(raw, unicode.IsSpace)
+	start := closeText + 2 + len(raw) - len(trimmed)
+	href = strings.TrimRightFunc(trimmed, unicode.IsSpace)
+	if href == "" || x.space[start] < start+len(href) {
+		return "", "", 0, false
+	}
+	return x.s[i+1 : closeText], href, end + 1 - i, true
+}
+
+// safeURL allows http(s), mailto and relative links only.
+func safeURL(href string) bool {
+	for _, r := range href {
+		if unicode.IsControl(r) || unicode.IsSpace(r) {
+			return false
+		}
+	}
+	u, err := url.Parse(href)
+	if err != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "", "http", "https", "mailto":
+		return true
+	default:
+		return false
+	}
+}
+
+func parseTaskItem(line string) (Item, bool) {
+	m := listItemRe.FindStringSubmatch(line)
+	if m == nil {
+		return Item{}, false
+	}
+	tm := taskRe.FindStringSubmatch(m[2])
+	if tm == nil {
+		return Item{}, false
+	}
+	return Item{Text: strings.TrimSpace(tm[2]), Done: tm[1] != " "}, true
+}
+
+func closesFence(line, fence string) bool {
+	t := strings.TrimSpace(line)
+	return strings.HasPrefix(t, fence) && strings.Trim(t, fence[:1]) == ""
+}
+
+func splitLines(src string) []string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.ReplaceAll(src, "\r", "\n")
+	return strings.Split(src, "\n")
+}
+
+func isOrdered(marker string) bool {
+	return marker[0] >= '0' && marker[0] <= '9'
+}
+
+func runLength(s string, c byte) int {
+	n := 0
+	for n < len(s) && s[n] == c {
+		n++
+	}
+	return n
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isASCIIPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
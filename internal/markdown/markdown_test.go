@@ -0,0 +1,88 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestToHTML_RendersSubset(t *testing.T) {
+	src := "# Plan\n\nShip **v2** with *care*, see [docs](https://example.com/docs) and `go test`.\n\n" +
+		"- [x] write code\n- [ ] review\n\n1. first\n2. second\n\n> quoted\n\n```\n<b>raw</b>\n```\n\n---"
+
+	require.Equal(t,
+		"<h1>Plan</h1>\n"+
+			`<p>Ship <strong>v2</strong> with <em>care</em>, see <a href="https://example.com/docs" rel="nofollow noopener noreferrer">docs</a> and <code>go test</code>.</p>`+"\n"+
+			"<ul>\n<li><input type=\"checkbox\" checked disabled> write code</li>\n<li><input type=\"checkbox\" disabled> review</li>\n</ul>\n"+
+			"<ol>\n<li>first</li>\n<li>second</li>\n</ol>\n"+
+			"<blockquote>\n<p>quoted</p>\n</blockquote>\n"+
+			"<pre><code>&lt;b&gt;raw&lt;/b&gt;\n</code></pre>\n"+
+			"<hr>\n",
+		ToHTML(src))
+}
+
+func TestToHTML_Sanitizes(t *testing.T) {
+	cases := map[string]string{
+		`<script>alert(1)</script>`:                   "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>\n",
+		`<img src=x onerror=alert(1)>`:                "<p>&lt;img src=x onerror=alert(1)&gt;</p>\n",
+		`[click](javascript:alert(1))`:                "<p>click</p>\n",
+		`[click](JaVaScRiPt:alert(1))`:                "<p>click</p>\n",
+		`[click](data:text/html;base64,PHNjcmlwdD4=)`: "<p>click</p>\n",
+		`[x](https://e.com/" onmouseover="alert(1))`:  "<p>[x](https://e.com/&#34; onmouseover=&#34;alert(1))</p>\n",
+		"[**<i>**](/ok)":                              `<p><a href="/ok" rel="nofollow noopener noreferrer"><strong>&lt;i&gt;</strong></a></p>` + "\n",
+	}
+	for src, want := range cases {
+		require.Equal(t, want, ToHTML(src), src)
+	}
+}
+
+func TestToHTML_Links(t *testing.T) {
+	cases := map[string]string{
+		"[a [b](/x)":    `<p>[a <a href="/x" rel="nofollow noopener noreferrer">b</a></p>` + "\n",
+		"[[b](/x)](/y)": `<p><a href="/y" rel="nofollow noopener noreferrer">[b](/x)</a></p>` + "\n",
+		"[a]( /x(1) )":  `<p><a href="/x(1)" rel="nofollow noopener noreferrer">a</a></p>` + "\n",
+	}
+	for src, want := range cases {
+		require.Equal(t, want, ToHTML(src), src)
+	}
+}
+
+// На каждой '[' ссылка раньше искалась до конца текста заново.
+func TestToHTML_ManyBracketsInLinearTime(t *testing.T) {
+	for name, src := range pathological(50_000) {
+		start := time.Now()
+		out := ToHTML(src)
+		require.NotEmpty(t, out)
+		require.Less(t, time.Since(start), 2*time.Second, name)
+	}
+}
+
+func BenchmarkToHTML_ManyBrackets(b *testing.B) {
+	inputs := pathological(10_000)
+	for b.Loop() {
+		for _, src := range inputs {
+			ToHTML(src)
+		}
+	}
+}
+
+func pathological(n int) map[string]string {
+	return map[string]string{
+		"unclosed brackets": strings.Repeat("[", n),
+		"unclosed urls":     strings.Repeat("[a](", n),
+		"spaced urls":       strings.Repeat("[a](", n) + "x y" + strings.Repeat(")", n),
+		"nested links":      strings.Repeat("[", n) + "x" + strings.Repeat("](/u)", n),
+	}
+}
+
+func TestTaskItems_SkipsCodeBlocks(t *testing.T) {
+	src := "- [ ] one\n* [x] two\n```\n- [ ] not an item\n```\n> - [X] quoted\n- plain\n1. [ ] numbered"
+	require.Equal(t, []Item{
+		{Text: "one"},
+		{Text: "two", Done: true},
+		{Text: "quoted", Done: true},
+		{Text: "numbered"},
+	}, TaskItems(src))
+}
@@ -0,0 +1,19 @@
+package mfa
+
+import "time"
+
+// Settings is a user's TOTP configuration. A secret without EnabledAt is an
+// enrollment that hasn't been confirmed with a first code yet.
+type Settings struct {
+	UserID       int
+	Secret       string
+	EnabledAt    *time.Time
+	LastUsedStep int64
+	CreatedAt    time.Time
+}
+
+// Enrollment is returned to the user to set up their authenticator app.
+type Enrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
@@ -0,0 +1,23 @@
+package mfa
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrNotFound = errors.New("mfa settings not found")
+
+type Repo interface {
+	// SaveSecret starts (or restarts) an enrollment. It must not overwrite
+	// an already enabled configuration.
+	SaveSecret(ctx context.Context, userID int, secret string, at time.Time) error
+	Get(ctx context.Context, userID int) (*Settings, error)
+	Enable(ctx context.Context, userID int, at time.Time) error
+	// UseStep records a successfully used time step; it returns false if the
+	// step is not newer than the last used one (code replay).
+	UseStep(ctx context.Context, userID int, step int64) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID int, hashes []string) error
+	// UseRecoveryCode marks an unused code as used; false if there is none.
+	UseRecoveryCode(ctx context.Context, userID int, hash string, at time.Time) (bool, error)
+}
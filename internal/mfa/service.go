@@ -0,0 +1,189 @@
+package mfa
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidInput   = errors.New("invalid input")
+	ErrInvalidCode    = errors.New("invalid mfa code")
+	ErrAlreadyEnabled = errors.New("mfa is already enabled")
+	ErrNotEnrolled    = errors.New("mfa enrollment not started")
+)
+
+// Issuer is shown by authenticator apps next to the account name.
+const Issuer = "Task Scheduler"
+
+const recoveryCodeCount = 10
+
+type Service interface {
+	// Enroll generates a new secret for the user; MFA stays off until Confirm.
+	Enroll(ctx context.Context, userID int, account string) (*Enrollment, error)
+	// Confirm enables MFA with a first valid code and returns recovery codes,
+	// which are shown only once.
+	Confirm(ctx context.Context, userID int, code string) ([]string, error)
+	IsEnabled(ctx context.Context, userID int) (bool, error)
+	// Verify accepts either a current TOTP code or an unused recovery code.
+	Verify(ctx context.Context, userID int, code, recoveryCode string) error
+}
+
+type mfaService struct {
+	repo Repo
+}
+
+func NewService(repo Repo) Service {
+	return &mfaService{repo: repo}
+}
+
+func (s *mfaService) Enroll(ctx context.Context, userID int, account string) (*Enrollment, error) {
+	if userID <= 0 || account == "" {
+		return nil, ErrInvalidInput
+	}
+
+	// 1) уже включено — повторная регистрация сломала бы текущее приложение
+	st, err := s.repo.Get(ctx, userID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	if st != nil && st.EnabledAt != nil {
+		return nil, ErrAlreadyEnabled
+	}
+
+	// 2) новый секрет (160 бит, как рекомендует RFC 4226)
+	key := make([]byte, 20)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	secret := b32.EncodeToString(key)
+	if err := s.repo.SaveSecret(ctx, userID, secret, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+
+	return &Enrollment{
+		Secret: secret,
+		URI:    provisioningURI(Issuer, account, secret),
+	}, nil
+}
+
+func (s *mfaService) Confirm(ctx context.Context, userID int, code string) ([]string, error) {
+	if userID <= 0 || code == "" {
+		return nil, ErrInvalidInput
+	}
+
+	st, err := s.repo.Get(ctx, userID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, ErrNotEnrolled
+		}
+		return nil, err
+	}
+	if st.EnabledAt != nil {
+		return nil, ErrAlreadyEnabled
+	}
+
+	now := time.Now().UTC()
+	if err := s.useTOTP(ctx, st, code, now); err != nil {
+		return nil, err
+	}
+
+	// коды восстановления: наружу — как есть, в БД — хеши
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		c, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = c
+		hashes[i] = hashRecoveryCode(c)
+	}
+	if err := s.repo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Enable(ctx, userID, now); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *mfaService) IsEnabled(ctx context.Context, userID int) (bool, error) {
+	st, err := s.repo.Get(ctx, userID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return st.EnabledAt != nil, nil
+}
+
+func (s *mfaService) Verify(ctx context.Context, userID int, code, recoveryCode string) error {
+	if userID <= 0 || (code == "" && recoveryCode == "") {
+		return ErrInvalidInput
+	}
+
+	st, err := s.repo.Get(ctx, userID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return ErrInvalidCode
+		}
+		return err
+	}
+	if st.EnabledAt == nil {
+		return ErrInvalidCode
+	}
+
+	now := time.Now().UTC()
+	if code != "" {
+		return s.useTOTP(ctx, st, code, now)
+	}
+
+	ok, err := s.repo.UseRecoveryCode(ctx, userID, hashRecoveryCode(recoveryCode), now)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+// useTOTP validates the code and burns its time step so it can't be replayed.
+func (s *mfaService) useTOTP(ctx context.Context, st *Settings, code string, now time.Time) error {
+	step, ok := validateTOTP(st.Secret, strings.TrimSpace(code), now)
+	if !ok || step <= st.LastUsedStep {
+		return ErrInvalidCode
+	}
+	fresh, err := s.repo.UseStep(ctx, st.UserID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+// newRecoveryCode returns 80 random bits as xxxx-xxxx-xxxx-xxxx (base32).
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	s := strings.ToLower(b32.EncodeToString(b))
+	return s[0:4] + "-" + s[4:8] + "-" + s[8:12] + "-" + s[12:16], nil
+}
+
+// hashRecoveryCode normalises the code before hashing, so users may type it
+// without dashes or in upper case.
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
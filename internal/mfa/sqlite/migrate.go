@@ -0,0 +1,23 @@
+package sqlite
+
+import "database/sql"
+
+func Migrate(db *sql.DB) error {
+	const q = `
+	CREATE TABLE IF NOT EXISTS user_mfa(
+	user_id INTEGER PRIMARY KEY,
+	secret TEXT NOT NULL,
+	enabled_at TEXT NULL,
+	last_used_step INTEGER NOT NULL DEFAULT 0,
+	created_at TEXT NOT NULL);
+
+	CREATE TABLE IF NOT EXISTS mfa_recovery_codes(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	code_hash TEXT NOT NULL,
+	used_at TEXT NULL);
+	CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user_id ON mfa_recovery_codes(user_id);
+	`
+	_, err := db.Exec(q)
+	return err
+}
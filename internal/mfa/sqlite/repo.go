@@ -0,0 +1,168 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"task_scheduler/internal/mfa"
+	"time"
+)
+
+type Repo struct {
+	db *sql.DB
+}
+
+func New(db *sql.DB) *Repo {
+	return &Repo{db: db}
+}
+
+func (r *Repo) SaveSecret(ctx context.Context, userID int, secret string, at time.Time) error {
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO user_mfa (user_id, secret, created_at) VALUES (?, ?, ?)
+		 ON CONFLICT(user_id) DO UPDATE SET secret = excluded.secret, created_at = excluded.created_at, last_used_step = 0
+		 WHERE user_mfa.enabled_at IS NULL`,
+		userID,
+		secret,
+		at.UTC().Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if aff == 0 {
+		return mfa.ErrAlreadyEnabled
+	}
+	return nil
+}
+
+func (r *Repo) Get(ctx context.Context, userID int) (*mfa.Settings, error) {
+	var (
+		st           mfa.Settings
+		enabledAt    sql.NullString
+		createdAtStr string
+	)
+	err := r.db.QueryRowContext(ctx,
+		`SELECT user_id, secret, enabled_at, last_used_step, created_at
+		 FROM user_mfa
+		 WHERE user_id = ?`,
+		userID,
+	).Scan(&st.UserID, &st.Secret, &enabledAt, &st.LastUsedStep, &createdAtStr)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, mfa.ErrNotFound
+		}
+		return nil, err
+	}
+
+	if st.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAtStr); err != nil {
+		return nil, err
+	}
+	if enabledAt.Valid {
+		t, err := time.Parse(time.RFC3339Nano, enabledAt.String)
+		if err != nil {
+			return nil, err
+		}
+		st.EnabledAt = &t
+	}
+	return &st, nil
+}
+
+func (r *Repo) Enable(ctx context.Context, userID int, at time.Time) error {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE user_mfa SET enabled_at = ? WHERE user_id = ?`,
+		at.UTC().Format(time.RFC3339Nano),
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if aff == 0 {
+		return mfa.ErrNotFound
+	}
+	return nil
+}
+
+func (r *Repo) UseStep(ctx context.Context, userID int, step int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE user_mfa SET last_used_step = ? WHERE user_id = ? AND last_used_step < ?`,
+		step,
+		userID,
+		step,
+	)
+	if err != nil {
+		return false, err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return aff == 1, nil
+}
+
+func (r *Repo) ReplaceRecoveryCodes(ctx context.Context, userID int, hashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	for _, h := range hashes {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES (?, ?)`,
+			userID,
+			h,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// DeleteAccountData deletes the user's 2FA settings and recovery codes when
+// the account goes.
+func (r *Repo) DeleteAccountData(ctx context.Context, userID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, table := range []string{"mfa_recovery_codes", "user_mfa"} {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE user_id = ?`, userID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *Repo) UseRecoveryCode(ctx context.Context, userID int, hash string, at time.Time) (bool, error) {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE mfa_recovery_codes SET used_at = ?
+		 WHERE id = (
+		   SELECT id FROM mfa_recovery_codes
+		   WHERE user_id = ? AND code_hash = ? AND used_at IS NULL
+		   LIMIT 1
+		 )`,
+		at.UTC().Format(time.RFC3339Nano),
+		userID,
+		hash,
+	)
+	if err != nil {
+		return false, err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return aff == 1, nil
+}
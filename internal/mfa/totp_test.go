@@ -0,0 +1,41 @@
+package mfa
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test vectors from RFC 6238 appendix B (SHA1), truncated to 6 digits.
+func TestTOTPCode_RFC6238(t *testing.T) {
+	secret := []byte("12345678901234567890")
+
+	cases := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.want, totpCode(secret, timeStep(time.Unix(c.unix, 0))), "t=%d", c.unix)
+	}
+}
+
+func TestValidateTOTP_Skew(t *testing.T) {
+	secret := b32.EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(1111111111, 0)
+
+	prev := totpCode([]byte("12345678901234567890"), timeStep(now)-1)
+	step, ok := validateTOTP(secret, prev, now)
+	require.True(t, ok)
+	require.Equal(t, timeStep(now)-1, step)
+
+	old := totpCode([]byte("12345678901234567890"), timeStep(now)-3)
+	_, ok = validateTOTP(secret, old, now)
+	require.False(t, ok)
+}
@@ -0,0 +1,50 @@
+// Package notify tells users about things that happened to them.
+package notify
+
+import (
+	"context"
+	"task_scheduler/internal/mail"
+	"task_scheduler/internal/user"
+)
+
+// Notification is addressed to a user, not to an email address: the
+// notifier decides how to reach them.
+type Notification struct {
+	UserID  int
+	Subject string
+	Body    string
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// Users is the part of the user service the notifier needs.
+type Users interface {
+	Get(ctx context.Context, userID int) (*user.User, error)
+}
+
+// MailNotifier delivers notifications by email.
+type MailNotifier struct {
+	users  Users
+	mailer mail.Mailer
+}
+
+func NewMailNotifier(users Users, mailer mail.Mailer) *MailNotifier {
+	return &MailNotifier{
+		users:  users,
+		mailer: mailer,
+	}
+}
+
+func (n *MailNotifier) Notify(ctx context.Context, msg Notification) error {
+	u, err := n.users.Get(ctx, msg.UserID)
+	if err != nil {
+		return err
+	}
+	return n.mailer.Send(ctx, mail.Message{
+		To:      u.Email,
+		Subject: msg.Subject,
+		Body:    msg.Body,
+	})
+}
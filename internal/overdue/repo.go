@@ -0,0 +1,15 @@
+package overdue
+
+import (
+	"context"
+	"time"
+)
+
+type Repo interface {
+	// Due returns ids (ascending, above afterID) of pending tasks that were
+	// due before dueBefore, aren't snoozed at now and haven't been escalated
+	// by the rule for their current due date.
+	Due(ctx context.Context, rule string, dueBefore, now time.Time, afterID, limit int) ([]int, error)
+	// MarkEscalated records the escalation; recording it twice is a no-op.
+	MarkEscalated(ctx context.Context, e Escalation) error
+}
@@ -0,0 +1,32 @@
+// Package overdue escalates tasks that stay pending past their due date.
+package overdue
+
+import "time"
+
+type Action string
+
+const (
+	// ActionNotify emails the assignee and the owner.
+	ActionNotify Action = "notify"
+	// ActionWebhook POSTs the task as JSON to URL.
+	ActionWebhook Action = "webhook"
+)
+
+// Rule fires once per task and due date, when the task has been overdue for
+// After. Moving the due date re-arms it.
+type Rule struct {
+	Name   string
+	After  time.Duration
+	Action Action
+	URL    string
+	// Secret signs webhook bodies (X-Signature-256: sha256=<hex HMAC>).
+	Secret string
+}
+
+// Escalation records that a rule fired for a task at a due date.
+type Escalation struct {
+	TaskID    int
+	Rule      string
+	DueAt     time.Time
+	CreatedAt time.Time
+}
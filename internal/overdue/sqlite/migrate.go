@@ -0,0 +1,16 @@
+package sqlite
+
+import "database/sql"
+
+func Migrate(db *sql.DB) error {
+	const q = `
+	CREATE TABLE IF NOT EXISTS task_escalations(
+	task_id INTEGER NOT NULL,
+	rule TEXT NOT NULL,
+	due_at TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	PRIMARY KEY (task_id, rule, due_at));
+	`
+	_, err := db.Exec(q)
+	return err
+}
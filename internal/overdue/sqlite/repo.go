@@ -0,0 +1,67 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"task_scheduler/internal/overdue"
+	"time"
+)
+
+type Repo struct {
+	db *sql.DB
+}
+
+func New(db *sql.DB) *Repo {
+	return &Repo{db: db}
+}
+
+// Due compares times with julianday: tasks store them as RFC3339Nano text,
+// which doesn't sort correctly within a second.
+func (r *Repo) Due(ctx context.Context, rule string, dueBefore, now time.Time, afterID, limit int) ([]int, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT t.id FROM tasks t
+		 WHERE t.status = 'pending' AND t.due_at IS NOT NULL AND t.id > ?
+		   AND julianday(t.due_at) < julianday(?)
+		   AND (t.snoozed_until IS NULL OR julianday(t.snoozed_until) <= julianday(?))
+		   AND NOT EXISTS (
+		     SELECT 1 FROM task_escalations e
+		     WHERE e.task_id = t.id AND e.rule = ? AND e.due_at = t.due_at)
+		 ORDER BY t.id
+		 LIMIT ?`,
+		afterID,
+		dueBefore.UTC().Format(time.RFC3339Nano),
+		now.UTC().Format(time.RFC3339Nano),
+		rule,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// MarkEscalated stores due_at formatted exactly like the tasks table, so
+// Due can match it as text.
+func (r *Repo) MarkEscalated(ctx context.Context, e overdue.Escalation) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT OR IGNORE INTO task_escalations (task_id, rule, due_at, created_at) VALUES (?, ?, ?, ?)`,
+		e.TaskID,
+		e.Rule,
+		e.DueAt.UTC().Format(time.RFC3339Nano),
+		e.CreatedAt.UTC().Format(time.RFC3339Nano),
+	)
+	return err
+}
@@ -0,0 +1,158 @@
+package overdue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"task_scheduler/internal/notify"
+	"task_scheduler/internal/task"
+	"task_scheduler/internal/webhook"
+	"time"
+)
+
+// sweepBatch bounds one query of the sweep.
+const sweepBatch = 100
+
+// Tasks loads tasks regardless of their owner.
+type Tasks interface {
+	GetByID(ctx context.Context, id int) (*task.Task, error)
+}
+
+// Sweeper applies the escalation rules to overdue tasks. Run Sweep
+// periodically; a failed action is retried on the next sweep.
+type Sweeper struct {
+	repo     Repo
+	tasks    Tasks
+	notifier notify.Notifier
+	client   *http.Client
+	rules    []Rule
+	now      func() time.Time
+}
+
+// NewSweeper uses client for webhooks; nil means a client with a 10s timeout.
+func NewSweeper(repo Repo, tasks Tasks, notifier notify.Notifier, client *http.Client, rules []Rule) *Sweeper {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Sweeper{
+		repo:     repo,
+		tasks:    tasks,
+		notifier: notifier,
+		client:   client,
+		rules:    rules,
+		now:      time.Now,
+	}
+}
+
+// webhookPayload is the body of a webhook action.
+type webhookPayload struct {
+	Event       string    `json:"event"`
+	Rule        string    `json:"rule"`
+	TaskID      int       `json:"task_id"`
+	Title       string    `json:"title"`
+	UserID      int       `json:"user_id"`
+	WorkspaceID int       `json:"workspace_id"`
+	AssigneeID  *int      `json:"assignee_id"`
+	DueAt       time.Time `json:"due_at"`
+	SentAt      time.Time `json:"sent_at"`
+}
+
+func (s *Sweeper) Sweep(ctx context.Context) error {
+	now := s.now().UTC()
+	for _, rule := range s.rules {
+		if err := s.sweepRule(ctx, rule, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Sweeper) sweepRule(ctx context.Context, rule Rule, now time.Time) error {
+	afterID := 0
+	for {
+		ids, err := s.repo.Due(ctx, rule.Name, now.Add(-rule.After), now, afterID, sweepBatch)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			afterID = id
+			tsk, err := s.tasks.GetByID(ctx, id)
+			if err != nil {
+				// удалили между запросами
+				if errors.Is(err, task.ErrNotFound) {
+					continue
+				}
+				return err
+			}
+			if tsk.DueAt == nil {
+				continue
+			}
+
+			// не доставили — не отмечаем, попробуем в следующий раз
+			if err := s.apply(ctx, rule, tsk, now); err != nil {
+				log.Println("[OVERDUE] rule "+rule.Name+" task "+strconv.Itoa(tsk.ID)+" error:", err)
+				continue
+			}
+			if err := s.repo.MarkEscalated(ctx, Escalation{
+				TaskID:    tsk.ID,
+				Rule:      rule.Name,
+				DueAt:     *tsk.DueAt,
+				CreatedAt: now,
+			}); err != nil {
+				return err
+			}
+		}
+		if len(ids) < sweepBatch {
+			return nil
+		}
+	}
+}
+
+func (s *Sweeper) apply(ctx context.Context, rule Rule, tsk *task.Task, now time.Time) error {
+	switch rule.Action {
+	case ActionNotify:
+		return s.notify(ctx, tsk, now)
+	case ActionWebhook:
+		return s.webhook(ctx, rule, tsk, now)
+	default:
+		return fmt.Errorf("overdue: unknown action %q", rule.Action)
+	}
+}
+
+// notify tells the assignee and the owner, once each.
+func (s *Sweeper) notify(ctx context.Context, tsk *task.Task, now time.Time) error {
+	recipients := []int{tsk.UserID}
+	if tsk.AssigneeID != nil && *tsk.AssigneeID != tsk.UserID {
+		recipients = append(recipients, *tsk.AssigneeID)
+	}
+	overdueFor := now.Sub(*tsk.DueAt).Truncate(time.Minute)
+	for _, userID := range recipients {
+		err := s.notifier.Notify(ctx, notify.Notification{
+			UserID:  userID,
+			Subject: "Task overdue: " + tsk.Title,
+			Body: "Task #" + strconv.Itoa(tsk.ID) + " \"" + tsk.Title + "\" was due at " +
+				tsk.DueAt.UTC().Format(time.RFC3339) + " and is overdue by " + overdueFor.String() + ".",
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Sweeper) webhook(ctx context.Context, rule Rule, tsk *task.Task, now time.Time) error {
+	return webhook.Send(ctx, s.client, rule.URL, rule.Secret, webhookPayload{
+		Event:       "task.overdue",
+		Rule:        rule.Name,
+		TaskID:      tsk.ID,
+		Title:       tsk.Title,
+		UserID:      tsk.UserID,
+		WorkspaceID: tsk.WorkspaceID,
+		AssigneeID:  tsk.AssigneeID,
+		DueAt:       tsk.DueAt.UTC(),
+		SentAt:      now,
+	})
+}
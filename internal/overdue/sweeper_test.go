@@ -0,0 +1,156 @@
+package overdue_test
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+
+	"task_scheduler/internal/mail"
+	"task_scheduler/internal/notify"
+	"task_scheduler/internal/overdue"
+	overduesqlite "task_scheduler/internal/overdue/sqlite"
+	"task_scheduler/internal/task"
+	tasksqlite "task_scheduler/internal/task/sqlite"
+	"task_scheduler/internal/user"
+	usersqlite "task_scheduler/internal/user/sqlite"
+)
+
+// hookPayload is the part of the webhook body the test looks at.
+type hookPayload struct {
+	Event  string `json:"event"`
+	Rule   string `json:"rule"`
+	TaskID int    `json:"task_id"`
+}
+
+// hookRecorder collects webhook deliveries whose signature checks out.
+type hookRecorder struct {
+	t      *testing.T
+	secret string
+	mu     sync.Mutex
+	fail   bool
+	got    []hookPayload
+}
+
+func (h *hookRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	require.NoError(h.t, err)
+	mac := hmac.New(sha256.New, []byte(h.secret))
+	mac.Write(body)
+	require.Equal(h.t, "sha256="+hex.EncodeToString(mac.Sum(nil)), r.Header.Get("X-Signature-256"))
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.fail {
+		http.Error(w, "down", http.StatusBadGateway)
+		return
+	}
+	var p hookPayload
+	require.NoError(h.t, json.Unmarshal(body, &p))
+	h.got = append(h.got, p)
+}
+
+func (h *hookRecorder) setFail(fail bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.fail = fail
+}
+
+func (h *hookRecorder) taskIDs() []int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ids := make([]int, 0, len(h.got))
+	for _, p := range h.got {
+		ids = append(ids, p.TaskID)
+	}
+	h.got = nil
+	return ids
+}
+
+func TestSweeper_EscalatesOncePerDueDate(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "tasks.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	require.NoError(t, tasksqlite.Migrate(db))
+	require.NoError(t, usersqlite.Migrate(db))
+	require.NoError(t, overduesqlite.Migrate(db))
+
+	mailbox := &bytes.Buffer{}
+	mailer := mail.NewWriterMailer(mailbox, "test@example.com")
+	userRepo := usersqlite.New(db)
+	userSvc := user.NewService(userRepo, userRepo, userRepo, mailer, user.Options{})
+	owner, err := userSvc.Register("owner@example.com", "secret123")
+	require.NoError(t, err)
+	assignee, err := userSvc.Register("assignee@example.com", "secret123")
+	require.NoError(t, err)
+
+	taskRepo := tasksqlite.New(db)
+	now := time.Now().UTC()
+	create := func(title string, due time.Time, status task.Status, snoozedUntil *time.Time) *task.Task {
+		tsk := &task.Task{
+			UserID:       owner.ID,
+			CreatedBy:    owner.ID,
+			AssigneeID:   &assignee.ID,
+			Title:        title,
+			DueAt:        &due,
+			SnoozedUntil: snoozedUntil,
+			Status:       status,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		}
+		require.NoError(t, taskRepo.Create(t.Context(), tsk, 0))
+		return tsk
+	}
+	later := now.Add(time.Hour)
+	late := create("Late", now.Add(-2*time.Hour), task.StatusPending, nil)
+	recent := create("Recent", now.Add(-30*time.Minute), task.StatusPending, nil)
+	create("Done", now.Add(-3*time.Hour), task.StatusDone, nil)
+	create("Snoozed", now.Add(-3*time.Hour), task.StatusPending, &later)
+	create("Upcoming", now.Add(time.Hour), task.StatusPending, nil)
+
+	hook := &hookRecorder{t: t, secret: "s3cret"}
+	srv := httptest.NewServer(hook)
+	t.Cleanup(srv.Close)
+
+	sweeper := overdue.NewSweeper(overduesqlite.New(db), taskRepo, notify.NewMailNotifier(userSvc, mailer), srv.Client(), []overdue.Rule{
+		{Name: "remind", After: time.Hour, Action: overdue.ActionNotify},
+		{Name: "hook", After: 0, Action: overdue.ActionWebhook, URL: srv.URL, Secret: "s3cret"},
+	})
+
+	// 1) письма — только по задаче, просроченной больше часа; webhook — по обеим
+	require.NoError(t, sweeper.Sweep(t.Context()))
+	require.Equal(t, 2, strings.Count(mailbox.String(), "Subject: Task overdue: Late"))
+	require.Contains(t, mailbox.String(), "To: owner@example.com")
+	require.Contains(t, mailbox.String(), "To: assignee@example.com")
+	require.NotContains(t, mailbox.String(), "Recent")
+	require.ElementsMatch(t, []int{late.ID, recent.ID}, hook.taskIDs())
+
+	// 2) повторный проход ничего не шлёт
+	mailbox.Reset()
+	require.NoError(t, sweeper.Sweep(t.Context()))
+	require.Empty(t, mailbox.String())
+	require.Empty(t, hook.taskIDs())
+
+	// 3) новый срок — правило срабатывает снова; недоставленное повторяется
+	moved := now.Add(-10 * time.Minute)
+	recent.DueAt = &moved
+	require.NoError(t, taskRepo.Update(t.Context(), recent, recent.UpdatedAt, 0))
+	hook.setFail(true)
+	require.NoError(t, sweeper.Sweep(t.Context()))
+	hook.setFail(false)
+	require.NoError(t, sweeper.Sweep(t.Context()))
+	require.Equal(t, []int{recent.ID}, hook.taskIDs())
+}
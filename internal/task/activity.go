@@ -0,0 +1,21 @@
+package task
+
+import "time"
+
+type ActivityType string
+
+const (
+	ActivityTaskCreated    ActivityType = "task.created"
+	ActivityCommentCreated ActivityType = "comment.created"
+	ActivityCommentEdited  ActivityType = "comment.edited"
+	ActivityCommentDeleted ActivityType = "comment.deleted"
+)
+
+// Activity is one entry of a task's feed. CommentID is set for comment
+// events.
+type Activity struct {
+	Type      ActivityType `json:"type"`
+	ActorID   int          `json:"actor_id"`
+	CommentID *int         `json:"comment_id,omitempty"`
+	At        time.Time    `json:"at"`
+}
@@ -0,0 +1,74 @@
+package task
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrWIPLimit rejects adding or moving a task to a board column that is full.
+var ErrWIPLimit = errors.New("the board column is at its WIP limit")
+
+// BoardColumns are the columns of a workspace board, in order: one per
+// status.
+var BoardColumns = []Status{StatusPending, StatusDone, StatusCanceled}
+
+type Board struct {
+	WorkspaceID int           `json:"workspace_id"`
+	Columns     []BoardColumn `json:"columns"`
+}
+
+// BoardColumn holds the first tasks of a status; Count is all of them,
+// snoozed and deferred ones included.
+type BoardColumn struct {
+	Status Status `json:"status"`
+	Count  int    `json:"count"`
+	// WIPLimit is nil when the column has no limit.
+	WIPLimit *int   `json:"wip_limit"`
+	Tasks    []Task `json:"tasks"`
+}
+
+func (s *TaskService) Board(ctx context.Context, userID, workspaceID, perColumn int) (*Board, error) {
+	if userID <= 0 || workspaceID <= 0 || perColumn < 0 {
+		return nil, ErrInvalidInput
+	}
+	if _, err := s.workspaceRole(ctx, workspaceID, userID); err != nil {
+		return nil, err
+	}
+	if perColumn == 0 {
+		perColumn = 20
+	}
+	if perColumn > 100 {
+		perColumn = 100
+	}
+	limits, err := s.workspaces.WIPLimits(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	board := &Board{WorkspaceID: workspaceID, Columns: make([]BoardColumn, 0, len(BoardColumns))}
+	for _, status := range BoardColumns {
+		tasks, total, err := s.repo.ListWorkspace(ctx, workspaceID, ListFilter{Status: status, Sort: SortRank}, perColumn, 0)
+		if err != nil {
+			return nil, err
+		}
+		col := BoardColumn{Status: status, Count: total, Tasks: withDerived(tasks)}
+		if n, ok := limits[string(status)]; ok {
+			col.WIPLimit = &n
+		}
+		board.Columns = append(board.Columns, col)
+	}
+	return board, nil
+}
+
+// wipLimit is the WIP limit of the board column the workspace task is in,
+// 0 if it has none. The repo enforces it when the task is saved.
+func (s *TaskService) wipLimit(ctx context.Context, tsk *Task) (int, error) {
+	if tsk.WorkspaceID == 0 {
+		return 0, nil
+	}
+	limits, err := s.workspaces.WIPLimits(ctx, tsk.WorkspaceID)
+	if err != nil {
+		return 0, err
+	}
+	return limits[string(tsk.Status)], nil
+}
@@ -0,0 +1,72 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var ErrCommentNotFound = errors.New("comment not found")
+
+// MaxCommentLength caps a comment body, in bytes.
+const MaxCommentLength = 10000
+
+// Comment is a Markdown note on a task. Replies point at their parent with
+// ParentID; the list is flat and clients build the thread. Deleted comments
+// keep their place in the thread with an empty body.
+type Comment struct {
+	ID        int        `json:"id"`
+	TaskID    int        `json:"task_id"`
+	ParentID  *int       `json:"parent_id"`
+	AuthorID  int        `json:"author_id"`
+	Body      string     `json:"body"`
+	Mentions  []string   `json:"mentions"`
+	CreatedAt time.Time  `json:"created_at"`
+	EditedAt  *time.Time `json:"edited_at"`
+	DeletedAt *time.Time `json:"deleted_at"`
+}
+
+// CommentRevision is a previous body of an edited comment.
+type CommentRevision struct {
+	CommentID int       `json:"comment_id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	// ReplacedAt is when this body was replaced by the next one.
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+type CommentRepo interface {
+	CreateComment(ctx context.Context, c *Comment) error
+	// GetComment returns ErrCommentNotFound unless the comment is on the task.
+	GetComment(ctx context.Context, taskID, id int) (*Comment, error)
+	ListComments(ctx context.Context, taskID int) ([]Comment, error)
+	// ListCommentsByAuthor returns the user's comments that aren't deleted.
+	ListCommentsByAuthor(ctx context.Context, authorID int) ([]Comment, error)
+	// UpdateComment saves the new body and stores rev as history, atomically.
+	UpdateComment(ctx context.Context, c *Comment, rev CommentRevision) error
+	// DeleteComment blanks the comment and drops its history.
+	DeleteComment(ctx context.Context, c *Comment) error
+	ListCommentRevisions(ctx context.Context, commentID int) ([]CommentRevision, error)
+	// ListTaskCommentRevisions returns the history of all comments on the task.
+	ListTaskCommentRevisions(ctx context.Context, taskID int) ([]CommentRevision, error)
+}
+
+// mentionPattern matches "@someone@example.com"; the leading @ must not be
+// glued to a word, so plain email addresses aren't mentions.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w.@+-])@([\w.%+-]+@[\w-]+(?:\.[\w-]+)+)`)
+
+// parseMentions returns the distinct lowercased emails mentioned in body.
+func parseMentions(body string) []string {
+	mentions := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, m := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		email := strings.ToLower(m[1])
+		if !seen[email] {
+			seen[email] = true
+			mentions = append(mentions, email)
+		}
+	}
+	return mentions
+}
@@ -0,0 +1,29 @@
+package task
+
+import "context"
+
+type EventType string
+
+const (
+	EventCreated       EventType = "task.created"
+	EventStatusChanged EventType = "task.status_changed"
+	// EventDue is raised outside the service, when a pending task's due
+	// date passes.
+	EventDue EventType = "task.due"
+)
+
+// Event describes a change made through the service. Task is the state
+// after the change.
+type Event struct {
+	Type    EventType
+	ActorID int
+	Task    Task
+	// PrevStatus is set for EventStatusChanged.
+	PrevStatus Status
+}
+
+// Observer is told about events after they are saved. It runs
+// synchronously, with the caller's context, and can't fail the change.
+type Observer interface {
+	TaskChanged(ctx context.Context, ev Event)
+}
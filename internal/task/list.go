@@ -0,0 +1,40 @@
+package task
+
+import "time"
+
+// ListSort orders a task list.
+type ListSort string
+
+const (
+	// SortCreated lists the newest tasks first; it is the default.
+	SortCreated ListSort = "created"
+	// SortRank follows the manual order set with Move.
+	SortRank ListSort = "rank"
+)
+
+// Valid accepts the known sorts and "" (the default).
+func (s ListSort) Valid() bool {
+	return s == "" || s == SortCreated || s == SortRank
+}
+
+// ListOptions tune the task lists beyond paging.
+type ListOptions struct {
+	// IncludeHidden also returns tasks that are snoozed or deferred.
+	IncludeHidden bool
+	Sort          ListSort
+}
+
+// ListFilter narrows and orders what the repo lists.
+type ListFilter struct {
+	// VisibleAt drops tasks snoozed or deferred past it; zero keeps all.
+	VisibleAt time.Time
+	// Status keeps only tasks with it; "" keeps all.
+	Status Status
+	Sort   ListSort
+}
+
+// Hidden reports whether the task is snoozed or deferred past now.
+func (t *Task) Hidden(now time.Time) bool {
+	return (t.SnoozedUntil != nil && t.SnoozedUntil.After(now)) ||
+		(t.DeferUntil != nil && t.DeferUntil.After(now))
+}
@@ -1,6 +1,9 @@
 package task
 
-import "time"
+import (
+	"task_scheduler/internal/markdown"
+	"time"
+)
 
 type Status string
 
@@ -10,12 +13,57 @@ const (
 	StatusCanceled Status = "canceled"
 )
 
+func (s Status) Valid() bool {
+	return s == StatusPending || s == StatusDone || s == StatusCanceled
+}
+
+// Task lives in its owner's (UserID) personal space, or in a workspace when
+// WorkspaceID isn't 0. CreatedBy never changes; AssigneeID is who should do it.
 type Task struct {
-	ID        int
-	UserID    int
-	Title     string
-	DueAt     *time.Time
-	Status    Status
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID          int  `json:"id"`
+	UserID      int  `json:"user_id"`
+	WorkspaceID int  `json:"workspace_id"`
+	CreatedBy   int  `json:"created_by"`
+	AssigneeID  *int `json:"assignee_id"`
+	// ParentID makes the task a subtask; it is set when a tree of tasks is
+	// created (CreateTree) and cleared if the parent is deleted.
+	ParentID    *int       `json:"parent_id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Checklist   Checklist  `json:"checklist"`
+	DueAt       *time.Time `json:"due_at"`
+	// Overdue is derived: still pending after DueAt.
+	Overdue bool `json:"overdue"`
+	// DeferUntil is the start date: until then the task stays out of the
+	// default lists. SnoozedUntil hides it the same way and is set by Snooze.
+	DeferUntil   *time.Time `json:"defer_until"`
+	SnoozedUntil *time.Time `json:"snoozed_until"`
+	// Rank is the manual position within the task's list; see RankBetween.
+	Rank string `json:"rank"`
+	// EstimateMinutes is the expected effort, compared with tracked time.
+	EstimateMinutes *int      `json:"estimate_minutes"`
+	Status          Status    `json:"status"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// MaxDescriptionLength caps the Markdown description, in bytes.
+const MaxDescriptionLength = 20000
+
+// Checklist counts the "- [ ]" items of the description. It is derived and
+// never stored.
+type Checklist struct {
+	Total int `json:"total"`
+	Done  int `json:"done"`
+}
+
+func countChecklist(description string) Checklist {
+	var c Checklist
+	for _, item := range markdown.TaskItems(description) {
+		c.Total++
+		if item.Done {
+			c.Done++
+		}
+	}
+	return c
 }
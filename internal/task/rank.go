@@ -0,0 +1,153 @@
+package task
+
+import (
+	"errors"
+	"strings"
+)
+
+// Ranks order tasks by hand within a list (the owner's personal tasks or a
+// workspace). A rank is a base-62 fraction written without the leading
+// "0.": ranks compare as plain strings, and there is always room for a new
+// one between two others. They never end in '0', so that no two spellings
+// mean the same number.
+
+const rankDigits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// MaxRankLength is how long ranks may grow before their list is rebalanced.
+const MaxRankLength = 12
+
+var (
+	ErrInvalidAnchor = errors.New("anchors must be other tasks of the same list, in order")
+
+	errRankOrder = errors.New("ranks out of order")
+)
+
+// RankList is a list that ranks are kept in: the owner's personal tasks
+// (WorkspaceID 0) or a workspace (UserID 0).
+type RankList struct {
+	UserID      int
+	WorkspaceID int
+}
+
+func listOf(t *Task) RankList {
+	if t.WorkspaceID > 0 {
+		return RankList{WorkspaceID: t.WorkspaceID}
+	}
+	return RankList{UserID: t.UserID}
+}
+
+// firstRank is the rank of the first task of an empty list.
+const firstRank = "V"
+
+// RankBetween returns a rank strictly between a and b. An empty a means the
+// start of the list, an empty b its end.
+func RankBetween(a, b string) (string, error) {
+	switch {
+	case a == "" && b == "":
+		return firstRank, nil
+	case b == "":
+		return rankAfter(a), nil
+	case a >= b:
+		return "", errRankOrder
+	}
+	return midpoint(a, b), nil
+}
+
+// rankAfter steps past a in the second digit, which leaves room for
+// thousands of tasks added at the end before ranks start to grow.
+func rankAfter(a string) string {
+	d := []int{0, 0}
+	for i := 0; i < len(d) && i < len(a); i++ {
+		d[i] = strings.IndexByte(rankDigits, a[i])
+	}
+	for i := 1; i >= 0; i-- {
+		d[i]++
+		if d[i] < len(rankDigits) {
+			return encodeRank(d)
+		}
+		d[i] = 0
+	}
+	// дошли до "zz" — дальше только удлиняясь
+	return midpoint(a, "")
+}
+
+// midpoint finds a rank between a and b (b == "" is the end), a < b.
+func midpoint(a, b string) string {
+	if b != "" {
+		// общий префикс оставляем как есть
+		n := 0
+		for n < len(b) && rankDigitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			rest := ""
+			if n < len(a) {
+				rest = a[n:]
+			}
+			return b[:n] + midpoint(rest, b[n:])
+		}
+	}
+
+	da := strings.IndexByte(rankDigits, rankDigitAt(a, 0))
+	db := len(rankDigits)
+	if b != "" {
+		db = strings.IndexByte(rankDigits, b[0])
+	}
+	if db-da > 1 {
+		return string(rankDigits[(da+db)/2])
+	}
+	// соседние цифры: b длиннее — хватит его первой цифры
+	if len(b) > 1 {
+		return b[:1]
+	}
+	rest := ""
+	if len(a) > 1 {
+		rest = a[1:]
+	}
+	return string(rankDigits[da]) + midpoint(rest, "")
+}
+
+// rankDigitAt pads a with zeros.
+func rankDigitAt(a string, i int) byte {
+	if i < len(a) {
+		return a[i]
+	}
+	return rankDigits[0]
+}
+
+// SpreadRanks returns n ranks in order, evenly spaced over the lower half
+// of the range; the upper half is left for tasks added at the end.
+func SpreadRanks(n int) []string {
+	// длина, при которой шаг не меньше 1
+	length, space := 1, len(rankDigits)/2
+	for space < n+1 {
+		length++
+		space *= len(rankDigits)
+	}
+	step := space / (n + 1)
+
+	ranks := make([]string, n)
+	for i := range ranks {
+		v := (i + 1) * step
+		d := make([]int, length)
+		for j := length - 1; j >= 0; j-- {
+			d[j] = v % len(rankDigits)
+			v /= len(rankDigits)
+		}
+		ranks[i] = encodeRank(d)
+	}
+	return ranks
+}
+
+// encodeRank writes digits, dropping trailing zeros.
+func encodeRank(d []int) string {
+	end := len(d)
+	for end > 0 && d[end-1] == 0 {
+		end--
+	}
+	var sb strings.Builder
+	for _, x := range d[:end] {
+		sb.WriteByte(rankDigits[x])
+	}
+	return sb.String()
+}
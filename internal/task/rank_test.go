@@ -0,0 +1,74 @@
+package task
+
+import (
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRankBetween_KeepsOrder(t *testing.T) {
+	ranks := []string{}
+	insert := func(i int) {
+		var lo, hi string
+		if i > 0 {
+			lo = ranks[i-1]
+		}
+		if i < len(ranks) {
+			hi = ranks[i]
+		}
+		r, err := RankBetween(lo, hi)
+		require.NoError(t, err)
+		require.Less(t, lo, r)
+		if hi != "" {
+			require.Less(t, r, hi)
+		}
+		require.False(t, strings.HasSuffix(r, "0"), r)
+		ranks = slices.Insert(ranks, i, r)
+	}
+
+	rnd := rand.New(rand.NewPCG(1, 2))
+	for range 500 {
+		insert(rnd.IntN(len(ranks) + 1))
+	}
+	// всё время в начало и в конец
+	for range 200 {
+		insert(0)
+		insert(len(ranks))
+	}
+	require.True(t, slices.IsSorted(ranks))
+	require.Len(t, slices.Compact(slices.Clone(ranks)), len(ranks))
+
+	_, err := RankBetween("b", "a")
+	require.True(t, errors.Is(err, errRankOrder))
+	_, err = RankBetween("a", "a")
+	require.True(t, errors.Is(err, errRankOrder))
+}
+
+func TestRankBetween_AppendsStayShort(t *testing.T) {
+	last := ""
+	for range 1000 {
+		r, err := RankBetween(last, "")
+		require.NoError(t, err)
+		require.Less(t, last, r)
+		last = r
+	}
+	require.LessOrEqual(t, len(last), 2)
+}
+
+func TestSpreadRanks(t *testing.T) {
+	for _, n := range []int{0, 1, 30, 31, 1000, 5000} {
+		ranks := SpreadRanks(n)
+		require.Len(t, ranks, n)
+		require.True(t, slices.IsSorted(ranks))
+		require.Len(t, slices.Compact(slices.Clone(ranks)), n)
+		for _, r := range ranks {
+			require.NotEmpty(t, r)
+			require.LessOrEqual(t, len(r), 3)
+			require.Less(t, r, "V")
+		}
+	}
+}
@@ -1,11 +1,48 @@
 package task
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type Repo interface {
-	Create(ctx context.Context, t *Task) error
+	// Create stores the task. A wipLimit above 0 caps how many tasks of the
+	// workspace may have its status (ErrWIPLimit); check and insert are atomic.
+	Create(ctx context.Context, t *Task, wipLimit int) error
+	// CreateTree stores all the nodes or none, setting their IDs and
+	// ParentIDs; wipLimit applies as in Create.
+	CreateTree(ctx context.Context, nodes []TreeNode, wipLimit int) error
 	Get(ctx context.Context, userID, id int) (*Task, error)
-	List(ctx context.Context, userID, limit, offset int) ([]Task, int, error)
-	Update(ctx context.Context, t *Task) error
+	// GetByID loads a task regardless of its owner; access is checked by the service.
+	GetByID(ctx context.Context, id int) (*Task, error)
+	// List returns the user's personal tasks (outside any workspace).
+	List(ctx context.Context, userID int, f ListFilter, limit, offset int) ([]Task, int, error)
+	ListWorkspace(ctx context.Context, workspaceID int, f ListFilter, limit, offset int) ([]Task, int, error)
+	// ListAssigned returns the tasks assigned to the user that they can
+	// still see: their own, shared with them, or in one of workspaceIDs.
+	ListAssigned(ctx context.Context, assigneeID int, workspaceIDs []int, f ListFilter, limit, offset int) ([]Task, int, error)
+	// ListInvolved returns the tasks the user created or is assigned, in and
+	// outside workspaces.
+	ListInvolved(ctx context.Context, userID int, limit, offset int) ([]Task, int, error)
+	// ListShared lists tasks shared with the user, plus their own ones if includeOwned.
+	ListShared(ctx context.Context, userID int, includeOwned bool, f ListFilter, limit, offset int) ([]Task, int, error)
+	// Search matches query against titles, descriptions and comment bodies.
+	// With workspaceID 0 it covers the user's personal and shared tasks,
+	// otherwise the workspace.
+	Search(ctx context.Context, userID, workspaceID int, query string, limit, offset int) ([]Task, int, error)
+	// Update saves the task if its updated_at is still prev, otherwise it
+	// returns ErrConflict; wipLimit applies as in Create, not counting the
+	// task itself.
+	Update(ctx context.Context, t *Task, prev time.Time, wipLimit int) error
+	// LastRank is the highest rank in the list, "" if it is empty.
+	LastRank(ctx context.Context, l RankList) (string, error)
+	// AdjacentRank is the nearest rank after (or before, if !after) rank in
+	// the list, leaving out task skipID; "" if there is none.
+	AdjacentRank(ctx context.Context, l RankList, rank string, after bool, skipID int) (string, error)
+	SetRank(ctx context.Context, id int, rank string, at time.Time) error
+	// Rebalance spreads the list's ranks evenly (SpreadRanks), keeping the order.
+	Rebalance(ctx context.Context, l RankList) error
+	// LongRankLists finds the lists that have a rank longer than n.
+	LongRankLists(ctx context.Context, n int) ([]RankList, error)
 	Delete(ctx context.Context, userID, id int) error
 }
@@ -3,54 +3,197 @@ package task
 import (
 	"context"
 	"errors"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"task_scheduler/internal/notify"
+	"task_scheduler/internal/user"
+	"task_scheduler/internal/workspace"
 	"time"
 )
 
 var (
 	ErrInvalidInput = errors.New("invalid input")
 	ErrNotFound     = errors.New("task not found")
+
+	ErrDescriptionTooLong = errors.New("description is too long")
+	ErrEmailNotVerified   = errors.New("email address is not verified")
+	// ErrConflict means the task changed between reading and saving it.
+	ErrConflict = errors.New("task was changed by another request")
 )
 
 type Service interface {
-	Create(ctx context.Context, userID int, title string, dueAt *time.Time) (*Task, error)
+	// Create adds a task to the user's personal space, or to the workspace
+	// if workspaceID isn't 0. Workspace guests can't create tasks.
+	Create(ctx context.Context, userID, workspaceID int, input CreateTaskInput) (*Task, error)
+	// CreateTree creates the tasks with their subtasks, all or none, like
+	// Create does. The result lists parents before their children.
+	CreateTree(ctx context.Context, userID, workspaceID int, roots []TreeInput) ([]Task, error)
 	Get(ctx context.Context, userID, id int) (*Task, error)
-	List(ctx context.Context, userID, limit, offset int) ([]Task, int, int, error)
+	// Role is what the user may do with the task; ErrNotFound if nothing.
+	Role(ctx context.Context, userID, id int) (Role, error)
+	// List, ListAssigned and ListWorkspace leave out snoozed and deferred
+	// tasks unless opts.IncludeHidden.
+	List(ctx context.Context, userID int, scope ListScope, opts ListOptions, limit, offset int) ([]Task, int, int, error)
+	// ListAssigned lists tasks assigned to the user, wherever they live, as
+	// long as the user still has access to them.
+	ListAssigned(ctx context.Context, userID int, opts ListOptions, limit, offset int) ([]Task, int, int, error)
+	// ListWorkspace lists the workspace's tasks for any of its members.
+	ListWorkspace(ctx context.Context, userID, workspaceID int, opts ListOptions, limit, offset int) ([]Task, int, int, error)
 	Update(ctx context.Context, userId, id int, input UpdateTaskInput) (*Task, error)
 	Delete(ctx context.Context, userID, id int) error
+	// Move puts the task right after one task and/or right before another
+	// of the same list (see RankList); at least one anchor is needed.
+	// Editors may move; anchors need only be visible.
+	Move(ctx context.Context, userID, id int, before, after *int) (*Task, error)
+	// RebalanceRanks re-spaces the lists whose ranks grew past MaxRankLength.
+	RebalanceRanks(ctx context.Context) error
+	// Board groups the workspace's tasks into status columns, perColumn
+	// tasks each in rank order, with counts and WIP limits. Any member may
+	// see it.
+	Board(ctx context.Context, userID, workspaceID, perColumn int) (*Board, error)
+	// Snooze hides the task from the default lists until the given time;
+	// nil wakes it up. Editors may snooze.
+	Snooze(ctx context.Context, userID, id int, until *time.Time) (*Task, error)
+
+	// Share gives the user with the email a role on the task. Only owners may share.
+	Share(ctx context.Context, userID, id int, email string, role Role) (*Share, error)
+	// Unshare removes a share. Owners may remove anyone; others only themselves.
+	Unshare(ctx context.Context, userID, id, targetUserID int) error
+	ListShares(ctx context.Context, userID, id int) ([]Share, error)
+
+	// Search finds tasks whose title, description or comments contain query:
+	// the user's personal and shared tasks, or the workspace's if workspaceID
+	// isn't 0.
+	Search(ctx context.Context, userID, workspaceID int, query string, limit, offset int) ([]Task, int, int, error)
+
+	// AddComment comments on the task, or replies to parentID. Anyone who can
+	// see the task may comment; mentioned collaborators are notified.
+	AddComment(ctx context.Context, userID, id int, parentID *int, body string) (*Comment, error)
+	ListComments(ctx context.Context, userID, id int) ([]Comment, error)
+	// EditComment changes a comment body, keeping the old one as history.
+	// Only the author may edit.
+	EditComment(ctx context.Context, userID, id, commentID int, body string) (*Comment, error)
+	// DeleteComment is allowed to the author and to task owners.
+	DeleteComment(ctx context.Context, userID, id, commentID int) error
+	CommentHistory(ctx context.Context, userID, id, commentID int) ([]CommentRevision, error)
+	// Activity is the task's feed, oldest first.
+	Activity(ctx context.Context, userID, id int) ([]Activity, error)
+}
+
+// Options turn on optional checks.
+type Options struct {
+	// RequireVerifiedEmail lets only users with a confirmed email create
+	// tasks, whichever way: directly, from a template or by a rule.
+	RequireVerifiedEmail bool
 }
 
 type TaskService struct {
-	repo Repo
+	repo       Repo
+	shares     ShareRepo
+	comments   CommentRepo
+	users      UserDirectory
+	workspaces WorkspaceDirectory
+	notifier   notify.Notifier
+	observer   Observer
+	opts       Options
 }
 
-func NewService(repo Repo) Service {
+// NewService takes an optional observer (nil is fine) that is told about
+// created tasks and status changes.
+func NewService(repo Repo, shares ShareRepo, comments CommentRepo, users UserDirectory, workspaces WorkspaceDirectory, notifier notify.Notifier, observer Observer, opts Options) Service {
 	return &TaskService{
-		repo: repo,
+		repo:       repo,
+		shares:     shares,
+		comments:   comments,
+		users:      users,
+		workspaces: workspaces,
+		notifier:   notifier,
+		observer:   observer,
+		opts:       opts,
 	}
 }
 
-func (s *TaskService) Create(ctx context.Context, userID int, title string, dueAt *time.Time) (*Task, error) {
-	if title == "" {
-		return nil, ErrInvalidInput
+func (s *TaskService) Create(ctx context.Context, userID, workspaceID int, input CreateTaskInput) (*Task, error) {
+	if err := validCreate(input); err != nil {
+		return nil, err
 	}
-	if userID <= 0 {
-		return nil, ErrInvalidInput
+	if err := s.canCreate(ctx, userID, workspaceID); err != nil {
+		return nil, err
 	}
-	now := time.Now().UTC()
-	task := &Task{
-		UserID:    userID,
-		Title:     title,
-		DueAt:     dueAt,
-		Status:    StatusPending,
-		CreatedAt: now,
-		UpdatedAt: now,
+	task := newTask(userID, workspaceID, input, time.Now().UTC())
+	// новая задача — в конец списка
+	last, err := s.repo.LastRank(ctx, listOf(task))
+	if err != nil {
+		return nil, err
+	}
+	task.Rank, _ = RankBetween(last, "")
+	wip, err := s.wipLimit(ctx, task)
+	if err != nil {
+		return nil, err
 	}
-	if err := s.repo.Create(ctx, task); err != nil {
+	if err := s.repo.Create(ctx, task, wip); err != nil {
 		return nil, err
 	}
+	s.emit(ctx, Event{Type: EventCreated, ActorID: userID, Task: *task})
 	return task, nil
 }
 
+func (s *TaskService) CreateTree(ctx context.Context, userID, workspaceID int, roots []TreeInput) ([]Task, error) {
+	// 1) раскладываем дерево: родитель всегда раньше детей
+	now := time.Now().UTC()
+	nodes := make([]TreeNode, 0, len(roots))
+	var walk func(items []TreeInput, parent int) error
+	walk = func(items []TreeInput, parent int) error {
+		for _, in := range items {
+			if err := validCreate(in.CreateTaskInput); err != nil {
+				return err
+			}
+			if len(nodes) == MaxTreeSize {
+				return ErrInvalidInput
+			}
+			nodes = append(nodes, TreeNode{Task: newTask(userID, workspaceID, in.CreateTaskInput, now), Parent: parent})
+			if err := walk(in.Children, len(nodes)-1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(roots, -1); err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, ErrInvalidInput
+	}
+	if err := s.canCreate(ctx, userID, workspaceID); err != nil {
+		return nil, err
+	}
+	last, err := s.repo.LastRank(ctx, listOf(nodes[0].Task))
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range nodes {
+		n.Task.Rank, _ = RankBetween(last, "")
+		last = n.Task.Rank
+	}
+
+	// 2) одной транзакцией; все задачи попадают в одну колонку
+	wip, err := s.wipLimit(ctx, nodes[0].Task)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateTree(ctx, nodes, wip); err != nil {
+		return nil, err
+	}
+	tasks := make([]Task, 0, len(nodes))
+	for _, n := range nodes {
+		tasks = append(tasks, *n.Task)
+		s.emit(ctx, Event{Type: EventCreated, ActorID: userID, Task: *n.Task})
+	}
+	return tasks, nil
+}
+
 func (s *TaskService) Get(ctx context.Context, userID int, id int) (*Task, error) {
 	if id <= 0 {
 		return nil, ErrInvalidInput
@@ -58,7 +201,7 @@ func (s *TaskService) Get(ctx context.Context, userID int, id int) (*Task, error
 	if userID <= 0 {
 		return nil, ErrInvalidInput
 	}
-	task, err := s.repo.Get(ctx, userID, id)
+	task, _, err := s.access(ctx, userID, id, RoleViewer)
 	if err != nil {
 		return nil, err
 	}
@@ -66,13 +209,21 @@ func (s *TaskService) Get(ctx context.Context, userID int, id int) (*Task, error
 
 }
 
-func (s *TaskService) List(ctx context.Context, userID int, limit, offset int) ([]Task, int, int, error) {
+func (s *TaskService) Role(ctx context.Context, userID, id int) (Role, error) {
+	if userID <= 0 || id <= 0 {
+		return "", ErrInvalidInput
+	}
+	_, role, err := s.access(ctx, userID, id, RoleViewer)
+	return role, err
+}
+
+func (s *TaskService) List(ctx context.Context, userID int, scope ListScope, opts ListOptions, limit, offset int) ([]Task, int, int, error) {
 	// 1. Валидация offset
 	if userID <= 0 {
 		return nil, 0, 0, ErrInvalidInput
 	}
 
-	if offset < 0 {
+	if offset < 0 || !opts.Sort.Valid() {
 		return nil, 0, 0, ErrInvalidInput
 	}
 
@@ -84,12 +235,73 @@ func (s *TaskService) List(ctx context.Context, userID int, limit, offset int) (
 		limit = 100
 	}
 
-	tasks, total, err := s.repo.List(ctx, userID, limit, offset)
+	var (
+		tasks []Task
+		total int
+		err   error
+	)
+	f := listFilter(opts)
+	switch scope {
+	case "", ListOwned:
+		tasks, total, err = s.repo.List(ctx, userID, f, limit, offset)
+	case ListShared:
+		tasks, total, err = s.repo.ListShared(ctx, userID, false, f, limit, offset)
+	case ListAll:
+		tasks, total, err = s.repo.ListShared(ctx, userID, true, f, limit, offset)
+	default:
+		return nil, 0, 0, ErrInvalidInput
+	}
 	if err != nil {
 		return nil, 0, 0, err
 	}
-	return tasks, total, limit, nil
+	return withDerived(tasks), total, limit, nil
+
+}
 
+func (s *TaskService) ListAssigned(ctx context.Context, userID int, opts ListOptions, limit, offset int) ([]Task, int, int, error) {
+	if userID <= 0 || offset < 0 || !opts.Sort.Valid() {
+		return nil, 0, 0, ErrInvalidInput
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	memberships, err := s.workspaces.ListForUser(ctx, userID)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	workspaceIDs := make([]int, 0, len(memberships))
+	for _, m := range memberships {
+		workspaceIDs = append(workspaceIDs, m.ID)
+	}
+	tasks, total, err := s.repo.ListAssigned(ctx, userID, workspaceIDs, listFilter(opts), limit, offset)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return withDerived(tasks), total, limit, nil
+}
+
+func (s *TaskService) ListWorkspace(ctx context.Context, userID, workspaceID int, opts ListOptions, limit, offset int) ([]Task, int, int, error) {
+	if userID <= 0 || workspaceID <= 0 || offset < 0 || !opts.Sort.Valid() {
+		return nil, 0, 0, ErrInvalidInput
+	}
+	if _, err := s.workspaceRole(ctx, workspaceID, userID); err != nil {
+		return nil, 0, 0, err
+	}
+
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	tasks, total, err := s.repo.ListWorkspace(ctx, workspaceID, listFilter(opts), limit, offset)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return withDerived(tasks), total, limit, nil
 }
 
 func (s *TaskService) Update(ctx context.Context, userID int, id int, input UpdateTaskInput) (*Task, error) {
@@ -97,14 +309,18 @@ func (s *TaskService) Update(ctx context.Context, userID int, id int, input Upda
 		return nil, ErrInvalidInput
 	}
 	// PATCH без полей — ошибка (на всякий, даже если handler уже проверяет)
-	if input.Title == nil && input.Status == nil && !input.DueAt.Set {
+	if input.Title == nil && input.Description == nil && input.Status == nil && !input.DueAt.Set && !input.DeferUntil.Set && !input.AssigneeID.Set && !input.EstimateMinutes.Set {
 		return nil, ErrInvalidInput
 	}
-	// 1) Берём текущую задачу (сразу проверка ownership)
-	tsk, err := s.repo.Get(ctx, userID, id)
+	// 1) Берём текущую задачу (сразу проверка прав: нужен editor)
+	tsk, _, err := s.access(ctx, userID, id, RoleEditor)
 	if err != nil {
 		return nil, err
 	}
+	if input.IfUpdatedAt != nil && !tsk.UpdatedAt.Equal(*input.IfUpdatedAt) {
+		return nil, ErrConflict
+	}
+	prev := tsk.UpdatedAt
 
 	// 2) Title
 	if input.Title != nil {
@@ -113,15 +329,28 @@ func (s *TaskService) Update(ctx context.Context, userID int, id int, input Upda
 		}
 		tsk.Title = *input.Title
 	}
+	if input.Description != nil {
+		if len(*input.Description) > MaxDescriptionLength {
+			return nil, ErrDescriptionTooLong
+		}
+		tsk.Description = *input.Description
+	}
 
 	// 3) Status
+	prevStatus := tsk.Status
 	if input.Status != nil {
-		switch *input.Status {
-		case string(StatusPending), string(StatusDone), string(StatusCanceled):
-			tsk.Status = Status(*input.Status)
-		default:
+		if !Status(*input.Status).Valid() {
 			return nil, ErrInvalidInput
 		}
+		tsk.Status = Status(*input.Status)
+	}
+	// колонка доски workspace может быть заполнена; место проверяется
+	// при записи, одним запросом с ней
+	wip := 0
+	if tsk.Status != prevStatus {
+		if wip, err = s.wipLimit(ctx, tsk); err != nil {
+			return nil, err
+		}
 	}
 
 	// 4) DueAt (3 состояния)
@@ -132,12 +361,51 @@ func (s *TaskService) Update(ctx context.Context, userID int, id int, input Upda
 			tsk.DueAt = input.DueAt.Value
 		}
 	}
+	if input.DeferUntil.Set {
+		tsk.DeferUntil = input.DeferUntil.Value
+	}
+	// начало не позже срока — проверяем итоговое состояние
+	if !validDefer(tsk.DeferUntil, tsk.DueAt) {
+		return nil, ErrInvalidInput
+	}
+	if input.EstimateMinutes.Set {
+		if !validEstimate(input.EstimateMinutes.Value) {
+			return nil, ErrInvalidInput
+		}
+		tsk.EstimateMinutes = input.EstimateMinutes.Value
+	}
+	// 5) Исполнитель: назначить можно только того, кто сам может редактировать задачу
+	// (проверяем только реальную смену — PATCH присылает текущего исполнителя как есть)
+	reassigned := input.AssigneeID.Set && !sameAssignee(tsk.AssigneeID, input.AssigneeID.Value)
+	if reassigned {
+		if v := input.AssigneeID.Value; v != nil {
+			if *v <= 0 {
+				return nil, ErrInvalidAssignee
+			}
+			if _, _, err := s.access(ctx, *v, id, RoleEditor); err != nil {
+				if errors.Is(err, ErrNotFound) || errors.Is(err, ErrForbidden) {
+					return nil, ErrInvalidAssignee
+				}
+				return nil, err
+			}
+		}
+		tsk.AssigneeID = input.AssigneeID.Value
+	}
 	tsk.UpdatedAt = time.Now().UTC()
+	derive(tsk, tsk.UpdatedAt)
 
-	// 5) Сохраняем
-	if err := s.repo.Update(ctx, tsk); err != nil {
+	// 6) Сохраняем
+	if err := s.repo.Update(ctx, tsk, prev, wip); err != nil {
 		return nil, err
 	}
+
+	// 7) новому исполнителю — уведомление (себе не шлём)
+	if reassigned && tsk.AssigneeID != nil && *tsk.AssigneeID != userID {
+		s.notifyAssigned(ctx, tsk)
+	}
+	if tsk.Status != prevStatus {
+		s.emit(ctx, Event{Type: EventStatusChanged, ActorID: userID, Task: *tsk, PrevStatus: prevStatus})
+	}
 	return tsk, nil
 
 }
@@ -146,5 +414,588 @@ func (s *TaskService) Delete(ctx context.Context, userID, id int) error {
 	if userID <= 0 || id <= 0 {
 		return ErrInvalidInput
 	}
-	return s.repo.Delete(ctx, userID, id)
+	tsk, _, err := s.access(ctx, userID, id, RoleOwner)
+	if err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, tsk.UserID, id)
+}
+
+func (s *TaskService) Move(ctx context.Context, userID, id int, before, after *int) (*Task, error) {
+	if userID <= 0 || id <= 0 || (before == nil && after == nil) {
+		return nil, ErrInvalidInput
+	}
+	tsk, _, err := s.access(ctx, userID, id, RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+	list := listOf(tsk)
+	// якорь — другая задача того же списка, которую пользователь видит
+	anchor := func(anchorID *int) (*Task, error) {
+		if anchorID == nil {
+			return nil, nil
+		}
+		if *anchorID == id {
+			return nil, ErrInvalidAnchor
+		}
+		a, _, err := s.access(ctx, userID, *anchorID, RoleViewer)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return nil, ErrInvalidAnchor
+			}
+			return nil, err
+		}
+		if listOf(a) != list {
+			return nil, ErrInvalidAnchor
+		}
+		return a, nil
+	}
+
+	var rank string
+	for attempt := 0; ; attempt++ {
+		beforeTask, err := anchor(before)
+		if err != nil {
+			return nil, err
+		}
+		afterTask, err := anchor(after)
+		if err != nil {
+			return nil, err
+		}
+		var lo, hi string
+		switch {
+		case beforeTask != nil && afterTask != nil:
+			lo, hi = afterTask.Rank, beforeTask.Rank
+		case afterTask != nil:
+			lo = afterTask.Rank
+			hi, err = s.repo.AdjacentRank(ctx, list, lo, true, id)
+		default:
+			hi = beforeTask.Rank
+			lo, err = s.repo.AdjacentRank(ctx, list, hi, false, id)
+		}
+		if err != nil {
+			return nil, err
+		}
+		rank, err = RankBetween(lo, hi)
+		if err == nil {
+			break
+		}
+		// одинаковые ранги (гонка) — перераскладываем список и пробуем ещё раз;
+		// если и тогда не вышло, якоря перепутаны местами
+		if attempt > 0 || !errors.Is(err, errRankOrder) {
+			return nil, ErrInvalidAnchor
+		}
+		if err := s.repo.Rebalance(ctx, list); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now().UTC()
+	if err := s.repo.SetRank(ctx, id, rank, now); err != nil {
+		return nil, err
+	}
+	tsk.Rank = rank
+	tsk.UpdatedAt = now
+	return tsk, nil
+}
+
+func (s *TaskService) RebalanceRanks(ctx context.Context) error {
+	lists, err := s.repo.LongRankLists(ctx, MaxRankLength)
+	if err != nil {
+		return err
+	}
+	for _, l := range lists {
+		if err := s.repo.Rebalance(ctx, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *TaskService) Snooze(ctx context.Context, userID, id int, until *time.Time) (*Task, error) {
+	if userID <= 0 || id <= 0 {
+		return nil, ErrInvalidInput
+	}
+	now := time.Now().UTC()
+	if until != nil && !until.After(now) {
+		return nil, ErrInvalidInput
+	}
+	tsk, _, err := s.access(ctx, userID, id, RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+	prev := tsk.UpdatedAt
+	tsk.SnoozedUntil = until
+	tsk.UpdatedAt = now
+	if err := s.repo.Update(ctx, tsk, prev, 0); err != nil {
+		return nil, err
+	}
+	return tsk, nil
+}
+
+func (s *TaskService) Share(ctx context.Context, userID, id int, email string, role Role) (*Share, error) {
+	email = strings.TrimSpace(strings.ToLower(email))
+	if userID <= 0 || id <= 0 || email == "" || !role.Valid() {
+		return nil, ErrInvalidInput
+	}
+	// 1) делиться может только owner
+	tsk, _, err := s.access(ctx, userID, id, RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2) получатель
+	targetID, err := s.users.IDByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, user.ErrNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	// владелец личной задачи и так owner, его роль не меняется
+	if tsk.WorkspaceID == 0 && targetID == tsk.UserID {
+		return nil, ErrInvalidInput
+	}
+
+	sh := &Share{
+		TaskID:    id,
+		UserID:    targetID,
+		Email:     email,
+		Role:      role,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.shares.UpsertShare(ctx, sh); err != nil {
+		return nil, err
+	}
+	return sh, nil
+}
+
+func (s *TaskService) Unshare(ctx context.Context, userID, id, targetUserID int) error {
+	if userID <= 0 || id <= 0 || targetUserID <= 0 {
+		return ErrInvalidInput
+	}
+	_, role, err := s.access(ctx, userID, id, RoleViewer)
+	if err != nil {
+		return err
+	}
+	// сам себя убрать может любой, остальных — только owner
+	if targetUserID != userID && !role.Allows(RoleOwner) {
+		return ErrForbidden
+	}
+	return s.shares.DeleteShare(ctx, id, targetUserID)
+}
+
+func (s *TaskService) ListShares(ctx context.Context, userID, id int) ([]Share, error) {
+	if userID <= 0 || id <= 0 {
+		return nil, ErrInvalidInput
+	}
+	if _, _, err := s.access(ctx, userID, id, RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.shares.ListShares(ctx, id)
+}
+
+func (s *TaskService) Search(ctx context.Context, userID, workspaceID int, query string, limit, offset int) ([]Task, int, int, error) {
+	query = strings.TrimSpace(query)
+	if userID <= 0 || workspaceID < 0 || query == "" || offset < 0 {
+		return nil, 0, 0, ErrInvalidInput
+	}
+	if workspaceID > 0 {
+		if _, err := s.workspaceRole(ctx, workspaceID, userID); err != nil {
+			return nil, 0, 0, err
+		}
+	}
+
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	tasks, total, err := s.repo.Search(ctx, userID, workspaceID, query, limit, offset)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return withDerived(tasks), total, limit, nil
+}
+
+func (s *TaskService) AddComment(ctx context.Context, userID, id int, parentID *int, body string) (*Comment, error) {
+	if userID <= 0 || id <= 0 || !validCommentBody(body) {
+		return nil, ErrInvalidInput
+	}
+	tsk, _, err := s.access(ctx, userID, id, RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	// ответ — только на комментарий этой же задачи
+	if parentID != nil {
+		if _, err := s.comments.GetComment(ctx, id, *parentID); err != nil {
+			if errors.Is(err, ErrCommentNotFound) {
+				return nil, ErrInvalidInput
+			}
+			return nil, err
+		}
+	}
+
+	c := &Comment{
+		TaskID:    id,
+		ParentID:  parentID,
+		AuthorID:  userID,
+		Body:      body,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.comments.CreateComment(ctx, c); err != nil {
+		return nil, err
+	}
+	c.Mentions = parseMentions(c.Body)
+	s.notifyMentioned(ctx, tsk, c, c.Mentions)
+	return c, nil
+}
+
+func (s *TaskService) ListComments(ctx context.Context, userID, id int) ([]Comment, error) {
+	if userID <= 0 || id <= 0 {
+		return nil, ErrInvalidInput
+	}
+	if _, _, err := s.access(ctx, userID, id, RoleViewer); err != nil {
+		return nil, err
+	}
+	comments, err := s.comments.ListComments(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for i := range comments {
+		comments[i].Mentions = parseMentions(comments[i].Body)
+	}
+	return comments, nil
+}
+
+func (s *TaskService) EditComment(ctx context.Context, userID, id, commentID int, body string) (*Comment, error) {
+	if userID <= 0 || id <= 0 || commentID <= 0 || !validCommentBody(body) {
+		return nil, ErrInvalidInput
+	}
+	tsk, _, err := s.access(ctx, userID, id, RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	c, err := s.comments.GetComment(ctx, id, commentID)
+	if err != nil {
+		return nil, err
+	}
+	if c.DeletedAt != nil {
+		return nil, ErrCommentNotFound
+	}
+	if c.AuthorID != userID {
+		return nil, ErrForbidden
+	}
+
+	// 1) старый текст уходит в историю
+	now := time.Now().UTC()
+	since := c.CreatedAt
+	if c.EditedAt != nil {
+		since = *c.EditedAt
+	}
+	rev := CommentRevision{
+		CommentID:  c.ID,
+		Body:       c.Body,
+		CreatedAt:  since,
+		ReplacedAt: now,
+	}
+	oldMentions := parseMentions(c.Body)
+	c.Body = body
+	c.EditedAt = &now
+	if err := s.comments.UpdateComment(ctx, c, rev); err != nil {
+		return nil, err
+	}
+
+	// 2) уведомляем только тех, кого упомянули впервые
+	c.Mentions = parseMentions(c.Body)
+	added := make([]string, 0, len(c.Mentions))
+	for _, email := range c.Mentions {
+		if !slices.Contains(oldMentions, email) {
+			added = append(added, email)
+		}
+	}
+	s.notifyMentioned(ctx, tsk, c, added)
+	return c, nil
+}
+
+func (s *TaskService) DeleteComment(ctx context.Context, userID, id, commentID int) error {
+	if userID <= 0 || id <= 0 || commentID <= 0 {
+		return ErrInvalidInput
+	}
+	_, role, err := s.access(ctx, userID, id, RoleViewer)
+	if err != nil {
+		return err
+	}
+	c, err := s.comments.GetComment(ctx, id, commentID)
+	if err != nil {
+		return err
+	}
+	if c.DeletedAt != nil {
+		return ErrCommentNotFound
+	}
+	if c.AuthorID != userID && !role.Allows(RoleOwner) {
+		return ErrForbidden
+	}
+	now := time.Now().UTC()
+	c.Body = ""
+	c.DeletedAt = &now
+	return s.comments.DeleteComment(ctx, c)
+}
+
+func (s *TaskService) CommentHistory(ctx context.Context, userID, id, commentID int) ([]CommentRevision, error) {
+	if userID <= 0 || id <= 0 || commentID <= 0 {
+		return nil, ErrInvalidInput
+	}
+	if _, _, err := s.access(ctx, userID, id, RoleViewer); err != nil {
+		return nil, err
+	}
+	if _, err := s.comments.GetComment(ctx, id, commentID); err != nil {
+		return nil, err
+	}
+	return s.comments.ListCommentRevisions(ctx, commentID)
+}
+
+func (s *TaskService) Activity(ctx context.Context, userID, id int) ([]Activity, error) {
+	if userID <= 0 || id <= 0 {
+		return nil, ErrInvalidInput
+	}
+	tsk, _, err := s.access(ctx, userID, id, RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	comments, err := s.comments.ListComments(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	revisions, err := s.comments.ListTaskCommentRevisions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	feed := []Activity{{Type: ActivityTaskCreated, ActorID: tsk.CreatedBy, At: tsk.CreatedAt}}
+	authors := make(map[int]int, len(comments))
+	for _, c := range comments {
+		authors[c.ID] = c.AuthorID
+		feed = append(feed, Activity{Type: ActivityCommentCreated, ActorID: c.AuthorID, CommentID: &c.ID, At: c.CreatedAt})
+		// удалить мог и owner задачи, но кто именно — не храним
+		if c.DeletedAt != nil {
+			feed = append(feed, Activity{Type: ActivityCommentDeleted, ActorID: c.AuthorID, CommentID: &c.ID, At: *c.DeletedAt})
+		}
+	}
+	// правит только автор
+	for _, rev := range revisions {
+		feed = append(feed, Activity{Type: ActivityCommentEdited, ActorID: authors[rev.CommentID], CommentID: &rev.CommentID, At: rev.ReplacedAt})
+	}
+	slices.SortStableFunc(feed, func(a, b Activity) int {
+		return a.At.Compare(b.At)
+	})
+	return feed, nil
+}
+
+// access loads the task and checks that the user has at least min on it.
+// Tasks the user can't see at all are reported as ErrNotFound, so their
+// existence isn't revealed.
+func (s *TaskService) access(ctx context.Context, userID, id int, min Role) (*Task, Role, error) {
+	tsk, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	derive(tsk, time.Now())
+
+	// личная задача принадлежит владельцу; в workspace роль даёт только
+	// членство, создатель задачи отдельных прав не имеет
+	if tsk.WorkspaceID == 0 && tsk.UserID == userID {
+		return tsk, RoleOwner, nil
+	}
+
+	// роль — максимум из личного шаринга и роли в workspace задачи
+	var role Role
+	shared, err := s.shares.GetRole(ctx, id, userID)
+	switch {
+	case err == nil:
+		role = shared
+	case !errors.Is(err, ErrNotFound):
+		return nil, "", err
+	}
+	if tsk.WorkspaceID > 0 {
+		wsRole, err := s.workspaceRole(ctx, tsk.WorkspaceID, userID)
+		switch {
+		case err == nil:
+			if wsRole.Allows(role) {
+				role = wsRole
+			}
+		case !errors.Is(err, ErrNotFound):
+			return nil, "", err
+		}
+	}
+	if role == "" {
+		return nil, "", ErrNotFound
+	}
+	if !role.Allows(min) {
+		return nil, "", ErrForbidden
+	}
+	return tsk, role, nil
+}
+
+// workspaceRole is the user's task role in the workspace; non-members get
+// ErrNotFound.
+func (s *TaskService) workspaceRole(ctx context.Context, workspaceID, userID int) (Role, error) {
+	r, err := s.workspaces.MemberRole(ctx, workspaceID, userID)
+	if err != nil {
+		if errors.Is(err, workspace.ErrNotMember) {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+	return workspaceTaskRole(r), nil
+}
+
+func (s *TaskService) notifyAssigned(ctx context.Context, tsk *Task) {
+	err := s.notifier.Notify(ctx, notify.Notification{
+		UserID:  *tsk.AssigneeID,
+		Subject: "Task assigned to you: " + tsk.Title,
+		Body:    "You are now the assignee of task #" + strconv.Itoa(tsk.ID) + " \"" + tsk.Title + "\".",
+	})
+	// назначение уже сохранено — ошибку доставки только логируем
+	if err != nil {
+		log.Println("[TASKS] notify assignee error:", err)
+	}
+}
+
+// notifyMentioned tells the mentioned collaborators about the comment.
+// Unknown emails, people who can't see the task and the author are skipped.
+func (s *TaskService) notifyMentioned(ctx context.Context, tsk *Task, c *Comment, emails []string) {
+	for _, email := range emails {
+		mentionedID, err := s.users.IDByEmail(ctx, email)
+		if err != nil {
+			if !errors.Is(err, user.ErrNotFound) {
+				log.Println("[TASKS] resolve mention error:", err)
+			}
+			continue
+		}
+		if mentionedID == c.AuthorID {
+			continue
+		}
+		if _, _, err := s.access(ctx, mentionedID, tsk.ID, RoleViewer); err != nil {
+			continue
+		}
+		err = s.notifier.Notify(ctx, notify.Notification{
+			UserID:  mentionedID,
+			Subject: "You were mentioned on task: " + tsk.Title,
+			Body:    "You were mentioned in a comment on task #" + strconv.Itoa(tsk.ID) + " \"" + tsk.Title + "\":\n\n" + c.Body,
+		})
+		if err != nil {
+			log.Println("[TASKS] notify mention error:", err)
+		}
+	}
+}
+
+func (s *TaskService) emit(ctx context.Context, ev Event) {
+	if s.observer != nil {
+		s.observer.TaskChanged(ctx, ev)
+	}
+}
+
+// validCreate checks a new task's fields.
+func validCreate(input CreateTaskInput) error {
+	if input.Title == "" {
+		return ErrInvalidInput
+	}
+	if len(input.Description) > MaxDescriptionLength {
+		return ErrDescriptionTooLong
+	}
+	if !validEstimate(input.EstimateMinutes) || !validDefer(input.DeferUntil, input.DueAt) {
+		return ErrInvalidInput
+	}
+	return nil
+}
+
+// canCreate checks that the user may add tasks to the workspace (or to
+// their personal space if workspaceID is 0): members and admins may,
+// guests may not. With RequireVerifiedEmail the email must be confirmed.
+func (s *TaskService) canCreate(ctx context.Context, userID, workspaceID int) error {
+	if userID <= 0 || workspaceID < 0 {
+		return ErrInvalidInput
+	}
+	if s.opts.RequireVerifiedEmail {
+		verified, err := s.users.IsVerified(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if !verified {
+			return ErrEmailNotVerified
+		}
+	}
+	if workspaceID == 0 {
+		return nil
+	}
+	role, err := s.workspaceRole(ctx, workspaceID, userID)
+	if err != nil {
+		return err
+	}
+	if !role.Allows(RoleEditor) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+func newTask(userID, workspaceID int, input CreateTaskInput, now time.Time) *Task {
+	t := &Task{
+		UserID:          userID,
+		WorkspaceID:     workspaceID,
+		CreatedBy:       userID,
+		Title:           input.Title,
+		Description:     input.Description,
+		DueAt:           input.DueAt,
+		DeferUntil:      input.DeferUntil,
+		EstimateMinutes: input.EstimateMinutes,
+		Status:          StatusPending,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	derive(t, now)
+	return t
+}
+
+// derive fills the fields computed from the stored ones.
+func derive(t *Task, now time.Time) {
+	t.Checklist = countChecklist(t.Description)
+	t.Overdue = t.Status == StatusPending && t.DueAt != nil && t.DueAt.Before(now)
+}
+
+func withDerived(tasks []Task) []Task {
+	now := time.Now()
+	for i := range tasks {
+		derive(&tasks[i], now)
+	}
+	return tasks
+}
+
+func validCommentBody(body string) bool {
+	return strings.TrimSpace(body) != "" && len(body) <= MaxCommentLength
+}
+
+// listFilter hides snoozed and deferred tasks unless asked not to.
+func listFilter(opts ListOptions) ListFilter {
+	f := ListFilter{Sort: opts.Sort}
+	if !opts.IncludeHidden {
+		f.VisibleAt = time.Now().UTC()
+	}
+	return f
+}
+
+// validDefer rejects a start date after the due date.
+func validDefer(deferUntil, dueAt *time.Time) bool {
+	return deferUntil == nil || dueAt == nil || !deferUntil.After(*dueAt)
+}
+
+func validEstimate(minutes *int) bool {
+	return minutes == nil || *minutes >= 0
+}
+
+func sameAssignee(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
 }
@@ -0,0 +1,95 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"task_scheduler/internal/workspace"
+	"time"
+)
+
+var (
+	ErrForbidden       = errors.New("your role on this task doesn't allow this")
+	ErrUserNotFound    = errors.New("no user with this email")
+	ErrInvalidAssignee = errors.New("assignee must be able to edit the task")
+)
+
+// Role is what a user may do with a task. The owner of a personal task is
+// always owner; in a workspace roles come from membership and shares only.
+type Role string
+
+const (
+	RoleViewer Role = "viewer"
+	RoleEditor Role = "editor"
+	RoleOwner  Role = "owner"
+)
+
+var roleRank = map[Role]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleOwner:  3,
+}
+
+func (r Role) Valid() bool {
+	_, ok := roleRank[r]
+	return ok
+}
+
+// Allows reports whether r is at least min.
+func (r Role) Allows(min Role) bool {
+	return roleRank[r] >= roleRank[min]
+}
+
+// ListScope selects which tasks List returns.
+type ListScope string
+
+const (
+	ListOwned  ListScope = "owned"
+	ListShared ListScope = "shared"
+	ListAll    ListScope = "all"
+)
+
+// Share grants a user a role on someone else's task.
+type Share struct {
+	TaskID    int       `json:"task_id"`
+	UserID    int       `json:"user_id"`
+	Email     string    `json:"email"`
+	Role      Role      `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ShareRepo interface {
+	// UpsertShare creates the share or changes the role of an existing one.
+	UpsertShare(ctx context.Context, s *Share) error
+	DeleteShare(ctx context.Context, taskID, userID int) error
+	ListShares(ctx context.Context, taskID int) ([]Share, error)
+	// GetRole returns ErrNotFound if the task isn't shared with the user.
+	GetRole(ctx context.Context, taskID, userID int) (Role, error)
+}
+
+// UserDirectory resolves share recipients and reports whether a user has
+// confirmed their email. It returns user.ErrNotFound for unknown users.
+type UserDirectory interface {
+	IDByEmail(ctx context.Context, email string) (int, error)
+	IsVerified(ctx context.Context, userID int) (bool, error)
+}
+
+// WorkspaceDirectory reports workspace membership. It returns
+// workspace.ErrNotMember for users outside the workspace.
+type WorkspaceDirectory interface {
+	MemberRole(ctx context.Context, workspaceID, userID int) (workspace.Role, error)
+	ListForUser(ctx context.Context, userID int) ([]workspace.Membership, error)
+	WIPLimits(ctx context.Context, workspaceID int) (map[string]int, error)
+}
+
+// workspaceTaskRole maps a workspace role onto what it allows on the
+// workspace's tasks.
+func workspaceTaskRole(r workspace.Role) Role {
+	switch r {
+	case workspace.RoleAdmin:
+		return RoleOwner
+	case workspace.RoleMember:
+		return RoleEditor
+	default:
+		return RoleViewer
+	}
+}
@@ -0,0 +1,239 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"task_scheduler/internal/task"
+	"time"
+)
+
+const commentColumns = `id, task_id, parent_id, author_id, body, created_at, edited_at, deleted_at`
+
+func (r *Repo) CreateComment(ctx context.Context, c *task.Comment) error {
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO task_comments (task_id, parent_id, author_id, body, created_at)
+		 VALUES (?, ?, ?, ?, ?)`,
+		c.TaskID,
+		nullInt(c.ParentID),
+		c.AuthorID,
+		c.Body,
+		c.CreatedAt.UTC().Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	c.ID = int(id)
+	return nil
+}
+
+func (r *Repo) GetComment(ctx context.Context, taskID, id int) (*task.Comment, error) {
+	row := r.db.QueryRowContext(ctx,
+		`SELECT `+commentColumns+` FROM task_comments WHERE task_id = ? AND id = ?`,
+		taskID,
+		id,
+	)
+	c, err := scanComment(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, task.ErrCommentNotFound
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+func (r *Repo) ListComments(ctx context.Context, taskID int) ([]task.Comment, error) {
+	return r.listComments(ctx, `task_id = ?`, taskID)
+}
+
+func (r *Repo) ListCommentsByAuthor(ctx context.Context, authorID int) ([]task.Comment, error) {
+	return r.listComments(ctx, `author_id = ? AND deleted_at IS NULL`, authorID)
+}
+
+func (r *Repo) listComments(ctx context.Context, where string, arg any) ([]task.Comment, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+commentColumns+`
+		 FROM task_comments
+		 WHERE `+where+`
+		 ORDER BY created_at, id`,
+		arg,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := make([]task.Comment, 0)
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, *c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+func (r *Repo) UpdateComment(ctx context.Context, c *task.Comment, rev task.CommentRevision) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO task_comment_revisions (comment_id, body, created_at, replaced_at) VALUES (?, ?, ?, ?)`,
+		rev.CommentID,
+		rev.Body,
+		rev.CreatedAt.UTC().Format(time.RFC3339Nano),
+		rev.ReplacedAt.UTC().Format(time.RFC3339Nano),
+	); err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx,
+		`UPDATE task_comments SET body = ?, edited_at = ? WHERE id = ? AND deleted_at IS NULL`,
+		c.Body,
+		nullTime(c.EditedAt),
+		c.ID,
+	)
+	if err != nil {
+		return err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if aff == 0 {
+		return task.ErrCommentNotFound
+	}
+	return tx.Commit()
+}
+
+func (r *Repo) DeleteComment(ctx context.Context, c *task.Comment) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// строка остаётся, чтобы не рвать ветку ответов
+	res, err := tx.ExecContext(ctx,
+		`UPDATE task_comments SET body = '', deleted_at = ? WHERE id = ? AND deleted_at IS NULL`,
+		nullTime(c.DeletedAt),
+		c.ID,
+	)
+	if err != nil {
+		return err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if aff == 0 {
+		return task.ErrCommentNotFound
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM task_comment_revisions WHERE comment_id = ?`, c.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *Repo) ListCommentRevisions(ctx context.Context, commentID int) ([]task.CommentRevision, error) {
+	return r.listRevisions(ctx, `comment_id = ?`, commentID)
+}
+
+func (r *Repo) ListTaskCommentRevisions(ctx context.Context, taskID int) ([]task.CommentRevision, error) {
+	return r.listRevisions(ctx, `comment_id IN (SELECT id FROM task_comments WHERE task_id = ?)`, taskID)
+}
+
+func (r *Repo) listRevisions(ctx context.Context, where string, arg any) ([]task.CommentRevision, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT comment_id, body, created_at, replaced_at
+		 FROM task_comment_revisions
+		 WHERE `+where+`
+		 ORDER BY id`,
+		arg,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := make([]task.CommentRevision, 0)
+	for rows.Next() {
+		var (
+			rev           task.CommentRevision
+			createdAtStr  string
+			replacedAtStr string
+		)
+		if err := rows.Scan(&rev.CommentID, &rev.Body, &createdAtStr, &replacedAtStr); err != nil {
+			return nil, err
+		}
+		if rev.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAtStr); err != nil {
+			return nil, err
+		}
+		if rev.ReplacedAt, err = time.Parse(time.RFC3339Nano, replacedAtStr); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func scanComment(s scanner) (*task.Comment, error) {
+	var (
+		c            task.Comment
+		parentID     sql.NullInt64
+		createdAtStr string
+		editedAt     sql.NullString
+		deletedAt    sql.NullString
+	)
+	if err := s.Scan(&c.ID, &c.TaskID, &parentID, &c.AuthorID, &c.Body, &createdAtStr, &editedAt, &deletedAt); err != nil {
+		return nil, err
+	}
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		c.ParentID = &id
+	}
+
+	var err error
+	if c.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAtStr); err != nil {
+		return nil, err
+	}
+	if c.EditedAt, err = parseNullTime(editedAt); err != nil {
+		return nil, err
+	}
+	if c.DeletedAt, err = parseNullTime(deletedAt); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func nullTime(t *time.Time) sql.NullString {
+	if t == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: t.UTC().Format(time.RFC3339Nano), Valid: true}
+}
+
+func parseNullTime(s sql.NullString) (*time.Time, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -1,6 +1,10 @@
 package sqlite
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+	"task_scheduler/internal/task"
+)
 
 func Migrate(db *sql.DB) error {
 	const schema = `
@@ -14,7 +18,168 @@ CREATE TABLE IF NOT EXISTS tasks (
   updated_at TEXT NOT NULL
 );
 
-CREATE INDEX IF NOT EXISTS idx_tasks_user_id_created_at ON tasks(user_id, created_at);`
-	_, err := db.Exec(schema)
-	return err
+CREATE INDEX IF NOT EXISTS idx_tasks_user_id_created_at ON tasks(user_id, created_at);
+
+CREATE TABLE IF NOT EXISTS task_shares (
+  task_id INTEGER NOT NULL,
+  user_id INTEGER NOT NULL,
+  email TEXT NOT NULL,
+  role TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  PRIMARY KEY (task_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_shares_user_id ON task_shares(user_id);
+
+CREATE TABLE IF NOT EXISTS task_comments (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  task_id INTEGER NOT NULL,
+  parent_id INTEGER NULL,
+  author_id INTEGER NOT NULL,
+  body TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  edited_at TEXT NULL,
+  deleted_at TEXT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_comments_task_id_created_at ON task_comments(task_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_task_comments_author_id ON task_comments(author_id);
+
+CREATE TABLE IF NOT EXISTS task_comment_revisions (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  comment_id INTEGER NOT NULL,
+  body TEXT NOT NULL,
+  created_at TEXT NOT NULL,
+  replaced_at TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_comment_revisions_comment_id ON task_comment_revisions(comment_id);`
+	if _, err := db.Exec(schema); err != nil {
+		return err
+	}
+
+	// workspace_id появился с workspaces: старые задачи остаются личными (0)
+	if _, err := addColumnIfMissing(db, "tasks", "workspace_id", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_tasks_workspace_id_created_at ON tasks(workspace_id, created_at)`); err != nil {
+		return err
+	}
+
+	// до назначений создателем был владелец
+	added, err := addColumnIfMissing(db, "tasks", "created_by", "INTEGER NOT NULL DEFAULT 0")
+	if err != nil {
+		return err
+	}
+	if added {
+		if _, err := db.Exec(`UPDATE tasks SET created_by = user_id`); err != nil {
+			return err
+		}
+	}
+	if _, err := addColumnIfMissing(db, "tasks", "assignee_id", "INTEGER NULL"); err != nil {
+		return err
+	}
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_tasks_assignee_id_created_at ON tasks(assignee_id, created_at)`); err != nil {
+		return err
+	}
+
+	if _, err := addColumnIfMissing(db, "tasks", "description", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if _, err := addColumnIfMissing(db, "tasks", "estimate_minutes", "INTEGER NULL"); err != nil {
+		return err
+	}
+	if _, err := addColumnIfMissing(db, "tasks", "defer_until", "TEXT NULL"); err != nil {
+		return err
+	}
+	if _, err := addColumnIfMissing(db, "tasks", "snoozed_until", "TEXT NULL"); err != nil {
+		return err
+	}
+	if _, err := addColumnIfMissing(db, "tasks", "parent_id", "INTEGER NULL"); err != nil {
+		return err
+	}
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_tasks_parent_id ON tasks(parent_id)`); err != nil {
+		return err
+	}
+
+	// ранги появились с ручной сортировкой: старые задачи раскладываем по
+	// порядку создания
+	added, err = addColumnIfMissing(db, "tasks", "rank", "TEXT NOT NULL DEFAULT ''")
+	if err != nil {
+		return err
+	}
+	for _, q := range []string{
+		`CREATE INDEX IF NOT EXISTS idx_tasks_user_id_rank ON tasks(user_id, workspace_id, rank)`,
+		`CREATE INDEX IF NOT EXISTS idx_tasks_workspace_id_rank ON tasks(workspace_id, rank)`,
+		`CREATE INDEX IF NOT EXISTS idx_tasks_workspace_id_status ON tasks(workspace_id, status)`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			return err
+		}
+	}
+	if added {
+		return backfillRanks(db)
+	}
+	return nil
+}
+
+func backfillRanks(db *sql.DB) error {
+	ctx := context.Background()
+	rows, err := db.QueryContext(ctx,
+		`SELECT DISTINCT CASE WHEN workspace_id = 0 THEN user_id ELSE 0 END, workspace_id FROM tasks`)
+	if err != nil {
+		return err
+	}
+	var lists []task.RankList
+	for rows.Next() {
+		var l task.RankList
+		if err := rows.Scan(&l.UserID, &l.WorkspaceID); err != nil {
+			rows.Close()
+			return err
+		}
+		lists = append(lists, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	for _, l := range lists {
+		if err := rebalance(ctx, tx, l); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// addColumnIfMissing adds a column to an existing table and reports whether it did.
+func addColumnIfMissing(db *sql.DB, table, column, definition string) (bool, error) {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return false, err
+		}
+		if name == column {
+			return false, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+
+	if _, err := db.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + definition); err != nil {
+		return false, err
+	}
+	return true, nil
 }
@@ -4,10 +4,16 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
 	"task_scheduler/internal/task"
 	"time"
 )
 
+// hiddenUntilLayout has a fixed width, so defer_until and snoozed_until
+// compare correctly as text when lists filter on them.
+const hiddenUntilLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
 type Repo struct {
 	db *sql.DB
 }
@@ -16,7 +22,37 @@ func New(db *sql.DB) *Repo {
 	return &Repo{db: db}
 }
 
-func (r *Repo) Create(ctx context.Context, t *task.Task) error {
+func (r *Repo) Create(ctx context.Context, t *task.Task, wipLimit int) error {
+	return insert(ctx, r.db, t, wipLimit)
+}
+
+// CreateTree inserts the nodes in one transaction, parents first.
+func (r *Repo) CreateTree(ctx context.Context, nodes []task.TreeNode, wipLimit int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, n := range nodes {
+		if n.Parent >= 0 {
+			n.Task.ParentID = &nodes[n.Parent].Task.ID
+		}
+		if err := insert(ctx, tx, n.Task, wipLimit); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// insert adds the task unless wipLimit > 0 and its column is full: the
+// count and the insert are one statement, so parallel requests can't both
+// take the last place.
+func insert(ctx context.Context, db execer, t *task.Task, wipLimit int) error {
 	// 1) Готовим значения для due_at: либо NULL, либо строка
 	var dueAt sql.NullString
 	if t.DueAt != nil {
@@ -24,19 +60,37 @@ func (r *Repo) Create(ctx context.Context, t *task.Task) error {
 	}
 
 	// 2) Вставляем запись
-	res, err := r.db.ExecContext(ctx,
-		`INSERT INTO tasks (user_id, title, due_at, status, created_at, updated_at)
-		 VALUES (?, ?, ?, ?, ?, ?)`,
+	res, err := db.ExecContext(ctx,
+		`INSERT INTO tasks (user_id, workspace_id, created_by, assignee_id, parent_id, title, description, due_at, defer_until, snoozed_until, rank, estimate_minutes, status, created_at, updated_at)
+		 SELECT ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+		 WHERE `+wipCondition,
 		t.UserID,
+		t.WorkspaceID,
+		t.CreatedBy,
+		nullInt(t.AssigneeID),
+		nullInt(t.ParentID),
 		t.Title,
+		t.Description,
 		dueAt,
+		hiddenUntil(t.DeferUntil),
+		hiddenUntil(t.SnoozedUntil),
+		t.Rank,
+		nullInt(t.EstimateMinutes),
 		string(t.Status),
 		t.CreatedAt.UTC().Format(time.RFC3339Nano),
 		t.UpdatedAt.UTC().Format(time.RFC3339Nano),
+		wipLimit, t.WorkspaceID, string(t.Status), 0, wipLimit,
 	)
 	if err != nil {
 		return err
 	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if aff == 0 {
+		return task.ErrWIPLimit
+	}
 
 	// 3) Забираем id, который сгенерировала БД
 	id, err := res.LastInsertId()
@@ -47,79 +101,130 @@ func (r *Repo) Create(ctx context.Context, t *task.Task) error {
 	return nil
 }
 
-func (r *Repo) Get(ctx context.Context, userID, id int) (*task.Task, error) {
-	var (
-		t            task.Task
-		dueAt        sql.NullString
-		StatusStr    string
-		createdAtStr string
-		updatedAtStr string
-	)
+const selectColumns = `id, user_id, workspace_id, created_by, assignee_id, parent_id, title, description, due_at, defer_until, snoozed_until, rank, estimate_minutes, status, created_at, updated_at`
 
-	err := r.db.QueryRowContext(ctx,
-		`SELECT id, user_id, title, due_at, status, created_at, updated_at
+func (r *Repo) Get(ctx context.Context, userID, id int) (*task.Task, error) {
+	row := r.db.QueryRowContext(ctx,
+		`SELECT `+selectColumns+`
 		 FROM tasks
 		 WHERE user_id = ? AND id = ?`,
 		userID,
 		id,
-	).Scan(
-		&t.ID,
-		&t.UserID,
-		&t.Title,
-		&dueAt,
-		&StatusStr,
-		&createdAtStr,
-		&updatedAtStr,
 	)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, task.ErrNotFound
+	return scanTaskRow(row)
+}
+
+func (r *Repo) GetByID(ctx context.Context, id int) (*task.Task, error) {
+	row := r.db.QueryRowContext(ctx,
+		`SELECT `+selectColumns+` FROM tasks WHERE id = ?`,
+		id,
+	)
+	return scanTaskRow(row)
+}
+
+func (r *Repo) List(ctx context.Context, userID int, f task.ListFilter, limit, offset int) ([]task.Task, int, error) {
+	where, args := visible(`user_id = ? AND workspace_id = 0`, []any{userID}, f)
+	return r.list(ctx, where, args, orderBy(f), limit, offset)
+}
+
+func (r *Repo) ListWorkspace(ctx context.Context, workspaceID int, f task.ListFilter, limit, offset int) ([]task.Task, int, error) {
+	where, args := visible(`workspace_id = ?`, []any{workspaceID}, f)
+	return r.list(ctx, where, args, orderBy(f), limit, offset)
+}
+
+func (r *Repo) ListAssigned(ctx context.Context, assigneeID int, workspaceIDs []int, f task.ListFilter, limit, offset int) ([]task.Task, int, error) {
+	// назначение само по себе доступа не даёт: задача должна быть своей,
+	// расшаренной или из workspace, где пользователь ещё состоит
+	access := `(workspace_id = 0 AND user_id = ?) OR id IN (SELECT task_id FROM task_shares WHERE user_id = ?)`
+	args := []any{assigneeID, assigneeID, assigneeID}
+	if len(workspaceIDs) > 0 {
+		access += ` OR workspace_id IN (?` + strings.Repeat(`, ?`, len(workspaceIDs)-1) + `)`
+		for _, id := range workspaceIDs {
+			args = append(args, id)
 		}
-		return nil, err
 	}
+	where, args := visible(`assignee_id = ? AND (`+access+`)`, args, f)
+	return r.list(ctx, where, args, orderBy(f), limit, offset)
+}
 
-	// due_at может быть NULL
-	if dueAt.Valid {
-		parsed, err := time.Parse(time.RFC3339Nano, dueAt.String)
-		if err != nil {
-			return nil, err
-		}
-		t.DueAt = &parsed
+func (r *Repo) ListInvolved(ctx context.Context, userID int, limit, offset int) ([]task.Task, int, error) {
+	where := `(user_id = ? AND workspace_id = 0) OR created_by = ? OR assignee_id = ?`
+	return r.list(ctx, where, []any{userID, userID, userID}, `created_at, id`, limit, offset)
+}
+
+func (r *Repo) ListShared(ctx context.Context, userID int, includeOwned bool, f task.ListFilter, limit, offset int) ([]task.Task, int, error) {
+	where := `id IN (SELECT task_id FROM task_shares WHERE user_id = ?)`
+	args := []any{userID}
+	if includeOwned {
+		where = `(user_id = ? AND workspace_id = 0) OR ` + where
+		args = append(args, userID)
 	}
+	where, args = visible(where, args, f)
+	return r.list(ctx, where, args, orderBy(f), limit, offset)
+}
 
-	// created_at / updated_at парсим из строк
-	createdAt, err := time.Parse(time.RFC3339Nano, createdAtStr)
-	if err != nil {
-		return nil, err
+// visible adds the ListFilter conditions to where.
+func visible(where string, args []any, f task.ListFilter) (string, []any) {
+	if f.Status != "" {
+		where = `(` + where + `) AND status = ?`
+		args = append(args, string(f.Status))
 	}
-	updatedAt, err := time.Parse(time.RFC3339Nano, updatedAtStr)
-	if err != nil {
-		return nil, err
+	if f.VisibleAt.IsZero() {
+		return where, args
 	}
-	t.CreatedAt = createdAt
-	t.UpdatedAt = updatedAt
-	// status в модели — Status (string alias)
-	t.Status = task.Status(StatusStr)
-	return &t, nil
+	at := f.VisibleAt.UTC().Format(hiddenUntilLayout)
+	where = `(` + where + `) AND (defer_until IS NULL OR defer_until <= ?) AND (snoozed_until IS NULL OR snoozed_until <= ?)`
+	return where, append(args, at, at)
+}
+
+func (r *Repo) Search(ctx context.Context, userID, workspaceID int, query string, limit, offset int) ([]task.Task, int, error) {
+	where := `((user_id = ? AND workspace_id = 0) OR id IN (SELECT task_id FROM task_shares WHERE user_id = ?))`
+	args := []any{userID, userID}
+	if workspaceID > 0 {
+		where = `workspace_id = ?`
+		args = []any{workspaceID}
+	}
+	pattern := likePattern(query)
+	where += ` AND (title LIKE ? ESCAPE '\' OR description LIKE ? ESCAPE '\' OR id IN (
+		SELECT task_id FROM task_comments WHERE deleted_at IS NULL AND body LIKE ? ESCAPE '\'))`
+	args = append(args, pattern, pattern, pattern)
+	return r.list(ctx, where, args, orderCreated, limit, offset)
+}
+
+// likePattern turns a search query into a LIKE "contains" pattern.
+func likePattern(query string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(query)
+	return "%" + escaped + "%"
+}
+
+const (
+	orderCreated = `created_at DESC`
+	orderRank    = `rank, id`
+)
+
+func orderBy(f task.ListFilter) string {
+	if f.Sort == task.SortRank {
+		return orderRank
+	}
+	return orderCreated
 }
 
-func (r *Repo) List(ctx context.Context, userID, limit, offset int) ([]task.Task, int, error) {
+// list returns one page of tasks matching where, in the given order, and the total.
+func (r *Repo) list(ctx context.Context, where string, args []any, order string, limit, offset int) ([]task.Task, int, error) {
 	// 1) Total
 	var total int
-	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM tasks WHERE user_id = ?`, userID).Scan(&total); err != nil {
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM tasks WHERE `+where, args...).Scan(&total); err != nil {
 		return nil, 0, err
 	}
 
 	// 2) Page rows
 	rows, err := r.db.QueryContext(ctx,
-		`SELECT id, user_id, title, due_at, status, created_at, updated_at
+		`SELECT `+selectColumns+`
 		 FROM tasks
-		 WHERE user_id = ?
-		 ORDER BY created_at DESC
+		 WHERE `+where+`
+		 ORDER BY `+order+`
 		 LIMIT ? OFFSET ?`,
-		userID,
-		limit,
-		offset,
+		append(args, limit, offset)...,
 	)
 	if err != nil {
 		return nil, 0, err
@@ -129,49 +234,11 @@ func (r *Repo) List(ctx context.Context, userID, limit, offset int) ([]task.Task
 	tasks := make([]task.Task, 0)
 
 	for rows.Next() {
-		var (
-			t          task.Task
-			dueAt      sql.NullString
-			createdStr string
-			updatedStr string
-			statusStr  string
-		)
-
-		if err := rows.Scan(
-			&t.ID,
-			&t.UserID,
-			&t.Title,
-			&dueAt,
-			&statusStr,
-			&createdStr,
-			&updatedStr,
-		); err != nil {
-			return nil, 0, err
-		}
-
-		// status в модели — Status (string alias)
-		t.Status = task.Status(statusStr)
-
-		if dueAt.Valid {
-			parsed, err := time.Parse(time.RFC3339Nano, dueAt.String)
-			if err != nil {
-				return nil, 0, err
-			}
-			t.DueAt = &parsed
-		}
-
-		createdAt, err := time.Parse(time.RFC3339Nano, createdStr)
-		if err != nil {
-			return nil, 0, err
-		}
-		updatedAt, err := time.Parse(time.RFC3339Nano, updatedStr)
+		t, err := scanTask(rows)
 		if err != nil {
 			return nil, 0, err
 		}
-		t.CreatedAt = createdAt
-		t.UpdatedAt = updatedAt
-
-		tasks = append(tasks, t)
+		tasks = append(tasks, *t)
 	}
 
 	// 3) rows.Err — ошибки итерации
@@ -182,13 +249,19 @@ func (r *Repo) List(ctx context.Context, userID, limit, offset int) ([]task.Task
 	return tasks, total, nil
 }
 
-func (r *Repo) Update(ctx context.Context, t *task.Task) error {
+// wipCondition holds when there is no limit (first argument 0) or the
+// workspace has fewer tasks with the status than the limit, not counting
+// the task with the given id. Arguments: limit, workspace, status, id, limit.
+const wipCondition = `(? = 0 OR (SELECT COUNT(*) FROM tasks WHERE workspace_id = ? AND status = ? AND id != ?) < ?)`
+
+func (r *Repo) Update(ctx context.Context, t *task.Task, prev time.Time, wipLimit int) error {
 	var dueAt sql.NullString
 	if t.DueAt != nil {
-		dueAt = sql.NullString{String: t.DueAt.UTC().Format(time.RFC3339Nano)}
+		dueAt = sql.NullString{String: t.DueAt.UTC().Format(time.RFC3339Nano), Valid: true}
 	}
 
-	res, err := r.db.ExecContext(ctx, `UPDATE tasks SET title = ?, due_at = ?, status = ?, updated_at = ? WHERE user_id = ? AND id = ?`, t.Title, dueAt, string(t.Status), t.UpdatedAt.UTC().Format(time.RFC3339Nano), t.UserID, t.ID)
+	res, err := r.db.ExecContext(ctx, `UPDATE tasks SET title = ?, description = ?, due_at = ?, defer_until = ?, snoozed_until = ?, estimate_minutes = ?, status = ?, assignee_id = ?, updated_at = ? WHERE user_id = ? AND id = ? AND updated_at = ? AND `+wipCondition, t.Title, t.Description, dueAt, hiddenUntil(t.DeferUntil), hiddenUntil(t.SnoozedUntil), nullInt(t.EstimateMinutes), string(t.Status), nullInt(t.AssigneeID), t.UpdatedAt.UTC().Format(time.RFC3339Nano), t.UserID, t.ID, prev.UTC().Format(time.RFC3339Nano),
+		wipLimit, t.WorkspaceID, string(t.Status), t.ID, wipLimit)
 	if err != nil {
 		return err
 	}
@@ -197,22 +270,167 @@ func (r *Repo) Update(ctx context.Context, t *task.Task) error {
 	if err != nil {
 		return err
 	}
-	if aff == 0 {
+	if aff > 0 {
+		return nil
+	}
+	// 0 строк: задачи нет, её успели изменить или колонка заполнена
+	var updatedAt string
+	err = r.db.QueryRowContext(ctx, `SELECT updated_at FROM tasks WHERE user_id = ? AND id = ?`, t.UserID, t.ID).Scan(&updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
 		return task.ErrNotFound
 	}
-	return nil
+	if err != nil {
+		return err
+	}
+	if updatedAt != prev.UTC().Format(time.RFC3339Nano) {
+		return task.ErrConflict
+	}
+	return task.ErrWIPLimit
 }
 
+// Delete removes the task with everything attached to it (see DeleteTasks).
 func (r *Repo) Delete(ctx context.Context, userID, id int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	n, err := deleteTasks(ctx, tx, `user_id = ? AND id = ?`, userID, id)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return task.ErrNotFound
+	}
+	return tx.Commit()
+}
+
+// DeleteAccountData deletes the user's personal tasks and shares and
+// detaches the user from everyone else's: tasks stay without an assignee,
+// comments stay in their thread as deleted. Workspace tasks belong to the
+// workspace and stay.
+func (r *Repo) DeleteAccountData(ctx context.Context, userID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := deleteTasks(ctx, tx, `user_id = ? AND workspace_id = 0`, userID); err != nil {
+		return err
+	}
+	for _, q := range []string{
+		`DELETE FROM task_shares WHERE user_id = ?`,
+		`UPDATE tasks SET assignee_id = NULL WHERE assignee_id = ?`,
+		`DELETE FROM task_comment_revisions WHERE comment_id IN (SELECT id FROM task_comments WHERE author_id = ?)`,
+	} {
+		if _, err := tx.ExecContext(ctx, q, userID); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE task_comments SET body = '', deleted_at = COALESCE(deleted_at, ?) WHERE author_id = ?`,
+		time.Now().UTC().Format(time.RFC3339Nano), userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// taskRows are rows of other tables that belong to a task and go away with
+// it. Attachments are purged separately, together with their blobs.
+var taskRows = []struct {
+	table string
+	query string
+}{
+	{"task_shares", `DELETE FROM task_shares WHERE task_id IN (%s)`},
+	{"task_comment_revisions", `DELETE FROM task_comment_revisions WHERE comment_id IN (SELECT id FROM task_comments WHERE task_id IN (%s))`},
+	{"task_comments", `DELETE FROM task_comments WHERE task_id IN (%s)`},
+	{"task_escalations", `DELETE FROM task_escalations WHERE task_id IN (%s)`},
+	{"automation_due_runs", `DELETE FROM automation_due_runs WHERE task_id IN (%s)`},
+	{"time_entries", `DELETE FROM time_entries WHERE task_id IN (%s)`},
+	// подзадачи остаются задачами верхнего уровня
+	{"tasks", `UPDATE tasks SET parent_id = NULL WHERE parent_id IN (%s)`},
+}
+
+// DeleteTasks deletes the tasks matching where, with their taskRows, inside
+// tx, and returns how many tasks it deleted. Deleting one task and deleting
+// an account both go through it.
+func deleteTasks(ctx context.Context, tx *sql.Tx, where string, args ...any) (int64, error) {
+	ids := `SELECT id FROM tasks WHERE ` + where
+	// таблицы других пакетов могут быть не смигрированы (например, в тестах)
+	for _, d := range taskRows {
+		exists, err := tableExists(ctx, tx, d.table)
+		if err != nil {
+			return 0, err
+		}
+		if !exists {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(d.query, ids), args...); err != nil {
+			return 0, err
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM tasks WHERE `+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func tableExists(ctx context.Context, tx *sql.Tx, table string) (bool, error) {
+	var n int
+	err := tx.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, table,
+	).Scan(&n)
+	return n > 0, err
+}
+
+// rankList is the where clause selecting the tasks of a rank list.
+func rankList(l task.RankList) (string, []any) {
+	if l.WorkspaceID > 0 {
+		return `workspace_id = ?`, []any{l.WorkspaceID}
+	}
+	return `user_id = ? AND workspace_id = 0`, []any{l.UserID}
+}
+
+// LastRank returns the highest rank in the list, "" if it has none.
+func (r *Repo) LastRank(ctx context.Context, l task.RankList) (string, error) {
+	where, args := rankList(l)
+	var rank sql.NullString
+	err := r.db.QueryRowContext(ctx, `SELECT MAX(rank) FROM tasks WHERE `+where, args...).Scan(&rank)
+	return rank.String, err
+}
+
+// AdjacentRank returns the rank right after (or before) rank in the list,
+// skipping the task skipID; "" if there is none.
+func (r *Repo) AdjacentRank(ctx context.Context, l task.RankList, rank string, after bool, skipID int) (string, error) {
+	where, args := rankList(l)
+	cmp, order := `>`, `ASC`
+	if !after {
+		cmp, order = `<`, `DESC`
+	}
+	var next string
+	err := r.db.QueryRowContext(ctx,
+		`SELECT rank FROM tasks WHERE `+where+` AND id <> ? AND rank `+cmp+` ? ORDER BY rank `+order+` LIMIT 1`,
+		append(args, skipID, rank)...,
+	).Scan(&next)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return next, err
+}
+
+func (r *Repo) SetRank(ctx context.Context, id int, rank string, at time.Time) error {
 	res, err := r.db.ExecContext(ctx,
-		`DELETE FROM tasks WHERE user_id = ? AND od = ?`,
-		userID,
+		`UPDATE tasks SET rank = ?, updated_at = ? WHERE id = ?`,
+		rank,
+		at.UTC().Format(time.RFC3339Nano),
 		id,
 	)
 	if err != nil {
 		return err
 	}
-
 	aff, err := res.RowsAffected()
 	if err != nil {
 		return err
@@ -222,3 +440,186 @@ func (r *Repo) Delete(ctx context.Context, userID, id int) error {
 	}
 	return nil
 }
+
+// Rebalance re-spaces the list's ranks evenly, keeping their order; tasks
+// without a rank go first, oldest first. updated_at is left alone: the
+// order the user sees does not change.
+func (r *Repo) Rebalance(ctx context.Context, l task.RankList) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := rebalance(ctx, tx, l); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func rebalance(ctx context.Context, tx *sql.Tx, l task.RankList) error {
+	where, args := rankList(l)
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id FROM tasks WHERE `+where+` ORDER BY rank = '' DESC, rank, created_at, id`,
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i, rank := range task.SpreadRanks(len(ids)) {
+		if _, err := tx.ExecContext(ctx, `UPDATE tasks SET rank = ? WHERE id = ?`, rank, ids[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LongRankLists returns the lists that have a rank longer than n.
+func (r *Repo) LongRankLists(ctx context.Context, n int) ([]task.RankList, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT DISTINCT CASE WHEN workspace_id = 0 THEN user_id ELSE 0 END, workspace_id
+		 FROM tasks WHERE length(rank) > ?`,
+		n,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lists []task.RankList
+	for rows.Next() {
+		var l task.RankList
+		if err := rows.Scan(&l.UserID, &l.WorkspaceID); err != nil {
+			return nil, err
+		}
+		lists = append(lists, l)
+	}
+	return lists, rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTaskRow(row *sql.Row) (*task.Task, error) {
+	t, err := scanTask(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, task.ErrNotFound
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+func scanTask(s scanner) (*task.Task, error) {
+	var (
+		t            task.Task
+		assigneeID   sql.NullInt64
+		parentID     sql.NullInt64
+		estimate     sql.NullInt64
+		dueAt        sql.NullString
+		deferUntil   sql.NullString
+		snoozedUntil sql.NullString
+		statusStr    string
+		createdAtStr string
+		updatedAtStr string
+	)
+
+	if err := s.Scan(
+		&t.ID,
+		&t.UserID,
+		&t.WorkspaceID,
+		&t.CreatedBy,
+		&assigneeID,
+		&parentID,
+		&t.Title,
+		&t.Description,
+		&dueAt,
+		&deferUntil,
+		&snoozedUntil,
+		&t.Rank,
+		&estimate,
+		&statusStr,
+		&createdAtStr,
+		&updatedAtStr,
+	); err != nil {
+		return nil, err
+	}
+
+	// status в модели — Status (string alias)
+	t.Status = task.Status(statusStr)
+
+	if assigneeID.Valid {
+		id := int(assigneeID.Int64)
+		t.AssigneeID = &id
+	}
+
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		t.ParentID = &id
+	}
+
+	if estimate.Valid {
+		minutes := int(estimate.Int64)
+		t.EstimateMinutes = &minutes
+	}
+
+	// due_at может быть NULL
+	if dueAt.Valid {
+		parsed, err := time.Parse(time.RFC3339Nano, dueAt.String)
+		if err != nil {
+			return nil, err
+		}
+		t.DueAt = &parsed
+	}
+
+	var err error
+	if t.DeferUntil, err = parseNullTime(deferUntil); err != nil {
+		return nil, err
+	}
+	if t.SnoozedUntil, err = parseNullTime(snoozedUntil); err != nil {
+		return nil, err
+	}
+
+	// created_at / updated_at парсим из строк
+	createdAt, err := time.Parse(time.RFC3339Nano, createdAtStr)
+	if err != nil {
+		return nil, err
+	}
+	updatedAt, err := time.Parse(time.RFC3339Nano, updatedAtStr)
+	if err != nil {
+		return nil, err
+	}
+	t.CreatedAt = createdAt
+	t.UpdatedAt = updatedAt
+	return &t, nil
+}
+
+func hiddenUntil(t *time.Time) sql.NullString {
+	if t == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: t.UTC().Format(hiddenUntilLayout), Valid: true}
+}
+
+func nullInt(v *int) sql.NullInt64 {
+	if v == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(*v), Valid: true}
+}
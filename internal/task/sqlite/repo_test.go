@@ -0,0 +1,186 @@
+package sqlite_test
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+
+	"task_scheduler/internal/task"
+	tasksqlite "task_scheduler/internal/task/sqlite"
+)
+
+func TestRepo_WIPLimit_HoldsUnderConcurrentWrites(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "tasks.db")+"?_pragma=busy_timeout(5000)")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	require.NoError(t, tasksqlite.Migrate(db))
+
+	ctx := t.Context()
+	repo := tasksqlite.New(db)
+	now := time.Now().UTC()
+	newTask := func(status task.Status) *task.Task {
+		return &task.Task{UserID: 1, CreatedBy: 1, WorkspaceID: 5, Title: "T", Status: status, CreatedAt: now, UpdatedAt: now}
+	}
+
+	const (
+		limit  = 3
+		writes = 10
+	)
+	// run пускает writes операций разом и считает принятые и отклонённые
+	run := func(op func(i int) error) (stored, rejected int) {
+		var (
+			wg sync.WaitGroup
+			mu sync.Mutex
+		)
+		for i := range writes {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := op(i)
+				mu.Lock()
+				defer mu.Unlock()
+				switch {
+				case err == nil:
+					stored++
+				case errors.Is(err, task.ErrWIPLimit):
+					rejected++
+				default:
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+		return stored, rejected
+	}
+
+	// 1) создание в колонку done: влезает ровно limit задач
+	stored, rejected := run(func(int) error { return repo.Create(ctx, newTask(task.StatusDone), limit) })
+	require.Equal(t, limit, stored)
+	require.Equal(t, writes-limit, rejected)
+
+	// 2) перенос из pending в canceled упирается в тот же лимит
+	pending := make([]*task.Task, writes)
+	for i := range pending {
+		pending[i] = newTask(task.StatusPending)
+		require.NoError(t, repo.Create(ctx, pending[i], 0))
+	}
+	stored, rejected = run(func(i int) error {
+		tsk := *pending[i]
+		tsk.Status = task.StatusCanceled
+		return repo.Update(ctx, &tsk, tsk.UpdatedAt, limit)
+	})
+	require.Equal(t, limit, stored)
+	require.Equal(t, writes-limit, rejected)
+
+	// 3) правка задачи, которая уже в полной колонке, сама себя не считает
+	var id int
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT id FROM tasks WHERE status = 'canceled' LIMIT 1`).Scan(&id))
+	kept, err := repo.Get(ctx, 1, id)
+	require.NoError(t, err)
+	kept.Title = "renamed"
+	require.NoError(t, repo.Update(ctx, kept, kept.UpdatedAt, limit))
+
+	// 4) личные задачи и чужие воркспейсы лимит не задевает, а удалённая
+	// задача остаётся ErrNotFound
+	personal := newTask(task.StatusDone)
+	personal.WorkspaceID = 0
+	require.NoError(t, repo.Create(ctx, personal, 0))
+	other := newTask(task.StatusDone)
+	other.WorkspaceID = 6
+	require.NoError(t, repo.Create(ctx, other, limit))
+	missing := newTask(task.StatusDone)
+	missing.ID = 100000
+	require.ErrorIs(t, repo.Update(ctx, missing, missing.UpdatedAt, limit), task.ErrNotFound)
+}
+
+func TestRepo_Update_RejectsStaleVersion(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "tasks.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	require.NoError(t, tasksqlite.Migrate(db))
+
+	ctx := t.Context()
+	repo := tasksqlite.New(db)
+	read := time.Now().UTC()
+	tsk := &task.Task{UserID: 1, CreatedBy: 1, Title: "T", Status: task.StatusPending, CreatedAt: read, UpdatedAt: read}
+	require.NoError(t, repo.Create(ctx, tsk, 0))
+
+	// первый писатель сохраняет правку поверх прочитанной версии
+	first := *tsk
+	first.Title = "first"
+	first.UpdatedAt = read.Add(time.Second)
+	require.NoError(t, repo.Update(ctx, &first, read, 0))
+
+	// второй читал ту же версию — его запись отклоняется, а не затирает первую
+	second := *tsk
+	second.Title = "second"
+	second.UpdatedAt = read.Add(2 * time.Second)
+	require.ErrorIs(t, repo.Update(ctx, &second, read, 0), task.ErrConflict)
+
+	stored, err := repo.Get(ctx, 1, tsk.ID)
+	require.NoError(t, err)
+	require.Equal(t, "first", stored.Title)
+}
+
+func TestRepo_DeleteAccountData_KeepsWorkspaceTasks(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "tasks.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	require.NoError(t, tasksqlite.Migrate(db))
+
+	ctx := t.Context()
+	repo := tasksqlite.New(db)
+	now := time.Now().UTC()
+	const (
+		gone  = 1
+		stays = 2
+	)
+
+	newTask := func(owner, workspaceID int) *task.Task {
+		tsk := &task.Task{UserID: owner, WorkspaceID: workspaceID, CreatedBy: owner, Title: "T", Status: task.StatusPending, CreatedAt: now, UpdatedAt: now}
+		require.NoError(t, repo.Create(ctx, tsk, 0))
+		return tsk
+	}
+	personal := newTask(gone, 0)
+	team := newTask(gone, 7)
+	othersTask := newTask(stays, 0)
+	assignee := gone
+	othersTask.AssigneeID = &assignee
+	require.NoError(t, repo.Update(ctx, othersTask, othersTask.UpdatedAt, 0))
+
+	// на личной задаче — шара и комментарий другого пользователя; на чужой — свой комментарий
+	require.NoError(t, repo.UpsertShare(ctx, &task.Share{TaskID: personal.ID, UserID: stays, Email: "stays@example.com", Role: task.RoleViewer, CreatedAt: now}))
+	require.NoError(t, repo.CreateComment(ctx, &task.Comment{TaskID: personal.ID, AuthorID: stays, Body: "hi", CreatedAt: now}))
+	require.NoError(t, repo.CreateComment(ctx, &task.Comment{TaskID: othersTask.ID, AuthorID: gone, Body: "mine", CreatedAt: now}))
+
+	require.NoError(t, repo.DeleteAccountData(ctx, gone))
+	// повтор после сбоя ничего не ломает
+	require.NoError(t, repo.DeleteAccountData(ctx, gone))
+
+	_, err = repo.GetByID(ctx, personal.ID)
+	require.ErrorIs(t, err, task.ErrNotFound)
+	kept, err := repo.GetByID(ctx, team.ID)
+	require.NoError(t, err)
+	require.Equal(t, gone, kept.CreatedBy)
+	detached, err := repo.GetByID(ctx, othersTask.ID)
+	require.NoError(t, err)
+	require.Nil(t, detached.AssigneeID)
+
+	for _, q := range []string{
+		`SELECT COUNT(*) FROM task_shares WHERE task_id = ?`,
+		`SELECT COUNT(*) FROM task_comments WHERE task_id = ?`,
+	} {
+		var n int
+		require.NoError(t, db.QueryRow(q, personal.ID).Scan(&n))
+		require.Zero(t, n, q)
+	}
+	var body string
+	require.NoError(t, db.QueryRow(`SELECT body FROM task_comments WHERE task_id = ?`, othersTask.ID).Scan(&body))
+	require.Empty(t, body)
+}
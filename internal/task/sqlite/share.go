@@ -0,0 +1,93 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"task_scheduler/internal/task"
+	"time"
+)
+
+func (r *Repo) UpsertShare(ctx context.Context, s *task.Share) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO task_shares (task_id, user_id, email, role, created_at)
+		 VALUES (?, ?, ?, ?, ?)
+		 ON CONFLICT (task_id, user_id) DO UPDATE SET role = excluded.role`,
+		s.TaskID,
+		s.UserID,
+		s.Email,
+		string(s.Role),
+		s.CreatedAt.UTC().Format(time.RFC3339Nano),
+	)
+	return err
+}
+
+func (r *Repo) DeleteShare(ctx context.Context, taskID, userID int) error {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM task_shares WHERE task_id = ? AND user_id = ?`,
+		taskID,
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if aff == 0 {
+		return task.ErrNotFound
+	}
+	return nil
+}
+
+func (r *Repo) ListShares(ctx context.Context, taskID int) ([]task.Share, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT task_id, user_id, email, role, created_at
+		 FROM task_shares
+		 WHERE task_id = ?
+		 ORDER BY created_at`,
+		taskID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := make([]task.Share, 0)
+	for rows.Next() {
+		var (
+			s            task.Share
+			role         string
+			createdAtStr string
+		)
+		if err := rows.Scan(&s.TaskID, &s.UserID, &s.Email, &role, &createdAtStr); err != nil {
+			return nil, err
+		}
+		s.Role = task.Role(role)
+		if s.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAtStr); err != nil {
+			return nil, err
+		}
+		shares = append(shares, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return shares, nil
+}
+
+func (r *Repo) GetRole(ctx context.Context, taskID, userID int) (task.Role, error) {
+	var role string
+	err := r.db.QueryRowContext(ctx,
+		`SELECT role FROM task_shares WHERE task_id = ? AND user_id = ?`,
+		taskID,
+		userID,
+	).Scan(&role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", task.ErrNotFound
+		}
+		return "", err
+	}
+	return task.Role(role), nil
+}
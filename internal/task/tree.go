@@ -0,0 +1,17 @@
+package task
+
+// MaxTreeSize caps how many tasks CreateTree makes at once.
+const MaxTreeSize = 200
+
+// TreeInput is a task to create together with its subtasks.
+type TreeInput struct {
+	CreateTaskInput
+	Children []TreeInput
+}
+
+// TreeNode is one task of a tree being stored. Parent is the index of its
+// parent among the nodes, -1 for a root; parents come before children.
+type TreeNode struct {
+	Task   *Task
+	Parent int
+}
@@ -7,8 +7,30 @@ type OptionalTime struct {
 	Value *time.Time
 }
 
+// OptionalInt tells "not sent" (Set == false) apart from an explicit null.
+type OptionalInt struct {
+	Set   bool
+	Value *int
+}
+
+type CreateTaskInput struct {
+	Title       string
+	Description string
+	DueAt       *time.Time
+	DeferUntil  *time.Time
+	// EstimateMinutes is optional; negative values are rejected.
+	EstimateMinutes *int
+}
+
 type UpdateTaskInput struct {
-	Title  *string
-	Status *string
-	DueAt  OptionalTime
+	Title           *string
+	Description     *string
+	Status          *string
+	DueAt           OptionalTime
+	DeferUntil      OptionalTime
+	AssigneeID      OptionalInt
+	EstimateMinutes OptionalInt
+	// IfUpdatedAt, when set, makes the update fail with ErrConflict unless
+	// the task is still at that version.
+	IfUpdatedAt *time.Time
 }
@@ -0,0 +1,143 @@
+// Package template keeps reusable task trees that are turned into real
+// tasks on demand, with dates relative to an anchor.
+package template
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"task_scheduler/internal/task"
+	"time"
+)
+
+var ErrInvalidTemplate = errors.New("invalid template")
+
+const (
+	maxNameLen = 100
+	maxDepth   = 5
+	maxOffset  = 5 * 366 * 24 * time.Hour
+	maxDescLen = 2000
+)
+
+// Template is personal (WorkspaceID 0) or shared by a workspace; UserID is
+// who made it.
+type Template struct {
+	ID          int       `json:"id"`
+	UserID      int       `json:"user_id"`
+	WorkspaceID int       `json:"workspace_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Items       []Item    `json:"items"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Item is a task of the template with its subtasks. Offsets are durations
+// from the anchor ("72h", "-24h"); empty means no date.
+type Item struct {
+	Title           string `json:"title"`
+	Description     string `json:"description,omitempty"`
+	DueOffset       string `json:"due_offset,omitempty"`
+	DeferOffset     string `json:"defer_offset,omitempty"`
+	EstimateMinutes *int   `json:"estimate_minutes,omitempty"`
+	Children        []Item `json:"children,omitempty"`
+}
+
+// validate normalizes the template and reports the first problem.
+func (t *Template) validate() error {
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" || len(t.Name) > maxNameLen {
+		return fmt.Errorf("%w: name is required, up to %d characters", ErrInvalidTemplate, maxNameLen)
+	}
+	if len(t.Description) > maxDescLen {
+		return fmt.Errorf("%w: description is up to %d characters", ErrInvalidTemplate, maxDescLen)
+	}
+	if len(t.Items) == 0 {
+		return fmt.Errorf("%w: at least one item is required", ErrInvalidTemplate)
+	}
+	count := 0
+	var walk func(items []Item, depth int) error
+	walk = func(items []Item, depth int) error {
+		if depth > maxDepth {
+			return fmt.Errorf("%w: items nest at most %d levels", ErrInvalidTemplate, maxDepth)
+		}
+		for i := range items {
+			count++
+			if count > task.MaxTreeSize {
+				return fmt.Errorf("%w: at most %d items", ErrInvalidTemplate, task.MaxTreeSize)
+			}
+			if err := items[i].validate(); err != nil {
+				return err
+			}
+			if err := walk(items[i].Children, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(t.Items, 1)
+}
+
+func (it *Item) validate() error {
+	it.Title = strings.TrimSpace(it.Title)
+	if it.Title == "" {
+		return fmt.Errorf("%w: every item needs a title", ErrInvalidTemplate)
+	}
+	if len(it.Description) > task.MaxDescriptionLength {
+		return fmt.Errorf("%w: item %q: %v", ErrInvalidTemplate, it.Title, task.ErrDescriptionTooLong)
+	}
+	if it.EstimateMinutes != nil && *it.EstimateMinutes < 0 {
+		return fmt.Errorf("%w: item %q: estimate_minutes must be >= 0", ErrInvalidTemplate, it.Title)
+	}
+	due, hasDue, err := parseOffset(it.DueOffset)
+	if err != nil {
+		return fmt.Errorf("%w: item %q: due_offset must be a duration like 72h", ErrInvalidTemplate, it.Title)
+	}
+	start, hasStart, err := parseOffset(it.DeferOffset)
+	if err != nil {
+		return fmt.Errorf("%w: item %q: defer_offset must be a duration like 24h", ErrInvalidTemplate, it.Title)
+	}
+	if hasDue && hasStart && start > due {
+		return fmt.Errorf("%w: item %q: defer_offset is after due_offset", ErrInvalidTemplate, it.Title)
+	}
+	return nil
+}
+
+func parseOffset(s string) (time.Duration, bool, error) {
+	if s == "" {
+		return 0, false, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, false, err
+	}
+	if d > maxOffset || d < -maxOffset {
+		return 0, false, errors.New("offset out of range")
+	}
+	return d, true, nil
+}
+
+// tree turns the items into task inputs dated from anchor.
+func tree(items []Item, anchor time.Time) []task.TreeInput {
+	out := make([]task.TreeInput, 0, len(items))
+	for _, it := range items {
+		in := task.TreeInput{
+			CreateTaskInput: task.CreateTaskInput{
+				Title:           it.Title,
+				Description:     it.Description,
+				EstimateMinutes: it.EstimateMinutes,
+			},
+			Children: tree(it.Children, anchor),
+		}
+		if d, ok, _ := parseOffset(it.DueOffset); ok {
+			due := anchor.Add(d)
+			in.DueAt = &due
+		}
+		if d, ok, _ := parseOffset(it.DeferOffset); ok {
+			start := anchor.Add(d)
+			in.DeferUntil = &start
+		}
+		out = append(out, in)
+	}
+	return out
+}
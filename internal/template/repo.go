@@ -0,0 +1,19 @@
+package template
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrNotFound = errors.New("template not found")
+
+type Repo interface {
+	Create(ctx context.Context, t *Template) error
+	Get(ctx context.Context, id int) (*Template, error)
+	// List and Count cover the workspace's templates, or the user's
+	// personal ones if workspaceID is 0.
+	List(ctx context.Context, userID, workspaceID int) ([]Template, error)
+	Count(ctx context.Context, userID, workspaceID int) (int, error)
+	Update(ctx context.Context, t *Template) error
+	Delete(ctx context.Context, id int) error
+}
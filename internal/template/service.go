@@ -0,0 +1,211 @@
+package template
+
+import (
+	"context"
+	"errors"
+	"task_scheduler/internal/task"
+	"task_scheduler/internal/workspace"
+	"time"
+)
+
+var (
+	ErrInvalidInput     = errors.New("invalid input")
+	ErrTooManyTemplates = errors.New("too many templates")
+	ErrForbidden        = errors.New("forbidden")
+)
+
+// maxTemplates bounds the templates of one personal space or workspace.
+const maxTemplates = 100
+
+// Input is what the user sends to create or replace a template.
+type Input struct {
+	Name        string
+	Description string
+	Items       []Item
+}
+
+// Tasks is the part of the task service that instantiation needs.
+type Tasks interface {
+	CreateTree(ctx context.Context, userID, workspaceID int, roots []task.TreeInput) ([]task.Task, error)
+}
+
+// Workspaces reports workspace membership. It returns workspace.ErrNotMember
+// for users outside the workspace.
+type Workspaces interface {
+	MemberRole(ctx context.Context, workspaceID, userID int) (workspace.Role, error)
+}
+
+// Service keeps personal templates for their author and workspace templates
+// for the workspace: every member may use them, admins and members may
+// change them, guests only read.
+type Service interface {
+	// Create adds a template to the user's personal space, or to the
+	// workspace if workspaceID isn't 0.
+	Create(ctx context.Context, userID, workspaceID int, in Input) (*Template, error)
+	List(ctx context.Context, userID, workspaceID int) ([]Template, error)
+	Get(ctx context.Context, userID, id int) (*Template, error)
+	// Update replaces the template.
+	Update(ctx context.Context, userID, id int, in Input) (*Template, error)
+	Delete(ctx context.Context, userID, id int) error
+	// Instantiate creates the template's tasks in the workspace (0 for the
+	// personal space), all or none, with dates counted from anchor.
+	Instantiate(ctx context.Context, userID, id, workspaceID int, anchor time.Time) ([]task.Task, error)
+}
+
+type templateService struct {
+	repo       Repo
+	tasks      Tasks
+	workspaces Workspaces
+}
+
+func NewService(repo Repo, tasks Tasks, workspaces Workspaces) Service {
+	return &templateService{repo: repo, tasks: tasks, workspaces: workspaces}
+}
+
+func (s *templateService) Create(ctx context.Context, userID, workspaceID int, in Input) (*Template, error) {
+	if userID <= 0 || workspaceID < 0 {
+		return nil, ErrInvalidInput
+	}
+	if err := s.canWrite(ctx, userID, workspaceID); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	t := &Template{
+		UserID:      userID,
+		WorkspaceID: workspaceID,
+		Name:        in.Name,
+		Description: in.Description,
+		Items:       in.Items,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+	n, err := s.repo.Count(ctx, userID, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	if n >= maxTemplates {
+		return nil, ErrTooManyTemplates
+	}
+	if err := s.repo.Create(ctx, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (s *templateService) List(ctx context.Context, userID, workspaceID int) ([]Template, error) {
+	if userID <= 0 || workspaceID < 0 {
+		return nil, ErrInvalidInput
+	}
+	if workspaceID > 0 {
+		if _, err := s.memberRole(ctx, userID, workspaceID); err != nil {
+			return nil, err
+		}
+	}
+	return s.repo.List(ctx, userID, workspaceID)
+}
+
+func (s *templateService) Get(ctx context.Context, userID, id int) (*Template, error) {
+	if userID <= 0 || id <= 0 {
+		return nil, ErrInvalidInput
+	}
+	return s.get(ctx, userID, id)
+}
+
+func (s *templateService) Update(ctx context.Context, userID, id int, in Input) (*Template, error) {
+	if userID <= 0 || id <= 0 {
+		return nil, ErrInvalidInput
+	}
+	t, err := s.get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.canWrite(ctx, userID, t.WorkspaceID); err != nil {
+		return nil, err
+	}
+	t.Name = in.Name
+	t.Description = in.Description
+	t.Items = in.Items
+	t.UpdatedAt = time.Now().UTC()
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(ctx, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (s *templateService) Delete(ctx context.Context, userID, id int) error {
+	if userID <= 0 || id <= 0 {
+		return ErrInvalidInput
+	}
+	t, err := s.get(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if err := s.canWrite(ctx, userID, t.WorkspaceID); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+func (s *templateService) Instantiate(ctx context.Context, userID, id, workspaceID int, anchor time.Time) ([]task.Task, error) {
+	if userID <= 0 || id <= 0 || workspaceID < 0 || anchor.IsZero() {
+		return nil, ErrInvalidInput
+	}
+	t, err := s.get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	// куда можно создавать задачи, решает сервис задач
+	return s.tasks.CreateTree(ctx, userID, workspaceID, tree(t.Items, anchor.UTC()))
+}
+
+// get loads a template the user may see; others' personal templates and
+// those of workspaces the user isn't in are ErrNotFound.
+func (s *templateService) get(ctx context.Context, userID, id int) (*Template, error) {
+	t, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if t.WorkspaceID == 0 {
+		if t.UserID != userID {
+			return nil, ErrNotFound
+		}
+		return t, nil
+	}
+	if _, err := s.memberRole(ctx, userID, t.WorkspaceID); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// canWrite checks that the user may change templates of the workspace (or
+// of their personal space if workspaceID is 0): guests may not.
+func (s *templateService) canWrite(ctx context.Context, userID, workspaceID int) error {
+	if workspaceID == 0 {
+		return nil
+	}
+	role, err := s.memberRole(ctx, userID, workspaceID)
+	if err != nil {
+		return err
+	}
+	if role == workspace.RoleGuest {
+		return ErrForbidden
+	}
+	return nil
+}
+
+func (s *templateService) memberRole(ctx context.Context, userID, workspaceID int) (workspace.Role, error) {
+	role, err := s.workspaces.MemberRole(ctx, workspaceID, userID)
+	if err != nil {
+		if errors.Is(err, workspace.ErrNotMember) {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+	return role, nil
+}
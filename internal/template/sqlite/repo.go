@@ -0,0 +1,177 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"task_scheduler/internal/template"
+	"time"
+)
+
+type Repo struct {
+	db *sql.DB
+}
+
+func New(db *sql.DB) *Repo {
+	return &Repo{db: db}
+}
+
+const selectColumns = `id, user_id, workspace_id, name, description, items, created_at, updated_at`
+
+// Create stores the item tree as one JSON column.
+func (r *Repo) Create(ctx context.Context, t *template.Template) error {
+	items, err := json.Marshal(t.Items)
+	if err != nil {
+		return err
+	}
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO task_templates (user_id, workspace_id, name, description, items, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		t.UserID,
+		t.WorkspaceID,
+		t.Name,
+		t.Description,
+		string(items),
+		t.CreatedAt.UTC().Format(time.RFC3339Nano),
+		t.UpdatedAt.UTC().Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	t.ID = int(id)
+	return nil
+}
+
+func (r *Repo) Get(ctx context.Context, id int) (*template.Template, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+selectColumns+` FROM task_templates WHERE id = ?`, id)
+	t, err := scanTemplate(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, template.ErrNotFound
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+func (r *Repo) List(ctx context.Context, userID, workspaceID int) ([]template.Template, error) {
+	where, args := spaceFilter(userID, workspaceID)
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+selectColumns+` FROM task_templates WHERE `+where+` ORDER BY name, id`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]template.Template, 0)
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *Repo) Count(ctx context.Context, userID, workspaceID int) (int, error) {
+	where, args := spaceFilter(userID, workspaceID)
+	var n int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM task_templates WHERE `+where, args...).Scan(&n)
+	return n, err
+}
+
+// spaceFilter selects the workspace's templates, or the user's personal ones
+// if workspaceID is 0.
+func spaceFilter(userID, workspaceID int) (string, []any) {
+	if workspaceID > 0 {
+		return `workspace_id = ?`, []any{workspaceID}
+	}
+	return `user_id = ? AND workspace_id = 0`, []any{userID}
+}
+
+func (r *Repo) Update(ctx context.Context, t *template.Template) error {
+	items, err := json.Marshal(t.Items)
+	if err != nil {
+		return err
+	}
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE task_templates SET name = ?, description = ?, items = ?, updated_at = ? WHERE id = ?`,
+		t.Name,
+		t.Description,
+		string(items),
+		t.UpdatedAt.UTC().Format(time.RFC3339Nano),
+		t.ID,
+	)
+	if err != nil {
+		return err
+	}
+	return requireAffected(res)
+}
+
+func (r *Repo) Delete(ctx context.Context, id int) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM task_templates WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(res)
+}
+
+// DeleteAccountData deletes the user's personal templates when the account
+// goes; the ones they made in workspaces stay with the workspace.
+func (r *Repo) DeleteAccountData(ctx context.Context, userID int) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM task_templates WHERE user_id = ? AND workspace_id = 0`, userID)
+	return err
+}
+
+func requireAffected(res sql.Result) error {
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if aff == 0 {
+		return template.ErrNotFound
+	}
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTemplate(s scanner) (*template.Template, error) {
+	var (
+		t            template.Template
+		items        string
+		createdAtStr string
+		updatedAtStr string
+	)
+	if err := s.Scan(&t.ID, &t.UserID, &t.WorkspaceID, &t.Name, &t.Description, &items, &createdAtStr, &updatedAtStr); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(items), &t.Items); err != nil {
+		return nil, err
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, createdAtStr)
+	if err != nil {
+		return nil, err
+	}
+	t.CreatedAt = createdAt
+	updatedAt, err := time.Parse(time.RFC3339Nano, updatedAtStr)
+	if err != nil {
+		return nil, err
+	}
+	t.UpdatedAt = updatedAt
+	return &t, nil
+}
@@ -0,0 +1,73 @@
+package timetrack
+
+import "time"
+
+type Source string
+
+const (
+	SourceTimer  Source = "timer"
+	SourceManual Source = "manual"
+)
+
+// Entry is time a user spent on a task. A timer entry has no EndedAt while
+// it runs; a user has at most one running timer.
+type Entry struct {
+	ID        int        `json:"id"`
+	TaskID    int        `json:"task_id"`
+	UserID    int        `json:"user_id"`
+	Source    Source     `json:"source"`
+	Note      string     `json:"note"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at"`
+	// Seconds is derived: up to EndedAt, or up to now while running.
+	Seconds   int64     `json:"duration_seconds"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ReportEntry is an entry with the task fields a report groups by.
+type ReportEntry struct {
+	Entry
+	TaskTitle       string
+	WorkspaceID     int
+	EstimateMinutes *int
+}
+
+type GroupBy string
+
+const (
+	GroupByDay       GroupBy = "day"
+	GroupByTask      GroupBy = "task"
+	GroupByWorkspace GroupBy = "workspace"
+	GroupByUser      GroupBy = "user"
+)
+
+// ReportQuery selects the caller's entries. From and To are inclusive dates
+// (YYYY-MM-DD) in Timezone; empty means the current month so far and UTC.
+// WorkspaceID limits the report to one workspace (0 is the personal space).
+type ReportQuery struct {
+	From        string
+	To          string
+	Timezone    string
+	GroupBy     GroupBy
+	WorkspaceID *int
+}
+
+// ReportRow sums one group. EstimatedSeconds adds up the estimates of the
+// distinct tasks worked on in the group, so a task tracked on several days
+// counts its estimate on each of them.
+type ReportRow struct {
+	Key              string `json:"key"`
+	Title            string `json:"title,omitempty"`
+	Tasks            int    `json:"tasks"`
+	TrackedSeconds   int64  `json:"tracked_seconds"`
+	EstimatedSeconds int64  `json:"estimated_seconds"`
+}
+
+type Report struct {
+	From     string      `json:"from"`
+	To       string      `json:"to"`
+	Timezone string      `json:"timezone"`
+	GroupBy  GroupBy     `json:"group_by"`
+	Rows     []ReportRow `json:"rows"`
+	Total    ReportRow   `json:"total"`
+}
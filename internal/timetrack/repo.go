@@ -0,0 +1,36 @@
+package timetrack
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("time entry not found")
+	// ErrOverlap is returned by Repo.Create when the user already has time
+	// recorded in the entry's interval.
+	ErrOverlap = errors.New("time entry overlaps another entry")
+)
+
+type Repo interface {
+	// Create inserts the entry unless it overlaps another entry of the same
+	// user on an existing task; a running entry is open-ended.
+	Create(ctx context.Context, e *Entry) error
+	// Get returns ErrNotFound unless the entry belongs to the task.
+	Get(ctx context.Context, taskID, id int) (*Entry, error)
+	List(ctx context.Context, taskID int) ([]Entry, error)
+	// ListByUser returns all of the user's entries on existing tasks.
+	ListByUser(ctx context.Context, userID int) ([]Entry, error)
+	// Running returns the user's running timer on an existing task, or ErrNotFound.
+	Running(ctx context.Context, userID int) (*Entry, error)
+	// Stop ends a running entry; ErrNotFound if it isn't running.
+	Stop(ctx context.Context, id int, endedAt time.Time) error
+	Delete(ctx context.Context, id int) error
+	// ListForReport returns the user's entries on existing tasks that overlap
+	// [from, to), optionally only in one workspace.
+	ListForReport(ctx context.Context, userID int, workspaceID *int, from, to time.Time) ([]ReportEntry, error)
+	// ListWorkspaceForReport is ListForReport for the entries of all users
+	// on the workspace's tasks.
+	ListWorkspaceForReport(ctx context.Context, workspaceID int, from, to time.Time) ([]ReportEntry, error)
+}
@@ -0,0 +1,125 @@
+package timetrack
+
+import (
+	"cmp"
+	"encoding/csv"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reportBuilder sums tracked time per group and remembers which tasks each
+// group touched, so every task's estimate is counted once per group.
+type reportBuilder struct {
+	groups   map[string]*reportGroup
+	allTasks map[int]*int
+}
+
+type reportGroup struct {
+	title   string
+	tracked time.Duration
+	tasks   map[int]*int
+}
+
+func newReportBuilder() *reportBuilder {
+	return &reportBuilder{
+		groups:   make(map[string]*reportGroup),
+		allTasks: make(map[int]*int),
+	}
+}
+
+func (b *reportBuilder) add(key, title string, e ReportEntry, d time.Duration) {
+	g, ok := b.groups[key]
+	if !ok {
+		g = &reportGroup{title: title, tasks: make(map[int]*int)}
+		b.groups[key] = g
+	}
+	g.tracked += d
+	g.tasks[e.TaskID] = e.EstimateMinutes
+	b.allTasks[e.TaskID] = e.EstimateMinutes
+}
+
+// rows orders days chronologically and tasks, workspaces and users by id.
+func (b *reportBuilder) rows(groupBy GroupBy) []ReportRow {
+	rows := make([]ReportRow, 0, len(b.groups))
+	for key, g := range b.groups {
+		rows = append(rows, ReportRow{
+			Key:              key,
+			Title:            g.title,
+			Tasks:            len(g.tasks),
+			TrackedSeconds:   int64(g.tracked / time.Second),
+			EstimatedSeconds: estimatedSeconds(g.tasks),
+		})
+	}
+	slices.SortFunc(rows, func(a, b ReportRow) int {
+		if groupBy == GroupByDay {
+			return strings.Compare(a.Key, b.Key)
+		}
+		ai, _ := strconv.Atoi(a.Key)
+		bi, _ := strconv.Atoi(b.Key)
+		return cmp.Compare(ai, bi)
+	})
+	return rows
+}
+
+func (b *reportBuilder) total() ReportRow {
+	var tracked time.Duration
+	for _, g := range b.groups {
+		tracked += g.tracked
+	}
+	return ReportRow{
+		Key:              "total",
+		Tasks:            len(b.allTasks),
+		TrackedSeconds:   int64(tracked / time.Second),
+		EstimatedSeconds: estimatedSeconds(b.allTasks),
+	}
+}
+
+func estimatedSeconds(tasks map[int]*int) int64 {
+	var total int64
+	for _, minutes := range tasks {
+		if minutes != nil {
+			total += int64(*minutes) * 60
+		}
+	}
+	return total
+}
+
+// WriteCSV writes the report rows followed by the total, with hours as
+// decimals for billing.
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	header := []string{string(r.GroupBy), "title", "tasks", "tracked_seconds", "estimated_seconds", "tracked_hours", "estimated_hours"}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, row := range append(slices.Clone(r.Rows), r.Total) {
+		if err := cw.Write([]string{
+			row.Key,
+			csvSafe(row.Title),
+			strconv.Itoa(row.Tasks),
+			strconv.FormatInt(row.TrackedSeconds, 10),
+			strconv.FormatInt(row.EstimatedSeconds, 10),
+			hours(row.TrackedSeconds),
+			hours(row.EstimatedSeconds),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func hours(seconds int64) string {
+	return strconv.FormatFloat(float64(seconds)/3600, 'f', 2, 64)
+}
+
+// csvSafe defuses cells that spreadsheets would run as formulas.
+func csvSafe(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
@@ -0,0 +1,345 @@
+package timetrack
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"task_scheduler/internal/task"
+	"task_scheduler/internal/workspace"
+	"time"
+)
+
+var (
+	ErrInvalidInput = errors.New("invalid input")
+	ErrTimerRunning = errors.New("another timer is already running")
+	ErrNoTimer      = errors.New("no timer is running on this task")
+)
+
+const (
+	// MaxNoteLength caps the note of an entry, in bytes.
+	MaxNoteLength = 1000
+	// maxManualEntry bounds one manual entry; longer work is several entries.
+	maxManualEntry = 24 * time.Hour
+	// maxReportDays bounds the report range.
+	maxReportDays = 366
+)
+
+const dateLayout = "2006-01-02"
+
+type Service interface {
+	// Start runs a timer on the task. A user has one running timer at a time.
+	Start(ctx context.Context, userID, taskID int, note string) (*Entry, error)
+	// Stop ends the user's timer on the task. It needs no role on the task,
+	// so a timer never outlives the access it was started with.
+	Stop(ctx context.Context, userID, taskID int) (*Entry, error)
+	// Add records finished work after the fact. It must not overlap the
+	// user's other entries, running timer included.
+	Add(ctx context.Context, userID, taskID int, startedAt, endedAt time.Time, note string) (*Entry, error)
+	List(ctx context.Context, userID, taskID int) ([]Entry, error)
+	// Delete is allowed to the entry's author and to task owners.
+	Delete(ctx context.Context, userID, taskID, id int) error
+	// Report sums the user's tracked time against task estimates.
+	Report(ctx context.Context, userID int, q ReportQuery) (*Report, error)
+	// WorkspaceReport sums the time tracked on the workspace's tasks: by all
+	// members for its admins, by the user themselves for everyone else.
+	// q.WorkspaceID is ignored.
+	WorkspaceReport(ctx context.Context, userID, workspaceID int, q ReportQuery) (*Report, error)
+}
+
+// Tasks reports the caller's role on a task; task.ErrNotFound if none.
+type Tasks interface {
+	Role(ctx context.Context, userID, taskID int) (task.Role, error)
+}
+
+// Workspaces reports workspace membership. It returns workspace.ErrNotMember
+// for users outside the workspace.
+type Workspaces interface {
+	MemberRole(ctx context.Context, workspaceID, userID int) (workspace.Role, error)
+}
+
+type timeService struct {
+	repo       Repo
+	tasks      Tasks
+	workspaces Workspaces
+	now        func() time.Time
+}
+
+func NewService(repo Repo, tasks Tasks, workspaces Workspaces) Service {
+	return &timeService{
+		repo:       repo,
+		tasks:      tasks,
+		workspaces: workspaces,
+		now:        time.Now,
+	}
+}
+
+func (s *timeService) Start(ctx context.Context, userID, taskID int, note string) (*Entry, error) {
+	if userID <= 0 || taskID <= 0 || len(note) > MaxNoteLength {
+		return nil, ErrInvalidInput
+	}
+	// время записывает тот, кто может работать над задачей
+	if err := s.require(ctx, userID, taskID, task.RoleEditor); err != nil {
+		return nil, err
+	}
+
+	now := s.now().UTC()
+	e := &Entry{
+		TaskID:    taskID,
+		UserID:    userID,
+		Source:    SourceTimer,
+		Note:      strings.TrimSpace(note),
+		StartedAt: now,
+		CreatedAt: now,
+	}
+	// проверка и вставка — один запрос, два параллельных старта не пройдут оба
+	if err := s.repo.Create(ctx, e); err != nil {
+		if errors.Is(err, ErrOverlap) {
+			return nil, ErrTimerRunning
+		}
+		return nil, err
+	}
+	return e, nil
+}
+
+func (s *timeService) Stop(ctx context.Context, userID, taskID int) (*Entry, error) {
+	if userID <= 0 || taskID <= 0 {
+		return nil, ErrInvalidInput
+	}
+	e, err := s.repo.Running(ctx, userID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, ErrNoTimer
+		}
+		return nil, err
+	}
+	if e.TaskID != taskID {
+		return nil, ErrNoTimer
+	}
+
+	now := s.now().UTC()
+	if err := s.repo.Stop(ctx, e.ID, now); err != nil {
+		// успели остановить параллельно
+		if errors.Is(err, ErrNotFound) {
+			return nil, ErrNoTimer
+		}
+		return nil, err
+	}
+	e.EndedAt = &now
+	e.Seconds = duration(e, now)
+	return e, nil
+}
+
+func (s *timeService) Add(ctx context.Context, userID, taskID int, startedAt, endedAt time.Time, note string) (*Entry, error) {
+	if userID <= 0 || taskID <= 0 || len(note) > MaxNoteLength {
+		return nil, ErrInvalidInput
+	}
+	now := s.now().UTC()
+	startedAt, endedAt = startedAt.UTC(), endedAt.UTC()
+	// только прошедшее время и не больше суток за раз
+	if !startedAt.Before(endedAt) || endedAt.After(now) || endedAt.Sub(startedAt) > maxManualEntry {
+		return nil, ErrInvalidInput
+	}
+	if err := s.require(ctx, userID, taskID, task.RoleEditor); err != nil {
+		return nil, err
+	}
+
+	e := &Entry{
+		TaskID:    taskID,
+		UserID:    userID,
+		Source:    SourceManual,
+		Note:      strings.TrimSpace(note),
+		StartedAt: startedAt,
+		EndedAt:   &endedAt,
+		CreatedAt: now,
+	}
+	if err := s.repo.Create(ctx, e); err != nil {
+		return nil, err
+	}
+	e.Seconds = duration(e, now)
+	return e, nil
+}
+
+func (s *timeService) List(ctx context.Context, userID, taskID int) ([]Entry, error) {
+	if userID <= 0 || taskID <= 0 {
+		return nil, ErrInvalidInput
+	}
+	if err := s.require(ctx, userID, taskID, task.RoleViewer); err != nil {
+		return nil, err
+	}
+	entries, err := s.repo.List(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now().UTC()
+	for i := range entries {
+		entries[i].Seconds = duration(&entries[i], now)
+	}
+	return entries, nil
+}
+
+func (s *timeService) Delete(ctx context.Context, userID, taskID, id int) error {
+	if userID <= 0 || taskID <= 0 || id <= 0 {
+		return ErrInvalidInput
+	}
+	role, err := s.tasks.Role(ctx, userID, taskID)
+	if err != nil {
+		return err
+	}
+	e, err := s.repo.Get(ctx, taskID, id)
+	if err != nil {
+		return err
+	}
+	// свою запись удаляет editor, чужую — только owner
+	allowed := role.Allows(task.RoleOwner) || (e.UserID == userID && role.Allows(task.RoleEditor))
+	if !allowed {
+		return task.ErrForbidden
+	}
+	return s.repo.Delete(ctx, e.ID)
+}
+
+func (s *timeService) Report(ctx context.Context, userID int, q ReportQuery) (*Report, error) {
+	if userID <= 0 || (q.WorkspaceID != nil && *q.WorkspaceID < 0) {
+		return nil, ErrInvalidInput
+	}
+	return s.report(q, func(from, to time.Time) ([]ReportEntry, error) {
+		return s.repo.ListForReport(ctx, userID, q.WorkspaceID, from, to)
+	})
+}
+
+func (s *timeService) WorkspaceReport(ctx context.Context, userID, workspaceID int, q ReportQuery) (*Report, error) {
+	if userID <= 0 || workspaceID <= 0 {
+		return nil, ErrInvalidInput
+	}
+	role, err := s.workspaces.MemberRole(ctx, workspaceID, userID)
+	if err != nil {
+		if errors.Is(err, workspace.ErrNotMember) {
+			return nil, task.ErrNotFound
+		}
+		return nil, err
+	}
+	q.WorkspaceID = &workspaceID
+	return s.report(q, func(from, to time.Time) ([]ReportEntry, error) {
+		if role == workspace.RoleAdmin {
+			return s.repo.ListWorkspaceForReport(ctx, workspaceID, from, to)
+		}
+		return s.repo.ListForReport(ctx, userID, q.WorkspaceID, from, to)
+	})
+}
+
+// report builds the report from the entries load returns for [from, to).
+func (s *timeService) report(q ReportQuery, load func(from, to time.Time) ([]ReportEntry, error)) (*Report, error) {
+	switch q.GroupBy {
+	case "":
+		q.GroupBy = GroupByDay
+	case GroupByDay, GroupByTask, GroupByWorkspace, GroupByUser:
+	default:
+		return nil, ErrInvalidInput
+	}
+	if q.Timezone == "" {
+		q.Timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(q.Timezone)
+	if err != nil {
+		return nil, ErrInvalidInput
+	}
+
+	// 1) диапазон: целые дни в часовом поясе отчёта, to включительно
+	now := s.now().In(loc)
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+	if q.From != "" {
+		if from, err = time.ParseInLocation(dateLayout, q.From, loc); err != nil {
+			return nil, ErrInvalidInput
+		}
+	}
+	lastDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if q.To != "" {
+		if lastDay, err = time.ParseInLocation(dateLayout, q.To, loc); err != nil {
+			return nil, ErrInvalidInput
+		}
+	}
+	to := lastDay.AddDate(0, 0, 1)
+	if !from.Before(to) || to.After(from.AddDate(0, 0, maxReportDays)) {
+		return nil, ErrInvalidInput
+	}
+
+	entries, err := load(from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	// 2) группируем, обрезая записи по границам диапазона (и дней)
+	b := newReportBuilder()
+	for _, e := range entries {
+		start, end := e.StartedAt, now
+		if e.EndedAt != nil {
+			end = *e.EndedAt
+		}
+		start, end = later(start, from), earlier(end, to)
+		if !start.Before(end) {
+			continue
+		}
+
+		switch q.GroupBy {
+		case GroupByDay:
+			for day := start.In(loc); day.Before(end); {
+				next := time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, loc)
+				b.add(day.Format(dateLayout), "", e, earlier(next, end).Sub(day))
+				day = next
+			}
+		case GroupByTask:
+			b.add(strconv.Itoa(e.TaskID), e.TaskTitle, e, end.Sub(start))
+		case GroupByWorkspace:
+			b.add(strconv.Itoa(e.WorkspaceID), "", e, end.Sub(start))
+		case GroupByUser:
+			b.add(strconv.Itoa(e.UserID), "", e, end.Sub(start))
+		}
+	}
+
+	return &Report{
+		From:     from.Format(dateLayout),
+		To:       lastDay.Format(dateLayout),
+		Timezone: loc.String(),
+		GroupBy:  q.GroupBy,
+		Rows:     b.rows(q.GroupBy),
+		Total:    b.total(),
+	}, nil
+}
+
+func (s *timeService) require(ctx context.Context, userID, taskID int, min task.Role) error {
+	role, err := s.tasks.Role(ctx, userID, taskID)
+	if err != nil {
+		return err
+	}
+	if !role.Allows(min) {
+		return task.ErrForbidden
+	}
+	return nil
+}
+
+// duration is the entry's length in whole seconds; running entries count up
+// to now.
+func duration(e *Entry, now time.Time) int64 {
+	end := now
+	if e.EndedAt != nil {
+		end = *e.EndedAt
+	}
+	if end.Before(e.StartedAt) {
+		return 0
+	}
+	return int64(end.Sub(e.StartedAt) / time.Second)
+}
+
+func earlier(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
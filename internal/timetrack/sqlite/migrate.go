@@ -0,0 +1,21 @@
+package sqlite
+
+import "database/sql"
+
+func Migrate(db *sql.DB) error {
+	const q = `
+	CREATE TABLE IF NOT EXISTS time_entries(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	task_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	source TEXT NOT NULL,
+	note TEXT NOT NULL,
+	started_at TEXT NOT NULL,
+	ended_at TEXT NULL,
+	created_at TEXT NOT NULL);
+	CREATE INDEX IF NOT EXISTS idx_time_entries_task_id ON time_entries(task_id, started_at);
+	CREATE INDEX IF NOT EXISTS idx_time_entries_user_id ON time_entries(user_id, started_at);
+	`
+	_, err := db.Exec(q)
+	return err
+}
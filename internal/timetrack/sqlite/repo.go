@@ -0,0 +1,265 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"task_scheduler/internal/timetrack"
+	"time"
+)
+
+// timeLayout has a fixed width, so stored times compare correctly as text
+// (RFC3339Nano drops trailing zeros).
+const timeLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+type Repo struct {
+	db *sql.DB
+}
+
+func New(db *sql.DB) *Repo {
+	return &Repo{db: db}
+}
+
+const selectColumns = `id, task_id, user_id, source, note, started_at, ended_at, created_at`
+
+func (r *Repo) Create(ctx context.Context, e *timetrack.Entry) error {
+	endedAt := nullTime(e.EndedAt)
+	// записи удалённых задач не мешают
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO time_entries (task_id, user_id, source, note, started_at, ended_at, created_at)
+		 SELECT ?, ?, ?, ?, ?, ?, ?
+		 WHERE NOT EXISTS (
+		   SELECT 1 FROM time_entries
+		   WHERE user_id = ? AND task_id IN (SELECT id FROM tasks)
+		     AND (ended_at IS NULL OR ended_at > ?)
+		     AND (? IS NULL OR started_at < ?))`,
+		e.TaskID,
+		e.UserID,
+		string(e.Source),
+		e.Note,
+		e.StartedAt.UTC().Format(timeLayout),
+		endedAt,
+		e.CreatedAt.UTC().Format(timeLayout),
+		e.UserID,
+		e.StartedAt.UTC().Format(timeLayout),
+		endedAt,
+		endedAt,
+	)
+	if err != nil {
+		return err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if aff == 0 {
+		return timetrack.ErrOverlap
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	e.ID = int(id)
+	return nil
+}
+
+func (r *Repo) Get(ctx context.Context, taskID, id int) (*timetrack.Entry, error) {
+	row := r.db.QueryRowContext(ctx,
+		`SELECT `+selectColumns+` FROM time_entries WHERE task_id = ? AND id = ?`,
+		taskID,
+		id,
+	)
+	return scanEntryRow(row)
+}
+
+func (r *Repo) List(ctx context.Context, taskID int) ([]timetrack.Entry, error) {
+	return r.list(ctx, `task_id = ?`, taskID)
+}
+
+func (r *Repo) ListByUser(ctx context.Context, userID int) ([]timetrack.Entry, error) {
+	return r.list(ctx, `user_id = ? AND task_id IN (SELECT id FROM tasks)`, userID)
+}
+
+func (r *Repo) list(ctx context.Context, where string, arg any) ([]timetrack.Entry, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+selectColumns+` FROM time_entries WHERE `+where+` ORDER BY started_at, id`,
+		arg,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]timetrack.Entry, 0)
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *Repo) Running(ctx context.Context, userID int) (*timetrack.Entry, error) {
+	row := r.db.QueryRowContext(ctx,
+		`SELECT `+selectColumns+` FROM time_entries
+		 WHERE user_id = ? AND ended_at IS NULL AND task_id IN (SELECT id FROM tasks)`,
+		userID,
+	)
+	return scanEntryRow(row)
+}
+
+func (r *Repo) Stop(ctx context.Context, id int, endedAt time.Time) error {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE time_entries SET ended_at = ? WHERE id = ? AND ended_at IS NULL`,
+		endedAt.UTC().Format(timeLayout),
+		id,
+	)
+	if err != nil {
+		return err
+	}
+	return requireAffected(res)
+}
+
+func (r *Repo) Delete(ctx context.Context, id int) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM time_entries WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return requireAffected(res)
+}
+
+// DeleteAccountData deletes the user's entries, workspace tasks included,
+// when the account goes.
+func (r *Repo) DeleteAccountData(ctx context.Context, userID int) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM time_entries WHERE user_id = ?`, userID)
+	return err
+}
+
+func (r *Repo) ListForReport(ctx context.Context, userID int, workspaceID *int, from, to time.Time) ([]timetrack.ReportEntry, error) {
+	where := `e.user_id = ? AND e.started_at < ? AND (e.ended_at IS NULL OR e.ended_at > ?)`
+	args := []any{userID, to.UTC().Format(timeLayout), from.UTC().Format(timeLayout)}
+	if workspaceID != nil {
+		where += ` AND t.workspace_id = ?`
+		args = append(args, *workspaceID)
+	}
+	return r.listForReport(ctx, where, args...)
+}
+
+func (r *Repo) ListWorkspaceForReport(ctx context.Context, workspaceID int, from, to time.Time) ([]timetrack.ReportEntry, error) {
+	return r.listForReport(ctx,
+		`t.workspace_id = ? AND e.started_at < ? AND (e.ended_at IS NULL OR e.ended_at > ?)`,
+		workspaceID,
+		to.UTC().Format(timeLayout),
+		from.UTC().Format(timeLayout),
+	)
+}
+
+func (r *Repo) listForReport(ctx context.Context, where string, args ...any) ([]timetrack.ReportEntry, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT e.id, e.task_id, e.user_id, e.source, e.note, e.started_at, e.ended_at, e.created_at,
+		        t.title, t.workspace_id, t.estimate_minutes
+		 FROM time_entries e JOIN tasks t ON t.id = e.task_id
+		 WHERE `+where+`
+		 ORDER BY e.started_at, e.id`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]timetrack.ReportEntry, 0)
+	for rows.Next() {
+		var (
+			re       timetrack.ReportEntry
+			estimate sql.NullInt64
+		)
+		e, err := scanEntry(rows, &re.TaskTitle, &re.WorkspaceID, &estimate)
+		if err != nil {
+			return nil, err
+		}
+		re.Entry = *e
+		if estimate.Valid {
+			minutes := int(estimate.Int64)
+			re.EstimateMinutes = &minutes
+		}
+		list = append(list, re)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func requireAffected(res sql.Result) error {
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if aff == 0 {
+		return timetrack.ErrNotFound
+	}
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanEntryRow(row *sql.Row) (*timetrack.Entry, error) {
+	e, err := scanEntry(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, timetrack.ErrNotFound
+		}
+		return nil, err
+	}
+	return e, nil
+}
+
+// scanEntry reads the entry columns followed by any extra ones.
+func scanEntry(s scanner, extra ...any) (*timetrack.Entry, error) {
+	var (
+		e            timetrack.Entry
+		source       string
+		startedAtStr string
+		endedAtStr   sql.NullString
+		createdAtStr string
+	)
+	dest := append([]any{&e.ID, &e.TaskID, &e.UserID, &source, &e.Note, &startedAtStr, &endedAtStr, &createdAtStr}, extra...)
+	if err := s.Scan(dest...); err != nil {
+		return nil, err
+	}
+	e.Source = timetrack.Source(source)
+
+	startedAt, err := time.Parse(time.RFC3339Nano, startedAtStr)
+	if err != nil {
+		return nil, err
+	}
+	e.StartedAt = startedAt
+	if endedAtStr.Valid {
+		endedAt, err := time.Parse(time.RFC3339Nano, endedAtStr.String)
+		if err != nil {
+			return nil, err
+		}
+		e.EndedAt = &endedAt
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, createdAtStr)
+	if err != nil {
+		return nil, err
+	}
+	e.CreatedAt = createdAt
+	return &e, nil
+}
+
+func nullTime(t *time.Time) sql.NullString {
+	if t == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: t.UTC().Format(timeLayout), Valid: true}
+}
@@ -0,0 +1,100 @@
+package sqlite_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+
+	"task_scheduler/internal/task"
+	tasksqlite "task_scheduler/internal/task/sqlite"
+	"task_scheduler/internal/timetrack"
+	timetracksqlite "task_scheduler/internal/timetrack/sqlite"
+)
+
+// day is 2026-01-05 at the given hour and minute, UTC.
+func day(hour, minute int) time.Time {
+	return time.Date(2026, 1, 5, hour, minute, 0, 0, time.UTC)
+}
+
+func entry(userID, taskID int, from time.Time, to *time.Time) *timetrack.Entry {
+	return &timetrack.Entry{TaskID: taskID, UserID: userID, Source: timetrack.SourceManual, StartedAt: from, EndedAt: to, CreatedAt: from}
+}
+
+func at(hour, minute int) *time.Time {
+	t := day(hour, minute)
+	return &t
+}
+
+// newRepo: у пользователя 1 запись 10:00–12:00, у пользователя 2 — 08:00–09:00,
+// и ещё запись 06:00–07:00 на задаче, которой уже нет.
+func newRepo(t *testing.T) (*timetracksqlite.Repo, int) {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "time.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	require.NoError(t, tasksqlite.Migrate(db))
+	require.NoError(t, timetracksqlite.Migrate(db))
+
+	ctx := t.Context()
+	now := time.Now().UTC()
+	tsk := &task.Task{UserID: 1, CreatedBy: 1, Title: "T", Status: task.StatusPending, CreatedAt: now, UpdatedAt: now}
+	require.NoError(t, tasksqlite.New(db).Create(ctx, tsk, 0))
+
+	repo := timetracksqlite.New(db)
+	require.NoError(t, repo.Create(ctx, entry(1, tsk.ID, day(10, 0), at(12, 0))))
+	require.NoError(t, repo.Create(ctx, entry(2, tsk.ID, day(8, 0), at(9, 0))))
+	// строки задачи нет — так выглядит запись, оставшаяся от удалённой
+	require.NoError(t, repo.Create(ctx, entry(1, tsk.ID+100, day(6, 0), at(7, 0))))
+	return repo, tsk.ID
+}
+
+func TestRepo_Create_Overlap(t *testing.T) {
+	tests := []struct {
+		name    string
+		userID  int
+		from    time.Time
+		to      *time.Time
+		overlap bool
+	}{
+		{"before", 1, day(8, 0), at(9, 0), false},
+		{"ends where the other starts", 1, day(9, 0), at(10, 0), false},
+		{"starts where the other ends", 1, day(12, 0), at(13, 0), false},
+		{"inside", 1, day(10, 30), at(11, 0), true},
+		{"covers", 1, day(9, 0), at(13, 0), true},
+		{"starts inside", 1, day(11, 0), at(13, 0), true},
+		{"ends inside", 1, day(9, 0), at(10, 30), true},
+		{"same interval", 1, day(10, 0), at(12, 0), true},
+		{"timer after", 1, day(12, 0), nil, false},
+		{"timer inside", 1, day(11, 0), nil, true},
+		{"timer before", 1, day(9, 0), nil, true},
+		{"other user", 2, day(10, 30), at(11, 0), false},
+		{"deleted task doesn't count", 1, day(6, 15), at(6, 45), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, taskID := newRepo(t)
+			err := repo.Create(t.Context(), entry(tt.userID, taskID, tt.from, tt.to))
+			if tt.overlap {
+				require.ErrorIs(t, err, timetrack.ErrOverlap)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestRepo_Create_RunningTimerBlocksLaterEntries(t *testing.T) {
+	repo, taskID := newRepo(t)
+	ctx := t.Context()
+	require.NoError(t, repo.Create(ctx, entry(1, taskID, day(20, 0), nil)))
+
+	// таймер идёт — всё, что после его старта, пересекается, до — нет
+	require.ErrorIs(t, repo.Create(ctx, entry(1, taskID, day(21, 0), at(22, 0))), timetrack.ErrOverlap)
+	require.ErrorIs(t, repo.Create(ctx, entry(1, taskID, day(19, 0), at(20, 30))), timetrack.ErrOverlap)
+	require.ErrorIs(t, repo.Create(ctx, entry(1, taskID, day(22, 0), nil)), timetrack.ErrOverlap)
+	require.NoError(t, repo.Create(ctx, entry(1, taskID, day(18, 0), at(19, 0))))
+}
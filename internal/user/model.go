@@ -6,5 +6,9 @@ type User struct {
 	ID           int
 	Email        string
 	PasswordHash string
+	DisplayName  string
+	Timezone     string
+	Locale       string
 	CreatedAt    time.Time
+	VerifiedAt   *time.Time
 }
@@ -0,0 +1,63 @@
+package user
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+	_ "time/tzdata" // timezone validation shouldn't depend on the host's zoneinfo
+	"unicode/utf8"
+)
+
+var (
+	ErrWrongPassword      = errors.New("current password is incorrect")
+	ErrInvalidDisplayName = errors.New("display name must be at most 100 characters")
+	ErrInvalidTimezone    = errors.New("unknown timezone (use an IANA name like Europe/Berlin)")
+	ErrInvalidLocale      = errors.New("invalid locale (use a language tag like en or pt-BR)")
+)
+
+const (
+	DefaultTimezone = "UTC"
+	DefaultLocale   = "en"
+
+	maxDisplayNameLen = 100
+)
+
+var localeRe = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+// ProfileUpdate holds the fields to change; nil fields are left untouched.
+type ProfileUpdate struct {
+	DisplayName *string
+	Timezone    *string
+	Locale      *string
+}
+
+// apply validates the update and copies it onto u.
+func (p ProfileUpdate) apply(u *User) error {
+	if p.DisplayName != nil {
+		name := strings.TrimSpace(*p.DisplayName)
+		if utf8.RuneCountInString(name) > maxDisplayNameLen {
+			return ErrInvalidDisplayName
+		}
+		u.DisplayName = name
+	}
+	if p.Timezone != nil {
+		tz := strings.TrimSpace(*p.Timezone)
+		// "Local" валиден для LoadLocation, но для профиля смысла не имеет
+		if tz == "" || tz == "Local" {
+			return ErrInvalidTimezone
+		}
+		if _, err := time.LoadLocation(tz); err != nil {
+			return ErrInvalidTimezone
+		}
+		u.Timezone = tz
+	}
+	if p.Locale != nil {
+		loc := strings.TrimSpace(*p.Locale)
+		if !localeRe.MatchString(loc) {
+			return ErrInvalidLocale
+		}
+		u.Locale = loc
+	}
+	return nil
+}
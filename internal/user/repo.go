@@ -1,6 +1,10 @@
 package user
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"time"
+)
 
 var (
 	ErrNotFound    = errors.New("user not found")
@@ -8,6 +12,13 @@ var (
 )
 
 type Repo interface {
-	Create(u *User) error
-	GetByEmail(email string) (*User, error)
+	Create(ctx context.Context, u *User) error
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	GetByID(ctx context.Context, id int) (*User, error)
+	UpdatePassword(ctx context.Context, userID int, passwordHash string) error
+	MarkVerified(ctx context.Context, userID int, at time.Time) error
+	UpdateProfile(ctx context.Context, u *User) error
+	// Delete removes the user with their reset and verification tokens.
+	// Data of other packages is deleted by them (see AccountData).
+	Delete(ctx context.Context, userID int) error
 }
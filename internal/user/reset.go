@@ -0,0 +1,27 @@
+package user
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// PasswordResetToken is a hashed single-use token for resetting a password.
+type PasswordResetToken struct {
+	ID        int
+	UserID    int
+	TokenHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UsedAt    *time.Time
+}
+
+type PasswordResetRepo interface {
+	CreateResetToken(t *PasswordResetToken) error
+	GetResetToken(hash string) (*PasswordResetToken, error)
+	// MarkResetTokenUsed returns false if the token was already used.
+	MarkResetTokenUsed(id int, at time.Time) (bool, error)
+	// InvalidateResetTokens marks every outstanding token of the user as used.
+	InvalidateResetTokens(userID int, at time.Time) error
+}
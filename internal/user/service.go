@@ -1,8 +1,16 @@
 package user
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"log"
+	netmail "net/mail"
 	"strings"
+	"task_scheduler/internal/mail"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
@@ -10,20 +18,79 @@ import (
 
 var (
 	ErrInvalidInput = errors.New("invalid input")
+	ErrInvalidEmail = errors.New("invalid email address")
 	ErrAuthFailed   = errors.New("invalid email or password")
 )
 
+const maxEmailLen = 254
+
 type Service interface {
 	Register(email, password string) (*User, error)
 	Authenticate(email, password string) (*User, error)
+	// RequestPasswordReset mails a reset token if the email is registered.
+	// It reports success for unknown emails too, so accounts can't be enumerated.
+	RequestPasswordReset(ctx context.Context, email string) error
+	// ResetPassword consumes a reset token and returns the affected user id.
+	ResetPassword(ctx context.Context, token, newPassword string) (int, error)
+	// VerifyEmail consumes a verification token and marks the address verified.
+	VerifyEmail(ctx context.Context, token string) error
+	// ResendVerification mails a new verification token. Unknown, already
+	// verified and throttled requests are silently ignored.
+	ResendVerification(ctx context.Context, email string) error
+	IsVerified(ctx context.Context, userID int) (bool, error)
+	Get(ctx context.Context, userID int) (*User, error)
+	// IDByEmail looks up a user for sharing; unknown emails yield ErrNotFound.
+	IDByEmail(ctx context.Context, email string) (int, error)
+	UpdateProfile(ctx context.Context, userID int, upd ProfileUpdate) (*User, error)
+	// ChangePassword requires the current password; a wrong one yields ErrWrongPassword.
+	ChangePassword(ctx context.Context, userID int, currentPassword, newPassword string) error
+	// Delete removes the account and everything it owns. Like ChangePassword
+	// it requires the current password; any AccountGuard can refuse it
+	// before anything is deleted.
+	Delete(ctx context.Context, userID int, currentPassword string) error
+	// SetAccountData registers what other packages keep for users. It must
+	// be called before Delete.
+	SetAccountData(data ...AccountData)
+}
+
+// AccountData is what another package keeps for a user; it goes away with
+// the account. DeleteAccountData must be safe to repeat: a deletion that
+// failed halfway is retried from the start.
+type AccountData interface {
+	DeleteAccountData(ctx context.Context, userID int) error
+}
+
+// AccountGuard is AccountData that can refuse the deletion, e.g. while the
+// user is the last admin of a workspace.
+type AccountGuard interface {
+	CheckAccountDeletion(ctx context.Context, userID int) error
+}
+
+// Options are the token lifetimes and limits of the service.
+type Options struct {
+	ResetTTL        time.Duration
+	VerificationTTL time.Duration
+	// ResendInterval is the minimum time between two verification emails.
+	ResendInterval time.Duration
 }
 
 type userService struct {
-	repo Repo
+	repo          Repo
+	resets        PasswordResetRepo
+	verifications VerificationRepo
+	mailer        mail.Mailer
+	opts          Options
+	accountData   []AccountData
 }
 
-func NewService(repo Repo) Service {
-	return &userService{repo: repo}
+func NewService(repo Repo, resets PasswordResetRepo, verifications VerificationRepo, mailer mail.Mailer, opts Options) Service {
+	return &userService{
+		repo:          repo,
+		resets:        resets,
+		verifications: verifications,
+		mailer:        mailer,
+		opts:          opts,
+	}
 }
 
 func (s *userService) Register(email, password string) (*User, error) {
@@ -31,6 +98,9 @@ func (s *userService) Register(email, password string) (*User, error) {
 	if email == "" || password == "" {
 		return nil, ErrInvalidInput
 	}
+	if !isValidEmail(email) {
+		return nil, ErrInvalidEmail
+	}
 
 	// минимальная защита: чтобы не регистрировали "123"
 	if len(password) < 6 {
@@ -47,16 +117,22 @@ func (s *userService) Register(email, password string) (*User, error) {
 	u := &User{
 		Email:        email,
 		PasswordHash: string(hash),
+		Timezone:     DefaultTimezone,
+		Locale:       DefaultLocale,
 		CreatedAt:    time.Now().UTC(),
 	}
 	// 3) сохраняем
-	if err := s.repo.Create(u); err != nil {
+	if err := s.repo.Create(context.Background(), u); err != nil {
 		if errors.Is(err, ErrEmailExists) {
 			return nil, ErrEmailExists
 		}
 		return nil, err
 	}
-	// 4) безопасность: наружу пароль-хеш не отдаём
+	// 4) письмо с подтверждением; не получилось — юзер запросит повторно
+	if err := s.sendVerification(context.Background(), u); err != nil {
+		log.Println("[USER] send verification:", err)
+	}
+	// 5) безопасность: наружу пароль-хеш не отдаём
 	u.PasswordHash = ""
 	return u, nil
 }
@@ -67,7 +143,7 @@ func (s *userService) Authenticate(email, password string) (*User, error) {
 		return nil, ErrInvalidInput
 	}
 	// 1) ищем пользователя
-	u, err := s.repo.GetByEmail(email)
+	u, err := s.repo.GetByEmail(context.Background(), email)
 	if err != nil {
 		if errors.Is(err, ErrNotFound) {
 			return nil, ErrAuthFailed
@@ -82,3 +158,308 @@ func (s *userService) Authenticate(email, password string) (*User, error) {
 	u.PasswordHash = ""
 	return u, nil
 }
+
+func (s *userService) RequestPasswordReset(ctx context.Context, email string) error {
+	email = strings.TrimSpace(strings.ToLower(email))
+	if email == "" {
+		return ErrInvalidInput
+	}
+	// 1) ищем пользователя; неизвестный email — молча успех
+	u, err := s.repo.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	// 2) токен: наружу — raw, в БД — хеш
+	raw, err := randomToken()
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	t := &PasswordResetToken{
+		UserID:    u.ID,
+		TokenHash: hashToken(raw),
+		ExpiresAt: now.Add(s.opts.ResetTTL),
+		CreatedAt: now,
+	}
+	if err := s.resets.CreateResetToken(t); err != nil {
+		return err
+	}
+
+	// 3) отправляем письмо
+	return s.mailer.Send(ctx, mail.Message{
+		To:      u.Email,
+		Subject: "Reset your password",
+		Body: "Someone asked to reset the password for your account.\n" +
+			"Use this token within " + s.opts.ResetTTL.String() + ":\n\n" + raw + "\n\n" +
+			"If it wasn't you, ignore this email.",
+	})
+}
+
+func (s *userService) ResetPassword(ctx context.Context, token, newPassword string) (int, error) {
+	if token == "" || len(newPassword) < 6 {
+		return 0, ErrInvalidInput
+	}
+	now := time.Now().UTC()
+
+	// 1) проверяем токен
+	t, err := s.resets.GetResetToken(hashToken(token))
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return 0, ErrInvalidResetToken
+		}
+		return 0, err
+	}
+	if t.UsedAt != nil || !now.Before(t.ExpiresAt) {
+		return 0, ErrInvalidResetToken
+	}
+
+	// 2) одноразовость: помечаем использованным до смены пароля
+	ok, err := s.resets.MarkResetTokenUsed(t.ID, now)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, ErrInvalidResetToken
+	}
+
+	// 3) новый пароль
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.repo.UpdatePassword(ctx, t.UserID, string(hash)); err != nil {
+		return 0, err
+	}
+
+	// 4) остальные выданные токены сброса больше не нужны
+	if err := s.resets.InvalidateResetTokens(t.UserID, now); err != nil {
+		log.Println("[USER] invalidate reset tokens:", err)
+	}
+	return t.UserID, nil
+}
+
+func (s *userService) VerifyEmail(ctx context.Context, token string) error {
+	if token == "" {
+		return ErrInvalidInput
+	}
+	now := time.Now().UTC()
+
+	t, err := s.verifications.GetVerificationToken(hashToken(token))
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return ErrInvalidVerificationToken
+		}
+		return err
+	}
+	if t.UsedAt != nil || !now.Before(t.ExpiresAt) {
+		return ErrInvalidVerificationToken
+	}
+
+	ok, err := s.verifications.MarkVerificationTokenUsed(t.ID, now)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidVerificationToken
+	}
+	return s.repo.MarkVerified(ctx, t.UserID, now)
+}
+
+func (s *userService) ResendVerification(ctx context.Context, email string) error {
+	email = strings.TrimSpace(strings.ToLower(email))
+	if email == "" {
+		return ErrInvalidInput
+	}
+
+	u, err := s.repo.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	if u.VerifiedAt != nil {
+		return nil
+	}
+
+	// throttling: не чаще раза в ResendInterval
+	last, err := s.verifications.LastVerificationSentAt(u.ID)
+	if err != nil {
+		return err
+	}
+	if last != nil && time.Since(*last) < s.opts.ResendInterval {
+		return nil
+	}
+	return s.sendVerification(ctx, u)
+}
+
+func (s *userService) IsVerified(ctx context.Context, userID int) (bool, error) {
+	if userID <= 0 {
+		return false, ErrInvalidInput
+	}
+	u, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return u.VerifiedAt != nil, nil
+}
+
+func (s *userService) Get(ctx context.Context, userID int) (*User, error) {
+	if userID <= 0 {
+		return nil, ErrInvalidInput
+	}
+	u, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	u.PasswordHash = ""
+	return u, nil
+}
+
+func (s *userService) IDByEmail(ctx context.Context, email string) (int, error) {
+	email = strings.TrimSpace(strings.ToLower(email))
+	if email == "" {
+		return 0, ErrInvalidInput
+	}
+	u, err := s.repo.GetByEmail(ctx, email)
+	if err != nil {
+		return 0, err
+	}
+	return u.ID, nil
+}
+
+func (s *userService) UpdateProfile(ctx context.Context, userID int, upd ProfileUpdate) (*User, error) {
+	if userID <= 0 {
+		return nil, ErrInvalidInput
+	}
+	u, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := upd.apply(u); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateProfile(ctx, u); err != nil {
+		return nil, err
+	}
+	u.PasswordHash = ""
+	return u, nil
+}
+
+func (s *userService) ChangePassword(ctx context.Context, userID int, currentPassword, newPassword string) error {
+	if userID <= 0 || currentPassword == "" || len(newPassword) < 6 {
+		return ErrInvalidInput
+	}
+	// 1) проверяем текущий пароль
+	u, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(currentPassword)); err != nil {
+		return ErrWrongPassword
+	}
+
+	// 2) сохраняем новый
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.UpdatePassword(ctx, userID, string(hash)); err != nil {
+		return err
+	}
+
+	// 3) выданные до смены токены сброса больше не должны работать
+	if err := s.resets.InvalidateResetTokens(userID, time.Now().UTC()); err != nil {
+		log.Println("[USER] invalidate reset tokens:", err)
+	}
+	return nil
+}
+
+func (s *userService) Delete(ctx context.Context, userID int, currentPassword string) error {
+	if userID <= 0 || currentPassword == "" {
+		return ErrInvalidInput
+	}
+	u, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(currentPassword)); err != nil {
+		return ErrWrongPassword
+	}
+
+	// 1) сначала все проверки: удалять, так целиком
+	for _, d := range s.accountData {
+		if g, ok := d.(AccountGuard); ok {
+			if err := g.CheckAccountDeletion(ctx, userID); err != nil {
+				return err
+			}
+		}
+	}
+	// 2) каждый пакет удаляет своё; сам пользователь — последним, чтобы
+	// после сбоя удаление можно было повторить
+	for _, d := range s.accountData {
+		if err := d.DeleteAccountData(ctx, userID); err != nil {
+			return err
+		}
+	}
+	return s.repo.Delete(ctx, userID)
+}
+
+func (s *userService) SetAccountData(data ...AccountData) {
+	s.accountData = append(s.accountData, data...)
+}
+
+func (s *userService) sendVerification(ctx context.Context, u *User) error {
+	raw, err := randomToken()
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	t := &VerificationToken{
+		UserID:    u.ID,
+		TokenHash: hashToken(raw),
+		ExpiresAt: now.Add(s.opts.VerificationTTL),
+		CreatedAt: now,
+	}
+	if err := s.verifications.CreateVerificationToken(t); err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mail.Message{
+		To:      u.Email,
+		Subject: "Confirm your email",
+		Body: "Welcome! Confirm your email address with this token within " +
+			s.opts.VerificationTTL.String() + ":\n\n" + raw,
+	})
+}
+
+// isValidEmail accepts a bare addr-spec (no display name) with a dotted domain.
+func isValidEmail(email string) bool {
+	if len(email) > maxEmailLen {
+		return false
+	}
+	addr, err := netmail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return false
+	}
+	at := strings.LastIndex(email, "@")
+	domain := email[at+1:]
+	return strings.Contains(domain, ".") && !strings.HasPrefix(domain, ".") && !strings.HasSuffix(domain, ".")
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
@@ -10,7 +10,77 @@ func Migrate(db *sql.DB) error {
 	password_hash TEXT NOT NULL,
 	created_at TEXT NOT NULL);
 	CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
+
+	CREATE TABLE IF NOT EXISTS password_reset_tokens(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	token_hash TEXT NOT NULL UNIQUE,
+	expires_at TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	used_at TEXT NULL);
+	CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user_id ON password_reset_tokens(user_id);
+
+	CREATE TABLE IF NOT EXISTS email_verification_tokens(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	token_hash TEXT NOT NULL UNIQUE,
+	expires_at TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	used_at TEXT NULL);
+	CREATE INDEX IF NOT EXISTS idx_email_verification_tokens_user_id ON email_verification_tokens(user_id, created_at);
 	`
-	_, err := db.Exec(q)
-	return err
+	if _, err := db.Exec(q); err != nil {
+		return err
+	}
+
+	// verified_at появился позже: уже существующие аккаунты считаем подтверждёнными
+	added, err := addColumnIfMissing(db, "users", "verified_at", "TEXT NULL")
+	if err != nil {
+		return err
+	}
+	if added {
+		if _, err := db.Exec(`UPDATE users SET verified_at = created_at`); err != nil {
+			return err
+		}
+	}
+
+	// профиль
+	profileColumns := []struct{ name, definition string }{
+		{"display_name", "TEXT NOT NULL DEFAULT ''"},
+		{"timezone", "TEXT NOT NULL DEFAULT 'UTC'"},
+		{"locale", "TEXT NOT NULL DEFAULT 'en'"},
+	}
+	for _, c := range profileColumns {
+		if _, err := addColumnIfMissing(db, "users", c.name, c.definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addColumnIfMissing adds a column to an existing table and reports whether it did.
+func addColumnIfMissing(db *sql.DB, table, column, definition string) (bool, error) {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return false, err
+		}
+		if name == column {
+			return false, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+
+	if _, err := db.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + definition); err != nil {
+		return false, err
+	}
+	return true, nil
 }
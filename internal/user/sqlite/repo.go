@@ -1,6 +1,7 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"strings"
@@ -16,13 +17,20 @@ func New(db *sql.DB) *Repo {
 	return &Repo{db: db}
 }
 
-func (r *Repo) Create(u *user.User) error {
-	query := `INSERT INTO users(email, password_hash, created_at) VALUES (?, ?, ?)`
+func (r *Repo) Create(ctx context.Context, u *user.User) error {
+	query := `
+	INSERT INTO users(email, password_hash, display_name, timezone, locale, created_at)
+	VALUES (?, ?, ?, ?, ?, ?)
+	`
 
-	res, err := r.db.Exec(
+	res, err := r.db.ExecContext(
+		ctx,
 		query,
 		u.Email,
 		u.PasswordHash,
+		u.DisplayName,
+		u.Timezone,
+		u.Locale,
 		u.CreatedAt.Format(time.RFC3339Nano),
 	)
 	if err != nil {
@@ -49,20 +57,38 @@ func isUniqueViolation(err error) bool {
 	return false
 }
 
-func (r *Repo) GetByEmail(email string) (*user.User, error) {
+func (r *Repo) GetByEmail(ctx context.Context, email string) (*user.User, error) {
 	query := `
-	SELECT id, email, password_hash, created_at
+	SELECT id, email, password_hash, display_name, timezone, locale, created_at, verified_at
 	FROM users
 	WHERE email = ?
 	`
+	return scanUser(r.db.QueryRowContext(ctx, query, email))
+}
+
+func (r *Repo) GetByID(ctx context.Context, id int) (*user.User, error) {
+	query := `
+	SELECT id, email, password_hash, display_name, timezone, locale, created_at, verified_at
+	FROM users
+	WHERE id = ?
+	`
+	return scanUser(r.db.QueryRowContext(ctx, query, id))
+}
+
+func scanUser(row *sql.Row) (*user.User, error) {
 	var u user.User
 	var createdAtStr string
+	var verifiedAt sql.NullString
 
-	err := r.db.QueryRow(query, email).Scan(
+	err := row.Scan(
 		&u.ID,
 		&u.Email,
 		&u.PasswordHash,
+		&u.DisplayName,
+		&u.Timezone,
+		&u.Locale,
 		&createdAtStr,
+		&verifiedAt,
 	)
 
 	if err != nil {
@@ -77,5 +103,105 @@ func (r *Repo) GetByEmail(email string) (*user.User, error) {
 		return nil, err
 	}
 	u.CreatedAt = t
+
+	if verifiedAt.Valid {
+		v, err := time.Parse(time.RFC3339Nano, verifiedAt.String)
+		if err != nil {
+			return nil, err
+		}
+		u.VerifiedAt = &v
+	}
 	return &u, nil
 }
+
+func (r *Repo) UpdatePassword(ctx context.Context, userID int, passwordHash string) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE users SET password_hash = ? WHERE id = ?`, passwordHash, userID)
+	if err != nil {
+		return err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if aff == 0 {
+		return user.ErrNotFound
+	}
+	return nil
+}
+
+func (r *Repo) MarkVerified(ctx context.Context, userID int, at time.Time) error {
+	res, err := r.db.ExecContext(
+		ctx,
+		`UPDATE users SET verified_at = ? WHERE id = ? AND verified_at IS NULL`,
+		at.UTC().Format(time.RFC3339Nano),
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+	// 0 строк — либо уже подтверждён, либо нет такого юзера; второе проверяем отдельно
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if aff == 0 {
+		if _, err := r.GetByID(ctx, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Repo) UpdateProfile(ctx context.Context, u *user.User) error {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE users SET display_name = ?, timezone = ?, locale = ? WHERE id = ?`,
+		u.DisplayName,
+		u.Timezone,
+		u.Locale,
+		u.ID,
+	)
+	if err != nil {
+		return err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if aff == 0 {
+		return user.ErrNotFound
+	}
+	return nil
+}
+
+// ownedTables hold the user's rows of this package. Other packages delete
+// theirs through user.AccountData before the user row goes.
+var ownedTables = []string{
+	"password_reset_tokens",
+	"email_verification_tokens",
+}
+
+func (r *Repo) Delete(ctx context.Context, userID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, table := range ownedTables {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE user_id = ?`, userID); err != nil {
+			return err
+		}
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = ?`, userID)
+	if err != nil {
+		return err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if aff == 0 {
+		return user.ErrNotFound
+	}
+	return tx.Commit()
+}
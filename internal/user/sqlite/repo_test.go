@@ -0,0 +1,42 @@
+package sqlite_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+
+	"task_scheduler/internal/user"
+	usersqlite "task_scheduler/internal/user/sqlite"
+)
+
+func TestRepo_Delete(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "users.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	require.NoError(t, usersqlite.Migrate(db))
+
+	ctx := t.Context()
+	users := usersqlite.New(db)
+	now := time.Now().UTC()
+
+	u := &user.User{Email: "gone@example.com", PasswordHash: "x", CreatedAt: now}
+	require.NoError(t, users.Create(ctx, u))
+	other := &user.User{Email: "stays@example.com", PasswordHash: "x", CreatedAt: now}
+	require.NoError(t, users.Create(ctx, other))
+	require.NoError(t, users.CreateResetToken(&user.PasswordResetToken{UserID: u.ID, TokenHash: "hash", ExpiresAt: now.Add(time.Hour), CreatedAt: now}))
+
+	require.NoError(t, users.Delete(ctx, u.ID))
+	require.ErrorIs(t, users.Delete(ctx, u.ID), user.ErrNotFound)
+
+	_, err = users.GetByID(ctx, u.ID)
+	require.ErrorIs(t, err, user.ErrNotFound)
+	_, err = users.GetByID(ctx, other.ID)
+	require.NoError(t, err)
+	var n int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM password_reset_tokens WHERE user_id = ?`, u.ID).Scan(&n))
+	require.Zero(t, n)
+}
@@ -0,0 +1,88 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"task_scheduler/internal/user"
+	"time"
+)
+
+func (r *Repo) CreateResetToken(t *user.PasswordResetToken) error {
+	res, err := r.db.Exec(
+		`INSERT INTO password_reset_tokens(user_id, token_hash, expires_at, created_at) VALUES (?, ?, ?, ?)`,
+		t.UserID,
+		t.TokenHash,
+		t.ExpiresAt.UTC().Format(time.RFC3339Nano),
+		t.CreatedAt.UTC().Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	t.ID = int(id)
+	return nil
+}
+
+func (r *Repo) GetResetToken(hash string) (*user.PasswordResetToken, error) {
+	var (
+		t            user.PasswordResetToken
+		expiresAtStr string
+		createdAtStr string
+		usedAt       sql.NullString
+	)
+	err := r.db.QueryRow(
+		`SELECT id, user_id, token_hash, expires_at, created_at, used_at
+		FROM password_reset_tokens
+		WHERE token_hash = ?`,
+		hash,
+	).Scan(&t.ID, &t.UserID, &t.TokenHash, &expiresAtStr, &createdAtStr, &usedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, user.ErrNotFound
+		}
+		return nil, err
+	}
+
+	if t.ExpiresAt, err = time.Parse(time.RFC3339Nano, expiresAtStr); err != nil {
+		return nil, err
+	}
+	if t.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAtStr); err != nil {
+		return nil, err
+	}
+	if usedAt.Valid {
+		u, err := time.Parse(time.RFC3339Nano, usedAt.String)
+		if err != nil {
+			return nil, err
+		}
+		t.UsedAt = &u
+	}
+	return &t, nil
+}
+
+func (r *Repo) MarkResetTokenUsed(id int, at time.Time) (bool, error) {
+	res, err := r.db.Exec(
+		`UPDATE password_reset_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL`,
+		at.UTC().Format(time.RFC3339Nano),
+		id,
+	)
+	if err != nil {
+		return false, err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return aff == 1, nil
+}
+
+func (r *Repo) InvalidateResetTokens(userID int, at time.Time) error {
+	_, err := r.db.Exec(
+		`UPDATE password_reset_tokens SET used_at = ? WHERE user_id = ? AND used_at IS NULL`,
+		at.UTC().Format(time.RFC3339Nano),
+		userID,
+	)
+	return err
+}
@@ -0,0 +1,99 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"task_scheduler/internal/user"
+	"time"
+)
+
+func (r *Repo) CreateVerificationToken(t *user.VerificationToken) error {
+	res, err := r.db.Exec(
+		`INSERT INTO email_verification_tokens(user_id, token_hash, expires_at, created_at) VALUES (?, ?, ?, ?)`,
+		t.UserID,
+		t.TokenHash,
+		t.ExpiresAt.UTC().Format(time.RFC3339Nano),
+		t.CreatedAt.UTC().Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	t.ID = int(id)
+	return nil
+}
+
+func (r *Repo) GetVerificationToken(hash string) (*user.VerificationToken, error) {
+	var (
+		t            user.VerificationToken
+		expiresAtStr string
+		createdAtStr string
+		usedAt       sql.NullString
+	)
+	err := r.db.QueryRow(
+		`SELECT id, user_id, token_hash, expires_at, created_at, used_at
+		FROM email_verification_tokens
+		WHERE token_hash = ?`,
+		hash,
+	).Scan(&t.ID, &t.UserID, &t.TokenHash, &expiresAtStr, &createdAtStr, &usedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, user.ErrNotFound
+		}
+		return nil, err
+	}
+
+	if t.ExpiresAt, err = time.Parse(time.RFC3339Nano, expiresAtStr); err != nil {
+		return nil, err
+	}
+	if t.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAtStr); err != nil {
+		return nil, err
+	}
+	if usedAt.Valid {
+		u, err := time.Parse(time.RFC3339Nano, usedAt.String)
+		if err != nil {
+			return nil, err
+		}
+		t.UsedAt = &u
+	}
+	return &t, nil
+}
+
+func (r *Repo) MarkVerificationTokenUsed(id int, at time.Time) (bool, error) {
+	res, err := r.db.Exec(
+		`UPDATE email_verification_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL`,
+		at.UTC().Format(time.RFC3339Nano),
+		id,
+	)
+	if err != nil {
+		return false, err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return aff == 1, nil
+}
+
+func (r *Repo) LastVerificationSentAt(userID int) (*time.Time, error) {
+	// id растёт монотонно — надёжнее, чем сравнивать строки created_at
+	var last string
+	err := r.db.QueryRow(
+		`SELECT created_at FROM email_verification_tokens WHERE user_id = ? ORDER BY id DESC LIMIT 1`,
+		userID,
+	).Scan(&last)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	t, err := time.Parse(time.RFC3339Nano, last)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,28 @@
+package user
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+
+// VerificationToken is a hashed single-use token confirming an email address.
+type VerificationToken struct {
+	ID        int
+	UserID    int
+	TokenHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UsedAt    *time.Time
+}
+
+type VerificationRepo interface {
+	CreateVerificationToken(t *VerificationToken) error
+	GetVerificationToken(hash string) (*VerificationToken, error)
+	// MarkVerificationTokenUsed returns false if the token was already used.
+	MarkVerificationTokenUsed(id int, at time.Time) (bool, error)
+	// LastVerificationSentAt returns when the latest token for the user was
+	// created, or nil if none was.
+	LastVerificationSentAt(userID int) (*time.Time, error)
+}
@@ -0,0 +1,37 @@
+package webhook_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"task_scheduler/internal/webhook"
+)
+
+func TestSend(t *testing.T) {
+	var (
+		body      []byte
+		signature string
+		status    = http.StatusNoContent
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(webhook.SignatureHeader)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+
+	require.NoError(t, webhook.Send(t.Context(), srv.Client(), srv.URL, "s3cret", map[string]int{"task_id": 7}))
+	require.JSONEq(t, `{"task_id":7}`, string(body))
+	require.Equal(t, webhook.Sign("s3cret", body), signature)
+
+	// без секрета подписи нет
+	require.NoError(t, webhook.Send(t.Context(), srv.Client(), srv.URL, "", map[string]int{"task_id": 7}))
+	require.Empty(t, signature)
+
+	status = http.StatusBadGateway
+	require.ErrorContains(t, webhook.Send(t.Context(), srv.Client(), srv.URL, "", nil), "502")
+}
@@ -0,0 +1,51 @@
+package workspace
+
+import "time"
+
+// Role is a member's role in a workspace.
+type Role string
+
+const (
+	RoleAdmin  Role = "admin"
+	RoleMember Role = "member"
+	RoleGuest  Role = "guest"
+)
+
+func (r Role) Valid() bool {
+	return r == RoleAdmin || r == RoleMember || r == RoleGuest
+}
+
+type Workspace struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	CreatedBy int       `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Member struct {
+	WorkspaceID int       `json:"workspace_id"`
+	UserID      int       `json:"user_id"`
+	Email       string    `json:"email"`
+	Role        Role      `json:"role"`
+	JoinedAt    time.Time `json:"joined_at"`
+}
+
+// Membership is a workspace together with the caller's role in it.
+type Membership struct {
+	Workspace
+	Role Role `json:"role"`
+}
+
+// Invitation lets whoever owns Email join the workspace. Only the hash of
+// the token is stored.
+type Invitation struct {
+	ID          int        `json:"id"`
+	WorkspaceID int        `json:"workspace_id"`
+	Email       string     `json:"email"`
+	Role        Role       `json:"role"`
+	TokenHash   string     `json:"-"`
+	InvitedBy   int        `json:"invited_by"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	AcceptedAt  *time.Time `json:"accepted_at"`
+}
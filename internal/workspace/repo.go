@@ -0,0 +1,37 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound      = errors.New("workspace not found")
+	ErrNotMember     = errors.New("not a member of this workspace")
+	ErrAlreadyMember = errors.New("user is already a member")
+)
+
+type Repo interface {
+	// CreateWorkspace stores the workspace and its first admin together.
+	CreateWorkspace(ctx context.Context, w *Workspace, admin *Member) error
+	ListForUser(ctx context.Context, userID int) ([]Membership, error)
+
+	AddMember(ctx context.Context, m *Member) error
+	// GetMember returns ErrNotMember if the user isn't in the workspace.
+	GetMember(ctx context.Context, workspaceID, userID int) (*Member, error)
+	ListMembers(ctx context.Context, workspaceID int) ([]Member, error)
+	// UpdateMemberRole returns ErrNotMember if the user isn't in the workspace.
+	UpdateMemberRole(ctx context.Context, workspaceID, userID int, role Role) error
+	RemoveMember(ctx context.Context, workspaceID, userID int) error
+	CountAdmins(ctx context.Context, workspaceID int) (int, error)
+
+	CreateInvitation(ctx context.Context, inv *Invitation) error
+	GetInvitation(ctx context.Context, hash string) (*Invitation, error)
+	// MarkInvitationAccepted returns false if the invitation was already used.
+	MarkInvitationAccepted(ctx context.Context, id int, at time.Time) (bool, error)
+
+	WIPLimits(ctx context.Context, workspaceID int) (map[string]int, error)
+	// SetWIPLimits replaces all of the workspace's limits.
+	SetWIPLimits(ctx context.Context, workspaceID int, limits map[string]int) error
+}
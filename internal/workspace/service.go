@@ -0,0 +1,376 @@
+package workspace
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"task_scheduler/internal/mail"
+	"task_scheduler/internal/user"
+	"time"
+)
+
+var (
+	ErrInvalidInput      = errors.New("invalid input")
+	ErrForbidden         = errors.New("only workspace admins can do this")
+	ErrLastAdmin         = errors.New("workspace must keep at least one admin")
+	ErrInvalidInvitation = errors.New("invalid or expired invitation")
+)
+
+const maxNameLen = 100
+
+// maxWIPColumns bounds how many column limits a workspace keeps.
+const maxWIPColumns = 20
+
+type Service interface {
+	// Create makes a workspace with the user as its admin.
+	Create(ctx context.Context, userID int, name string) (*Workspace, error)
+	ListForUser(ctx context.Context, userID int) ([]Membership, error)
+	// Members lists the workspace members; any member may see them.
+	Members(ctx context.Context, userID, workspaceID int) ([]Member, error)
+	// RemoveMember lets admins remove anyone and members leave on their own.
+	RemoveMember(ctx context.Context, userID, workspaceID, targetUserID int) error
+	// ChangeRole sets a member's role. Admins only; the last admin can't be
+	// demoted.
+	ChangeRole(ctx context.Context, userID, workspaceID, targetUserID int, role Role) (*Member, error)
+	// Invite mails an invitation token to the email. Admins only.
+	Invite(ctx context.Context, userID, workspaceID int, email string, role Role) (*Invitation, error)
+	// AcceptInvite adds the user to the workspace. The invitation must have
+	// been sent to the user's email.
+	AcceptInvite(ctx context.Context, userID int, token string) (*Member, error)
+	// MemberRole returns ErrNotMember for users outside the workspace.
+	MemberRole(ctx context.Context, workspaceID, userID int) (Role, error)
+
+	// WIPLimits returns the board's work-in-progress limits by column
+	// (task status); columns without a limit are left out. It doesn't check
+	// membership: callers do.
+	WIPLimits(ctx context.Context, workspaceID int) (map[string]int, error)
+	// SetWIPLimits replaces the limits; 0 removes a column's limit. Admins only.
+	SetWIPLimits(ctx context.Context, userID, workspaceID int, limits map[string]int) (map[string]int, error)
+
+	// CheckAccountDeletion returns ErrLastAdmin while the user is the only
+	// admin of a workspace: the account can go once someone else is admin.
+	CheckAccountDeletion(ctx context.Context, userID int) error
+	// DeleteAccountData removes the user from all workspaces.
+	DeleteAccountData(ctx context.Context, userID int) error
+}
+
+// Users is the part of the user service workspaces need.
+type Users interface {
+	Get(ctx context.Context, userID int) (*user.User, error)
+}
+
+type workspaceService struct {
+	repo      Repo
+	users     Users
+	mailer    mail.Mailer
+	inviteTTL time.Duration
+}
+
+func NewService(repo Repo, users Users, mailer mail.Mailer, inviteTTL time.Duration) Service {
+	return &workspaceService{
+		repo:      repo,
+		users:     users,
+		mailer:    mailer,
+		inviteTTL: inviteTTL,
+	}
+}
+
+func (s *workspaceService) Create(ctx context.Context, userID int, name string) (*Workspace, error) {
+	name = strings.TrimSpace(name)
+	if userID <= 0 || name == "" || len(name) > maxNameLen {
+		return nil, ErrInvalidInput
+	}
+	u, err := s.users.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	w := &Workspace{
+		Name:      name,
+		CreatedBy: userID,
+		CreatedAt: now,
+	}
+	admin := &Member{
+		UserID:   userID,
+		Email:    u.Email,
+		Role:     RoleAdmin,
+		JoinedAt: now,
+	}
+	if err := s.repo.CreateWorkspace(ctx, w, admin); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (s *workspaceService) ListForUser(ctx context.Context, userID int) ([]Membership, error) {
+	if userID <= 0 {
+		return nil, ErrInvalidInput
+	}
+	return s.repo.ListForUser(ctx, userID)
+}
+
+func (s *workspaceService) Members(ctx context.Context, userID, workspaceID int) ([]Member, error) {
+	if userID <= 0 || workspaceID <= 0 {
+		return nil, ErrInvalidInput
+	}
+	if _, err := s.repo.GetMember(ctx, workspaceID, userID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListMembers(ctx, workspaceID)
+}
+
+func (s *workspaceService) RemoveMember(ctx context.Context, userID, workspaceID, targetUserID int) error {
+	if userID <= 0 || workspaceID <= 0 || targetUserID <= 0 {
+		return ErrInvalidInput
+	}
+	// 1) права: админ удаляет кого угодно, остальные — только себя
+	me, err := s.repo.GetMember(ctx, workspaceID, userID)
+	if err != nil {
+		return err
+	}
+	if targetUserID != userID && me.Role != RoleAdmin {
+		return ErrForbidden
+	}
+
+	// 2) последнего админа не удаляем — иначе workspace станет неуправляемым
+	target, err := s.repo.GetMember(ctx, workspaceID, targetUserID)
+	if err != nil {
+		return err
+	}
+	if err := s.keepsAnAdmin(ctx, workspaceID, target.Role); err != nil {
+		return err
+	}
+	return s.repo.RemoveMember(ctx, workspaceID, targetUserID)
+}
+
+func (s *workspaceService) ChangeRole(ctx context.Context, userID, workspaceID, targetUserID int, role Role) (*Member, error) {
+	if userID <= 0 || workspaceID <= 0 || targetUserID <= 0 || !role.Valid() {
+		return nil, ErrInvalidInput
+	}
+	me, err := s.repo.GetMember(ctx, workspaceID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if me.Role != RoleAdmin {
+		return nil, ErrForbidden
+	}
+
+	// как и при удалении: последний админ не может стать обычным участником
+	target, err := s.repo.GetMember(ctx, workspaceID, targetUserID)
+	if err != nil {
+		return nil, err
+	}
+	if target.Role == role {
+		return target, nil
+	}
+	if err := s.keepsAnAdmin(ctx, workspaceID, target.Role); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdateMemberRole(ctx, workspaceID, targetUserID, role); err != nil {
+		return nil, err
+	}
+	target.Role = role
+	return target, nil
+}
+
+func (s *workspaceService) Invite(ctx context.Context, userID, workspaceID int, email string, role Role) (*Invitation, error) {
+	email = strings.TrimSpace(strings.ToLower(email))
+	if userID <= 0 || workspaceID <= 0 || email == "" || !role.Valid() {
+		return nil, ErrInvalidInput
+	}
+	me, err := s.repo.GetMember(ctx, workspaceID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if me.Role != RoleAdmin {
+		return nil, ErrForbidden
+	}
+
+	// токен: наружу (в письмо) — raw, в БД — хеш
+	raw, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	inv := &Invitation{
+		WorkspaceID: workspaceID,
+		Email:       email,
+		Role:        role,
+		TokenHash:   hashToken(raw),
+		InvitedBy:   userID,
+		ExpiresAt:   now.Add(s.inviteTTL),
+		CreatedAt:   now,
+	}
+	if err := s.repo.CreateInvitation(ctx, inv); err != nil {
+		return nil, err
+	}
+
+	if err := s.mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "You've been invited to a workspace",
+		Body: me.Email + " invited you to join their workspace as " + string(role) + ".\n" +
+			"Sign in with this email and accept the invitation within " + s.inviteTTL.String() + " using this token:\n\n" + raw,
+	}); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+func (s *workspaceService) AcceptInvite(ctx context.Context, userID int, token string) (*Member, error) {
+	if userID <= 0 || token == "" {
+		return nil, ErrInvalidInput
+	}
+	now := time.Now().UTC()
+
+	// 1) проверяем приглашение
+	inv, err := s.repo.GetInvitation(ctx, hashToken(token))
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, ErrInvalidInvitation
+		}
+		return nil, err
+	}
+	if inv.AcceptedAt != nil || !now.Before(inv.ExpiresAt) {
+		return nil, ErrInvalidInvitation
+	}
+
+	// 2) приглашение адресное: чужим токеном не воспользоваться
+	u, err := s.users.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(u.Email, inv.Email) {
+		return nil, ErrInvalidInvitation
+	}
+
+	// 3) одноразовость
+	ok, err := s.repo.MarkInvitationAccepted(ctx, inv.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidInvitation
+	}
+
+	m := &Member{
+		WorkspaceID: inv.WorkspaceID,
+		UserID:      userID,
+		Email:       u.Email,
+		Role:        inv.Role,
+		JoinedAt:    now,
+	}
+	if err := s.repo.AddMember(ctx, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (s *workspaceService) MemberRole(ctx context.Context, workspaceID, userID int) (Role, error) {
+	if workspaceID <= 0 || userID <= 0 {
+		return "", ErrInvalidInput
+	}
+	m, err := s.repo.GetMember(ctx, workspaceID, userID)
+	if err != nil {
+		return "", err
+	}
+	return m.Role, nil
+}
+
+func (s *workspaceService) WIPLimits(ctx context.Context, workspaceID int) (map[string]int, error) {
+	if workspaceID <= 0 {
+		return nil, ErrInvalidInput
+	}
+	return s.repo.WIPLimits(ctx, workspaceID)
+}
+
+func (s *workspaceService) SetWIPLimits(ctx context.Context, userID, workspaceID int, limits map[string]int) (map[string]int, error) {
+	if userID <= 0 || workspaceID <= 0 || len(limits) > maxWIPColumns {
+		return nil, ErrInvalidInput
+	}
+	me, err := s.repo.GetMember(ctx, workspaceID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if me.Role != RoleAdmin {
+		return nil, ErrForbidden
+	}
+
+	// 0 — без лимита, такие колонки не храним
+	set := make(map[string]int, len(limits))
+	for column, n := range limits {
+		if column == "" || n < 0 {
+			return nil, ErrInvalidInput
+		}
+		if n > 0 {
+			set[column] = n
+		}
+	}
+	if err := s.repo.SetWIPLimits(ctx, workspaceID, set); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *workspaceService) CheckAccountDeletion(ctx context.Context, userID int) error {
+	memberships, err := s.repo.ListForUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, m := range memberships {
+		if err := s.keepsAnAdmin(ctx, m.ID, m.Role); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *workspaceService) DeleteAccountData(ctx context.Context, userID int) error {
+	memberships, err := s.repo.ListForUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, m := range memberships {
+		// проверка та же, что при RemoveMember: роль могли сменить после CheckAccountDeletion
+		if err := s.keepsAnAdmin(ctx, m.ID, m.Role); err != nil {
+			return err
+		}
+		if err := s.repo.RemoveMember(ctx, m.ID, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keepsAnAdmin returns ErrLastAdmin if a member with the role is the
+// workspace's only admin.
+func (s *workspaceService) keepsAnAdmin(ctx context.Context, workspaceID int, role Role) error {
+	if role != RoleAdmin {
+		return nil
+	}
+	admins, err := s.repo.CountAdmins(ctx, workspaceID)
+	if err != nil {
+		return err
+	}
+	if admins <= 1 {
+		return ErrLastAdmin
+	}
+	return nil
+}
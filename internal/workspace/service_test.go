@@ -0,0 +1,232 @@
+package workspace_test
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+
+	"task_scheduler/internal/mail"
+	"task_scheduler/internal/user"
+	usersqlite "task_scheduler/internal/user/sqlite"
+	"task_scheduler/internal/workspace"
+	workspacesqlite "task_scheduler/internal/workspace/sqlite"
+)
+
+// outbox keeps the invitation tokens it was asked to mail.
+type outbox struct{ tokens []string }
+
+func (o *outbox) Send(_ context.Context, msg mail.Message) error {
+	lines := strings.Split(strings.TrimSpace(msg.Body), "\n")
+	o.tokens = append(o.tokens, lines[len(lines)-1])
+	return nil
+}
+
+// Users are registered in this order, so they get these ids.
+const (
+	admin = 1
+	bob   = 2
+	eve   = 3
+)
+
+type fixture struct {
+	repo  *workspacesqlite.Repo
+	users user.Service
+	out   *outbox
+	svc   workspace.Service
+	ws    int
+}
+
+// newFixture: admin, bob и eve; workspace admin'а, приглашения живут час.
+func newFixture(t *testing.T) fixture {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "workspaces.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	require.NoError(t, usersqlite.Migrate(db))
+	require.NoError(t, workspacesqlite.Migrate(db))
+
+	userRepo := usersqlite.New(db)
+	f := fixture{
+		repo:  workspacesqlite.New(db),
+		users: user.NewService(userRepo, userRepo, userRepo, mail.NewWriterMailer(io.Discard, "test@example.com"), user.Options{}),
+		out:   &outbox{},
+	}
+	for _, email := range []string{"admin@example.com", "bob@example.com", "eve@example.com"} {
+		_, err := f.users.Register(email, "secret123")
+		require.NoError(t, err)
+	}
+	f.svc = workspace.NewService(f.repo, f.users, f.out, time.Hour)
+	ws, err := f.svc.Create(t.Context(), admin, "Acme")
+	require.NoError(t, err)
+	f.ws = ws.ID
+	return f
+}
+
+// invite returns the mailed token.
+func (f fixture) invite(t *testing.T, svc workspace.Service, email string, role workspace.Role) string {
+	t.Helper()
+	_, err := svc.Invite(t.Context(), admin, f.ws, email, role)
+	require.NoError(t, err)
+	return f.out.tokens[len(f.out.tokens)-1]
+}
+
+// join adds the user with the role through an invitation.
+func (f fixture) join(t *testing.T, userID int, email string, role workspace.Role) {
+	t.Helper()
+	_, err := f.svc.AcceptInvite(t.Context(), userID, f.invite(t, f.svc, email, role))
+	require.NoError(t, err)
+}
+
+func TestService_AcceptInvite(t *testing.T) {
+	f := newFixture(t)
+	token := f.invite(t, f.svc, "Bob@Example.com", workspace.RoleMember)
+
+	// приглашение адресное
+	_, err := f.svc.AcceptInvite(t.Context(), eve, token)
+	require.ErrorIs(t, err, workspace.ErrInvalidInvitation)
+
+	m, err := f.svc.AcceptInvite(t.Context(), bob, token)
+	require.NoError(t, err)
+	require.Equal(t, workspace.RoleMember, m.Role)
+	role, err := f.svc.MemberRole(t.Context(), f.ws, bob)
+	require.NoError(t, err)
+	require.Equal(t, workspace.RoleMember, role)
+}
+
+func TestService_AcceptInvite_Rejected(t *testing.T) {
+	tests := []struct {
+		name  string
+		token func(t *testing.T, f fixture) string
+	}{
+		{"unknown token", func(*testing.T, fixture) string { return "nope" }},
+		{"expired", func(t *testing.T, f fixture) string {
+			expired := workspace.NewService(f.repo, f.users, f.out, -time.Minute)
+			return f.invite(t, expired, "bob@example.com", workspace.RoleMember)
+		}},
+		{"used twice", func(t *testing.T, f fixture) string {
+			token := f.invite(t, f.svc, "bob@example.com", workspace.RoleMember)
+			_, err := f.svc.AcceptInvite(t.Context(), bob, token)
+			require.NoError(t, err)
+			return token
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			_, err := f.svc.AcceptInvite(t.Context(), bob, tt.token(t, f))
+			require.ErrorIs(t, err, workspace.ErrInvalidInvitation)
+		})
+	}
+}
+
+func TestService_Invite_AdminsOnly(t *testing.T) {
+	f := newFixture(t)
+	f.join(t, bob, "bob@example.com", workspace.RoleMember)
+
+	_, err := f.svc.Invite(t.Context(), bob, f.ws, "eve@example.com", workspace.RoleMember)
+	require.ErrorIs(t, err, workspace.ErrForbidden)
+	_, err = f.svc.Invite(t.Context(), eve, f.ws, "eve@example.com", workspace.RoleAdmin)
+	require.ErrorIs(t, err, workspace.ErrNotMember)
+	_, err = f.svc.Invite(t.Context(), admin, f.ws, "eve@example.com", "owner")
+	require.ErrorIs(t, err, workspace.ErrInvalidInput)
+}
+
+func TestService_RemoveMember_KeepsLastAdmin(t *testing.T) {
+	f := newFixture(t)
+	f.join(t, bob, "bob@example.com", workspace.RoleMember)
+
+	require.ErrorIs(t, f.svc.RemoveMember(t.Context(), admin, f.ws, admin), workspace.ErrLastAdmin)
+	require.ErrorIs(t, f.svc.RemoveMember(t.Context(), bob, f.ws, admin), workspace.ErrForbidden)
+
+	// со вторым админом первый может уйти
+	_, err := f.svc.ChangeRole(t.Context(), admin, f.ws, bob, workspace.RoleAdmin)
+	require.NoError(t, err)
+	require.NoError(t, f.svc.RemoveMember(t.Context(), admin, f.ws, admin))
+	_, err = f.svc.MemberRole(t.Context(), f.ws, admin)
+	require.ErrorIs(t, err, workspace.ErrNotMember)
+}
+
+func TestService_RemoveMember_MembersLeaveOnTheirOwn(t *testing.T) {
+	f := newFixture(t)
+	f.join(t, bob, "bob@example.com", workspace.RoleMember)
+	f.join(t, eve, "eve@example.com", workspace.RoleMember)
+
+	require.ErrorIs(t, f.svc.RemoveMember(t.Context(), bob, f.ws, eve), workspace.ErrForbidden)
+	require.NoError(t, f.svc.RemoveMember(t.Context(), bob, f.ws, bob))
+	require.NoError(t, f.svc.RemoveMember(t.Context(), admin, f.ws, eve))
+	members, err := f.svc.Members(t.Context(), admin, f.ws)
+	require.NoError(t, err)
+	require.Len(t, members, 1)
+}
+
+func TestService_ChangeRole(t *testing.T) {
+	f := newFixture(t)
+	f.join(t, bob, "bob@example.com", workspace.RoleGuest)
+
+	m, err := f.svc.ChangeRole(t.Context(), admin, f.ws, bob, workspace.RoleMember)
+	require.NoError(t, err)
+	require.Equal(t, workspace.RoleMember, m.Role)
+	role, err := f.svc.MemberRole(t.Context(), f.ws, bob)
+	require.NoError(t, err)
+	require.Equal(t, workspace.RoleMember, role)
+
+	// только админ и только участникам
+	_, err = f.svc.ChangeRole(t.Context(), bob, f.ws, bob, workspace.RoleAdmin)
+	require.ErrorIs(t, err, workspace.ErrForbidden)
+	_, err = f.svc.ChangeRole(t.Context(), admin, f.ws, eve, workspace.RoleMember)
+	require.ErrorIs(t, err, workspace.ErrNotMember)
+	_, err = f.svc.ChangeRole(t.Context(), admin, f.ws, bob, "owner")
+	require.ErrorIs(t, err, workspace.ErrInvalidInput)
+}
+
+func TestService_ChangeRole_KeepsLastAdmin(t *testing.T) {
+	f := newFixture(t)
+	f.join(t, bob, "bob@example.com", workspace.RoleMember)
+
+	_, err := f.svc.ChangeRole(t.Context(), admin, f.ws, admin, workspace.RoleMember)
+	require.ErrorIs(t, err, workspace.ErrLastAdmin)
+	// та же роль — ничего не меняется, и ошибки нет
+	m, err := f.svc.ChangeRole(t.Context(), admin, f.ws, admin, workspace.RoleAdmin)
+	require.NoError(t, err)
+	require.Equal(t, workspace.RoleAdmin, m.Role)
+
+	_, err = f.svc.ChangeRole(t.Context(), admin, f.ws, bob, workspace.RoleAdmin)
+	require.NoError(t, err)
+	_, err = f.svc.ChangeRole(t.Context(), bob, f.ws, admin, workspace.RoleMember)
+	require.NoError(t, err)
+	_, err = f.svc.ChangeRole(t.Context(), bob, f.ws, bob, workspace.RoleGuest)
+	require.ErrorIs(t, err, workspace.ErrLastAdmin)
+}
+
+func TestService_AccountDeletion_KeepsLastAdmin(t *testing.T) {
+	f := newFixture(t)
+	f.users.SetAccountData(f.svc)
+	f.join(t, bob, "bob@example.com", workspace.RoleMember)
+
+	// единственный админ не может удалить аккаунт — ничего не удаляется
+	require.ErrorIs(t, f.users.Delete(t.Context(), admin, "secret123"), workspace.ErrLastAdmin)
+	_, err := f.users.Get(t.Context(), admin)
+	require.NoError(t, err)
+	_, err = f.svc.MemberRole(t.Context(), f.ws, admin)
+	require.NoError(t, err)
+
+	// обычный участник уходит вместе с членством
+	require.NoError(t, f.users.Delete(t.Context(), bob, "secret123"))
+	_, err = f.svc.MemberRole(t.Context(), f.ws, bob)
+	require.ErrorIs(t, err, workspace.ErrNotMember)
+
+	f.join(t, eve, "eve@example.com", workspace.RoleAdmin)
+	require.NoError(t, f.users.Delete(t.Context(), admin, "secret123"))
+	members, err := f.svc.Members(t.Context(), eve, f.ws)
+	require.NoError(t, err)
+	require.Len(t, members, 1)
+	_, err = f.users.Get(t.Context(), admin)
+	require.ErrorIs(t, err, user.ErrNotFound)
+}
@@ -0,0 +1,41 @@
+package sqlite
+
+import "database/sql"
+
+func Migrate(db *sql.DB) error {
+	const q = `
+	CREATE TABLE IF NOT EXISTS workspaces(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	created_by INTEGER NOT NULL,
+	created_at TEXT NOT NULL);
+
+	CREATE TABLE IF NOT EXISTS workspace_members(
+	workspace_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	role TEXT NOT NULL,
+	joined_at TEXT NOT NULL,
+	PRIMARY KEY (workspace_id, user_id));
+	CREATE INDEX IF NOT EXISTS idx_workspace_members_user_id ON workspace_members(user_id);
+
+	CREATE TABLE IF NOT EXISTS workspace_invitations(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	workspace_id INTEGER NOT NULL,
+	email TEXT NOT NULL,
+	role TEXT NOT NULL,
+	token_hash TEXT NOT NULL UNIQUE,
+	invited_by INTEGER NOT NULL,
+	expires_at TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	accepted_at TEXT NULL);
+	CREATE INDEX IF NOT EXISTS idx_workspace_invitations_workspace_id ON workspace_invitations(workspace_id);
+
+	CREATE TABLE IF NOT EXISTS workspace_wip_limits(
+	workspace_id INTEGER NOT NULL,
+	column_status TEXT NOT NULL,
+	wip_limit INTEGER NOT NULL,
+	PRIMARY KEY (workspace_id, column_status));
+	`
+	_, err := db.Exec(q)
+	return err
+}
@@ -0,0 +1,360 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"task_scheduler/internal/workspace"
+	"time"
+)
+
+type Repo struct {
+	db *sql.DB
+}
+
+func New(db *sql.DB) *Repo {
+	return &Repo{db: db}
+}
+
+func (r *Repo) CreateWorkspace(ctx context.Context, w *workspace.Workspace, admin *workspace.Member) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO workspaces (name, created_by, created_at) VALUES (?, ?, ?)`,
+		w.Name,
+		w.CreatedBy,
+		w.CreatedAt.UTC().Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	w.ID = int(id)
+	admin.WorkspaceID = w.ID
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO workspace_members (workspace_id, user_id, role, joined_at) VALUES (?, ?, ?, ?)`,
+		admin.WorkspaceID,
+		admin.UserID,
+		string(admin.Role),
+		admin.JoinedAt.UTC().Format(time.RFC3339Nano),
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *Repo) ListForUser(ctx context.Context, userID int) ([]workspace.Membership, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT w.id, w.name, w.created_by, w.created_at, m.role
+		 FROM workspaces w
+		 JOIN workspace_members m ON m.workspace_id = w.id
+		 WHERE m.user_id = ?
+		 ORDER BY w.name`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]workspace.Membership, 0)
+	for rows.Next() {
+		var (
+			ms           workspace.Membership
+			createdAtStr string
+			role         string
+		)
+		if err := rows.Scan(&ms.ID, &ms.Name, &ms.CreatedBy, &createdAtStr, &role); err != nil {
+			return nil, err
+		}
+		if ms.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAtStr); err != nil {
+			return nil, err
+		}
+		ms.Role = workspace.Role(role)
+		list = append(list, ms)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *Repo) AddMember(ctx context.Context, m *workspace.Member) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO workspace_members (workspace_id, user_id, role, joined_at) VALUES (?, ?, ?, ?)`,
+		m.WorkspaceID,
+		m.UserID,
+		string(m.Role),
+		m.JoinedAt.UTC().Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return workspace.ErrAlreadyMember
+		}
+		return err
+	}
+	return nil
+}
+
+// участники вместе с email из users: workspace живёт в той же БД
+const selectMembers = `
+	SELECT m.workspace_id, m.user_id, u.email, m.role, m.joined_at
+	FROM workspace_members m
+	JOIN users u ON u.id = m.user_id`
+
+func (r *Repo) GetMember(ctx context.Context, workspaceID, userID int) (*workspace.Member, error) {
+	row := r.db.QueryRowContext(ctx,
+		selectMembers+` WHERE m.workspace_id = ? AND m.user_id = ?`,
+		workspaceID,
+		userID,
+	)
+	m, err := scanMember(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, workspace.ErrNotMember
+		}
+		return nil, err
+	}
+	return m, nil
+}
+
+func (r *Repo) ListMembers(ctx context.Context, workspaceID int) ([]workspace.Member, error) {
+	rows, err := r.db.QueryContext(ctx,
+		selectMembers+` WHERE m.workspace_id = ? ORDER BY m.joined_at`,
+		workspaceID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := make([]workspace.Member, 0)
+	for rows.Next() {
+		m, err := scanMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, *m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (r *Repo) UpdateMemberRole(ctx context.Context, workspaceID, userID int, role workspace.Role) error {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE workspace_members SET role = ? WHERE workspace_id = ? AND user_id = ?`,
+		string(role),
+		workspaceID,
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if aff == 0 {
+		return workspace.ErrNotMember
+	}
+	return nil
+}
+
+func (r *Repo) RemoveMember(ctx context.Context, workspaceID, userID int) error {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM workspace_members WHERE workspace_id = ? AND user_id = ?`,
+		workspaceID,
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if aff == 0 {
+		return workspace.ErrNotMember
+	}
+	return nil
+}
+
+func (r *Repo) CountAdmins(ctx context.Context, workspaceID int) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM workspace_members WHERE workspace_id = ? AND role = ?`,
+		workspaceID,
+		string(workspace.RoleAdmin),
+	).Scan(&n)
+	return n, err
+}
+
+func (r *Repo) CreateInvitation(ctx context.Context, inv *workspace.Invitation) error {
+	res, err := r.db.ExecContext(ctx,
+		`INSERT INTO workspace_invitations (workspace_id, email, role, token_hash, invited_by, expires_at, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		inv.WorkspaceID,
+		inv.Email,
+		string(inv.Role),
+		inv.TokenHash,
+		inv.InvitedBy,
+		inv.ExpiresAt.UTC().Format(time.RFC3339Nano),
+		inv.CreatedAt.UTC().Format(time.RFC3339Nano),
+	)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	inv.ID = int(id)
+	return nil
+}
+
+func (r *Repo) GetInvitation(ctx context.Context, hash string) (*workspace.Invitation, error) {
+	var (
+		inv          workspace.Invitation
+		role         string
+		expiresAtStr string
+		createdAtStr string
+		acceptedAt   sql.NullString
+	)
+	err := r.db.QueryRowContext(ctx,
+		`SELECT id, workspace_id, email, role, token_hash, invited_by, expires_at, created_at, accepted_at
+		 FROM workspace_invitations
+		 WHERE token_hash = ?`,
+		hash,
+	).Scan(
+		&inv.ID,
+		&inv.WorkspaceID,
+		&inv.Email,
+		&role,
+		&inv.TokenHash,
+		&inv.InvitedBy,
+		&expiresAtStr,
+		&createdAtStr,
+		&acceptedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, workspace.ErrNotFound
+		}
+		return nil, err
+	}
+	inv.Role = workspace.Role(role)
+
+	if inv.ExpiresAt, err = time.Parse(time.RFC3339Nano, expiresAtStr); err != nil {
+		return nil, err
+	}
+	if inv.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAtStr); err != nil {
+		return nil, err
+	}
+	if acceptedAt.Valid {
+		t, err := time.Parse(time.RFC3339Nano, acceptedAt.String)
+		if err != nil {
+			return nil, err
+		}
+		inv.AcceptedAt = &t
+	}
+	return &inv, nil
+}
+
+func (r *Repo) MarkInvitationAccepted(ctx context.Context, id int, at time.Time) (bool, error) {
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE workspace_invitations SET accepted_at = ? WHERE id = ? AND accepted_at IS NULL`,
+		at.UTC().Format(time.RFC3339Nano),
+		id,
+	)
+	if err != nil {
+		return false, err
+	}
+	aff, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return aff == 1, nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanMember(s scanner) (*workspace.Member, error) {
+	var (
+		m           workspace.Member
+		role        string
+		joinedAtStr string
+	)
+	if err := s.Scan(&m.WorkspaceID, &m.UserID, &m.Email, &role, &joinedAtStr); err != nil {
+		return nil, err
+	}
+	m.Role = workspace.Role(role)
+
+	var err error
+	if m.JoinedAt, err = time.Parse(time.RFC3339Nano, joinedAtStr); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *Repo) WIPLimits(ctx context.Context, workspaceID int) (map[string]int, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT column_status, wip_limit FROM workspace_wip_limits WHERE workspace_id = ?`,
+		workspaceID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	limits := make(map[string]int)
+	for rows.Next() {
+		var (
+			column string
+			n      int
+		)
+		if err := rows.Scan(&column, &n); err != nil {
+			return nil, err
+		}
+		limits[column] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return limits, nil
+}
+
+func (r *Repo) SetWIPLimits(ctx context.Context, workspaceID int, limits map[string]int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM workspace_wip_limits WHERE workspace_id = ?`, workspaceID); err != nil {
+		return err
+	}
+	for column, n := range limits {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO workspace_wip_limits (workspace_id, column_status, wip_limit) VALUES (?, ?, ?)`,
+			workspaceID,
+			column,
+			n,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}